@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/koderover/zadig/pkg/microservice/zgctl"
+)
+
+var runJobOpts = &zgctl.RunJobOptions{}
+
+func init() {
+	runJobCmd.Flags().StringVar(&runJobOpts.WorkflowName, "workflow", "", "name of the workflow")
+	runJobCmd.Flags().Int64Var(&runJobOpts.TaskID, "task", 0, "id of the workflow task")
+	runJobCmd.Flags().StringVar(&runJobOpts.JobName, "job", "", "name of the job")
+	runJobCmd.Flags().StringVar(&runJobOpts.SecretEnvFile, "secret-env-file", "", "file of secret envs and credentials in KEY=VALUE lines, the missing ones are prompted for")
+	runJobCmd.Flags().StringVar(&runJobOpts.JobExecutorBin, "jobexecutor", "$HOME/.zadig/bin/jobexecutor", "linux binary of the job executor")
+	runJobCmd.Flags().StringVar(&runJobOpts.WorkspaceDir, "workspace", "", "local dir mounted as the workspace of the job")
+	_ = runJobCmd.MarkFlagRequired("workflow")
+	_ = runJobCmd.MarkFlagRequired("task")
+	_ = runJobCmd.MarkFlagRequired("job")
+
+	rootCmd.AddCommand(runJobCmd)
+}
+
+var runJobCmd = &cobra.Command{
+	Use:   "run-job",
+	Short: "run a freestyle job of a workflow task locally",
+	Long:  "run-job fetches the context of a job in a workflow task and runs it with the job executor in a local docker container.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+
+		z := zgctl.NewZgCtl(&zgctl.ZgCtlConfig{
+			ZadigHost:  zadigHost,
+			ZadigToken: zadigToken,
+			HomeDir:    os.ExpandEnv(homeDir),
+		})
+		return z.RunJob(ctx, runJobOpts)
+	},
+}
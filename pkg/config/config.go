@@ -203,6 +203,10 @@ func ObjectStorageChartTemplatePath(name string) string {
 	return ObjectStorageTemplatePath(name, setting.ChartTemplatesPath)
 }
 
+func ObjectStorageEnvSnapshotPath(project, env string) string {
+	return filepath.Join(project, "env-snapshots", env)
+}
+
 func LocalServicePath(project, service string) string {
 	return filepath.Join(DataPath(), project, service)
 }
@@ -230,10 +230,19 @@ func WebHookURL() string {
 	return fmt.Sprintf("%s/api/aslan/webhook", configbase.SystemAddress())
 }
 
+// EnvWakeURL is requested by the ingress controller when a sleeping environment configured to wake on request is visited.
+func EnvWakeURL(productName, envName, token string) string {
+	return fmt.Sprintf("%s/api/aslan/environment/environments/%s/wake/%s?projectName=%s", configbase.SystemAddress(), envName, token, productName)
+}
+
 func ObjectStorageServicePath(project, service string) string {
 	return configbase.ObjectStorageServicePath(project, service)
 }
 
+func ObjectStorageEnvSnapshotPath(project, env string) string {
+	return configbase.ObjectStorageEnvSnapshotPath(project, env)
+}
+
 func LocalServicePath(project, service string) string {
 	return configbase.LocalServicePathWithRevision(project, service, "latest")
 }
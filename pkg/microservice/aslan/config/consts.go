@@ -117,6 +117,18 @@ const (
 	StatusWaitingApprove Status = "waitforapprove"
 )
 
+// FailureClass is the cause of a failed job, used to decide whether the job should be retried.
+type FailureClass string
+
+const (
+	FailurePodEviction    FailureClass = "pod_eviction"
+	FailureImagePull      FailureClass = "image_pull"
+	FailureTimeout        FailureClass = "timeout"
+	FailureScript         FailureClass = "script"
+	FailureInfrastructure FailureClass = "infrastructure"
+	FailureUnknown        FailureClass = "unknown"
+)
+
 type TaskStatus string
 
 const (
@@ -168,9 +180,15 @@ const (
 	StepArchiveDistribute StepType = "archive_distribute"
 	StepJunitReport       StepType = "junit_report"
 	StepHtmlReport        StepType = "html_report"
+	StepCoverageReport    StepType = "coverage_report"
 	StepTarArchive        StepType = "tar_archive"
 	StepSonarCheck        StepType = "sonar_check"
 	StepDistributeImage   StepType = "distribute_image"
+	StepUploadArtifact    StepType = "upload_artifact"
+	StepDownloadArtifact  StepType = "download_artifact"
+	StepCacheRestore      StepType = "cache_restore"
+	StepCacheSave         StepType = "cache_save"
+	StepImageScan         StepType = "image_scan"
 )
 
 type JobType string
@@ -180,6 +198,7 @@ const (
 	JobDeploy               JobType = "deploy"
 	JobZadigBuild           JobType = "zadig-build"
 	JobZadigDistributeImage JobType = "zadig-distribute-image"
+	JobZadigImageScan       JobType = "zadig-image-scan"
 	JobZadigTesting         JobType = "zadig-test"
 	JobZadigScanning        JobType = "zadig-scanning"
 	JobCustomDeploy         JobType = "custom-deploy"
@@ -208,8 +227,11 @@ const (
 type ApprovalType string
 
 const (
-	NativeApproval ApprovalType = "native"
-	LarkApproval   ApprovalType = "lark"
+	NativeApproval   ApprovalType = "native"
+	LarkApproval     ApprovalType = "lark"
+	DingTalkApproval ApprovalType = "dingtalk"
+	WeComApproval    ApprovalType = "wecom"
+	WebhookApproval  ApprovalType = "webhook"
 )
 
 type ApproveOrReject string
@@ -219,6 +241,30 @@ const (
 	Reject  ApproveOrReject = "reject"
 )
 
+type ApprovalStatus string
+
+const (
+	ApprovalStatusPending   ApprovalStatus = "pending"
+	ApprovalStatusApproved  ApprovalStatus = "approved"
+	ApprovalStatusRejected  ApprovalStatus = "rejected"
+	ApprovalStatusTimeout   ApprovalStatus = "timeout"
+	ApprovalStatusCancelled ApprovalStatus = "cancelled"
+)
+
+type ApprovalAction string
+
+const (
+	ApprovalActionCreate   ApprovalAction = "create"
+	ApprovalActionApprove  ApprovalAction = "approve"
+	ApprovalActionReject   ApprovalAction = "reject"
+	ApprovalActionDelegate ApprovalAction = "delegate"
+	ApprovalActionEscalate ApprovalAction = "escalate"
+	ApprovalActionResume   ApprovalAction = "resume"
+)
+
+// MaxTaskPriority is the max absolute value of the priority of workflow v4 tasks.
+const MaxTaskPriority = 100
+
 type DeploySourceType string
 
 const (
@@ -319,6 +365,24 @@ const (
 	CommonEnvCfgTypePvc       CommonEnvCfgType = "PVC"
 )
 
+// DriftPolicy decides what to do when the live state of an environment drifts from what zadig applied.
+type DriftPolicy string
+
+const (
+	DriftPolicyAlert  DriftPolicy = "alert"
+	DriftPolicyRevert DriftPolicy = "revert"
+	DriftPolicyAdopt  DriftPolicy = "adopt"
+)
+
+type DriftAction string
+
+const (
+	DriftActionAlerted  DriftAction = "alerted"
+	DriftActionReverted DriftAction = "reverted"
+	DriftActionAdopted  DriftAction = "adopted"
+	DriftActionFailed   DriftAction = "failed"
+)
+
 // for custom blue-green release job
 const (
 	BlueGreenVerionLabelName = "zadig-blue-green-version"
@@ -358,7 +422,8 @@ const (
 )
 
 const (
-	TestJobJunitReportStepName   = "junit-report-step"
-	TestJobHTMLReportStepName    = "html-report-step"
-	TestJobArchiveResultStepName = "archive-result-step"
+	TestJobJunitReportStepName    = "junit-report-step"
+	TestJobHTMLReportStepName     = "html-report-step"
+	TestJobArchiveResultStepName  = "archive-result-step"
+	TestJobCoverageReportStepName = "coverage-report-step"
 )
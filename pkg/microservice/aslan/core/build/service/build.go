@@ -34,6 +34,7 @@ import (
 	e "github.com/koderover/zadig/pkg/tool/errors"
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
 )
 
 type BuildResp struct {
@@ -247,6 +248,9 @@ func CreateBuild(username string, build *commonmodels.Build, log *zap.SugaredLog
 		if err := commonutil.CheckDefineResourceParam(build.PreBuild.ResReq, build.PreBuild.ResReqSpec); err != nil {
 			return e.ErrCreateBuildModule.AddDesc(err.Error())
 		}
+		if err := commonutil.CheckServiceContainers(build.PreBuild.ServiceContainers); err != nil {
+			return e.ErrCreateBuildModule.AddDesc(err.Error())
+		}
 	}
 
 	if err := commonrepo.NewBuildColl().Create(build); err != nil {
@@ -264,6 +268,9 @@ func UpdateBuild(username string, build *commonmodels.Build, log *zap.SugaredLog
 	if err := commonutil.CheckDefineResourceParam(build.PreBuild.ResReq, build.PreBuild.ResReqSpec); err != nil {
 		return e.ErrUpdateBuildModule.AddDesc(err.Error())
 	}
+	if err := commonutil.CheckServiceContainers(build.PreBuild.ServiceContainers); err != nil {
+		return e.ErrUpdateBuildModule.AddDesc(err.Error())
+	}
 
 	existed, err := commonrepo.NewBuildColl().Find(&commonrepo.BuildFindOption{Name: build.Name, ProductName: build.ProductName})
 	if err == nil && existed.PreBuild != nil && build.PreBuild != nil {
@@ -521,6 +528,16 @@ func correctFields(build *commonmodels.Build) error {
 	if build.PostBuild != nil && build.PostBuild.DockerBuild != nil {
 		build.PostBuild.DockerBuild.DockerFile = strings.Trim(build.PostBuild.DockerBuild.DockerFile, " ")
 		build.PostBuild.DockerBuild.WorkDir = strings.Trim(build.PostBuild.DockerBuild.WorkDir, " ")
+		platforms := make([]string, 0)
+		for _, platform := range build.PostBuild.DockerBuild.Platforms {
+			if platform = strings.TrimSpace(platform); platform != "" {
+				platforms = append(platforms, platform)
+			}
+		}
+		build.PostBuild.DockerBuild.Platforms = platforms
+		if err := step.ValidateBuildEngine(step.BuildEngine(build.PostBuild.DockerBuild.BuildEngine), platforms); err != nil {
+			return e.ErrInvalidParam.AddErr(err)
+		}
 	}
 	if build.TemplateID == "" {
 		for _, repo := range build.Repos {
@@ -84,6 +84,8 @@ type PreBuild struct {
 
 	// UseHostDockerDaemon determines is dockerDaemon on host node is used in pod
 	UseHostDockerDaemon bool `bson:"use_host_docker_daemon" json:"use_host_docker_daemon"`
+	// ServiceContainers are started with the build pod, such as databases used by tests
+	ServiceContainers []*ServiceContainer `bson:"service_containers,omitempty" json:"service_containers,omitempty"`
 
 	// TODO: Deprecated.
 	Namespace string `bson:"namespace"                       json:"namespace"`
@@ -130,6 +132,14 @@ type DockerBuild struct {
 	TemplateID string `bson:"template_id"            json:"template_id"`
 	// TemplateName is the name of the template dockerfile
 	TemplateName string `bson:"template_name"        json:"template_name"`
+	// BuildEngine is one of docker, buildkit and kaniko, default is docker
+	BuildEngine string `bson:"build_engine,omitempty" json:"build_engine,omitempty"`
+	// Platforms the image is built for, only buildkit supports more than one platform
+	Platforms []string `bson:"platforms,omitempty"  json:"platforms,omitempty"`
+	// RemoteCache whether to import and export the layer cache from the registry
+	RemoteCache bool `bson:"remote_cache,omitempty" json:"remote_cache,omitempty"`
+	// CacheRef is the registry reference of the cache, default is <image repo>:buildcache
+	CacheRef string `bson:"cache_ref,omitempty"    json:"cache_ref,omitempty"`
 }
 
 type JenkinsBuild struct {
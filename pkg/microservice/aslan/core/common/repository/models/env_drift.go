@@ -0,0 +1,50 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+)
+
+const (
+	DriftKindHelmValues = "HelmValues"
+)
+
+// EnvDrift records a difference between the live state of a service in an environment and what zadig applied,
+// it is resolved when the difference disappears.
+type EnvDrift struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"           json:"id,omitempty"`
+	ProductName  string             `bson:"product_name"            json:"product_name"`
+	EnvName      string             `bson:"env_name"                json:"env_name"`
+	ServiceName  string             `bson:"service_name"            json:"service_name"`
+	Kind         string             `bson:"kind"                    json:"kind"`
+	ResourceName string             `bson:"resource_name"           json:"resource_name"`
+	// Fields are the paths of the drifted fields, e.g. spec.template.spec.containers[0].image
+	Fields     []string           `bson:"fields"                  json:"fields"`
+	Policy     config.DriftPolicy `bson:"policy"                  json:"policy"`
+	Action     config.DriftAction `bson:"action"                  json:"action"`
+	Message    string             `bson:"message,omitempty"       json:"message,omitempty"`
+	Resolved   bool               `bson:"resolved"                json:"resolved"`
+	CreateTime int64              `bson:"create_time"             json:"create_time"`
+	LastSeen   int64              `bson:"last_seen"               json:"last_seen"`
+}
+
+func (EnvDrift) TableName() string {
+	return "env_drift"
+}
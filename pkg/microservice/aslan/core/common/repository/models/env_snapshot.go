@@ -0,0 +1,43 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EnvSnapshot is a versioned snapshot of an environment, the content of the snapshot is archived in the
+// object storage and it can be restored into a new environment or rolled back to the environment.
+type EnvSnapshot struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"           json:"id,omitempty"`
+	ProductName string             `bson:"product_name"            json:"product_name"`
+	EnvName     string             `bson:"env_name"                json:"env_name"`
+	Version     int64              `bson:"version"                 json:"version"`
+	Source      string             `bson:"source"                  json:"source"`
+	ClusterID   string             `bson:"cluster_id"              json:"cluster_id"`
+	Namespace   string             `bson:"namespace"               json:"namespace"`
+	Services    []string           `bson:"services"                json:"services"`
+	Description string             `bson:"description"             json:"description"`
+	// ObjectKey is the key of the archive in the default object storage
+	ObjectKey  string `bson:"object_key"              json:"object_key"`
+	CreatedBy  string `bson:"created_by"              json:"created_by"`
+	CreateTime int64  `bson:"create_time"             json:"create_time"`
+}
+
+func (EnvSnapshot) TableName() string {
+	return "env_snapshot"
+}
@@ -22,11 +22,26 @@ type IMApp struct {
 	ID   primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	Type string             `json:"type" bson:"type"`
 	Name string             `json:"name" bson:"name"`
+	// AppID is unique among all apps, it is the app id of lark, the app key of DingTalk, the agent id of WeCom
+	// and the address of webhook if it is empty.
+	AppID     string `json:"app_id" bson:"app_id"`
+	AppSecret string `json:"app_secret" bson:"app_secret"`
 	// Lark fields
-	AppID                   string `json:"app_id" bson:"app_id"`
-	AppSecret               string `json:"app_secret" bson:"app_secret"`
 	EncryptKey              string `json:"encrypt_key" bson:"encrypt_key"`
 	LarkDefaultApprovalCode string `json:"-" bson:"lark_default_approval_code"`
+	// DingTalk fields
+	DingTalkProcessCode string `json:"dingtalk_process_code" bson:"dingtalk_process_code"`
+	// DingTalkFormName is the name of the text component in the approval form which holds the content.
+	DingTalkFormName string `json:"dingtalk_form_name" bson:"dingtalk_form_name"`
+	// WeCom fields
+	WeComCorpID     string `json:"wecom_corp_id" bson:"wecom_corp_id"`
+	WeComTemplateID string `json:"wecom_template_id" bson:"wecom_template_id"`
+	// WeComControlID is the id of the textarea control in the approval template which holds the content.
+	WeComControlID string `json:"wecom_control_id" bson:"wecom_control_id"`
+	// Webhook fields
+	WebhookAddress string `json:"webhook_address" bson:"webhook_address"`
+	// WebhookSecret signs the requests sent to the webhook and the callbacks from it.
+	WebhookSecret string `json:"webhook_secret" bson:"webhook_secret"`
 
 	UpdateTime int64 `json:"update_time" bson:"update_time"`
 }
@@ -0,0 +1,40 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// ImageScan is the result of an image scanned by the image scan job, Vulnerabilities counts the
+// vulnerabilities by severity except the ignored ones.
+type ImageScan struct {
+	ProjectName     string         `bson:"project_name"            json:"project_name"`
+	WorkflowName    string         `bson:"workflow_name"           json:"workflow_name"`
+	TaskID          int64          `bson:"task_id"                 json:"task_id"`
+	JobName         string         `bson:"job_name"                json:"job_name"`
+	ServiceName     string         `bson:"service_name"            json:"service_name"`
+	ServiceModule   string         `bson:"service_module"          json:"service_module"`
+	Image           string         `bson:"image"                   json:"image"`
+	Vulnerabilities map[string]int `bson:"vulnerabilities"         json:"vulnerabilities"`
+	Ignored         int            `bson:"ignored"                 json:"ignored"`
+	SBOMFormat      string         `bson:"sbom_format"             json:"sbom_format"`
+	ReportKey       string         `bson:"report_key"              json:"report_key"`
+	SBOMKey         string         `bson:"sbom_key"                json:"sbom_key"`
+	Passed          bool           `bson:"passed"                  json:"passed"`
+	CreateTime      int64          `bson:"create_time"             json:"create_time"`
+}
+
+func (ImageScan) TableName() string {
+	return "image_scan"
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/url"
+	"strings"
 	"text/template"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -46,6 +47,17 @@ type Notification struct {
 	Revision     string              `bson:"revision"                     json:"revision"`
 	RepoOwner    string              `bson:"repo_owner"                   json:"repo_owner"`
 	RepoName     string              `bson:"repo_name"                    json:"repo_name"`
+	PreviewEnv   *PreviewEnvInfo     `bson:"preview_env,omitempty"        json:"preview_env,omitempty"`
+}
+
+// PreviewEnvInfo is the preview environment shown in the comment of a pull request.
+type PreviewEnvInfo struct {
+	ProductName string   `bson:"product_name"          json:"product_name"`
+	EnvName     string   `bson:"env_name"              json:"env_name"`
+	BaseEnv     string   `bson:"base_env"              json:"base_env"`
+	Services    []string `bson:"services"              json:"services"`
+	Status      string   `bson:"status"                json:"status"`
+	Message     string   `bson:"message,omitempty"     json:"message,omitempty"`
 }
 
 type PrTaskInfo struct {
@@ -101,6 +113,10 @@ func (n *Notification) ToString() string {
 }
 
 func (n *Notification) CreateCommentBody() (comment string, err error) {
+	if n.PreviewEnv != nil {
+		return n.createPreviewEnvCommentBody()
+	}
+
 	hasTest := false
 	for _, task := range n.Tasks {
 		task.EncodedDisplayName = url.QueryEscape(task.WorkflowDisplayName)
@@ -191,6 +207,27 @@ func (n *Notification) CreateCommentBody() (comment string, err error) {
 	return buffer.String(), nil
 }
 
+func (n *Notification) createPreviewEnvCommentBody() (string, error) {
+	tmplSource := "预览环境：{{if .Env.EnvName}}[{{.Env.EnvName}}]({{.BaseURI}}/v1/projects/detail/{{.Env.ProductName}}/envs/detail?envName={{.Env.EnvName}}){{else}}-{{end}} 状态：{{.Env.Status}} \n\n" +
+		"{{if and .Env.EnvName .Env.Services}}部署的服务：{{join .Env.Services \", \"}} \n\n" +
+		"访问方式：请求中添加 Header `x-env: {{.Env.EnvName}}`，其余服务由基准环境 {{.Env.BaseEnv}} 提供 \n\n{{end}}" +
+		"{{if .Env.Message}}{{.Env.Message}} \n{{end}}"
+
+	tmpl := template.Must(template.New("comment").Funcs(template.FuncMap{"join": strings.Join}).Parse(tmplSource))
+	buffer := bytes.NewBufferString("")
+	if err := tmpl.Execute(buffer, struct {
+		Env     *PreviewEnvInfo
+		BaseURI string
+	}{
+		n.PreviewEnv,
+		n.BaseURI,
+	}); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
 func getEnvRecyclePolicy(policy string) string {
 	switch policy {
 	case config.EnvRecyclePolicyAlways:
@@ -0,0 +1,43 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PreviewEnv is the environment created for a pull request, it is deleted when the pull request is closed
+// or it has not been active for the idle time of the project.
+type PreviewEnv struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"           json:"id,omitempty"`
+	ProjectName    string             `bson:"project_name"            json:"project_name"`
+	EnvName        string             `bson:"env_name"                json:"env_name"`
+	BaseEnv        string             `bson:"base_env"                json:"base_env"`
+	CodehostID     int                `bson:"codehost_id"             json:"codehost_id"`
+	RepoOwner      string             `bson:"repo_owner"              json:"repo_owner"`
+	RepoNamespace  string             `bson:"repo_namespace"          json:"repo_namespace"`
+	RepoName       string             `bson:"repo_name"               json:"repo_name"`
+	PrID           int                `bson:"pr_id"                   json:"pr_id"`
+	Services       []string           `bson:"services"                json:"services"`
+	NotificationID string             `bson:"notification_id"         json:"notification_id"`
+	CreateTime     int64              `bson:"create_time"             json:"create_time"`
+	LastActiveTime int64              `bson:"last_active_time"        json:"last_active_time"`
+}
+
+func (PreviewEnv) TableName() string {
+	return "preview_env"
+}
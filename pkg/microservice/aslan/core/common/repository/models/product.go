@@ -67,6 +67,14 @@ type Product struct {
 
 	// New Since v1.16.0, used to determine whether to install resources
 	ServiceDeployStrategy map[string]string `bson:"service_deploy_strategy" json:"service_deploy_strategy"`
+
+	// DriftPolicy is empty if drift detection is disabled for the environment.
+	DriftPolicy config.DriftPolicy `bson:"drift_policy,omitempty" json:"drift_policy,omitempty"`
+
+	// SleepConfig is nil if the environment never sleeps.
+	SleepConfig *EnvSleepConfig `bson:"sleep_config,omitempty" json:"sleep_config,omitempty"`
+	// SleepState is only set while the environment is sleeping, it is used to wake the environment.
+	SleepState *EnvSleepState `bson:"sleep_state,omitempty" json:"-"`
 }
 
 type CreateUpdateCommonEnvCfgArgs struct {
@@ -120,6 +128,34 @@ type ProductShareEnv struct {
 	BaseEnv string `bson:"base_env" json:"base_env"`
 }
 
+type EnvSleepConfig struct {
+	Enable        bool   `bson:"enable"          json:"enable"`
+	SleepCron     string `bson:"sleep_cron"      json:"sleep_cron"`
+	WakeCron      string `bson:"wake_cron"       json:"wake_cron"`
+	TimeZone      string `bson:"time_zone"       json:"time_zone"`
+	WakeOnRequest bool   `bson:"wake_on_request" json:"wake_on_request"`
+}
+
+type EnvSleepState struct {
+	Workloads []*SleepingWorkload `bson:"workloads"  json:"workloads"`
+	Ingresses []*SleepingIngress  `bson:"ingresses"  json:"ingresses"`
+	WakeToken string              `bson:"wake_token" json:"-"`
+	SleepTime int64               `bson:"sleep_time" json:"sleep_time"`
+}
+
+// SleepingWorkload records the replicas of a workload before it was scaled to zero.
+type SleepingWorkload struct {
+	Kind     string `bson:"kind"     json:"kind"`
+	Name     string `bson:"name"     json:"name"`
+	Replicas int32  `bson:"replicas" json:"replicas"`
+}
+
+// SleepingIngress is an ingress pointed at the wake endpoint, AuthURL is the annotation restored when the env wakes.
+type SleepingIngress struct {
+	Name    string `bson:"name"     json:"name"`
+	AuthURL string `bson:"auth_url" json:"auth_url"`
+}
+
 func (Product) TableName() string {
 	return "product"
 }
@@ -58,6 +58,7 @@ type Service struct {
 	VariableYaml     string           `bson:"variable_yaml"                  json:"variable_yaml"` // New since 1.16.0, stores the variable yaml of k8s services
 	ServiceVars      []string         `bson:"service_vars"                   json:"service_vars"`  // New since 1.16.0, stores keys in variables which can be set in env
 	HelmChart        *HelmChart       `bson:"helm_chart,omitempty"           json:"helm_chart,omitempty"`
+	Kustomize        *Kustomize       `bson:"kustomize,omitempty"            json:"kustomize,omitempty"` // Kustomize is set if the yaml is built from a kustomization in the code repository
 	EnvConfigs       []*EnvConfig     `bson:"env_configs,omitempty"          json:"env_configs,omitempty"`
 	EnvStatuses      []*EnvStatus     `bson:"env_statuses,omitempty"         json:"env_statuses,omitempty"`
 	ReleaseNaming    string           `bson:"release_naming"                 json:"release_naming"`
@@ -185,6 +186,28 @@ type ServiceTmplRevision struct {
 	GerritRemoteName string `bson:"gerrit_remote_name,omitempty"   json:"gerrit_remote_name,omitempty"`
 }
 
+// Kustomize describes the kustomizations of a service, the paths are relative to the load path of the service.
+type Kustomize struct {
+	Path     string              `bson:"path"     json:"path"`
+	Overlays []*KustomizeOverlay `bson:"overlays" json:"overlays"`
+}
+
+// KustomizeOverlay is the overlay used to deploy the service in the environment.
+type KustomizeOverlay struct {
+	EnvName string `bson:"env_name" json:"env_name"`
+	Path    string `bson:"path"     json:"path"`
+}
+
+// OverlayPath returns the path of the overlay for the environment, or the default kustomization if there is none.
+func (k *Kustomize) OverlayPath(envName string) string {
+	for _, overlay := range k.Overlays {
+		if overlay.EnvName == envName {
+			return overlay.Path
+		}
+	}
+	return k.Path
+}
+
 type HelmChart struct {
 	Name       string `bson:"name"               json:"name"`
 	Version    string `bson:"version"     json:"version"`
@@ -25,6 +25,10 @@ const (
 	// 工作流任务的留存
 	WorkflowTaskRetention     CapacityTarget = "WorkflowTaskRetention"
 	DefaultWorkflowRemainDays int            = 365
+	// 依赖缓存的留存，按最近使用时间淘汰
+	DependencyCacheRetention CapacityTarget = "DependencyCacheRetention"
+	DefaultCacheRemainDays   int            = 7
+	DefaultCacheMaxSize      int64          = 10240
 )
 
 var DefaultWorkflowTaskRetention = &CapacityStrategy{
@@ -34,10 +38,19 @@ var DefaultWorkflowTaskRetention = &CapacityStrategy{
 	},
 }
 
+var DefaultDependencyCacheRetention = &CapacityStrategy{
+	Target: DependencyCacheRetention,
+	Retention: &RetentionConfig{
+		MaxDays: DefaultCacheRemainDays,
+		MaxSize: DefaultCacheMaxSize,
+	},
+}
+
 // RetentionConfig 资源留存相关的配置
 type RetentionConfig struct {
-	MaxDays  int `bson:"max_days"      json:"max_days"`  // 最多几天
-	MaxItems int `bson:"max_items"     json:"max_items"` // 最多几条
+	MaxDays  int   `bson:"max_days"           json:"max_days"`           // 最多几天
+	MaxItems int   `bson:"max_items"          json:"max_items"`          // 最多几条
+	MaxSize  int64 `bson:"max_size,omitempty" json:"max_size,omitempty"` // 最大容量，单位 MB
 }
 
 // CapacityStrategy 系统配额策略
@@ -69,6 +69,10 @@ type Product struct {
 	CustomTarRule              *CustomRule          `bson:"custom_tar_rule,omitempty"           json:"custom_tar_rule,omitempty"`
 	DeliveryVersionHook        *DeliveryVersionHook `bson:"delivery_version_hook"               json:"delivery_version_hook"`
 	Public                     bool                 `bson:"public,omitempty"                    json:"public"`
+	// WorkflowConcurrency is the max number of running workflow v4 tasks of the project, 0 means no limit.
+	WorkflowConcurrency int `bson:"workflow_concurrency"                json:"workflow_concurrency"`
+	// PreviewEnv controls the environments created for pull requests of the project.
+	PreviewEnv *PreviewEnvPolicy `bson:"preview_env,omitempty"                json:"preview_env,omitempty"`
 }
 
 type ServiceInfo struct {
@@ -171,6 +175,24 @@ type DeliveryVersionHook struct {
 	Path     string `bson:"path"       json:"path"`
 }
 
+// PreviewEnvPolicy describes the preview environments of pull requests, they are sub environments of
+// the share environment BaseEnv and only contain the services the pull requests touch.
+type PreviewEnvPolicy struct {
+	Enabled bool   `bson:"enabled"      json:"enabled"`
+	BaseEnv string `bson:"base_env"     json:"base_env"`
+	// MaxEnvs is the max number of preview environments existing at the same time, 0 means no limit.
+	MaxEnvs int `bson:"max_envs"     json:"max_envs"`
+	// IdleMinutes is how long a preview environment can stay unused before it is deleted, 0 means never.
+	IdleMinutes int64 `bson:"idle_minutes" json:"idle_minutes"`
+	// Workflow is the workflow v4 run with the code of the pull request whenever it is updated, its build jobs
+	// only build the services of the preview environment and its deploy jobs deploy to the preview environment.
+	Workflow string `bson:"workflow"     json:"workflow"`
+}
+
+func (p *PreviewEnvPolicy) IsEnabled() bool {
+	return p != nil && p.Enabled
+}
+
 type AutoDeployPolicy struct {
 	Enable bool `bson:"enable" json:"enable"`
 }
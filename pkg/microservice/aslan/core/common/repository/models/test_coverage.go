@@ -0,0 +1,41 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// TestCoverage is the coverage of a testing in a workflow task, the coverages are percentages.
+type TestCoverage struct {
+	TestName        string  `bson:"test_name"               json:"test_name"`
+	ProjectName     string  `bson:"project_name"            json:"project_name"`
+	WorkflowName    string  `bson:"workflow_name"           json:"workflow_name"`
+	TaskID          int64   `bson:"task_id"                 json:"task_id"`
+	JobName         string  `bson:"job_name"                json:"job_name"`
+	Branch          string  `bson:"branch"                  json:"branch"`
+	PR              int     `bson:"pr"                      json:"pr"`
+	Format          string  `bson:"format"                  json:"format"`
+	LinesCovered    int     `bson:"lines_covered"           json:"lines_covered"`
+	LinesValid      int     `bson:"lines_valid"             json:"lines_valid"`
+	BranchesCovered int     `bson:"branches_covered"        json:"branches_covered"`
+	BranchesValid   int     `bson:"branches_valid"          json:"branches_valid"`
+	LineCoverage    float64 `bson:"line_coverage"           json:"line_coverage"`
+	BranchCoverage  float64 `bson:"branch_coverage"         json:"branch_coverage"`
+	ReportKey       string  `bson:"report_key"              json:"report_key"`
+	CreateTime      int64   `bson:"create_time"             json:"create_time"`
+}
+
+func (TestCoverage) TableName() string {
+	return "test_coverage"
+}
@@ -22,8 +22,11 @@ type TestTaskStat struct {
 	TotalFailure  int    `bson:"total_failure"           json:"totalFailure"`
 	TotalDuration int64  `bson:"total_duration"          json:"totalDuration"`
 	TestCaseNum   int    `bson:"test_case_num"           json:"testCaseNum"`
-	CreateTime    int64  `bson:"create_time"             json:"createTime"`
-	UpdateTime    int64  `bson:"update_time"             json:"updateTime"`
+	// coverages of the latest run, in percentage
+	LineCoverage   float64 `bson:"line_coverage"           json:"lineCoverage"`
+	BranchCoverage float64 `bson:"branch_coverage"         json:"branchCoverage"`
+	CreateTime     int64   `bson:"create_time"             json:"createTime"`
+	UpdateTime     int64   `bson:"update_time"             json:"updateTime"`
 }
 
 func (TestTaskStat) TableName() string {
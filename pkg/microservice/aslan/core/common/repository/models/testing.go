@@ -41,18 +41,22 @@ type Testing struct {
 	TestReportPath string `bson:"test_report_path"         json:"test_report_path"`
 	Threshold      int    `bson:"threshold"                json:"threshold"`
 	TestType       string `bson:"test_type"                json:"test_type"`
+	// 覆盖率报告
+	CoverageReport *CoverageReport `bson:"coverage_report,omitempty" json:"coverage_report,omitempty"`
 
 	// TODO: Deprecated.
 	Caches []string `bson:"caches"                   json:"caches"`
 
-	ArtifactPaths []string         `bson:"artifact_paths,omitempty" json:"artifact_paths,omitempty"`
-	TestCaseNum   int              `bson:"-"                        json:"test_case_num,omitempty"`
-	ExecuteNum    int              `bson:"-"                        json:"execute_num,omitempty"`
-	PassRate      float64          `bson:"-"                        json:"pass_rate,omitempty"`
-	AvgDuration   float64          `bson:"-"                        json:"avg_duration,omitempty"`
-	Workflows     []*Workflow      `bson:"-"                        json:"workflows,omitempty"`
-	Schedules     *ScheduleCtrl    `bson:"schedules,omitempty"      json:"schedules,omitempty"`
-	HookCtl       *TestingHookCtrl `bson:"hook_ctl"                 json:"hook_ctl"`
+	ArtifactPaths  []string         `bson:"artifact_paths,omitempty" json:"artifact_paths,omitempty"`
+	TestCaseNum    int              `bson:"-"                        json:"test_case_num,omitempty"`
+	ExecuteNum     int              `bson:"-"                        json:"execute_num,omitempty"`
+	PassRate       float64          `bson:"-"                        json:"pass_rate,omitempty"`
+	LineCoverage   float64          `bson:"-"                        json:"line_coverage,omitempty"`
+	BranchCoverage float64          `bson:"-"                        json:"branch_coverage,omitempty"`
+	AvgDuration    float64          `bson:"-"                        json:"avg_duration,omitempty"`
+	Workflows      []*Workflow      `bson:"-"                        json:"workflows,omitempty"`
+	Schedules      *ScheduleCtrl    `bson:"schedules,omitempty"      json:"schedules,omitempty"`
+	HookCtl        *TestingHookCtrl `bson:"hook_ctl"                 json:"hook_ctl"`
 	// TODO: Deprecated.
 	NotifyCtl *NotifyCtl `bson:"notify_ctl,omitempty"     json:"notify_ctl,omitempty"`
 	// New since V1.12.0.
@@ -68,6 +72,18 @@ type Testing struct {
 	Outputs                  []*Output `bson:"outputs"                   json:"outputs"`
 }
 
+// CoverageReport parses the coverage report of the testing and fails the test if coverage is below the thresholds or
+// regresses too much, thresholds are percentages and 0 means no gate.
+type CoverageReport struct {
+	ReportPath        string  `bson:"report_path"                   json:"report_path"`
+	Format            string  `bson:"format,omitempty"              json:"format,omitempty"`
+	MinLineCoverage   float64 `bson:"min_line_coverage,omitempty"   json:"min_line_coverage,omitempty"`
+	MinBranchCoverage float64 `bson:"min_branch_coverage,omitempty" json:"min_branch_coverage,omitempty"`
+	// MaxRegression is the max drop of line coverage in percentage points compared with the base branch, which is
+	// the target branch of pull requests and the branch itself otherwise.
+	MaxRegression float64 `bson:"max_regression,omitempty"      json:"max_regression,omitempty"`
+}
+
 type TestingHookCtrl struct {
 	Enabled bool           `bson:"enabled" json:"enabled"`
 	Items   []*TestingHook `bson:"items" json:"items"`
@@ -98,6 +114,8 @@ type PreTest struct {
 	// EnableProxy
 	EnableProxy bool   `bson:"enable_proxy"           json:"enable_proxy"`
 	ClusterID   string `bson:"cluster_id"             json:"cluster_id"`
+	// ServiceContainers are started with the test pod, such as databases and message brokers
+	ServiceContainers []*ServiceContainer `bson:"service_containers,omitempty" json:"service_containers,omitempty"`
 
 	// TODO: Deprecated.
 	Namespace string `bson:"namespace"              json:"namespace"`
@@ -22,6 +22,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	jobtypes "github.com/koderover/zadig/pkg/types/job"
 )
 
 type WorkflowTask struct {
@@ -51,6 +52,14 @@ type WorkflowTask struct {
 	IsRestart           bool               `bson:"is_restart"                json:"is_restart"`
 	MultiRun            bool               `bson:"multi_run"                 json:"multi_run"`
 	ShareStorages       []*ShareStorage    `bson:"share_storages"            json:"share_storages"`
+	DAG                 bool               `bson:"dag"                       json:"dag"`
+	// RetryFrom is the id of the failed task which this task was retried from.
+	RetryFrom   int64 `bson:"retry_from,omitempty"      json:"retry_from,omitempty"`
+	Priority    int   `bson:"priority"                  json:"priority"`
+	Concurrency int   `bson:"concurrency"               json:"concurrency"`
+	// QueuePosition and EstimatedWait are set for tasks waiting in the queue, the wait time is in seconds.
+	QueuePosition int   `bson:"-"                         json:"queue_position,omitempty"`
+	EstimatedWait int64 `bson:"-"                         json:"estimated_wait,omitempty"`
 }
 
 func (WorkflowTask) TableName() string {
@@ -82,6 +91,43 @@ type JobTask struct {
 	Retry      int64         `bson:"retry"               json:"retry"`
 	Spec       interface{}   `bson:"spec"                json:"spec"`
 	Outputs    []*Output     `bson:"outputs"             json:"outputs"`
+	// OriginName is the name of the workflow job which this job task was generated from.
+	OriginName string `bson:"origin_name"         json:"origin_name"`
+	// DependsOn is the origin names of upstream jobs.
+	DependsOn []string `bson:"depends_on"          json:"depends_on"`
+	If        string   `bson:"if"                  json:"if"`
+	// Condition is the evaluation result of If, it is nil if the job has no condition.
+	Condition *ConditionResult `bson:"condition,omitempty" json:"condition,omitempty"`
+	// Matrix is the axis values of the job task expanded from a matrix job.
+	Matrix      map[string]string `bson:"matrix,omitempty"    json:"matrix,omitempty"`
+	MaxParallel int               `bson:"max_parallel"        json:"max_parallel"`
+	RetryPolicy *RetryPolicy      `bson:"retry_policy"        json:"retry_policy,omitempty"`
+	// ContinueOnError means the failure of the job does not fail the stage.
+	ContinueOnError bool                `bson:"continue_on_error"   json:"continue_on_error"`
+	FailureClass    config.FailureClass `bson:"failure_class"       json:"failure_class,omitempty"`
+	// Attempts records the failed attempts before the current one.
+	Attempts []*JobAttempt `bson:"attempts"            json:"attempts,omitempty"`
+	// ReusedFrom is the id of the task in which the job ran, it is set when the result of the
+	// job is kept by a retried task, such jobs are not run again.
+	ReusedFrom int64 `bson:"reused_from,omitempty" json:"reused_from,omitempty"`
+}
+
+type JobAttempt struct {
+	Attempt      int                 `bson:"attempt"             json:"attempt"`
+	Status       config.Status       `bson:"status"              json:"status"`
+	Error        string              `bson:"error"               json:"error"`
+	FailureClass config.FailureClass `bson:"failure_class"       json:"failure_class"`
+	StartTime    int64               `bson:"start_time"          json:"start_time"`
+	EndTime      int64               `bson:"end_time"            json:"end_time"`
+	// LogName is the name to get the container log of the attempt, it is empty if the job has no container log.
+	LogName string `bson:"log_name"            json:"log_name"`
+}
+
+type ConditionResult struct {
+	Expression string `bson:"expression"          json:"expression"`
+	Passed     bool   `bson:"passed"              json:"passed"`
+	// Message explains why the condition was not passed.
+	Message string `bson:"message"             json:"message"`
 }
 
 type JobTaskCustomDeploySpec struct {
@@ -107,6 +153,7 @@ type JobTaskDeploySpec struct {
 	Timeout            int                 `bson:"timeout"                          json:"timeout"                             yaml:"timeout"`
 	ReplaceResources   []Resource          `bson:"replace_resources"                json:"replace_resources"                   yaml:"replace_resources"`
 	RelatedPodLabels   []map[string]string `bson:"-"                                json:"-"                                   yaml:"-"`
+	SignatureCheck     *SignatureCheck     `bson:"signature_check,omitempty"        json:"signature_check,omitempty"           yaml:"signature_check,omitempty"`
 }
 
 type Resource struct {
@@ -126,6 +173,14 @@ type JobTaskHelmDeploySpec struct {
 	ReleaseName        string                   `bson:"release_name"                     json:"release_name"                        yaml:"release_name"`
 	Timeout            int                      `bson:"timeout"                          json:"timeout"                             yaml:"timeout"`
 	ReplaceResources   []Resource               `bson:"replace_resources"                json:"replace_resources"                   yaml:"replace_resources"`
+	SignatureCheck     *SignatureCheck          `bson:"signature_check,omitempty"        json:"signature_check,omitempty"           yaml:"signature_check,omitempty"`
+}
+
+// SignatureCheck verifies that the images are signed by the private key of PublicKey before they are
+// deployed, the signatures are read from Registries.
+type SignatureCheck struct {
+	PublicKey  string               `bson:"public_key"                       json:"public_key"                          yaml:"public_key"`
+	Registries []*RegistryNamespace `bson:"registries"                       json:"registries"                          yaml:"registries"`
 }
 
 type ImageAndServiceModule struct {
@@ -327,10 +382,21 @@ type StepTask struct {
 	Error     string          `bson:"error"          json:"error"        yaml:"error"`
 	StepType  config.StepType `bson:"type"           json:"type"         yaml:"type"`
 	Onfailure bool            `bson:"on_failure"     json:"on_failure"   yaml:"on_failure"`
+	// RunIf decides whether the step runs according to the results of the previous steps, on_success by default.
+	RunIf jobtypes.RunIf `bson:"run_if,omitempty"  json:"run_if,omitempty"  yaml:"run_if,omitempty"`
+	// Timeout of the step in minutes, 0 means no limit.
+	Timeout int64 `bson:"timeout,omitempty" json:"timeout,omitempty" yaml:"timeout,omitempty"`
 	// step input params,differ form steps
 	Spec interface{} `bson:"spec"           json:"spec"   yaml:"spec"`
 	// step output results,like testing results,differ form steps
 	Result interface{} `bson:"result"         json:"result"  yaml:"result"`
+	If     string      `bson:"if"             json:"if"      yaml:"if"`
+	// Condition is the evaluation result of If, skipped steps will not be sent to job executor.
+	Condition *ConditionResult `bson:"condition,omitempty" json:"condition,omitempty" yaml:"-"`
+	// Status, StartTime and EndTime are reported by the job executor when the job is completed.
+	Status    config.Status `bson:"status,omitempty"     json:"status,omitempty"     yaml:"-"`
+	StartTime int64         `bson:"start_time,omitempty" json:"start_time,omitempty" yaml:"-"`
+	EndTime   int64         `bson:"end_time,omitempty"   json:"end_time,omitempty"   yaml:"-"`
 }
 
 type WorkflowTaskCtx struct {
@@ -346,8 +412,12 @@ type WorkflowTaskCtx struct {
 	WorkflowTaskCreatorEmail  string
 	WorkflowTaskCreatorMobile string
 	WorkflowKeyVals           []*KeyVal
+	WorkflowParams            []*Param
+	HookPayload               *HookPayload
 	GlobalContextGet          func(key string) (string, bool)
 	GlobalContextSet          func(key, value string)
 	GlobalContextEach         func(f func(k, v string) bool)
 	ClusterIDAdd              func(clusterID string)
+	// ReusedJobs is the id of the task in which each reused job ran, keyed by job name.
+	ReusedJobs map[string]int64
 }
@@ -18,6 +18,7 @@ package models
 
 import (
 	"fmt"
+	"strconv"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
@@ -361,6 +362,7 @@ type HookPayload struct {
 	Owner          string `bson:"owner"            json:"owner,omitempty"`
 	Repo           string `bson:"repo"             json:"repo,omitempty"`
 	Branch         string `bson:"branch"           json:"branch,omitempty"`
+	Tag            string `bson:"tag"              json:"tag,omitempty"`
 	Ref            string `bson:"ref"              json:"ref,omitempty"`
 	IsPr           bool   `bson:"is_pr"            json:"is_pr,omitempty"`
 	CheckRunID     int64  `bson:"check_run_id"     json:"check_run_id,omitempty"`
@@ -370,6 +372,28 @@ type HookPayload struct {
 	CodehostID     int    `bson:"codehost_id"      json:"codehost_id"`
 }
 
+// TriggerVariables returns the trigger.* variables which can be used in conditions of workflow v4 jobs,
+// all of them are empty if the task was not triggered by webhook.
+func (h *HookPayload) TriggerVariables() map[string]string {
+	payload := h
+	if payload == nil {
+		payload = &HookPayload{}
+	}
+	pr := ""
+	if payload.IsPr {
+		pr = payload.MergeRequestID
+	}
+	return map[string]string{
+		"trigger.owner":     payload.Owner,
+		"trigger.repo":      payload.Repo,
+		"trigger.branch":    payload.Branch,
+		"trigger.tag":       payload.Tag,
+		"trigger.pr":        pr,
+		"trigger.is_pr":     strconv.FormatBool(payload.IsPr),
+		"trigger.commit_id": payload.CommitID,
+	}
+}
+
 type TargetArgs struct {
 	Name             string            `bson:"name"                          json:"name"`
 	ImageName        string            `bson:"image_name"                    json:"image_name"`
@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+)
+
+// WorkflowApproval is the persisted state of an approval of a workflow v4 stage,
+// it survives restarts of aslan and is shared by all replicas.
+type WorkflowApproval struct {
+	ID                  primitive.ObjectID  `bson:"_id,omitempty"          json:"id,omitempty"`
+	ProjectName         string              `bson:"project_name"           json:"project_name"`
+	WorkflowName        string              `bson:"workflow_name"          json:"workflow_name"`
+	WorkflowDisplayName string              `bson:"workflow_display_name"  json:"workflow_display_name"`
+	TaskID              int64               `bson:"task_id"                json:"task_id"`
+	StageName           string              `bson:"stage_name"             json:"stage_name"`
+	Type                config.ApprovalType `bson:"type"                   json:"type"`
+	// IMAppID and InstanceID are the app and the approval instance in the external approval system.
+	IMAppID         string                `bson:"im_app_id"              json:"im_app_id,omitempty"`
+	InstanceID      string                `bson:"instance_id"            json:"instance_id,omitempty"`
+	Description     string                `bson:"description"            json:"description"`
+	Status          config.ApprovalStatus `bson:"status"                 json:"status"`
+	ApproveUsers    []*User               `bson:"approve_users"          json:"approve_users"`
+	NeededApprovers int                   `bson:"needed_approvers"       json:"needed_approvers"`
+	// Timeout is in minutes, counted from CreateTime.
+	Timeout       int     `bson:"timeout"                json:"timeout"`
+	EscalateAfter int     `bson:"escalate_after"         json:"escalate_after"`
+	EscalateUsers []*User `bson:"escalate_users"         json:"escalate_users"`
+	Escalated     bool    `bson:"escalated"              json:"escalated"`
+	// Records is the audit trail of the approval, it is only appended.
+	Records    []*ApprovalRecord `bson:"records"                json:"records"`
+	CreateTime int64             `bson:"create_time"            json:"create_time"`
+	UpdateTime int64             `bson:"update_time"            json:"update_time"`
+}
+
+type ApprovalRecord struct {
+	Action   config.ApprovalAction `bson:"action"                 json:"action"`
+	UserID   string                `bson:"user_id"                json:"user_id"`
+	UserName string                `bson:"user_name"              json:"user_name"`
+	Comment  string                `bson:"comment"                json:"comment"`
+	// DelegateTo is the user the approval is delegated to, only for delegate records.
+	DelegateTo *User `bson:"delegate_to,omitempty"  json:"delegate_to,omitempty"`
+	Time       int64 `bson:"time"                   json:"time"`
+}
+
+func (WorkflowApproval) TableName() string {
+	return "workflow_approval"
+}
@@ -34,6 +34,10 @@ type WorkflowQueue struct {
 	TaskRevoker         string             `bson:"task_revoker,omitempty"                     json:"task_revoker,omitempty"`
 	CreateTime          int64              `bson:"create_time"                                json:"create_time,omitempty"`
 	MultiRun            bool               `bson:"multi_run"                                  json:"multi_run"`
+	Priority            int                `bson:"priority"                                   json:"priority"`
+	Concurrency         int                `bson:"concurrency"                                json:"concurrency"`
+	// Preempted tasks are scheduled before all other tasks, quotas still apply to them.
+	Preempted bool `bson:"preempted"                                  json:"preempted"`
 }
 
 func (WorkflowQueue) TableName() string {
@@ -26,6 +26,7 @@ import (
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/lark"
 	"github.com/koderover/zadig/pkg/types"
+	jobtypes "github.com/koderover/zadig/pkg/types/job"
 )
 
 type WorkflowV4 struct {
@@ -51,6 +52,41 @@ type WorkflowV4 struct {
 	HookPayload     *HookPayload             `bson:"hook_payload"        yaml:"-"                   json:"hook_payload,omitempty"`
 	BaseName        string                   `bson:"base_name"           yaml:"-"                   json:"base_name"`
 	ShareStorages   []*ShareStorage          `bson:"share_storages"      yaml:"share_storages"      json:"share_storages"`
+	// DAG means all jobs in the workflow are scheduled by their depends_on edges, stages are only used for grouping.
+	DAG bool `bson:"dag"                 yaml:"dag"                 json:"dag"`
+	// Priority is the priority of the tasks, tasks with higher priority are scheduled first.
+	Priority int `bson:"priority"            yaml:"priority"            json:"priority"`
+	// Concurrency is the max number of running tasks when multi run is enabled, 0 means no limit.
+	Concurrency int `bson:"concurrency"         yaml:"concurrency"         json:"concurrency"`
+	// CodeSource defines the workflow by a yaml file in the application repository when it is enabled.
+	CodeSource *WorkflowCodeSource `bson:"code_source,omitempty" yaml:"code_source,omitempty" json:"code_source,omitempty"`
+}
+
+// WorkflowCodeSource is a yaml file of the workflow in a repository, it has the same schema as the workflows edited in zadig.
+// Tasks triggered by the events of the repository use the file at the commit of the event, so each branch runs its own definition.
+type WorkflowCodeSource struct {
+	Enabled       bool   `bson:"enabled"             yaml:"enabled"             json:"enabled"`
+	CodehostID    int    `bson:"codehost_id"         yaml:"codehost_id"         json:"codehost_id"`
+	RepoOwner     string `bson:"repo_owner"          yaml:"repo_owner"          json:"repo_owner"`
+	RepoNamespace string `bson:"repo_namespace"      yaml:"repo_namespace"      json:"repo_namespace"`
+	RepoName      string `bson:"repo_name"           yaml:"repo_name"           json:"repo_name"`
+	Source        string `bson:"source"              yaml:"source"              json:"source"`
+	// Branch is the branch whose file is synced to zadig.
+	Branch string `bson:"branch"              yaml:"branch"              json:"branch"`
+	Path   string `bson:"path"                yaml:"path"                json:"path"`
+	// SyncToZadig updates the workflow in zadig with the file when it is pushed to the branch.
+	SyncToZadig bool `bson:"sync_to_zadig"       yaml:"sync_to_zadig"       json:"sync_to_zadig"`
+}
+
+func (s *WorkflowCodeSource) IsEnabled() bool {
+	return s != nil && s.Enabled
+}
+
+func (s *WorkflowCodeSource) GetRepoNamespace() string {
+	if s.RepoNamespace != "" {
+		return s.RepoNamespace
+	}
+	return s.RepoOwner
 }
 
 type WorkflowStage struct {
@@ -66,6 +102,24 @@ type Approval struct {
 	Description    string              `bson:"description"                 yaml:"description"                   json:"description"`
 	NativeApproval *NativeApproval     `bson:"native_approval"             yaml:"native_approval,omitempty"     json:"native_approval,omitempty"`
 	LarkApproval   *LarkApproval       `bson:"lark_approval"               yaml:"lark_approval,omitempty"       json:"lark_approval,omitempty"`
+	// DingTalkApproval, WeComApproval and WebhookApproval are handled by approval providers.
+	DingTalkApproval *ExternalApproval `bson:"dingtalk_approval"           yaml:"dingtalk_approval,omitempty"   json:"dingtalk_approval,omitempty"`
+	WeComApproval    *ExternalApproval `bson:"wecom_approval"              yaml:"wecom_approval,omitempty"      json:"wecom_approval,omitempty"`
+	WebhookApproval  *ExternalApproval `bson:"webhook_approval"            yaml:"webhook_approval,omitempty"    json:"webhook_approval,omitempty"`
+}
+
+// GetExternalApproval returns the approval handled by approval providers, it is nil for native and lark approvals.
+func (a *Approval) GetExternalApproval() *ExternalApproval {
+	switch a.Type {
+	case config.DingTalkApproval:
+		return a.DingTalkApproval
+	case config.WeComApproval:
+		return a.WeComApproval
+	case config.WebhookApproval:
+		return a.WebhookApproval
+	default:
+		return nil
+	}
 }
 
 type NativeApproval struct {
@@ -73,6 +127,9 @@ type NativeApproval struct {
 	ApproveUsers    []*User                `bson:"approve_users"               yaml:"approve_users"              json:"approve_users"`
 	NeededApprovers int                    `bson:"needed_approvers"            yaml:"needed_approvers"           json:"needed_approvers"`
 	RejectOrApprove config.ApproveOrReject `bson:"reject_or_approve"           yaml:"-"                          json:"reject_or_approve"`
+	// EscalateAfter is the minutes after which EscalateUsers are added to the approvers, 0 means never.
+	EscalateAfter int     `bson:"escalate_after"              yaml:"escalate_after,omitempty"   json:"escalate_after,omitempty"`
+	EscalateUsers []*User `bson:"escalate_users"              yaml:"escalate_users,omitempty"   json:"escalate_users,omitempty"`
 }
 
 type LarkApproval struct {
@@ -81,6 +138,16 @@ type LarkApproval struct {
 	ApproveUsers []*LarkApprovalUser `bson:"approve_users"               yaml:"approve_users"              json:"approve_users"`
 }
 
+// ExternalApproval is an approval in the system of the IM app ApprovalID, the user ids of ApproveUsers are the ids in that system.
+type ExternalApproval struct {
+	Timeout      int     `bson:"timeout"                     yaml:"timeout"                    json:"timeout"`
+	ApprovalID   string  `bson:"approval_id"                 yaml:"approval_id"                json:"approval_id"`
+	ApproveUsers []*User `bson:"approve_users"               yaml:"approve_users"              json:"approve_users"`
+	// NeededApprovers is only used by DingTalk and WeCom, it is either 1 for any one of the approvers to decide,
+	// or the number of ApproveUsers for all of them to approve.
+	NeededApprovers int `bson:"needed_approvers"            yaml:"needed_approvers"           json:"needed_approvers"`
+}
+
 type LarkApprovalUser struct {
 	lark.UserInfo   `bson:",inline"  yaml:",inline"  json:",inline"`
 	RejectOrApprove config.ApproveOrReject `bson:"reject_or_approve"           yaml:"-"                          json:"reject_or_approve"`
@@ -102,6 +169,42 @@ type Job struct {
 	// only for webhook workflow args to skip some tasks.
 	Skipped bool        `bson:"skipped"        yaml:"skipped"  json:"skipped"`
 	Spec    interface{} `bson:"spec"           yaml:"spec"     json:"spec"`
+	// DependsOn is the name list of upstream jobs, the job starts as soon as all of them passed.
+	DependsOn []string `bson:"depends_on"     yaml:"depends_on,omitempty" json:"depends_on,omitempty"`
+	// If is a condition expression evaluated right before the job runs, the job is skipped when it is false.
+	If string `bson:"if"             yaml:"if,omitempty"         json:"if,omitempty"`
+	// Matrix expands freestyle, build and testing jobs into one job task for each combination of its axes.
+	Matrix *Matrix `bson:"matrix"         yaml:"matrix,omitempty"     json:"matrix,omitempty"`
+	// Retry reruns the failed job according to the policy.
+	Retry *RetryPolicy `bson:"retry"          yaml:"retry,omitempty"      json:"retry,omitempty"`
+	// ContinueOnError makes the stage and downstream jobs go on when the job failed.
+	ContinueOnError bool `bson:"continue_on_error" yaml:"continue_on_error,omitempty" json:"continue_on_error,omitempty"`
+	// AllowFailure is an alias of ContinueOnError.
+	AllowFailure bool `bson:"allow_failure"  yaml:"allow_failure,omitempty"     json:"allow_failure,omitempty"`
+}
+
+type RetryPolicy struct {
+	// Retries is the max number of retries after the first attempt failed.
+	Retries int `bson:"retries"        yaml:"retries"              json:"retries"`
+	// Backoff is the seconds to wait before the first retry, 10 by default, it is doubled for each next retry.
+	Backoff int64 `bson:"backoff"        yaml:"backoff"              json:"backoff"`
+	// MaxBackoff limits the seconds to wait between retries, 0 means no limit.
+	MaxBackoff int64 `bson:"max_backoff"    yaml:"max_backoff"          json:"max_backoff"`
+	// RetryOn is the failure classes to retry, all failures are retried if it is empty.
+	RetryOn []config.FailureClass `bson:"retry_on"       yaml:"retry_on,omitempty"   json:"retry_on,omitempty"`
+}
+
+type Matrix struct {
+	Axes []*MatrixAxis `bson:"axes"           yaml:"axes"              json:"axes"`
+	// Exclude removes the combinations which match all values of any item.
+	Exclude []map[string]string `bson:"exclude"        yaml:"exclude,omitempty" json:"exclude,omitempty"`
+	// MaxParallel limits the number of expanded job tasks running at the same time, 0 means no limit.
+	MaxParallel int `bson:"max_parallel"   yaml:"max_parallel"      json:"max_parallel"`
+}
+
+type MatrixAxis struct {
+	Name   string   `bson:"name"           yaml:"name"              json:"name"`
+	Values []string `bson:"values"         yaml:"values"            json:"values"`
 }
 
 type CustomDeployJobSpec struct {
@@ -136,6 +239,21 @@ type FreestyleJobSpec struct {
 type ZadigBuildJobSpec struct {
 	DockerRegistryID string             `bson:"docker_registry_id"     yaml:"docker_registry_id"     json:"docker_registry_id"`
 	ServiceAndBuilds []*ServiceAndBuild `bson:"service_and_builds"     yaml:"service_and_builds"     json:"service_and_builds"`
+	Signing          *ImageSigning      `bson:"signing,omitempty"      yaml:"signing,omitempty"      json:"signing,omitempty"`
+}
+
+// ImageSigning signs the images pushed by the job with the key in the private key store, and attaches the
+// provenance of the images if Provenance is true.
+type ImageSigning struct {
+	Enabled    bool   `bson:"enabled"        yaml:"enabled"        json:"enabled"`
+	KeyID      string `bson:"key_id"         yaml:"key_id"         json:"key_id"`
+	Provenance bool   `bson:"provenance"     yaml:"provenance"     json:"provenance"`
+}
+
+// ImageVerification refuses to deploy the images which are not signed by the key in the private key store.
+type ImageVerification struct {
+	Enabled bool   `bson:"enabled"        yaml:"enabled"        json:"enabled"`
+	KeyID   string `bson:"key_id"         yaml:"key_id"         json:"key_id"`
 }
 
 type ServiceAndBuild struct {
@@ -143,12 +261,19 @@ type ServiceAndBuild struct {
 	ServiceModule    string              `bson:"service_module"      yaml:"service_module"   json:"service_module"`
 	BuildName        string              `bson:"build_name"          yaml:"build_name"       json:"build_name"`
 	Image            string              `bson:"-"                   yaml:"-"                json:"image"`
+	MatrixImages     []*MatrixImage      `bson:"-"                   yaml:"-"                json:"matrix_images,omitempty"` // set instead of Image when the build job has a matrix
 	Package          string              `bson:"-"                   yaml:"-"                json:"package"`
 	KeyVals          []*KeyVal           `bson:"key_vals"            yaml:"key_vals"         json:"key_vals"`
 	Repos            []*types.Repository `bson:"repos"               yaml:"repos"            json:"repos"`
 	ShareStorageInfo *ShareStorageInfo   `bson:"share_storage_info"   yaml:"share_storage_info"   json:"share_storage_info"`
 }
 
+type MatrixImage struct {
+	// Suffix is the suffix of the key of the expanded job task which builds the image.
+	Suffix string `bson:"suffix"         yaml:"suffix"            json:"suffix"`
+	Image  string `bson:"image"          yaml:"image"             json:"image"`
+}
+
 type ZadigDeployJobSpec struct {
 	Env                string `bson:"env"                      yaml:"env"                         json:"env"`
 	DeployType         string `bson:"deploy_type"              yaml:"-"                           json:"deploy_type"`
@@ -158,12 +283,15 @@ type ZadigDeployJobSpec struct {
 	// 当 source 为 fromjob 时需要，指定部署镜像来源是上游哪一个构建任务
 	JobName          string             `bson:"job_name"             yaml:"job_name"             json:"job_name"`
 	ServiceAndImages []*ServiceAndImage `bson:"service_and_images"   yaml:"service_and_images"   json:"service_and_images"`
+	Verification     *ImageVerification `bson:"verification,omitempty" yaml:"verification,omitempty" json:"verification,omitempty"`
 }
 
 type ServiceAndImage struct {
 	ServiceName   string `bson:"service_name"        yaml:"service_name"     json:"service_name"`
 	ServiceModule string `bson:"service_module"      yaml:"service_module"   json:"service_module"`
 	Image         string `bson:"image"               yaml:"image"            json:"image"`
+	// MatrixSuffix is set if the image is built by an expanded job task of a matrix build job.
+	MatrixSuffix string `bson:"matrix_suffix,omitempty" yaml:"-"                json:"matrix_suffix,omitempty"`
 }
 
 type ZadigDistributeImageJobSpec struct {
@@ -176,10 +304,33 @@ type ZadigDistributeImageJobSpec struct {
 	TargetRegistryID string              `bson:"target_registry_id"             json:"target_registry_id"            yaml:"target_registry_id"`
 	Tatgets          []*DistributeTarget `bson:"targets"                        json:"targets"                       yaml:"targets"`
 	// unit is minute.
+	Timeout   int64         `bson:"timeout"                        json:"timeout"                       yaml:"timeout"`
+	ClusterID string        `bson:"cluster_id"                     json:"cluster_id"                    yaml:"cluster_id"`
+	Signing   *ImageSigning `bson:"signing,omitempty"              json:"signing,omitempty"             yaml:"signing,omitempty"`
+}
+
+type ZadigImageScanJobSpec struct {
+	// fromjob/runtime, `fromjob` means that the images are obtained from the upstream build or distribute job
+	Source config.DeploySourceType `bson:"source"     yaml:"source"     json:"source"`
+	// required when source is `fromjob`, specify which upstream build or distribute job the images come from
+	JobName string `bson:"job_name"                       json:"job_name"                      yaml:"job_name"`
+	// images are required when source is `runtime`
+	Targets        []*ImageScanTarget `bson:"targets"                        json:"targets"                       yaml:"targets"`
+	SBOMFormat     string             `bson:"sbom_format"                    json:"sbom_format"                   yaml:"sbom_format"`
+	FailSeverities []string           `bson:"fail_severities"                json:"fail_severities"               yaml:"fail_severities"`
+	WarnSeverities []string           `bson:"warn_severities"                json:"warn_severities"               yaml:"warn_severities"`
+	IgnoredCVEs    []string           `bson:"ignored_cves"                   json:"ignored_cves"                  yaml:"ignored_cves"`
+	// unit is minute.
 	Timeout   int64  `bson:"timeout"                        json:"timeout"                       yaml:"timeout"`
 	ClusterID string `bson:"cluster_id"                     json:"cluster_id"                    yaml:"cluster_id"`
 }
 
+type ImageScanTarget struct {
+	ServiceName   string `bson:"service_name"              yaml:"service_name"               json:"service_name"`
+	ServiceModule string `bson:"service_module"            yaml:"service_module"             json:"service_module"`
+	Image         string `bson:"image,omitempty"           yaml:"image,omitempty"            json:"image,omitempty"`
+}
+
 type DistributeTarget struct {
 	ServiceName   string `bson:"service_name"              yaml:"service_name"               json:"service_name"`
 	ServiceModule string `bson:"service_module"            yaml:"service_module"             json:"service_module"`
@@ -189,6 +340,8 @@ type DistributeTarget struct {
 	TargetImage   string `bson:"target_image,omitempty"    yaml:"-"                          json:"target_image,omitempty"`
 	// if UpdateTag was false, use SourceTag as TargetTag.
 	UpdateTag bool `bson:"update_tag"                yaml:"update_tag"                json:"update_tag"`
+	// MatrixSuffix is set if the source image is built by an expanded job task of a matrix build job.
+	MatrixSuffix string `bson:"matrix_suffix,omitempty"   yaml:"-"                          json:"matrix_suffix,omitempty"`
 }
 
 type ZadigTestingJobSpec struct {
@@ -367,6 +520,38 @@ type JobProperties struct {
 	ShareStorageInfo    *ShareStorageInfo    `bson:"share_storage_info"     json:"share_storage_info"    yaml:"share_storage_info"`
 	ShareStorageDetails []*StorageDetail     `bson:"share_storage_details"  json:"share_storage_details" yaml:"-"`
 	UseHostDockerDaemon bool                 `bson:"use_host_docker_daemon,omitempty" json:"use_host_docker_daemon,omitempty" yaml:"use_host_docker_daemon"`
+	ServiceContainers   []*ServiceContainer  `bson:"service_containers,omitempty"     json:"service_containers,omitempty"     yaml:"service_containers,omitempty"`
+}
+
+// ServiceContainer is a sidecar of the job pod like a database or a message broker, the steps of the job
+// run after all service containers are ready, and reach them by their names as hostnames.
+type ServiceContainer struct {
+	Name           string                 `bson:"name"                      json:"name"                      yaml:"name"`
+	Image          string                 `bson:"image"                     json:"image"                     yaml:"image"`
+	Envs           []*KeyVal              `bson:"envs"                      json:"envs"                      yaml:"envs"`
+	Ports          []int32                `bson:"ports"                     json:"ports"                     yaml:"ports"`
+	Command        []string               `bson:"command,omitempty"         json:"command,omitempty"         yaml:"command,omitempty"`
+	Args           []string               `bson:"args,omitempty"            json:"args,omitempty"            yaml:"args,omitempty"`
+	ReadinessProbe *ServiceReadinessProbe `bson:"readiness_probe,omitempty" json:"readiness_probe,omitempty" yaml:"readiness_probe,omitempty"`
+	// ReadyTimeout in seconds, default is 300.
+	ReadyTimeout int64 `bson:"ready_timeout,omitempty"   json:"ready_timeout,omitempty"   yaml:"ready_timeout,omitempty"`
+}
+
+type ServiceProbeType string
+
+const (
+	ServiceProbeTCP  ServiceProbeType = "tcp"
+	ServiceProbeHTTP ServiceProbeType = "http"
+	ServiceProbeExec ServiceProbeType = "exec"
+)
+
+// ServiceReadinessProbe checks whether a service container is ready, the first port is checked by tcp if it is not set.
+type ServiceReadinessProbe struct {
+	Type          ServiceProbeType `bson:"type"                     json:"type"                     yaml:"type"`
+	Port          int32            `bson:"port,omitempty"           json:"port,omitempty"           yaml:"port,omitempty"`
+	Path          string           `bson:"path,omitempty"           json:"path,omitempty"           yaml:"path,omitempty"`
+	Command       []string         `bson:"command,omitempty"        json:"command,omitempty"        yaml:"command,omitempty"`
+	PeriodSeconds int32            `bson:"period_seconds,omitempty" json:"period_seconds,omitempty" yaml:"period_seconds,omitempty"`
 }
 
 type Step struct {
@@ -374,6 +559,10 @@ type Step struct {
 	Timeout  int64           `bson:"timeout"        json:"timeout"          yaml:"timeout"`
 	StepType config.StepType `bson:"type"           json:"type"             yaml:"type"`
 	Spec     interface{}     `bson:"spec"           json:"spec"             yaml:"spec"`
+	// If is a condition expression, the step is skipped when it is evaluated to false.
+	If string `bson:"if"             json:"if,omitempty"     yaml:"if,omitempty"`
+	// RunIf decides whether the step runs according to the results of the previous steps: always, on_failure or on_success.
+	RunIf jobtypes.RunIf `bson:"run_if,omitempty" json:"run_if,omitempty" yaml:"run_if,omitempty"`
 }
 
 type Output struct {
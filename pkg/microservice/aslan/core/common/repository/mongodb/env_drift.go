@@ -0,0 +1,153 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type EnvDriftColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+type EnvDriftListOption struct {
+	ProductName string
+	EnvName     string
+	Resolved    *bool
+	Limit       int64
+}
+
+func NewEnvDriftColl() *EnvDriftColl {
+	name := models.EnvDrift{}.TableName()
+	return &EnvDriftColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *EnvDriftColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *EnvDriftColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "product_name", Value: 1},
+				bson.E{Key: "env_name", Value: 1},
+				bson.E{Key: "resolved", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "last_seen", Value: -1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+	}
+
+	_, err := c.Indexes().CreateMany(ctx, mod)
+	return err
+}
+
+// Upsert updates the unresolved drift of the same resource, a new drift is created if there isn't one.
+func (c *EnvDriftColl) Upsert(args *models.EnvDrift) (*models.EnvDrift, error) {
+	if args == nil {
+		return nil, errors.New("nil envDrift args")
+	}
+
+	now := time.Now().Unix()
+	query := bson.M{
+		"product_name":  args.ProductName,
+		"env_name":      args.EnvName,
+		"service_name":  args.ServiceName,
+		"kind":          args.Kind,
+		"resource_name": args.ResourceName,
+		"resolved":      false,
+	}
+	change := bson.M{
+		"$set": bson.M{
+			"fields":    args.Fields,
+			"policy":    args.Policy,
+			"action":    args.Action,
+			"message":   args.Message,
+			"last_seen": now,
+		},
+		"$setOnInsert": bson.M{
+			"create_time": now,
+		},
+	}
+	resp := new(models.EnvDrift)
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err := c.FindOneAndUpdate(context.TODO(), query, change, opts).Decode(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ResolveOthers marks the unresolved drifts of the environment as resolved, except the ones in ids and the ones of
+// the skipped services, which are not scanned.
+func (c *EnvDriftColl) ResolveOthers(productName, envName string, ids []primitive.ObjectID, skippedServices []string) error {
+	query := bson.M{
+		"product_name": productName,
+		"env_name":     envName,
+		"resolved":     false,
+	}
+	if len(ids) > 0 {
+		query["_id"] = bson.M{"$nin": ids}
+	}
+	if len(skippedServices) > 0 {
+		query["service_name"] = bson.M{"$nin": skippedServices}
+	}
+	change := bson.M{"$set": bson.M{"resolved": true}}
+	_, err := c.UpdateMany(context.TODO(), query, change)
+	return err
+}
+
+func (c *EnvDriftColl) List(opt *EnvDriftListOption) ([]*models.EnvDrift, error) {
+	query := bson.M{
+		"product_name": opt.ProductName,
+		"env_name":     opt.EnvName,
+	}
+	if opt.Resolved != nil {
+		query["resolved"] = *opt.Resolved
+	}
+	findOpts := options.Find().SetSort(bson.D{{"last_seen", -1}})
+	if opt.Limit > 0 {
+		findOpts.SetLimit(opt.Limit)
+	}
+	resp := make([]*models.EnvDrift, 0)
+	cursor, err := c.Collection.Find(context.TODO(), query, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
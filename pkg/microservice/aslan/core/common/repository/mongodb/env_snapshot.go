@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type EnvSnapshotColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewEnvSnapshotColl() *EnvSnapshotColl {
+	name := models.EnvSnapshot{}.TableName()
+	return &EnvSnapshotColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *EnvSnapshotColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *EnvSnapshotColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "product_name", Value: 1},
+			bson.E{Key: "env_name", Value: 1},
+			bson.E{Key: "version", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+func (c *EnvSnapshotColl) Create(args *models.EnvSnapshot) error {
+	if args == nil {
+		return errors.New("nil envSnapshot args")
+	}
+
+	_, err := c.InsertOne(context.TODO(), args)
+	return err
+}
+
+func (c *EnvSnapshotColl) Find(productName, envName string, version int64) (*models.EnvSnapshot, error) {
+	query := bson.M{"product_name": productName, "env_name": envName, "version": version}
+	resp := new(models.EnvSnapshot)
+	if err := c.FindOne(context.TODO(), query).Decode(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// List lists the snapshots of the environment, the latest one comes first.
+func (c *EnvSnapshotColl) List(productName, envName string) ([]*models.EnvSnapshot, error) {
+	query := bson.M{"product_name": productName, "env_name": envName}
+	resp := make([]*models.EnvSnapshot, 0)
+	cursor, err := c.Collection.Find(context.TODO(), query, options.Find().SetSort(bson.D{{"version", -1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *EnvSnapshotColl) Delete(productName, envName string, version int64) error {
+	query := bson.M{"product_name": productName, "env_name": envName, "version": version}
+	_, err := c.DeleteOne(context.TODO(), query)
+	return err
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type ImageScanColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewImageScanColl() *ImageScanColl {
+	name := models.ImageScan{}.TableName()
+	return &ImageScanColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *ImageScanColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *ImageScanColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "workflow_name", Value: 1},
+				bson.E{Key: "task_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "image", Value: 1},
+				bson.E{Key: "create_time", Value: -1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+	}
+
+	_, err := c.Indexes().CreateMany(ctx, mod)
+	return err
+}
+
+func (c *ImageScanColl) Create(args *models.ImageScan) error {
+	if args == nil {
+		return errors.New("nil imageScan args")
+	}
+
+	_, err := c.InsertOne(context.TODO(), args)
+	return err
+}
+
+func (c *ImageScanColl) ListByTask(workflowName string, taskID int64) ([]*models.ImageScan, error) {
+	resp := make([]*models.ImageScan, 0)
+	query := bson.M{"workflow_name": workflowName, "task_id": taskID}
+	cursor, err := c.Collection.Find(context.TODO(), query, options.Find().SetSort(bson.D{{"create_time", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// FindLatestByImage finds the latest scan result of the image.
+func (c *ImageScanColl) FindLatestByImage(image string) (*models.ImageScan, error) {
+	opts := options.FindOne().SetSort(bson.D{{"create_time", -1}})
+	resp := new(models.ImageScan)
+	if err := c.FindOne(context.TODO(), bson.M{"image": image}, opts).Decode(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
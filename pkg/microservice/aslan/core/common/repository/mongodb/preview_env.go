@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type PreviewEnvColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+type PreviewEnvFindOption struct {
+	ProjectName   string
+	RepoNamespace string
+	RepoName      string
+	PrID          int
+}
+
+func NewPreviewEnvColl() *PreviewEnvColl {
+	name := models.PreviewEnv{}.TableName()
+	return &PreviewEnvColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *PreviewEnvColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *PreviewEnvColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "project_name", Value: 1},
+				bson.E{Key: "env_name", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "project_name", Value: 1},
+				bson.E{Key: "repo_namespace", Value: 1},
+				bson.E{Key: "repo_name", Value: 1},
+				bson.E{Key: "pr_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	_, err := c.Indexes().CreateMany(ctx, mod)
+	return err
+}
+
+func (c *PreviewEnvColl) Create(args *models.PreviewEnv) error {
+	if args == nil {
+		return errors.New("nil previewEnv args")
+	}
+
+	_, err := c.InsertOne(context.TODO(), args)
+	return err
+}
+
+func (c *PreviewEnvColl) Find(opt *PreviewEnvFindOption) (*models.PreviewEnv, error) {
+	query := bson.M{
+		"project_name":   opt.ProjectName,
+		"repo_namespace": opt.RepoNamespace,
+		"repo_name":      opt.RepoName,
+		"pr_id":          opt.PrID,
+	}
+	resp := new(models.PreviewEnv)
+	if err := c.FindOne(context.TODO(), query).Decode(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// List lists the preview environments of the project, all of them are returned if projectName is empty.
+func (c *PreviewEnvColl) List(projectName string) ([]*models.PreviewEnv, error) {
+	query := bson.M{}
+	if projectName != "" {
+		query["project_name"] = projectName
+	}
+	resp := make([]*models.PreviewEnv, 0)
+	cursor, err := c.Collection.Find(context.TODO(), query, options.Find().SetSort(bson.D{{"create_time", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *PreviewEnvColl) Count(projectName string) (int64, error) {
+	return c.CountDocuments(context.TODO(), bson.M{"project_name": projectName})
+}
+
+func (c *PreviewEnvColl) UpdateLastActiveTime(projectName, envName string, lastActiveTime int64) error {
+	query := bson.M{"project_name": projectName, "env_name": envName}
+	change := bson.M{"$set": bson.M{"last_active_time": lastActiveTime}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
+func (c *PreviewEnvColl) Delete(projectName, envName string) error {
+	_, err := c.DeleteOne(context.TODO(), bson.M{"project_name": projectName, "env_name": envName})
+	return err
+}
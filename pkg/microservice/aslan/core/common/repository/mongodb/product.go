@@ -443,6 +443,44 @@ func (c *ProductColl) UpdateProductRecycleDay(envName, productName string, recyc
 	return err
 }
 
+func (c *ProductColl) UpdateDriftPolicy(envName, productName string, policy config.DriftPolicy) error {
+	query := bson.M{"env_name": envName, "product_name": productName}
+	change := bson.M{"$set": bson.M{
+		"drift_policy": policy,
+	}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+
+	return err
+}
+
+func (c *ProductColl) UpdateSleepConfig(envName, productName string, sleepConfig *models.EnvSleepConfig) error {
+	query := bson.M{"env_name": envName, "product_name": productName}
+	change := bson.M{"$set": bson.M{
+		"sleep_config": sleepConfig,
+	}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+
+	return err
+}
+
+// UpdateSleepState sets the status and the sleep state of the environment, the sleep state is removed if it is nil.
+func (c *ProductColl) UpdateSleepState(envName, productName, status string, state *models.EnvSleepState) error {
+	query := bson.M{"env_name": envName, "product_name": productName}
+	change := bson.M{"$set": bson.M{
+		"status":      status,
+		"sleep_state": state,
+	}}
+	if state == nil {
+		change = bson.M{
+			"$set":   bson.M{"status": status},
+			"$unset": bson.M{"sleep_state": ""},
+		}
+	}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+
+	return err
+}
+
 func (c *ProductColl) UpdateIsPublic(envName, productName string, isPublic bool) error {
 	query := bson.M{"env_name": envName, "product_name": productName}
 	change := bson.M{"$set": bson.M{
@@ -294,6 +294,8 @@ func (c *ProductColl) Update(productName string, args *template.Product) error {
 		"custom_image_rule":     args.CustomImageRule,
 		"delivery_version_hook": args.DeliveryVersionHook,
 		"public":                args.Public,
+		"workflow_concurrency":  args.WorkflowConcurrency,
+		"preview_env":           args.PreviewEnv,
 	}}
 
 	_, err := c.UpdateOne(context.TODO(), query, change)
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type TestCoverageListOption struct {
+	TestNames    []string
+	ProjectNames []string
+	StartTime    int64
+	EndTime      int64
+}
+
+type TestCoverageColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewTestCoverageColl() *TestCoverageColl {
+	name := models.TestCoverage{}.TableName()
+	return &TestCoverageColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *TestCoverageColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *TestCoverageColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "test_name", Value: 1},
+				bson.E{Key: "branch", Value: 1},
+				bson.E{Key: "pr", Value: 1},
+				bson.E{Key: "create_time", Value: -1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+		{
+			Keys:    bson.M{"create_time": 1},
+			Options: options.Index().SetUnique(false),
+		},
+	}
+
+	_, err := c.Indexes().CreateMany(ctx, mod)
+	return err
+}
+
+func (c *TestCoverageColl) Create(args *models.TestCoverage) error {
+	if args == nil {
+		return errors.New("nil testCoverage args")
+	}
+
+	_, err := c.InsertOne(context.TODO(), args)
+	return err
+}
+
+// FindLatestOfBranch finds the latest coverage of the branch, coverages of pull requests are not included.
+func (c *TestCoverageColl) FindLatestOfBranch(testName, branch string) (*models.TestCoverage, error) {
+	query := bson.M{"test_name": testName, "branch": branch, "pr": 0}
+	opts := options.FindOne().SetSort(bson.D{{"create_time", -1}})
+	resp := new(models.TestCoverage)
+	if err := c.FindOne(context.TODO(), query, opts).Decode(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *TestCoverageColl) List(option *TestCoverageListOption) ([]*models.TestCoverage, error) {
+	resp := make([]*models.TestCoverage, 0)
+	query := bson.M{}
+	if len(option.TestNames) > 0 {
+		query["test_name"] = bson.M{"$in": option.TestNames}
+	}
+	if len(option.ProjectNames) > 0 {
+		query["project_name"] = bson.M{"$in": option.ProjectNames}
+	}
+	if option.StartTime > 0 {
+		query["create_time"] = bson.M{"$gte": option.StartTime, "$lte": option.EndTime}
+	}
+
+	opts := options.Find().SetSort(bson.D{{"create_time", 1}})
+	cursor, err := c.Collection.Find(context.TODO(), query, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+// ErrApprovalNotUpdated is returned when the approval is not pending or the user can not operate it any more.
+var ErrApprovalNotUpdated = errors.New("approval is not updated")
+
+type WorkflowApprovalColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewWorkflowApprovalColl() *WorkflowApprovalColl {
+	name := models.WorkflowApproval{}.TableName()
+	return &WorkflowApprovalColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *WorkflowApprovalColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *WorkflowApprovalColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "workflow_name", Value: 1},
+				bson.E{Key: "task_id", Value: 1},
+				bson.E{Key: "stage_name", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "status", Value: 1},
+				bson.E{Key: "approve_users.user_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+	}
+
+	_, err := c.Indexes().CreateMany(ctx, mod)
+	return err
+}
+
+func (c *WorkflowApprovalColl) Create(args *models.WorkflowApproval) error {
+	if args == nil {
+		return errors.New("nil workflow approval")
+	}
+	args.CreateTime = time.Now().Unix()
+	args.UpdateTime = args.CreateTime
+
+	res, err := c.InsertOne(context.TODO(), args)
+	if err != nil {
+		return err
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		args.ID = id
+	}
+	return nil
+}
+
+func (c *WorkflowApprovalColl) Find(workflowName string, taskID int64, stageName string) (*models.WorkflowApproval, error) {
+	resp := new(models.WorkflowApproval)
+	query := bson.M{"workflow_name": workflowName, "task_id": taskID, "stage_name": stageName}
+	err := c.FindOne(context.TODO(), query).Decode(resp)
+	return resp, err
+}
+
+func (c *WorkflowApprovalColl) GetByID(idString string) (*models.WorkflowApproval, error) {
+	id, err := primitive.ObjectIDFromHex(idString)
+	if err != nil {
+		return nil, err
+	}
+	resp := new(models.WorkflowApproval)
+	err = c.FindOne(context.TODO(), bson.M{"_id": id}).Decode(resp)
+	return resp, err
+}
+
+// ListPending lists the pending approvals which are waiting for the decision of the user.
+func (c *WorkflowApprovalColl) ListPending(userID string) ([]*models.WorkflowApproval, error) {
+	query := bson.M{
+		"status":        config.ApprovalStatusPending,
+		"type":          config.NativeApproval,
+		"approve_users": bson.M{"$elemMatch": bson.M{"user_id": userID, "reject_or_approve": ""}},
+	}
+	opts := options.Find().SetSort(bson.D{{"create_time", -1}})
+
+	resp := make([]*models.WorkflowApproval, 0)
+	ctx := context.Background()
+	cursor, err := c.Collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &resp)
+	return resp, err
+}
+
+// Decide records the decision of an approver, it fails with ErrApprovalNotUpdated if the approval is not pending
+// or the user is not an approver who has not decided yet, so that concurrent decisions never overwrite each other.
+func (c *WorkflowApprovalColl) Decide(approval *models.WorkflowApproval, record *models.ApprovalRecord, decision config.ApproveOrReject) error {
+	query := bson.M{
+		"_id":           approval.ID,
+		"status":        config.ApprovalStatusPending,
+		"approve_users": bson.M{"$elemMatch": bson.M{"user_id": record.UserID, "reject_or_approve": ""}},
+	}
+	change := bson.M{
+		"$set": bson.M{
+			"approve_users.$.reject_or_approve": decision,
+			"approve_users.$.comment":           record.Comment,
+			"approve_users.$.operation_time":    record.Time,
+			"update_time":                       record.Time,
+		},
+		"$push": bson.M{"records": record},
+	}
+	return c.updatePending(query, change)
+}
+
+// Delegate hands the undecided slot of the user in record over to record.DelegateTo.
+func (c *WorkflowApprovalColl) Delegate(approval *models.WorkflowApproval, record *models.ApprovalRecord) error {
+	if record.DelegateTo == nil {
+		return errors.New("nil delegate user")
+	}
+	query := bson.M{
+		"_id":    approval.ID,
+		"status": config.ApprovalStatusPending,
+		"$and": bson.A{
+			bson.M{"approve_users": bson.M{"$elemMatch": bson.M{"user_id": record.UserID, "reject_or_approve": ""}}},
+			bson.M{"approve_users.user_id": bson.M{"$ne": record.DelegateTo.UserID}},
+		},
+	}
+	change := bson.M{
+		"$set": bson.M{
+			"approve_users.$.user_id":   record.DelegateTo.UserID,
+			"approve_users.$.user_name": record.DelegateTo.UserName,
+			"update_time":               record.Time,
+		},
+		"$push": bson.M{"records": record},
+	}
+	return c.updatePending(query, change)
+}
+
+// Escalate adds users to the approvers of a pending approval, it only takes effect once.
+func (c *WorkflowApprovalColl) Escalate(approval *models.WorkflowApproval, users []*models.User, record *models.ApprovalRecord) error {
+	query := bson.M{"_id": approval.ID, "status": config.ApprovalStatusPending, "escalated": false}
+	change := bson.M{
+		"$set":  bson.M{"escalated": true, "update_time": record.Time},
+		"$push": bson.M{"approve_users": bson.M{"$each": users}, "records": record},
+	}
+	return c.updatePending(query, change)
+}
+
+// AddRecord appends a record to the audit trail of the approval.
+func (c *WorkflowApprovalColl) AddRecord(approval *models.WorkflowApproval, record *models.ApprovalRecord) error {
+	query := bson.M{"_id": approval.ID}
+	change := bson.M{"$push": bson.M{"records": record}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
+func (c *WorkflowApprovalColl) SetInstanceID(approval *models.WorkflowApproval, instanceID string) error {
+	query := bson.M{"_id": approval.ID}
+	change := bson.M{"$set": bson.M{"instance_id": instanceID, "update_time": time.Now().Unix()}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
+// Conclude sets the final status of a pending approval decided by record.
+func (c *WorkflowApprovalColl) Conclude(approval *models.WorkflowApproval, status config.ApprovalStatus, record *models.ApprovalRecord) error {
+	query := bson.M{"_id": approval.ID, "status": config.ApprovalStatusPending}
+	change := bson.M{
+		"$set":  bson.M{"status": status, "update_time": record.Time},
+		"$push": bson.M{"records": record},
+	}
+	return c.updatePending(query, change)
+}
+
+// Finish sets the final status of a pending approval.
+func (c *WorkflowApprovalColl) Finish(approval *models.WorkflowApproval, status config.ApprovalStatus) error {
+	query := bson.M{"_id": approval.ID, "status": config.ApprovalStatusPending}
+	change := bson.M{"$set": bson.M{"status": status, "update_time": time.Now().Unix()}}
+	return c.updatePending(query, change)
+}
+
+func (c *WorkflowApprovalColl) updatePending(query, change bson.M) error {
+	res, err := c.UpdateOne(context.TODO(), query, change)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrApprovalNotUpdated
+	}
+	return nil
+}
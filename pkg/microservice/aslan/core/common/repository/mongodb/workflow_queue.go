@@ -124,3 +124,29 @@ func (c *WorkflowQueueColl) Update(args *models.WorkflowQueue) error {
 	_, err := c.UpdateOne(context.TODO(), query, change)
 	return err
 }
+
+// UpdatePriority sets the priority of a task waiting in the queue.
+func (c *WorkflowQueueColl) UpdatePriority(workflowName string, taskID int64, priority int) error {
+	return c.updatePending(workflowName, taskID, bson.M{"priority": priority})
+}
+
+// Preempt makes a task waiting in the queue be scheduled before all other tasks.
+func (c *WorkflowQueueColl) Preempt(workflowName string, taskID int64) error {
+	return c.updatePending(workflowName, taskID, bson.M{"preempted": true})
+}
+
+func (c *WorkflowQueueColl) updatePending(workflowName string, taskID int64, change bson.M) error {
+	query := bson.M{
+		"workflow_name": workflowName,
+		"task_id":       taskID,
+		"status":        bson.M{"$in": []config.Status{config.StatusWaiting, config.StatusBlocked}},
+	}
+	res, err := c.UpdateOne(context.TODO(), query, bson.M{"$set": change})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
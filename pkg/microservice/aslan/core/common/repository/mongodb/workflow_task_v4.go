@@ -290,3 +290,30 @@ func (c *WorkflowTaskv4Coll) ListByCursor(opt *ListWorkflowTaskV4Option) (*mongo
 
 	return c.Collection.Find(context.TODO(), query)
 }
+
+// ListFinished returns the start and end time of the latest finished tasks of the workflow.
+func (c *WorkflowTaskv4Coll) ListFinished(workflowName string, limit int) ([]*models.WorkflowTask, error) {
+	resp := make([]*models.WorkflowTask, 0)
+	query := bson.M{
+		"workflow_name": workflowName,
+		"status":        bson.M{"$in": []config.Status{config.StatusPassed, config.StatusFailed}},
+		"end_time":      bson.M{"$gt": 0},
+	}
+	opt := options.Find().
+		SetSort(bson.D{{"create_time", -1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"start_time": 1, "end_time": 1})
+
+	cursor, err := c.Collection.Find(context.TODO(), query, opt)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
+
+func (c *WorkflowTaskv4Coll) UpdatePriority(workflowName string, taskID int64, priority int) error {
+	query := bson.M{"workflow_name": workflowName, "task_id": taskID}
+	_, err := c.UpdateOne(context.TODO(), query, bson.M{"$set": bson.M{"priority": priority}})
+	return err
+}
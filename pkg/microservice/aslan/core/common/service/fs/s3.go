@@ -152,7 +152,7 @@ func DeleteArchivedFileFromS3(names []string, s3Base string, logger *zap.Sugared
 	}
 
 	s3PathList := make([]string, 0, len(names))
-	for _, name := range s3PathList {
+	for _, name := range names {
 		tarball := fmt.Sprintf("%s.tar.gz", name)
 		s3Path := filepath.Join(s3.Subfolder, s3Base, tarball)
 		s3PathList = append(s3PathList, s3Path)
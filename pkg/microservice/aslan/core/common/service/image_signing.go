@@ -0,0 +1,42 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/util/signature"
+)
+
+// GetImageSigningKey reads the PEM encoded key from the private key store, the key is only read when it is used
+// and must not be saved anywhere else.
+func GetImageSigningKey(keyID string) ([]byte, error) {
+	privateKey, err := commonrepo.NewPrivateKeyColl().Find(commonrepo.FindPrivateKeyOption{ID: keyID})
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s not found: %v", keyID, err)
+	}
+	key, err := base64.StdEncoding.DecodeString(privateKey.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode signing key %s error: %v", privateKey.Name, err)
+	}
+	if _, err := signature.PublicKey(key); err != nil {
+		return nil, fmt.Errorf("signing key %s: %v", privateKey.Name, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/krusty"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	fsservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/fs"
+	"github.com/koderover/zadig/pkg/tool/log"
+	fsutil "github.com/koderover/zadig/pkg/util/fs"
+)
+
+// imageOverlayDir is the generated kustomization which sets the images on top of the one being built.
+const imageOverlayDir = "/.zadig-images"
+
+// BuildKustomization runs kustomize build for the kustomization in dir of the tree, the images are set
+// in the same way as `kustomize edit set image` does.
+func BuildKustomization(tree fs.FS, dir string, images []kusttypes.Image) (string, error) {
+	fSys := filesys.MakeFsInMemory()
+	err := fs.WalkDir(tree, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return fSys.MkdirAll(path.Join("/", p))
+		}
+		content, err := fs.ReadFile(tree, p)
+		if err != nil {
+			return err
+		}
+		return fSys.WriteFile(path.Join("/", p), content)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to load kustomization files, err: %s", err)
+	}
+
+	target := path.Join("/", dir)
+	if len(images) > 0 {
+		kustomization := &kusttypes.Kustomization{
+			TypeMeta: kusttypes.TypeMeta{
+				APIVersion: kusttypes.KustomizationVersion,
+				Kind:       kusttypes.KustomizationKind,
+			},
+			Resources: []string{path.Join("..", dir)},
+			Images:    images,
+		}
+		content, err := yaml.Marshal(kustomization)
+		if err != nil {
+			return "", err
+		}
+		if err = fSys.MkdirAll(imageOverlayDir); err != nil {
+			return "", err
+		}
+		if err = fSys.WriteFile(path.Join(imageOverlayDir, "kustomization.yaml"), content); err != nil {
+			return "", err
+		}
+		target = imageOverlayDir
+	}
+
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, target)
+	if err != nil {
+		return "", fmt.Errorf("failed to build kustomization %s, err: %s", dir, err)
+	}
+	content, err := resources.AsYaml()
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// RenderKustomizeService builds the overlay of the environment from the kustomization files of the service revision,
+// the images of the containers which are different from the service template are set in the kustomization.
+func RenderKustomizeService(svcTmpl *commonmodels.Service, envName string, containers []*commonmodels.Container) (string, error) {
+	base, err := preloadKustomization(svcTmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to load kustomization files of service %s, err: %s", svcTmpl.ServiceName, err)
+	}
+
+	return BuildKustomization(os.DirFS(base), path.Join(svcTmpl.ServiceName, svcTmpl.Kustomize.OverlayPath(envName)), kustomizeImages(svcTmpl.Containers, containers))
+}
+
+// preloadKustomization makes sure the kustomization files of the service revision are on the disk and returns the base dir,
+// the latest files are used if the revision is not saved.
+func preloadKustomization(svcTmpl *commonmodels.Service) (string, error) {
+	s3Base := config.ObjectStorageServicePath(svcTmpl.ProductName, svcTmpl.ServiceName)
+	base := config.LocalServicePathWithRevision(svcTmpl.ProductName, svcTmpl.ServiceName, svcTmpl.Revision)
+	if ok, err := fsutil.DirExists(filepath.Join(base, svcTmpl.ServiceName)); err == nil && ok {
+		return base, nil
+	}
+	err := fsservice.DownloadAndExtractFilesFromS3(config.ServiceNameWithRevision(svcTmpl.ServiceName, svcTmpl.Revision), base, s3Base, log.SugaredLogger())
+	if err == nil {
+		return base, nil
+	}
+	log.Warnf("Failed to download kustomization of service %s revision %d, use the latest one, err: %s", svcTmpl.ServiceName, svcTmpl.Revision, err)
+
+	base = config.LocalServicePath(svcTmpl.ProductName, svcTmpl.ServiceName)
+	if ok, err := fsutil.DirExists(filepath.Join(base, svcTmpl.ServiceName)); err == nil && ok {
+		return base, nil
+	}
+	return base, fsservice.DownloadAndExtractFilesFromS3(svcTmpl.ServiceName, base, s3Base, log.SugaredLogger())
+}
+
+// kustomizeImages returns the images to set for the containers whose image is changed, e.g. by a build job.
+func kustomizeImages(ori []*commonmodels.Container, replace []*commonmodels.Container) []kusttypes.Image {
+	replaceMap := make(map[string]string)
+	for _, container := range replace {
+		replaceMap[container.Name] = container.Image
+	}
+
+	images := make([]kusttypes.Image, 0)
+	for _, container := range ori {
+		image, ok := replaceMap[container.Name]
+		if !ok || image == "" || image == container.Image {
+			continue
+		}
+		name, _, _ := splitImage(container.Image)
+		newName, tag, digest := splitImage(image)
+		images = append(images, kusttypes.Image{Name: name, NewName: newName, NewTag: tag, Digest: digest})
+	}
+	return images
+}
+
+// splitImage splits the image into the name, tag and digest.
+func splitImage(image string) (string, string, string) {
+	if i := strings.Index(image, "@"); i > 0 {
+		name, _, _ := splitImage(image[:i])
+		return name, "", image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:], ""
+	}
+	return image, "", ""
+}
@@ -213,6 +213,15 @@ func RenderEnvService(prod *commonmodels.Product, render *commonmodels.RenderSet
 		return "", err
 	}
 
+	if svcTmpl.Kustomize != nil {
+		parsedYaml, err := RenderKustomizeService(svcTmpl, prod.EnvName, service.Containers)
+		if err != nil {
+			log.Errorf("failed to render kustomize service %s, err: %s", svcTmpl.ServiceName, err)
+			return "", err
+		}
+		return ParseSysKeys(prod.Namespace, prod.EnvName, prod.ProductName, service.ServiceName, parsedYaml), nil
+	}
+
 	// Note only the keys in TemplateService.ServiceVar can work
 
 	parsedYaml, err := RenderServiceYaml(svcTmpl.Yaml, prod.ProductName, svcTmpl.ServiceName, render, svcTmpl.ServiceVars, svcTmpl.VariableYaml)
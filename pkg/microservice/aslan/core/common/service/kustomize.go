@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"io/fs"
+	"path"
+
+	"github.com/27149chen/afero"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	fsservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/fs"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/kube"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+// LoadKustomizeService downloads the kustomization files of the service from the code host and
+// builds the default kustomization, the root dir of the returned files is the service name.
+func LoadKustomizeService(svc *commonmodels.Service) (fs.FS, string, error) {
+	tree, err := fsservice.DownloadFilesFromSource(
+		&fsservice.DownloadFromSourceArgs{CodehostID: svc.CodehostID, Owner: svc.RepoOwner, Namespace: svc.RepoNamespace, Repo: svc.RepoName, Path: svc.LoadPath, Branch: svc.BranchName},
+		func(afero.Fs) (string, error) {
+			return svc.ServiceName, nil
+		})
+	if err != nil {
+		log.Errorf("Failed to download kustomization files of service %s, err: %s", svc.ServiceName, err)
+		return nil, "", err
+	}
+
+	yaml, err := kube.BuildKustomization(tree, path.Join(svc.ServiceName, svc.Kustomize.Path), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return tree, yaml, nil
+}
+
+// SaveKustomizeService saves the kustomization files as the latest ones and the ones of the service revision,
+// the environments are rendered from the files of the revision they are using.
+func SaveKustomizeService(svc *commonmodels.Service, tree fs.FS) error {
+	var copies []string
+	if svc.Revision > 0 {
+		copies = append(copies, config.ServiceNameWithRevision(svc.ServiceName, svc.Revision))
+	}
+	if err := SaveAndUploadService(svc.ProductName, svc.ServiceName, copies, tree); err != nil {
+		log.Errorf("Failed to save kustomization files of service %s in project %s, err: %s", svc.ServiceName, svc.ProductName, err)
+		return err
+	}
+	return nil
+}
@@ -121,6 +121,28 @@ func (s *Service) SendErrWebhookComment(
 	return notification, nil
 }
 
+// UpdatePreviewEnvComment comments the preview environment on the pull request, the notification is saved
+// when it is commented for the first time so that the comment is updated afterwards.
+func (s *Service) UpdatePreviewEnvComment(notification *models.Notification, logger *zap.SugaredLogger) error {
+	if err := s.Client.Comment(notification); err != nil {
+		logger.Errorf("failed to comment to %s %v", notification.ToString(), err)
+		return err
+	}
+
+	if notification.ID.IsZero() {
+		if err := s.Coll.Create(notification); err != nil {
+			logger.Errorf("failed to save %s %v", notification.ToString(), err)
+			return err
+		}
+		return nil
+	}
+	if err := s.Coll.Upsert(notification); err != nil {
+		logger.Errorf("failed to save %s %v", notification.ToString(), err)
+		return err
+	}
+	return nil
+}
+
 func convertTaskStatusToNotificationTaskStatus(status config.Status) config.TaskStatus {
 	switch status {
 	case config.StatusWaiting:
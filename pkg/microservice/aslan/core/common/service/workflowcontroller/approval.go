@@ -0,0 +1,345 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/instantmessage"
+)
+
+// ApproveStage records the decision of a user on a native approval, the decision is persisted at once,
+// so it does not matter which aslan replica runs the workflow task.
+func ApproveStage(workflowName, stageName, userName, userID, comment string, taskID int64, approve bool) error {
+	approval, err := findPendingApproval(workflowName, stageName, taskID)
+	if err != nil {
+		return err
+	}
+	user := findApprovalUser(approval.ApproveUsers, userID)
+	if user == nil {
+		return fmt.Errorf("user %s has no authority to approve", userName)
+	}
+	if user.RejectOrApprove != "" {
+		return fmt.Errorf("%s have %s already", userName, user.RejectOrApprove)
+	}
+
+	record := &commonmodels.ApprovalRecord{
+		Action:   config.ApprovalActionApprove,
+		UserID:   userID,
+		UserName: userName,
+		Comment:  comment,
+		Time:     time.Now().Unix(),
+	}
+	decision := config.Approve
+	if !approve {
+		record.Action = config.ApprovalActionReject
+		decision = config.Reject
+	}
+	err = mongodb.NewWorkflowApprovalColl().Decide(approval, record, decision)
+	if err == mongodb.ErrApprovalNotUpdated {
+		return fmt.Errorf("approval of workflow %s ID %d stage %s has been changed, please refresh and retry", workflowName, taskID, stageName)
+	}
+	return err
+}
+
+// DelegateApproval hands the approval of a user over to another user, who takes the place of the user in the approvers.
+func DelegateApproval(workflowName, stageName, userName, userID, comment string, taskID int64, delegateTo *commonmodels.User) error {
+	if delegateTo == nil || delegateTo.UserID == "" {
+		return errors.New("delegate user is empty")
+	}
+	if delegateTo.UserID == userID {
+		return errors.New("can not delegate approval to yourself")
+	}
+	approval, err := findPendingApproval(workflowName, stageName, taskID)
+	if err != nil {
+		return err
+	}
+	user := findApprovalUser(approval.ApproveUsers, userID)
+	if user == nil {
+		return fmt.Errorf("user %s has no authority to approve", userName)
+	}
+	if user.RejectOrApprove != "" {
+		return fmt.Errorf("%s have %s already", userName, user.RejectOrApprove)
+	}
+	if findApprovalUser(approval.ApproveUsers, delegateTo.UserID) != nil {
+		return fmt.Errorf("%s is an approver already", delegateTo.UserName)
+	}
+
+	record := &commonmodels.ApprovalRecord{
+		Action:     config.ApprovalActionDelegate,
+		UserID:     userID,
+		UserName:   userName,
+		Comment:    comment,
+		DelegateTo: &commonmodels.User{UserID: delegateTo.UserID, UserName: delegateTo.UserName},
+		Time:       time.Now().Unix(),
+	}
+	err = mongodb.NewWorkflowApprovalColl().Delegate(approval, record)
+	if err == mongodb.ErrApprovalNotUpdated {
+		return fmt.Errorf("approval of workflow %s ID %d stage %s has been changed, please refresh and retry", workflowName, taskID, stageName)
+	}
+	return err
+}
+
+func ListPendingApprovals(userID string) ([]*commonmodels.WorkflowApproval, error) {
+	return mongodb.NewWorkflowApprovalColl().ListPending(userID)
+}
+
+func findPendingApproval(workflowName, stageName string, taskID int64) (*commonmodels.WorkflowApproval, error) {
+	approval, err := mongodb.NewWorkflowApprovalColl().Find(workflowName, taskID, stageName)
+	if err != nil {
+		return nil, fmt.Errorf("workflow %s ID %d stage %s do not need approve", workflowName, taskID, stageName)
+	}
+	if approval.Type != config.NativeApproval {
+		return nil, fmt.Errorf("workflow %s ID %d stage %s is approved in %s", workflowName, taskID, stageName, approval.Type)
+	}
+	if approval.Status != config.ApprovalStatusPending {
+		return nil, fmt.Errorf("approval of workflow %s ID %d stage %s is %s already", workflowName, taskID, stageName, approval.Status)
+	}
+	return approval, nil
+}
+
+func findApprovalUser(users []*commonmodels.User, userID string) *commonmodels.User {
+	for _, user := range users {
+		if user.UserID == userID {
+			return user
+		}
+	}
+	return nil
+}
+
+func waitForNativeApprove(ctx context.Context, stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) error {
+	approval := stage.Approval.NativeApproval
+	if approval == nil {
+		return errors.New("waitForApprove: native approval data not found")
+	}
+	if approval.Timeout == 0 {
+		approval.Timeout = 60
+	}
+	approvalColl := mongodb.NewWorkflowApprovalColl()
+	defer ack()
+
+	record, err := approvalColl.Find(workflowCtx.WorkflowName, workflowCtx.TaskID, stage.Name)
+	switch {
+	case err == mongo.ErrNoDocuments:
+		record = newWorkflowApproval(stage, workflowCtx)
+		if err := approvalColl.Create(record); err != nil {
+			stage.Status = config.StatusFailed
+			return errors.Wrap(err, "create approval")
+		}
+		sendApproveNotifications(workflowCtx, logger)
+	case err != nil:
+		stage.Status = config.StatusFailed
+		return errors.Wrap(err, "find approval")
+	default:
+		// the task is resumed after aslan restarted, keep waiting for the persisted approval.
+		logger.Infof("resume approval of stage %s, status: %s", stage.Name, record.Status)
+		if err := approvalColl.AddRecord(record, &commonmodels.ApprovalRecord{Action: config.ApprovalActionResume, Time: time.Now().Unix()}); err != nil {
+			logger.Errorf("add approval record error: %v", err)
+		}
+	}
+
+	deadline := record.CreateTime + int64(approval.Timeout)*60
+	latestApproveCount := 0
+	for {
+		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			stage.Status = config.StatusCancelled
+			finishApproval(approvalColl, record, config.ApprovalStatusCancelled, logger)
+			return fmt.Errorf("workflow was canceled")
+		default:
+		}
+
+		if latest, err := approvalColl.Find(workflowCtx.WorkflowName, workflowCtx.TaskID, stage.Name); err != nil {
+			logger.Errorf("find approval of stage %s error: %v", stage.Name, err)
+		} else {
+			record = latest
+		}
+		approval.ApproveUsers = record.ApproveUsers
+		if record.Status != config.ApprovalStatusPending {
+			return setStageApprovalResult(stage, record.Status)
+		}
+
+		approved, approveCount, err := checkApproval(record)
+		if err != nil {
+			status := finishApproval(approvalColl, record, config.ApprovalStatusRejected, logger)
+			approval.ApproveUsers = record.ApproveUsers
+			// keep the name of the rejecting approver in the error.
+			if resultErr := setStageApprovalResult(stage, status); status != config.ApprovalStatusRejected {
+				return resultErr
+			}
+			return err
+		}
+		if approved {
+			status := finishApproval(approvalColl, record, config.ApprovalStatusApproved, logger)
+			approval.ApproveUsers = record.ApproveUsers
+			return setStageApprovalResult(stage, status)
+		}
+		if approveCount != latestApproveCount {
+			ack()
+			latestApproveCount = approveCount
+		}
+
+		now := time.Now().Unix()
+		if now >= deadline {
+			status := finishApproval(approvalColl, record, config.ApprovalStatusTimeout, logger)
+			approval.ApproveUsers = record.ApproveUsers
+			return setStageApprovalResult(stage, status)
+		}
+		if needEscalation(record, now) {
+			escalateApproval(record, workflowCtx, logger)
+			ack()
+		}
+	}
+}
+
+func newWorkflowApproval(stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx) *commonmodels.WorkflowApproval {
+	approval := stage.Approval.NativeApproval
+	approveUsers := make([]*commonmodels.User, 0, len(approval.ApproveUsers))
+	for _, user := range approval.ApproveUsers {
+		approveUsers = append(approveUsers, &commonmodels.User{UserID: user.UserID, UserName: user.UserName})
+	}
+	return &commonmodels.WorkflowApproval{
+		ProjectName:         workflowCtx.ProjectName,
+		WorkflowName:        workflowCtx.WorkflowName,
+		WorkflowDisplayName: workflowCtx.WorkflowDisplayName,
+		TaskID:              workflowCtx.TaskID,
+		StageName:           stage.Name,
+		Type:                config.NativeApproval,
+		Description:         stage.Approval.Description,
+		Status:              config.ApprovalStatusPending,
+		ApproveUsers:        approveUsers,
+		NeededApprovers:     approval.NeededApprovers,
+		Timeout:             approval.Timeout,
+		EscalateAfter:       approval.EscalateAfter,
+		EscalateUsers:       approval.EscalateUsers,
+		Records:             []*commonmodels.ApprovalRecord{{Action: config.ApprovalActionCreate, Time: time.Now().Unix()}},
+	}
+}
+
+// checkApproval returns an error if any approver rejected, and whether enough approvers approved.
+func checkApproval(approval *commonmodels.WorkflowApproval) (bool, int, error) {
+	approveCount := 0
+	for _, user := range approval.ApproveUsers {
+		if user.RejectOrApprove == config.Reject {
+			return false, approveCount, fmt.Errorf("%s reject this task", user.UserName)
+		}
+		if user.RejectOrApprove == config.Approve {
+			approveCount++
+		}
+	}
+	return approveCount >= approval.NeededApprovers, approveCount, nil
+}
+
+// needEscalation returns whether the pending approval has waited long enough to be escalated.
+func needEscalation(approval *commonmodels.WorkflowApproval, now int64) bool {
+	return approval.EscalateAfter > 0 && !approval.Escalated && now >= approval.CreateTime+int64(approval.EscalateAfter)*60
+}
+
+// escalationUsers returns the escalate users who are not approvers yet.
+func escalationUsers(approval *commonmodels.WorkflowApproval) []*commonmodels.User {
+	users := []*commonmodels.User{}
+	for _, user := range approval.EscalateUsers {
+		if findApprovalUser(approval.ApproveUsers, user.UserID) == nil && findApprovalUser(users, user.UserID) == nil {
+			users = append(users, &commonmodels.User{UserID: user.UserID, UserName: user.UserName})
+		}
+	}
+	return users
+}
+
+func escalateApproval(approval *commonmodels.WorkflowApproval, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger) {
+	users := escalationUsers(approval)
+	record := &commonmodels.ApprovalRecord{Action: config.ApprovalActionEscalate, Time: time.Now().Unix()}
+	if err := mongodb.NewWorkflowApprovalColl().Escalate(approval, users, record); err != nil {
+		logger.Errorf("escalate approval of stage %s error: %v", approval.StageName, err)
+		return
+	}
+	logger.Infof("approval of stage %s is escalated to %d users", approval.StageName, len(users))
+	sendApproveNotifications(workflowCtx, logger)
+}
+
+// approvalStore is the part of the approval collection used to finish an approval.
+type approvalStore interface {
+	Finish(approval *commonmodels.WorkflowApproval, status config.ApprovalStatus) error
+	GetByID(idString string) (*commonmodels.WorkflowApproval, error)
+}
+
+// finishApproval sets the final status of a pending approval and returns the status it ends up with. If the approval
+// has been concluded concurrently, e.g. by a webhook callback, the approval is reloaded and its final status is returned.
+func finishApproval(store approvalStore, approval *commonmodels.WorkflowApproval, status config.ApprovalStatus, logger *zap.SugaredLogger) config.ApprovalStatus {
+	err := store.Finish(approval, status)
+	if err == nil {
+		approval.Status = status
+		return status
+	}
+	if err != mongodb.ErrApprovalNotUpdated {
+		logger.Errorf("finish approval of stage %s error: %v", approval.StageName, err)
+		return status
+	}
+	latest, err := store.GetByID(approval.ID.Hex())
+	if err != nil {
+		logger.Errorf("reload approval of stage %s error: %v", approval.StageName, err)
+		return status
+	}
+	*approval = *latest
+	if approval.Status == config.ApprovalStatusPending {
+		// Finish only fails to update an approval which is not pending, this should never happen.
+		logger.Errorf("approval of stage %s is still pending after it is finished", approval.StageName)
+		return status
+	}
+	return approval.Status
+}
+
+// setStageApprovalResult sets the status of the stage by the final status of its approval, it returns nil only if
+// the approval is approved.
+func setStageApprovalResult(stage *commonmodels.StageTask, status config.ApprovalStatus) error {
+	nativeApproval := stage.Approval.NativeApproval
+	switch status {
+	case config.ApprovalStatusApproved:
+		if nativeApproval != nil {
+			nativeApproval.RejectOrApprove = config.Approve
+		}
+		return nil
+	case config.ApprovalStatusRejected:
+		stage.Status = config.StatusReject
+		if nativeApproval != nil {
+			nativeApproval.RejectOrApprove = config.Reject
+		}
+		return errors.New("approval has been rejected")
+	case config.ApprovalStatusTimeout:
+		stage.Status = config.StatusCancelled
+		return errors.New("workflow timeout")
+	default:
+		stage.Status = config.StatusCancelled
+		return fmt.Errorf("approval is %s", status)
+	}
+}
+
+func sendApproveNotifications(workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger) {
+	if err := instantmessage.NewWeChatClient().SendWorkflowTaskAproveNotifications(workflowCtx.WorkflowName, workflowCtx.TaskID); err != nil {
+		logger.Errorf("send approve notification failed, error: %v", err)
+	}
+}
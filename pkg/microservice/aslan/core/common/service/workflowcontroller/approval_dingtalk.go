@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/tool/dingtalk"
+)
+
+const defaultDingTalkFormName = "审批内容"
+
+type dingTalkApprovalProvider struct {
+	imApp  *commonmodels.IMApp
+	client *dingtalk.Client
+}
+
+func newDingTalkApprovalProvider(imApp *commonmodels.IMApp) *dingTalkApprovalProvider {
+	return &dingTalkApprovalProvider{
+		imApp:  imApp,
+		client: dingtalk.NewClient(imApp.AppID, imApp.AppSecret),
+	}
+}
+
+func (p *dingTalkApprovalProvider) Create(approval *commonmodels.WorkflowApproval, workflowCtx *commonmodels.WorkflowTaskCtx) (string, error) {
+	originator, err := p.client.GetUserIDByMobile(workflowCtx.WorkflowTaskCreatorMobile)
+	if err != nil {
+		return "", errors.Wrapf(err, "get dingtalk user by mobile-%s", workflowCtx.WorkflowTaskCreatorMobile)
+	}
+	actionType := dingtalk.ApprovalActionOr
+	allApprove, err := needAllApprovers(approval)
+	if err != nil {
+		return "", err
+	}
+	if allApprove {
+		actionType = dingtalk.ApprovalActionAnd
+	}
+	userIDs := []string{}
+	for _, user := range approval.ApproveUsers {
+		userIDs = append(userIDs, user.UserID)
+	}
+	formName := p.imApp.DingTalkFormName
+	if formName == "" {
+		formName = defaultDingTalkFormName
+	}
+	return p.client.CreateApprovalInstance(&dingtalk.CreateApprovalInstanceArgs{
+		ProcessCode:      p.imApp.DingTalkProcessCode,
+		OriginatorUserID: originator,
+		Approvers:        []*dingtalk.Approver{{ActionType: actionType, UserIDs: userIDs}},
+		FormValues: []*dingtalk.FormComponentValue{{
+			Name:  formName,
+			Value: approvalContent(workflowCtx, approval.StageName, approval.Description),
+		}},
+	})
+}
+
+func (p *dingTalkApprovalProvider) Check(approval *commonmodels.WorkflowApproval, users []*commonmodels.User) (config.ApprovalStatus, error) {
+	instance, err := p.client.GetApprovalInstance(approval.InstanceID)
+	if err != nil {
+		return config.ApprovalStatusPending, err
+	}
+	for _, record := range instance.OperationRecords {
+		switch {
+		case strings.EqualFold(record.Result, dingtalk.InstanceResultAgree):
+			updateApprovalUser(users, record.UserID, config.Approve, record.Remark, dingtalk.ParseRecordTime(record.Date))
+		case strings.EqualFold(record.Result, dingtalk.InstanceResultRefuse):
+			updateApprovalUser(users, record.UserID, config.Reject, record.Remark, dingtalk.ParseRecordTime(record.Date))
+		}
+	}
+
+	switch instance.Status {
+	case dingtalk.InstanceStatusCompleted:
+		if instance.Result == dingtalk.InstanceResultAgree {
+			return config.ApprovalStatusApproved, nil
+		}
+		return config.ApprovalStatusRejected, nil
+	case dingtalk.InstanceStatusTerminated:
+		return config.ApprovalStatusCancelled, nil
+	default:
+		return config.ApprovalStatusPending, nil
+	}
+}
+
+func (p *dingTalkApprovalProvider) Cancel(approval *commonmodels.WorkflowApproval) error {
+	return p.client.TerminateApprovalInstance(approval.InstanceID, "Zadig 工作流任务已取消或审批超时")
+}
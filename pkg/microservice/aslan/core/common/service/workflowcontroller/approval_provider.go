@@ -0,0 +1,202 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+)
+
+// external systems are not checked every second to avoid hitting their rate limits.
+const externalApprovalCheckInterval = 5 * time.Second
+
+// approvalProvider runs approvals in an external approval system.
+type approvalProvider interface {
+	// Create starts an approval instance in the external system and returns the id of it.
+	Create(approval *commonmodels.WorkflowApproval, workflowCtx *commonmodels.WorkflowTaskCtx) (string, error)
+	// Check returns the status of the approval instance, the decisions of users are updated in users.
+	Check(approval *commonmodels.WorkflowApproval, users []*commonmodels.User) (config.ApprovalStatus, error)
+	// Cancel withdraws the approval instance when the task is cancelled or the approval timed out.
+	Cancel(approval *commonmodels.WorkflowApproval) error
+}
+
+func newApprovalProvider(approvalType config.ApprovalType, imAppID string) (approvalProvider, error) {
+	imApp, err := mongodb.NewIMAppColl().GetByID(context.Background(), imAppID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get im app %s", imAppID)
+	}
+	switch approvalType {
+	case config.DingTalkApproval:
+		return newDingTalkApprovalProvider(imApp), nil
+	case config.WeComApproval:
+		return newWeComApprovalProvider(imApp), nil
+	case config.WebhookApproval:
+		return newWebhookApprovalProvider(imApp), nil
+	default:
+		return nil, fmt.Errorf("approval type %s has no provider", approvalType)
+	}
+}
+
+// waitForExternalApprove waits for approvals handled by approval providers, the approval is persisted like the native one,
+// so the task can be resumed after aslan restarted.
+func waitForExternalApprove(ctx context.Context, stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) error {
+	approval := stage.Approval.GetExternalApproval()
+	if approval == nil {
+		stage.Status = config.StatusFailed
+		return fmt.Errorf("waitForApprove: %s approval data not found", stage.Approval.Type)
+	}
+	if approval.Timeout == 0 {
+		approval.Timeout = 60
+	}
+	provider, err := newApprovalProvider(stage.Approval.Type, approval.ApprovalID)
+	if err != nil {
+		stage.Status = config.StatusFailed
+		return err
+	}
+	approvalColl := mongodb.NewWorkflowApprovalColl()
+	defer ack()
+
+	record, err := approvalColl.Find(workflowCtx.WorkflowName, workflowCtx.TaskID, stage.Name)
+	switch {
+	case err == mongo.ErrNoDocuments:
+		record = newExternalWorkflowApproval(stage, workflowCtx, approval)
+		if err := approvalColl.Create(record); err != nil {
+			stage.Status = config.StatusFailed
+			return errors.Wrap(err, "create approval")
+		}
+		instanceID, err := provider.Create(record, workflowCtx)
+		if err != nil {
+			stage.Status = config.StatusFailed
+			finishApproval(approvalColl, record, config.ApprovalStatusCancelled, logger)
+			return errors.Wrapf(err, "create %s approval instance", stage.Approval.Type)
+		}
+		record.InstanceID = instanceID
+		if err := approvalColl.SetInstanceID(record, instanceID); err != nil {
+			logger.Errorf("set instance id of approval %s error: %v", record.ID.Hex(), err)
+		}
+		logger.Infof("create %s approval instance %s of stage %s", stage.Approval.Type, instanceID, stage.Name)
+		sendApproveNotifications(workflowCtx, logger)
+	case err != nil:
+		stage.Status = config.StatusFailed
+		return errors.Wrap(err, "find approval")
+	default:
+		if record.InstanceID == "" {
+			stage.Status = config.StatusFailed
+			finishApproval(approvalColl, record, config.ApprovalStatusCancelled, logger)
+			return errors.New("approval instance was not created")
+		}
+		logger.Infof("resume %s approval instance %s of stage %s", record.Type, record.InstanceID, stage.Name)
+		if err := approvalColl.AddRecord(record, &commonmodels.ApprovalRecord{Action: config.ApprovalActionResume, Time: time.Now().Unix()}); err != nil {
+			logger.Errorf("add approval record error: %v", err)
+		}
+	}
+
+	deadline := record.CreateTime + int64(approval.Timeout)*60
+	lastCheck := time.Time{}
+	for {
+		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			stage.Status = config.StatusCancelled
+			cancelExternalApproval(provider, record, logger)
+			finishApproval(approvalColl, record, config.ApprovalStatusCancelled, logger)
+			return fmt.Errorf("workflow was canceled")
+		default:
+		}
+
+		if time.Since(lastCheck) >= externalApprovalCheckInterval {
+			lastCheck = time.Now()
+			status, err := provider.Check(record, approval.ApproveUsers)
+			if err != nil {
+				logger.Errorf("check %s approval instance %s error: %v", record.Type, record.InstanceID, err)
+			}
+			switch status {
+			case config.ApprovalStatusApproved, config.ApprovalStatusRejected, config.ApprovalStatusCancelled:
+				return setStageApprovalResult(stage, finishApproval(approvalColl, record, status, logger))
+			}
+		}
+
+		if time.Now().Unix() >= deadline {
+			status := finishApproval(approvalColl, record, config.ApprovalStatusTimeout, logger)
+			if status == config.ApprovalStatusTimeout {
+				cancelExternalApproval(provider, record, logger)
+			}
+			return setStageApprovalResult(stage, status)
+		}
+	}
+}
+
+func newExternalWorkflowApproval(stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, approval *commonmodels.ExternalApproval) *commonmodels.WorkflowApproval {
+	approveUsers := make([]*commonmodels.User, 0, len(approval.ApproveUsers))
+	for _, user := range approval.ApproveUsers {
+		approveUsers = append(approveUsers, &commonmodels.User{UserID: user.UserID, UserName: user.UserName})
+	}
+	return &commonmodels.WorkflowApproval{
+		ProjectName:         workflowCtx.ProjectName,
+		WorkflowName:        workflowCtx.WorkflowName,
+		WorkflowDisplayName: workflowCtx.WorkflowDisplayName,
+		TaskID:              workflowCtx.TaskID,
+		StageName:           stage.Name,
+		Type:                stage.Approval.Type,
+		IMAppID:             approval.ApprovalID,
+		Description:         stage.Approval.Description,
+		Status:              config.ApprovalStatusPending,
+		ApproveUsers:        approveUsers,
+		NeededApprovers:     approval.NeededApprovers,
+		Timeout:             approval.Timeout,
+		Records:             []*commonmodels.ApprovalRecord{{Action: config.ApprovalActionCreate, Time: time.Now().Unix()}},
+	}
+}
+
+func cancelExternalApproval(provider approvalProvider, approval *commonmodels.WorkflowApproval, logger *zap.SugaredLogger) {
+	if err := provider.Cancel(approval); err != nil {
+		logger.Errorf("cancel %s approval instance %s error: %v", approval.Type, approval.InstanceID, err)
+	}
+}
+
+// updateApprovalUser records the decision of the user whose id is userID.
+func updateApprovalUser(users []*commonmodels.User, userID string, decision config.ApproveOrReject, comment string, operationTime int64) {
+	for _, user := range users {
+		if user.UserID == userID {
+			user.RejectOrApprove = decision
+			user.Comment = comment
+			user.OperationTime = operationTime
+		}
+	}
+}
+
+// needAllApprovers returns whether all approvers must approve, the approval systems support either any one or
+// all of the approvers, the other numbers of needed approvers are rejected.
+func needAllApprovers(approval *commonmodels.WorkflowApproval) (bool, error) {
+	switch {
+	case approval.NeededApprovers <= 1:
+		return false, nil
+	case approval.NeededApprovers == len(approval.ApproveUsers):
+		return true, nil
+	default:
+		return false, fmt.Errorf("%s approval needs 1 or all of the %d approvers, %d is not supported", approval.Type, len(approval.ApproveUsers), approval.NeededApprovers)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+// fakeApprovalStore keeps a single approval, it can conclude the approval before Finish to simulate a concurrent
+// decision.
+type fakeApprovalStore struct {
+	approval    *commonmodels.WorkflowApproval
+	concurrent  config.ApprovalStatus
+	finishCalls int
+}
+
+func (s *fakeApprovalStore) Finish(approval *commonmodels.WorkflowApproval, status config.ApprovalStatus) error {
+	s.finishCalls++
+	if s.concurrent != "" {
+		s.approval.Status = s.concurrent
+	}
+	if s.approval.Status != config.ApprovalStatusPending {
+		return mongodb.ErrApprovalNotUpdated
+	}
+	s.approval.Status = status
+	return nil
+}
+
+func (s *fakeApprovalStore) GetByID(idString string) (*commonmodels.WorkflowApproval, error) {
+	latest := *s.approval
+	return &latest, nil
+}
+
+var _ = Describe("Testing workflow approval", func() {
+
+	logger := log.NopSugaredLogger()
+
+	newStage := func() *commonmodels.StageTask {
+		return &commonmodels.StageTask{
+			Name:     "stage",
+			Status:   config.StatusRunning,
+			Approval: &commonmodels.Approval{NativeApproval: &commonmodels.NativeApproval{}},
+		}
+	}
+	newStore := func(concurrent config.ApprovalStatus) (*fakeApprovalStore, *commonmodels.WorkflowApproval) {
+		id := primitive.NewObjectID()
+		store := &fakeApprovalStore{
+			approval:   &commonmodels.WorkflowApproval{ID: id, StageName: "stage", Status: config.ApprovalStatusPending},
+			concurrent: concurrent,
+		}
+		return store, &commonmodels.WorkflowApproval{ID: id, StageName: "stage", Status: config.ApprovalStatusPending}
+	}
+
+	It("should finish the pending approval with the status", func() {
+		store, record := newStore("")
+		Expect(finishApproval(store, record, config.ApprovalStatusApproved, logger)).To(Equal(config.ApprovalStatusApproved))
+		Expect(store.approval.Status).To(Equal(config.ApprovalStatusApproved))
+	})
+
+	It("should take the status concluded concurrently", func() {
+		for _, c := range []struct {
+			finish     config.ApprovalStatus
+			concurrent config.ApprovalStatus
+		}{
+			{finish: config.ApprovalStatusTimeout, concurrent: config.ApprovalStatusApproved},
+			{finish: config.ApprovalStatusApproved, concurrent: config.ApprovalStatusRejected},
+			{finish: config.ApprovalStatusRejected, concurrent: config.ApprovalStatusApproved},
+			{finish: config.ApprovalStatusCancelled, concurrent: config.ApprovalStatusTimeout},
+		} {
+			store, record := newStore(c.concurrent)
+			Expect(finishApproval(store, record, c.finish, logger)).To(Equal(c.concurrent))
+			Expect(record.Status).To(Equal(c.concurrent))
+			Expect(store.finishCalls).To(Equal(1))
+		}
+	})
+
+	It("should set the stage by the final status of the approval", func() {
+		stage := newStage()
+		Expect(setStageApprovalResult(stage, config.ApprovalStatusApproved)).ShouldNot(HaveOccurred())
+		Expect(stage.Status).To(Equal(config.StatusRunning))
+		Expect(stage.Approval.NativeApproval.RejectOrApprove).To(Equal(config.Approve))
+
+		stage = newStage()
+		Expect(setStageApprovalResult(stage, config.ApprovalStatusRejected)).Should(HaveOccurred())
+		Expect(stage.Status).To(Equal(config.StatusReject))
+		Expect(stage.Approval.NativeApproval.RejectOrApprove).To(Equal(config.Reject))
+
+		for _, status := range []config.ApprovalStatus{config.ApprovalStatusTimeout, config.ApprovalStatusCancelled} {
+			stage = newStage()
+			Expect(setStageApprovalResult(stage, status)).Should(HaveOccurred())
+			Expect(stage.Status).To(Equal(config.StatusCancelled))
+		}
+	})
+
+	It("should approve once enough approvers approved and reject on any rejection", func() {
+		approval := &commonmodels.WorkflowApproval{
+			NeededApprovers: 2,
+			ApproveUsers: []*commonmodels.User{
+				{UserID: "a", RejectOrApprove: config.Approve},
+				{UserID: "b"},
+				{UserID: "c"},
+			},
+		}
+		approved, count, err := checkApproval(approval)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(approved).To(BeFalse())
+		Expect(count).To(Equal(1))
+
+		approval.ApproveUsers[1].RejectOrApprove = config.Approve
+		approved, count, err = checkApproval(approval)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(approved).To(BeTrue())
+		Expect(count).To(Equal(2))
+
+		approval.ApproveUsers[2].RejectOrApprove = config.Reject
+		_, _, err = checkApproval(approval)
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should escalate the pending approval once after the escalation time", func() {
+		approval := &commonmodels.WorkflowApproval{CreateTime: 1000, EscalateAfter: 10}
+		Expect(needEscalation(approval, 1000+10*60-1)).To(BeFalse())
+		Expect(needEscalation(approval, 1000+10*60)).To(BeTrue())
+
+		approval.Escalated = true
+		Expect(needEscalation(approval, 1000+10*60)).To(BeFalse())
+		Expect(needEscalation(&commonmodels.WorkflowApproval{CreateTime: 1000}, 1000000)).To(BeFalse())
+	})
+
+	It("should escalate to the users who are not approvers yet", func() {
+		approval := &commonmodels.WorkflowApproval{
+			ApproveUsers:  []*commonmodels.User{{UserID: "a"}, {UserID: "b"}},
+			EscalateUsers: []*commonmodels.User{{UserID: "b"}, {UserID: "c", UserName: "C"}, {UserID: "c", UserName: "C"}},
+		}
+		users := escalationUsers(approval)
+		Expect(users).To(HaveLen(1))
+		Expect(users[0].UserID).To(Equal("c"))
+		Expect(users[0].UserName).To(Equal("C"))
+	})
+})
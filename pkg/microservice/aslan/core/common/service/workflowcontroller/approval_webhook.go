@@ -0,0 +1,210 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+// WebhookSignatureHeader holds the hex encoded hmac-sha256 of the body, signed by the secret of the webhook,
+// in both the requests sent to the webhook and the callbacks from it.
+const WebhookSignatureHeader = "X-Zadig-Signature"
+
+const (
+	webhookApprovalEventCreate = "create"
+	webhookApprovalEventCancel = "cancel"
+
+	// webhookCallbackMaxSkew limits how far the timestamp of a callback may be from now, so that a captured callback
+	// can not be replayed later.
+	webhookCallbackMaxSkew = 5 * time.Minute
+)
+
+// WebhookApprovalRequest is posted to the webhook when an approval is created or cancelled.
+type WebhookApprovalRequest struct {
+	Event               string               `json:"event"`
+	ApprovalID          string               `json:"approval_id"`
+	ProjectName         string               `json:"project_name"`
+	WorkflowName        string               `json:"workflow_name"`
+	WorkflowDisplayName string               `json:"workflow_display_name"`
+	TaskID              int64                `json:"task_id"`
+	StageName           string               `json:"stage_name"`
+	Description         string               `json:"description"`
+	ApproveUsers        []*commonmodels.User `json:"approve_users"`
+	Timeout             int                  `json:"timeout"`
+	DetailURL           string               `json:"detail_url,omitempty"`
+	CallbackURL         string               `json:"callback_url,omitempty"`
+}
+
+// WebhookApprovalCallback is posted to CallbackURL of the request by the approval system when it is decided.
+// ApprovalID and Timestamp (unix seconds) are required, they bind the signed body to one approval and a short time.
+type WebhookApprovalCallback struct {
+	ApprovalID string `json:"approval_id"`
+	Timestamp  int64  `json:"timestamp"`
+	Approve    bool   `json:"approve"`
+	UserID     string `json:"user_id"`
+	UserName   string `json:"user_name"`
+	Comment    string `json:"comment"`
+}
+
+type webhookApprovalProvider struct {
+	imApp *commonmodels.IMApp
+}
+
+func newWebhookApprovalProvider(imApp *commonmodels.IMApp) *webhookApprovalProvider {
+	return &webhookApprovalProvider{imApp: imApp}
+}
+
+func (p *webhookApprovalProvider) Create(approval *commonmodels.WorkflowApproval, workflowCtx *commonmodels.WorkflowTaskCtx) (string, error) {
+	req := newWebhookApprovalRequest(webhookApprovalEventCreate, approval)
+	req.DetailURL = approvalDetailURL(workflowCtx)
+	req.CallbackURL = fmt.Sprintf("%s/api/aslan/workflow/v4/workflowtask/approval/callback/%s", configbase.SystemAddress(), approval.ID.Hex())
+	if err := p.send(req); err != nil {
+		return "", err
+	}
+	return approval.ID.Hex(), nil
+}
+
+// Check reads the approval from db, the callback concludes it.
+func (p *webhookApprovalProvider) Check(approval *commonmodels.WorkflowApproval, users []*commonmodels.User) (config.ApprovalStatus, error) {
+	latest, err := mongodb.NewWorkflowApprovalColl().GetByID(approval.ID.Hex())
+	if err != nil {
+		return config.ApprovalStatusPending, err
+	}
+	for _, record := range latest.Records {
+		switch record.Action {
+		case config.ApprovalActionApprove:
+			updateApprovalUser(users, record.UserID, config.Approve, record.Comment, record.Time)
+		case config.ApprovalActionReject:
+			updateApprovalUser(users, record.UserID, config.Reject, record.Comment, record.Time)
+		}
+	}
+	return latest.Status, nil
+}
+
+func (p *webhookApprovalProvider) Cancel(approval *commonmodels.WorkflowApproval) error {
+	return p.send(newWebhookApprovalRequest(webhookApprovalEventCancel, approval))
+}
+
+func (p *webhookApprovalProvider) send(req *WebhookApprovalRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return errors.Wrap(err, "marshal request")
+	}
+	_, err = httpclient.Post(p.imApp.WebhookAddress,
+		httpclient.SetBody(body),
+		httpclient.SetHeader("Content-Type", "application/json"),
+		httpclient.SetHeader(WebhookSignatureHeader, util.ComputeHmacSha256(string(body), p.imApp.WebhookSecret)),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "send %s event to webhook", req.Event)
+	}
+	return nil
+}
+
+func newWebhookApprovalRequest(event string, approval *commonmodels.WorkflowApproval) *WebhookApprovalRequest {
+	return &WebhookApprovalRequest{
+		Event:               event,
+		ApprovalID:          approval.ID.Hex(),
+		ProjectName:         approval.ProjectName,
+		WorkflowName:        approval.WorkflowName,
+		WorkflowDisplayName: approval.WorkflowDisplayName,
+		TaskID:              approval.TaskID,
+		StageName:           approval.StageName,
+		Description:         approval.Description,
+		ApproveUsers:        approval.ApproveUsers,
+		Timeout:             approval.Timeout,
+	}
+}
+
+// HandleWebhookApprovalCallback concludes the webhook approval approvalID after the signature of body is verified.
+func HandleWebhookApprovalCallback(approvalID, signature string, body []byte) error {
+	approval, err := mongodb.NewWorkflowApprovalColl().GetByID(approvalID)
+	if err != nil {
+		return fmt.Errorf("approval %s not found", approvalID)
+	}
+	if approval.Type != config.WebhookApproval {
+		return fmt.Errorf("approval %s is not a webhook approval", approvalID)
+	}
+	imApp, err := mongodb.NewIMAppColl().GetByID(context.Background(), approval.IMAppID)
+	if err != nil {
+		return errors.Wrapf(err, "get im app %s", approval.IMAppID)
+	}
+	// the callback can not be verified without a secret, it is rejected instead of being signed with an empty key.
+	if imApp.WebhookSecret == "" {
+		return fmt.Errorf("webhook secret of im app %s is empty", approval.IMAppID)
+	}
+	expected := util.ComputeHmacSha256(string(body), imApp.WebhookSecret)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("invalid signature")
+	}
+
+	args := &WebhookApprovalCallback{}
+	if err := json.Unmarshal(body, args); err != nil {
+		return errors.Wrap(err, "unmarshal callback")
+	}
+	if err := args.validate(approvalID, time.Now()); err != nil {
+		return err
+	}
+	if approval.Status != config.ApprovalStatusPending {
+		return fmt.Errorf("approval %s is %s already", approvalID, approval.Status)
+	}
+	record := &commonmodels.ApprovalRecord{
+		Action:   config.ApprovalActionApprove,
+		UserID:   args.UserID,
+		UserName: args.UserName,
+		Comment:  args.Comment,
+		Time:     time.Now().Unix(),
+	}
+	status := config.ApprovalStatusApproved
+	if !args.Approve {
+		record.Action = config.ApprovalActionReject
+		status = config.ApprovalStatusRejected
+	}
+	err = mongodb.NewWorkflowApprovalColl().Conclude(approval, status, record)
+	if err == mongodb.ErrApprovalNotUpdated {
+		return fmt.Errorf("approval %s is decided already", approvalID)
+	}
+	return err
+}
+
+// validate rejects the callbacks signed for other approvals or at other times.
+func (c *WebhookApprovalCallback) validate(approvalID string, now time.Time) error {
+	if c.ApprovalID != approvalID {
+		return fmt.Errorf("callback is for approval %q instead of %s", c.ApprovalID, approvalID)
+	}
+	if c.Timestamp == 0 {
+		return errors.New("callback timestamp is required")
+	}
+	skew := now.Sub(time.Unix(c.Timestamp, 0))
+	if skew > webhookCallbackMaxSkew || skew < -webhookCallbackMaxSkew {
+		return fmt.Errorf("callback timestamp %d is out of the allowed window of %s", c.Timestamp, webhookCallbackMaxSkew)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing webhook approval callback", func() {
+
+	now := time.Unix(1665000000, 0)
+
+	It("should accept the callback of the approval in time", func() {
+		callback := &WebhookApprovalCallback{ApprovalID: "6343c1f0e7d1a2b3c4d5e6f7", Timestamp: now.Unix() - 30, Approve: true}
+		Expect(callback.validate("6343c1f0e7d1a2b3c4d5e6f7", now)).ShouldNot(HaveOccurred())
+	})
+
+	It("should reject the callback replayed for another approval", func() {
+		callback := &WebhookApprovalCallback{ApprovalID: "6343c1f0e7d1a2b3c4d5e6f7", Timestamp: now.Unix(), Approve: true}
+		Expect(callback.validate("6343c1f0e7d1a2b3c4d5e6f8", now)).Should(HaveOccurred())
+		Expect((&WebhookApprovalCallback{Timestamp: now.Unix()}).validate("6343c1f0e7d1a2b3c4d5e6f8", now)).Should(HaveOccurred())
+	})
+
+	It("should reject the callback out of the time window", func() {
+		id := "6343c1f0e7d1a2b3c4d5e6f7"
+		Expect((&WebhookApprovalCallback{ApprovalID: id}).validate(id, now)).Should(HaveOccurred())
+		Expect((&WebhookApprovalCallback{ApprovalID: id, Timestamp: now.Add(-10 * time.Minute).Unix()}).validate(id, now)).Should(HaveOccurred())
+		Expect((&WebhookApprovalCallback{ApprovalID: id, Timestamp: now.Add(10 * time.Minute).Unix()}).validate(id, now)).Should(HaveOccurred())
+	})
+})
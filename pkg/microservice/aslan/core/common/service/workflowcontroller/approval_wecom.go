@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/wecom"
+)
+
+type weComApprovalProvider struct {
+	imApp  *commonmodels.IMApp
+	client *wecom.Client
+}
+
+func newWeComApprovalProvider(imApp *commonmodels.IMApp) *weComApprovalProvider {
+	return &weComApprovalProvider{
+		imApp:  imApp,
+		client: wecom.NewClient(imApp.WeComCorpID, imApp.AppSecret),
+	}
+}
+
+func (p *weComApprovalProvider) Create(approval *commonmodels.WorkflowApproval, workflowCtx *commonmodels.WorkflowTaskCtx) (string, error) {
+	creator, err := p.client.GetUserIDByMobile(workflowCtx.WorkflowTaskCreatorMobile)
+	if err != nil {
+		return "", errors.Wrapf(err, "get wecom user by mobile-%s", workflowCtx.WorkflowTaskCreatorMobile)
+	}
+	attr := wecom.ApproverAttrOr
+	allApprove, err := needAllApprovers(approval)
+	if err != nil {
+		return "", err
+	}
+	if allApprove {
+		attr = wecom.ApproverAttrAnd
+	}
+	userIDs := []string{}
+	for _, user := range approval.ApproveUsers {
+		userIDs = append(userIDs, user.UserID)
+	}
+	return p.client.CreateApprovalInstance(&wecom.CreateApprovalInstanceArgs{
+		CreatorUserID: creator,
+		TemplateID:    p.imApp.WeComTemplateID,
+		ControlID:     p.imApp.WeComControlID,
+		Approvers:     []*wecom.Approver{{Attr: attr, UserIDs: userIDs}},
+		Content:       approvalContent(workflowCtx, approval.StageName, approval.Description),
+		Summary:       fmt.Sprintf("%s %s", workflowCtx.WorkflowDisplayName, approval.StageName),
+	})
+}
+
+func (p *weComApprovalProvider) Check(approval *commonmodels.WorkflowApproval, users []*commonmodels.User) (config.ApprovalStatus, error) {
+	instance, err := p.client.GetApprovalInstance(approval.InstanceID)
+	if err != nil {
+		return config.ApprovalStatusPending, err
+	}
+	for _, record := range instance.SpRecord {
+		for _, detail := range record.Details {
+			switch detail.SpStatus {
+			case wecom.SpStatusApproved:
+				updateApprovalUser(users, detail.Approver.UserID, config.Approve, detail.Speech, detail.SpTime)
+			case wecom.SpStatusRejected:
+				updateApprovalUser(users, detail.Approver.UserID, config.Reject, detail.Speech, detail.SpTime)
+			}
+		}
+	}
+
+	switch instance.SpStatus {
+	case wecom.SpStatusApproved:
+		return config.ApprovalStatusApproved, nil
+	case wecom.SpStatusRejected:
+		return config.ApprovalStatusRejected, nil
+	case wecom.SpStatusPending:
+		return config.ApprovalStatusPending, nil
+	default:
+		return config.ApprovalStatusCancelled, nil
+	}
+}
+
+// Cancel does nothing, approvals can only be revoked by their creators in WeCom.
+func (p *weComApprovalProvider) Cancel(approval *commonmodels.WorkflowApproval) error {
+	log.Infof("wecom approval %s can not be revoked by api", approval.InstanceID)
+	return nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"fmt"
+	"path"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const (
+	BuildKitImage = "moby/buildkit:v0.11.6-rootless"
+	KanikoImage   = "gcr.io/kaniko-project/executor:v1.9.2-debug"
+
+	buildKitContainer = "buildkitd"
+	kanikoContainer   = "kaniko"
+)
+
+// kanikoScript runs the build scripts written by the docker build steps one by one until the job executor
+// writes the stop file, kaniko has to run in its own container because it takes over the root filesystem
+// of the container it runs in.
+var kanikoScript = fmt.Sprintf(`dir=%s
+trap 'exit 0' TERM
+while [ ! -f $dir/stop ]; do
+  if [ -f $dir/build.sh ]; then
+    mv $dir/build.sh $dir/build.run.sh
+    /busybox/sh $dir/build.run.sh > $dir/build.log 2>&1
+    echo $? > $dir/exit.tmp
+    mv $dir/exit.tmp $dir/exit
+  fi
+  sleep 1
+done`, path.Join(step.BuildEngineDir, string(step.BuildEngineKaniko)))
+
+// getBuildEngines returns the build engines used by the docker build steps of the job except docker.
+func getBuildEngines(jobTaskSpec *commonmodels.JobTaskFreestyleSpec) map[step.BuildEngine]bool {
+	resp := make(map[step.BuildEngine]bool)
+	for _, stepTask := range jobTaskSpec.Steps {
+		if stepTask.StepType != config.StepDockerBuild {
+			continue
+		}
+		spec := &step.StepDockerBuildSpec{}
+		if err := commonmodels.IToi(stepTask.Spec, spec); err != nil {
+			continue
+		}
+		if engine := spec.GetBuildEngine(); engine != step.BuildEngineDocker {
+			resp[engine] = true
+		}
+	}
+	return resp
+}
+
+// setBuildEngineContainers adds the containers of daemonless build engines to the job pod, they share the
+// zadig context volume with the job container, so no docker daemon or privileged container is needed.
+func setBuildEngineContainers(job *batchv1.Job, jobTaskSpec *commonmodels.JobTaskFreestyleSpec) {
+	engines := getBuildEngines(jobTaskSpec)
+	podSpec := &job.Spec.Template.Spec
+	contextMount := corev1.VolumeMount{Name: "zadig-context", MountPath: ZadigContextDir}
+
+	if engines[step.BuildEngineBuildKit] {
+		binDir := path.Join(step.BuildEngineDir, "bin")
+		podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Name:            "init-buildctl",
+			Image:           BuildKitImage,
+			Command:         []string{"/bin/sh", "-c", fmt.Sprintf("mkdir -p %s && cp /usr/bin/buildctl %s/", binDir, binDir)},
+			VolumeMounts:    []corev1.VolumeMount{contextMount},
+		})
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name:         "buildkitd",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+		podSpec.Containers = append(podSpec.Containers, corev1.Container{
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Name:            buildKitContainer,
+			Image:           BuildKitImage,
+			Args:            []string{"--oci-worker-no-process-sandbox", "--addr", step.BuildKitAddr},
+			SecurityContext: &corev1.SecurityContext{
+				RunAsUser:      int64Ptr(1000),
+				RunAsGroup:     int64Ptr(1000),
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined},
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				contextMount,
+				{Name: "buildkitd", MountPath: "/home/user/.local/share/buildkit"},
+			},
+		})
+		if job.Spec.Template.Annotations == nil {
+			job.Spec.Template.Annotations = make(map[string]string)
+		}
+		job.Spec.Template.Annotations["container.apparmor.security.beta.kubernetes.io/"+buildKitContainer] = "unconfined"
+	}
+
+	if engines[step.BuildEngineKaniko] {
+		podSpec.Containers = append(podSpec.Containers, corev1.Container{
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Name:            kanikoContainer,
+			Image:           KanikoImage,
+			Command:         []string{"/busybox/sh", "-c", kanikoScript},
+			VolumeMounts:    []corev1.VolumeMount{contextMount},
+		})
+	}
+}
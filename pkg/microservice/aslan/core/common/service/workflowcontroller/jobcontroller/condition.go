@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/util/expression"
+)
+
+const maskedValue = "******"
+
+// evaluateCondition evaluates the if expression of a job or step, the returned result is
+// never nil and explains why the condition did not pass.
+func evaluateCondition(raw string, workflowCtx *commonmodels.WorkflowTaskCtx) (*commonmodels.ConditionResult, error) {
+	result := &commonmodels.ConditionResult{Expression: raw}
+	expr, err := expression.Parse(raw)
+	if err != nil {
+		result.Message = err.Error()
+		return result, err
+	}
+	vars, credentials := conditionVariables(workflowCtx)
+	result.Passed, err = expr.Evaluate(vars)
+	if err != nil {
+		result.Message = err.Error()
+		return result, err
+	}
+	if !result.Passed {
+		values := []string{}
+		for _, name := range expr.Variables() {
+			value := vars[name]
+			if credentials[name] {
+				value = maskedValue
+			}
+			values = append(values, fmt.Sprintf("%s=%q", name, value))
+		}
+		result.Message = fmt.Sprintf("condition %s is false", raw)
+		if len(values) > 0 {
+			result.Message += fmt.Sprintf(" with %s", strings.Join(values, ", "))
+		}
+	}
+	return result, nil
+}
+
+// conditionVariables collects all variables which can be used in conditions, the second return
+// value marks the credential params whose values should not be shown.
+func conditionVariables(workflowCtx *commonmodels.WorkflowTaskCtx) (map[string]string, map[string]bool) {
+	vars := workflowCtx.HookPayload.TriggerVariables()
+	credentials := map[string]bool{}
+	vars["project"] = workflowCtx.ProjectName
+	vars["workflow.name"] = workflowCtx.WorkflowName
+	vars["workflow.task.id"] = fmt.Sprintf("%d", workflowCtx.TaskID)
+	for _, param := range workflowCtx.WorkflowParams {
+		name := "workflow.params." + param.Name
+		vars[name] = param.Value
+		credentials[name] = param.IsCredential
+	}
+	for _, kv := range workflowCtx.WorkflowKeyVals {
+		name := "env." + kv.Key
+		vars[name] = kv.Value
+		credentials[name] = kv.IsCredential
+	}
+	for name, value := range jobOutputVariables(workflowCtx) {
+		vars[name] = value
+	}
+	return vars, credentials
+}
+
+// checkJobCondition records the evaluation result of the job condition,
+// returns false if the job should be skipped or failed because of the condition.
+func checkJobCondition(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx) (bool, error) {
+	if job.If == "" {
+		return true, nil
+	}
+	result, err := evaluateCondition(job.If, workflowCtx)
+	job.Condition = result
+	return result.Passed, err
+}
+
+// evaluateStepConditions evaluates conditions of all steps, the skipped steps are kept in the job spec
+// to show their status, but will not be sent to job executor.
+func evaluateStepConditions(steps []*commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx) error {
+	for _, step := range steps {
+		if step.If == "" {
+			continue
+		}
+		result, err := evaluateCondition(step.If, workflowCtx)
+		step.Condition = result
+		if !result.Passed {
+			step.Status = config.StatusSkipped
+		}
+		if err != nil {
+			return fmt.Errorf("step %s: %v", step.Name, err)
+		}
+	}
+	return nil
+}
+
+func runnableSteps(steps []*commonmodels.StepTask) []*commonmodels.StepTask {
+	resp := []*commonmodels.StepTask{}
+	for _, step := range steps {
+		if step.Condition != nil && !step.Condition.Passed {
+			continue
+		}
+		resp = append(resp, step)
+	}
+	return resp
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+// DAGRunner runs jobs as a directed acyclic graph, a job starts as soon as all of
+// its upstream jobs passed, and is skipped when any of them did not pass.
+type DAGRunner struct {
+	jobs        []*commonmodels.JobTask
+	workflowCtx *commonmodels.WorkflowTaskCtx
+	concurrency int
+	logger      *zap.SugaredLogger
+	ack         func()
+	ctx         context.Context
+	limiter     *matrixLimiter
+
+	// upstreams and downstreams are indexed by the position of the job in jobs.
+	upstreams   map[int][]int
+	downstreams map[int][]int
+	// gate blocks a ready job until it is allowed to start, the job is skipped if gate returns an error.
+	gate func(job *commonmodels.JobTask) error
+}
+
+func NewDAGRunner(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) *DAGRunner {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	r := &DAGRunner{
+		jobs:        jobs,
+		workflowCtx: workflowCtx,
+		concurrency: concurrency,
+		logger:      logger,
+		ack:         ack,
+		ctx:         ctx,
+		limiter:     newMatrixLimiter(),
+		upstreams:   make(map[int][]int),
+		downstreams: make(map[int][]int),
+	}
+	r.buildGraph()
+	return r
+}
+
+// one workflow job may be expanded to multiple job tasks, so the depends_on edges
+// are resolved by origin name, dependencies outside the given jobs are ignored,
+// they were either finished in former stages or skipped on task creation.
+func (r *DAGRunner) buildGraph() {
+	originIndex := make(map[string][]int)
+	for i, job := range r.jobs {
+		originIndex[jobOriginName(job)] = append(originIndex[jobOriginName(job)], i)
+	}
+	for i, job := range r.jobs {
+		for _, dependency := range job.DependsOn {
+			for _, upstream := range originIndex[dependency] {
+				if upstream == i {
+					continue
+				}
+				r.upstreams[i] = append(r.upstreams[i], upstream)
+				r.downstreams[upstream] = append(r.downstreams[upstream], i)
+			}
+		}
+	}
+}
+
+// SetGate sets the function to wait on before a ready job starts, e.g. the approval of its stage.
+// jobs waiting on the gate do not take the concurrency.
+func (r *DAGRunner) SetGate(gate func(job *commonmodels.JobTask) error) *DAGRunner {
+	r.gate = gate
+	return r
+}
+
+// Run runs all jobs in dependency order and blocks until all of them finished or skipped.
+func (r *DAGRunner) Run() {
+	pending := make(map[int]int, len(r.jobs))
+	ready := []int{}
+	doneChan := make(chan int)
+	gatedChan := make(chan int)
+	running, waiting, finished := 0, 0, 0
+	enqueue := func(index int) {
+		if r.gate == nil {
+			ready = append(ready, index)
+			return
+		}
+		waiting++
+		go func() {
+			if err := r.gate(r.jobs[index]); err != nil {
+				r.skipJob(r.jobs[index], err.Error())
+			}
+			gatedChan <- index
+		}()
+	}
+	for i := range r.jobs {
+		pending[i] = len(r.upstreams[i])
+		if pending[i] == 0 {
+			enqueue(i)
+		}
+	}
+
+	for finished < len(r.jobs) {
+		for len(ready) > 0 && running < r.concurrency {
+			index := ready[0]
+			ready = ready[1:]
+			running++
+			go func(index int) {
+				r.limiter.acquire(r.jobs[index])
+				runJob(r.ctx, r.jobs[index], r.workflowCtx, r.logger, r.ack)
+				r.limiter.release(r.jobs[index])
+				doneChan <- index
+			}(index)
+		}
+		if running == 0 && waiting == 0 {
+			// should never happen since cycles are rejected on lint, skip the rest to avoid hanging forever.
+			for i, job := range r.jobs {
+				if job.Status == "" {
+					r.skipJob(job, "job dependencies can not be resolved")
+					finished++
+					pending[i] = -1
+				}
+			}
+			break
+		}
+
+		var index int
+		select {
+		case index = <-gatedChan:
+			waiting--
+			if r.jobs[index].Status != config.StatusSkipped {
+				ready = append(ready, index)
+				continue
+			}
+		case index = <-doneChan:
+			running--
+		}
+		finished++
+		passed := r.jobs[index].Status == config.StatusPassed || JobFailureIgnored(r.jobs[index])
+		for _, downstream := range r.downstreams[index] {
+			if pending[downstream] < 0 {
+				continue
+			}
+			if !passed {
+				finished += r.skipBranch(downstream, pending, r.jobs[index].Name)
+				continue
+			}
+			pending[downstream]--
+			if pending[downstream] == 0 {
+				enqueue(downstream)
+			}
+		}
+	}
+}
+
+// skipBranch skips the job and all of its downstream jobs, returns the number of newly skipped jobs.
+func (r *DAGRunner) skipBranch(index int, pending map[int]int, upstreamName string) int {
+	if pending[index] < 0 {
+		return 0
+	}
+	pending[index] = -1
+	job := r.jobs[index]
+	r.skipJob(job, fmt.Sprintf("upstream job %s did not pass", upstreamName))
+	count := 1
+	for _, downstream := range r.downstreams[index] {
+		count += r.skipBranch(downstream, pending, job.Name)
+	}
+	return count
+}
+
+func (r *DAGRunner) skipJob(job *commonmodels.JobTask, reason string) {
+	job.Status = config.StatusSkipped
+	job.Error = reason
+	job.StartTime = time.Now().Unix()
+	job.EndTime = job.StartTime
+	r.logger.Infof("skip job: %s, reason: %s", job.Name, reason)
+	r.ack()
+}
+
+func hasJobDependencies(jobs []*commonmodels.JobTask) bool {
+	for _, job := range jobs {
+		if len(job.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func jobOriginName(job *commonmodels.JobTask) string {
+	if job.OriginName != "" {
+		return job.OriginName
+	}
+	return job.Name
+}
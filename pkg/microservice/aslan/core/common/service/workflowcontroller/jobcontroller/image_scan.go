@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"fmt"
+	"path"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const TrivyImage = "aquasec/trivy:0.41.0"
+
+// setImageScanContainers provides the scanner to the job container by an init container, the scanner binary
+// is copied to the zadig context volume.
+func setImageScanContainers(job *batchv1.Job, jobTaskSpec *commonmodels.JobTaskFreestyleSpec) {
+	for _, stepTask := range jobTaskSpec.Steps {
+		if stepTask.StepType != config.StepImageScan {
+			continue
+		}
+		binDir := path.Join(step.ImageScanDir, "bin")
+		podSpec := &job.Spec.Template.Spec
+		podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Name:            "init-trivy",
+			Image:           TrivyImage,
+			Command:         []string{"/bin/sh", "-c", fmt.Sprintf("mkdir -p %s && cp /usr/local/bin/trivy %s/", binDir, binDir)},
+			VolumeMounts:    []corev1.VolumeMount{{Name: "zadig-context", MountPath: ZadigContextDir}},
+		})
+		return
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/regclient/regclient"
+	regconfig "github.com/regclient/regclient/config"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	crClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/tool/kube/updater"
+	"github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util/signature"
+)
+
+const (
+	CosignImage = "bitnami/cosign:2.0.2"
+
+	imageSigningKeysVolume = "image-signing-keys"
+)
+
+// imageSigningKeyIDs returns the ids of the keys used by the steps of the job to sign the images they push.
+func imageSigningKeyIDs(jobTaskSpec *commonmodels.JobTaskFreestyleSpec) []string {
+	keyIDs := sets.NewString()
+	for _, stepTask := range jobTaskSpec.Steps {
+		switch stepTask.StepType {
+		case config.StepDockerBuild:
+			spec := &step.StepDockerBuildSpec{}
+			if err := commonmodels.IToi(stepTask.Spec, spec); err == nil && spec.Signing != nil {
+				keyIDs.Insert(spec.Signing.KeyID)
+			}
+		case config.StepDistributeImage:
+			spec := &step.StepImageDistributeSpec{}
+			if err := commonmodels.IToi(stepTask.Spec, spec); err == nil && spec.Signing != nil {
+				keyIDs.Insert(spec.Signing.KeyID)
+			}
+		}
+	}
+	return keyIDs.List()
+}
+
+// setImageSigningContainers provides cosign to the job container by an init container, the cosign binary
+// is copied to the zadig context volume. The signing keys are mounted from the secret named after the job.
+func setImageSigningContainers(job *batchv1.Job, jobTaskSpec *commonmodels.JobTaskFreestyleSpec) {
+	if len(imageSigningKeyIDs(jobTaskSpec)) == 0 {
+		return
+	}
+	binDir := path.Join(step.ImageSigningDir, "bin")
+	podSpec := &job.Spec.Template.Spec
+	podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Name:            "init-cosign",
+		Image:           CosignImage,
+		Command:         []string{"/bin/sh", "-c", fmt.Sprintf("mkdir -p %s && cp /opt/bitnami/cosign/bin/cosign %s/", binDir, binDir)},
+		VolumeMounts:    []corev1.VolumeMount{{Name: "zadig-context", MountPath: ZadigContextDir}},
+	})
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: imageSigningKeysVolume,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: job.Name, DefaultMode: int32Ptr(0400)},
+		},
+	})
+	podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      imageSigningKeysVolume,
+		MountPath: step.ImageSigningKeyDir,
+		ReadOnly:  true,
+	})
+}
+
+// createImageSigningSecret saves the signing keys of the job into a secret named after the job, the keys are read
+// from the private key store only when the job runs, so that they are never saved in the task.
+func createImageSigningSecret(namespace, jobName string, jobLabel *JobLabel, jobTaskSpec *commonmodels.JobTaskFreestyleSpec, kubeClient crClient.Client) error {
+	keyIDs := imageSigningKeyIDs(jobTaskSpec)
+	if len(keyIDs) == 0 {
+		return nil
+	}
+	data := make(map[string][]byte, len(keyIDs))
+	for _, keyID := range keyIDs {
+		key, err := service.GetImageSigningKey(keyID)
+		if err != nil {
+			return err
+		}
+		data[keyID] = key
+	}
+	return updater.UpdateOrCreateSecret(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: namespace,
+			Labels:    getJobLabels(jobLabel),
+		},
+		Data: data,
+		Type: corev1.SecretTypeOpaque,
+	}, kubeClient)
+}
+
+func deleteImageSigningSecret(namespace, jobName string, jobTaskSpec *commonmodels.JobTaskFreestyleSpec, kubeClient crClient.Client) error {
+	if len(imageSigningKeyIDs(jobTaskSpec)) == 0 {
+		return nil
+	}
+	if err := updater.DeleteSecretWithName(namespace, jobName, kubeClient); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// verifySignatures returns an error if any of the images is not signed by the private key of the check, otherwise it
+// returns the images pinned to the verified digests, so that the tags can not be pointed to other images before deploy.
+func verifySignatures(ctx context.Context, check *commonmodels.SignatureCheck, images []string) ([]string, error) {
+	if check == nil {
+		return images, nil
+	}
+	pub, err := signature.ParsePublicKey([]byte(check.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of the signature check: %v", err)
+	}
+	hosts := []regconfig.Host{}
+	for _, reg := range check.Registries {
+		host := regconfig.HostNewName(reg.RegAddr)
+		host.User = reg.AccessKey
+		host.Pass = reg.SecretKey
+		if reg.AdvancedSetting != nil {
+			host.RegCert = reg.AdvancedSetting.TLSCert
+			if !reg.AdvancedSetting.TLSEnabled {
+				host.TLS = regconfig.TLSInsecure
+			}
+		}
+		if strings.HasPrefix(reg.RegAddr, "http://") {
+			host.TLS = regconfig.TLSDisabled
+		}
+		hosts = append(hosts, *host)
+	}
+	client := regclient.New(regclient.WithConfigHosts(hosts))
+	pinned := make([]string, 0, len(images))
+	for _, image := range images {
+		verified, err := signature.Verify(ctx, client, image, pub)
+		if err != nil {
+			return nil, fmt.Errorf("signature verification failed: %v", err)
+		}
+		pinned = append(pinned, verified)
+	}
+	return pinned, nil
+}
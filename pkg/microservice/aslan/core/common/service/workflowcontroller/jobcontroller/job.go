@@ -18,7 +18,6 @@ package jobcontroller
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -79,18 +78,58 @@ func initJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTas
 }
 
 func runJob(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
-	// render global variables for every job.
-	workflowCtx.GlobalContextEach(func(k, v string) bool {
-		b, _ := json.Marshal(job)
-		v = strings.Trim(v, "\n")
-		replacedString := strings.ReplaceAll(string(b), k, v)
-		if err := json.Unmarshal([]byte(replacedString), &job); err != nil {
-			logger.Errorf("unmarshal job error: %v", err)
+	// the result of the job is kept from the task it was retried from.
+	if job.ReusedFrom > 0 {
+		logger.Infof("job: %s reused from task %d, status: %s", job.Name, job.ReusedFrom, job.Status)
+		return
+	}
+	// render outputs of upstream jobs for every job.
+	if err := renderJobVariables(job, workflowCtx); err != nil {
+		job.Status = config.StatusFailed
+		job.Error = fmt.Sprintf("render job variables error: %v", err)
+		job.StartTime = time.Now().Unix()
+		job.EndTime = job.StartTime
+		logger.Errorf("job: %s %s", job.Name, job.Error)
+		ack()
+		return
+	}
+	if run, err := checkJobCondition(job, workflowCtx); !run {
+		job.Status = config.StatusSkipped
+		if err != nil {
+			job.Status = config.StatusFailed
+			job.Error = err.Error()
 		}
-		return true
-	})
+		job.StartTime = time.Now().Unix()
+		job.EndTime = job.StartTime
+		logger.Infof("job: %s not started, status: %s, condition: %s", job.Name, job.Status, job.Condition.Message)
+		ack()
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		runJobAttempt(ctx, job, workflowCtx, logger, ack)
+		if !shouldRetry(ctx, job, attempt) {
+			return
+		}
+		backoff := retryBackoff(job.RetryPolicy, attempt)
+		recordJobAttempt(job, attempt, workflowCtx, logger)
+		logger.Infof("job: %s attempt %d failed with %s, retry in %s", job.Name, attempt, job.FailureClass, backoff)
+		ack()
+		select {
+		case <-ctx.Done():
+			job.Status = config.StatusCancelled
+			ack()
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func runJobAttempt(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
 	job.Status = config.StatusPrepare
+	job.Error = ""
+	job.FailureClass = ""
 	job.StartTime = time.Now().Unix()
+	job.EndTime = 0
 	job.K8sJobName = getJobName(workflowCtx.WorkflowName, workflowCtx.TaskID)
 	ack()
 
@@ -102,6 +141,9 @@ func runJob(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonm
 			job.Status = config.StatusFailed
 			job.Error = errMsg
 		}
+		if jobStatusFailed(job.Status) && job.Status != config.StatusCancelled && job.FailureClass == "" {
+			job.FailureClass = defaultFailureClass(job.Status)
+		}
 		job.EndTime = time.Now().Unix()
 		logger.Infof("finish job: %s,status: %s", job.Name, job.Status)
 		ack()
@@ -112,10 +154,14 @@ func runJob(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonm
 }
 
 func RunJobs(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) {
+	if hasJobDependencies(jobs) {
+		NewDAGRunner(ctx, jobs, workflowCtx, concurrency, logger, ack).Run()
+		return
+	}
 	if concurrency == 1 {
 		for _, job := range jobs {
 			runJob(ctx, job, workflowCtx, logger, ack)
-			if jobStatusFailed(job.Status) {
+			if jobStatusFailed(job.Status) && !JobFailureIgnored(job) {
 				return
 			}
 		}
@@ -128,6 +174,9 @@ func RunJobs(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *com
 func CleanWorkflowJobs(ctx context.Context, workflowTask *commonmodels.WorkflowTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
 	for _, stage := range workflowTask.Stages {
 		for _, job := range stage.Jobs {
+			if job.ReusedFrom > 0 {
+				continue
+			}
 			jobCtl := initJobCtl(job, workflowCtx, logger, ack)
 			jobCtl.Clean(ctx)
 		}
@@ -145,6 +194,7 @@ type Pool struct {
 	ack         func()
 	ctx         context.Context
 	wg          sync.WaitGroup
+	limiter     *matrixLimiter
 }
 
 // NewPool initializes a new pool with the given tasks and
@@ -158,6 +208,7 @@ func NewPool(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *com
 		logger:      logger,
 		ack:         ack,
 		ctx:         ctx,
+		limiter:     newMatrixLimiter(),
 	}
 }
 
@@ -182,11 +233,49 @@ func (p *Pool) Run() {
 // The work loop for any single goroutine.
 func (p *Pool) work() {
 	for job := range p.jobsChan {
+		p.limiter.acquire(job)
 		runJob(p.ctx, job, p.workflowCtx, p.logger, p.ack)
+		p.limiter.release(job)
 		p.wg.Done()
 	}
 }
 
+// matrixLimiter keeps the number of running job tasks expanded from the same matrix job
+// no more than its max parallel.
+type matrixLimiter struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	running map[string]int
+}
+
+func newMatrixLimiter() *matrixLimiter {
+	l := &matrixLimiter{running: make(map[string]int)}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+func (l *matrixLimiter) acquire(job *commonmodels.JobTask) {
+	if job.MaxParallel <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.running[jobOriginName(job)] >= job.MaxParallel {
+		l.cond.Wait()
+	}
+	l.running[jobOriginName(job)]++
+}
+
+func (l *matrixLimiter) release(job *commonmodels.JobTask) {
+	if job.MaxParallel <= 0 {
+		return
+	}
+	l.mu.Lock()
+	l.running[jobOriginName(job)]--
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
 func saveFile(src io.Reader, localFile string) error {
 	out, err := os.Create(localFile)
 	if err != nil {
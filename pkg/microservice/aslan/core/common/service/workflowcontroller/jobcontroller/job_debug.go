@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// GetJobDebugContext renders the context of a job run by the job executor, so that it can be run locally.
+// Values of secret envs, credentials in the steps and the object storage for large outputs are not returned.
+func GetJobDebugContext(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger) (*types.JobDebugContext, error) {
+	jobTaskSpec := &commonmodels.JobTaskFreestyleSpec{}
+	if err := commonmodels.IToi(job.Spec, jobTaskSpec); err != nil {
+		return nil, err
+	}
+	if len(jobTaskSpec.Steps) == 0 {
+		return nil, fmt.Errorf("job %s is not run by job executor", job.Name)
+	}
+
+	jobCtx := BuildJobExcutorContext(jobTaskSpec, job, workflowCtx, logger)
+	jobCtx.OutputStorage = nil
+	secretKeys := []string{}
+	for i, env := range jobCtx.SecretEnvs {
+		key := strings.SplitN(env, "=", 2)[0]
+		secretKeys = append(secretKeys, key)
+		jobCtx.SecretEnvs[i] = key + "="
+	}
+	content, err := yaml.Marshal(jobCtx)
+	if err != nil {
+		return nil, fmt.Errorf("marshal job context error: %v", err)
+	}
+	content, credentials, err := stripCredentials(content)
+	if err != nil {
+		return nil, fmt.Errorf("strip credentials error: %v", err)
+	}
+
+	return &types.JobDebugContext{
+		JobName:           job.Name,
+		Image:             getBaseImage(jobTaskSpec.Properties.BuildOS, jobTaskSpec.Properties.ImageFrom),
+		Workspace:         jobCtx.Workspace,
+		JobContext:        string(content),
+		SecretEnvs:        secretKeys,
+		Credentials:       credentials,
+		ServiceContainers: jobCtx.ServiceContainers,
+	}, nil
+}
+
+// credentialKeys are the yaml keys of the credentials in the specs of the steps.
+var credentialKeys = sets.NewString("password", "oauth_token", "private_access_token", "ssh_key", "sk", "SK", "secret_key", "private_key", "sonar_token")
+
+var credentialKeyInvalidRe = regexp.MustCompile("[^A-Z0-9]+")
+
+// stripCredentials replaces the credentials in the job context with placeholders, the keys of them are named after
+// the path of the credentials, e.g. the oauth token of the first repo in step build-git is STEPS_BUILD_GIT_SPEC_REPOS_0_OAUTH_TOKEN.
+func stripCredentials(content []byte) ([]byte, []string, error) {
+	doc := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal(content, doc); err != nil {
+		return nil, nil, err
+	}
+	credentials := []string{}
+	var walk func(node *yamlv3.Node, path []string)
+	walk = func(node *yamlv3.Node, path []string) {
+		switch node.Kind {
+		case yamlv3.DocumentNode:
+			for _, n := range node.Content {
+				walk(n, path)
+			}
+		case yamlv3.SequenceNode:
+			for i, n := range node.Content {
+				walk(n, append(path, sequenceItemName(n, i)))
+			}
+		case yamlv3.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				if credentialKeys.Has(key.Value) && value.Kind == yamlv3.ScalarNode && value.Value != "" {
+					name := strings.Trim(credentialKeyInvalidRe.ReplaceAllString(strings.ToUpper(strings.Join(append(path, key.Value), "_")), "_"), "_")
+					credentials = append(credentials, name)
+					value.Value = types.JobDebugCredentialPlaceholder(name)
+					value.Tag = "!!str"
+					continue
+				}
+				walk(value, append(path, key.Value))
+			}
+		}
+	}
+	walk(doc, nil)
+
+	content, err := yamlv3.Marshal(doc)
+	return content, credentials, err
+}
+
+// sequenceItemName names the item by its name if it has one, so that the keys of the credentials in steps are stable.
+func sequenceItemName(node *yamlv3.Node, index int) string {
+	if node.Kind == yamlv3.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "name" && node.Content[i+1].Value != "" {
+				return node.Content[i+1].Value
+			}
+		}
+	}
+	return strconv.Itoa(index)
+}
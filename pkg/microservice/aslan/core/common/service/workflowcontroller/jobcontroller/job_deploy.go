@@ -86,6 +86,12 @@ func (c *DeployJobCtl) run(ctx context.Context) error {
 		err      error
 		replaced = false
 	)
+	pinned, err := verifySignatures(ctx, c.jobTaskSpec.SignatureCheck, []string{c.jobTaskSpec.Image})
+	if err != nil {
+		logError(c.job, err.Error(), c.logger)
+		return err
+	}
+	c.jobTaskSpec.Image = pinned[0]
 	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
 		Name:    c.workflowCtx.ProjectName,
 		EnvName: c.jobTaskSpec.Env,
@@ -37,6 +37,7 @@ import (
 	"github.com/koderover/zadig/pkg/tool/dockerhost"
 	krkubeclient "github.com/koderover/zadig/pkg/tool/kube/client"
 	"github.com/koderover/zadig/pkg/tool/kube/updater"
+	jobtypes "github.com/koderover/zadig/pkg/types/job"
 )
 
 const (
@@ -80,6 +81,7 @@ func (c *FreestyleJobCtl) Run(ctx context.Context) {
 		return
 	}
 	if err := c.run(ctx); err != nil {
+		c.job.FailureClass = config.FailureInfrastructure
 		return
 	}
 	c.wait(ctx)
@@ -99,8 +101,12 @@ func (c *FreestyleJobCtl) prepare(ctx context.Context) error {
 	if c.jobTaskSpec.Properties.ClusterID == "" {
 		c.jobTaskSpec.Properties.ClusterID = setting.LocalClusterID
 	}
+	if err := evaluateStepConditions(c.jobTaskSpec.Steps, c.workflowCtx); err != nil {
+		logError(c.job, err.Error(), c.logger)
+		return err
+	}
 	// init step configration.
-	if err := stepcontroller.PrepareSteps(ctx, c.workflowCtx, &c.jobTaskSpec.Properties.Paths, c.job.Name, c.jobTaskSpec.Steps, c.logger); err != nil {
+	if err := stepcontroller.PrepareSteps(ctx, c.workflowCtx, &c.jobTaskSpec.Properties.Paths, c.job.Name, runnableSteps(c.jobTaskSpec.Steps), c.logger); err != nil {
 		logError(c.job, err.Error(), c.logger)
 		return err
 	}
@@ -183,6 +189,12 @@ func (c *FreestyleJobCtl) run(ctx context.Context) error {
 
 	c.logger.Infof("succeed to create cm for job %s", c.job.K8sJobName)
 
+	if err := createImageSigningSecret(c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, jobLabel, c.jobTaskSpec, c.kubeclient); err != nil {
+		msg := fmt.Sprintf("create image signing secret error: %v", err)
+		logError(c.job, msg, c.logger)
+		return errors.New(msg)
+	}
+
 	jobImage := getBaseImage(c.jobTaskSpec.Properties.BuildOS, c.jobTaskSpec.Properties.ImageFrom)
 
 	c.jobTaskSpec.Properties.Registries = getMatchedRegistries(jobImage, c.jobTaskSpec.Properties.Registries)
@@ -223,9 +235,30 @@ func (c *FreestyleJobCtl) wait(ctx context.Context) {
 	if c.job.Status == config.StatusRunning {
 		c.ack()
 	} else {
+		c.setFailureClass()
 		return
 	}
+	if services := c.jobTaskSpec.Properties.ServiceContainers; len(services) > 0 {
+		status, err := waitServiceContainersReady(ctx, c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, c.job.Name, services, c.kubeclient, c.clientset, c.restConfig, c.logger)
+		if err != nil {
+			c.logger.Error(err)
+			c.job.Error = err.Error()
+		}
+		if status != config.StatusRunning {
+			c.job.Status = status
+			c.setFailureClass()
+			return
+		}
+	}
 	c.job.Status = waitJobEndWithFile(ctx, taskTimeout, c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, true, c.kubeclient, c.clientset, c.restConfig, c.logger)
+	c.setFailureClass()
+}
+
+func (c *FreestyleJobCtl) setFailureClass() {
+	if c.job.Status != config.StatusFailed && c.job.Status != config.StatusTimeout {
+		return
+	}
+	c.job.FailureClass = getPodFailureClass(c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, c.job.Status, c.kubeclient)
 }
 
 func (c *FreestyleJobCtl) complete(ctx context.Context) {
@@ -243,6 +276,9 @@ func (c *FreestyleJobCtl) complete(ctx context.Context) {
 			if err := ensureDeleteConfigMap(c.jobTaskSpec.Properties.Namespace, jobLabel, c.kubeclient); err != nil {
 				c.logger.Error(err)
 			}
+			if err := deleteImageSigningSecret(c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, c.jobTaskSpec, c.kubeclient); err != nil {
+				c.logger.Error(err)
+			}
 		}()
 	}()
 
@@ -250,19 +286,37 @@ func (c *FreestyleJobCtl) complete(ctx context.Context) {
 	if err := getJobOutputFromRunningPod(c.jobTaskSpec.Properties.Namespace, c.job.Name, c.job, c.workflowCtx, c.kubeclient, c.clientset, c.restConfig); err != nil {
 		c.logger.Error(err)
 	}
+	if err := getStepResultsFromRunningPod(c.jobTaskSpec.Properties.Namespace, c.job.Name, c.job, c.jobTaskSpec.Steps, c.kubeclient, c.clientset, c.restConfig); err != nil {
+		c.logger.Error(err)
+	}
 
-	if err := saveContainerLog(c.jobTaskSpec.Properties.Namespace, c.jobTaskSpec.Properties.ClusterID, c.workflowCtx.WorkflowName, c.job.Name, c.workflowCtx.TaskID, jobLabel, c.kubeclient); err != nil {
+	secrets := credentialValues(c.jobTaskSpec.Properties.Envs)
+	if err := saveContainerLog(c.jobTaskSpec.Properties.Namespace, c.jobTaskSpec.Properties.ClusterID, c.workflowCtx.WorkflowName, c.job.Name, c.workflowCtx.TaskID, jobLabel, secrets, c.kubeclient); err != nil {
 		c.logger.Error(err)
 		c.job.Error = err.Error()
 		return
 	}
-	if err := stepcontroller.SummarizeSteps(ctx, c.workflowCtx, &c.jobTaskSpec.Properties.Paths, c.job.Name, c.jobTaskSpec.Steps, c.logger); err != nil {
+	if err := saveServiceContainerLogs(c.jobTaskSpec.Properties.Namespace, c.jobTaskSpec.Properties.ClusterID, c.workflowCtx.WorkflowName, c.job.Name, c.workflowCtx.TaskID, jobLabel, c.jobTaskSpec.Properties.ServiceContainers, secrets, c.kubeclient); err != nil {
+		c.logger.Warnf("failed to save logs of service containers: %v", err)
+	}
+	if err := stepcontroller.SummarizeSteps(ctx, c.workflowCtx, &c.jobTaskSpec.Properties.Paths, c.job.Name, runnableSteps(c.jobTaskSpec.Steps), c.logger); err != nil {
 		c.logger.Error(err)
 		c.job.Error = err.Error()
 		return
 	}
 }
 
+// credentialValues returns the values of credential envs, which are masked in the logs.
+func credentialValues(envs []*commonmodels.KeyVal) []string {
+	resp := []string{}
+	for _, env := range envs {
+		if env.IsCredential && env.Value != "" {
+			resp = append(resp, env.Value)
+		}
+	}
+	return resp
+}
+
 func BuildJobExcutorContext(jobTaskSpec *commonmodels.JobTaskFreestyleSpec, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger) *JobContext {
 	var envVars, secretEnvVars []string
 	for _, env := range jobTaskSpec.Properties.Envs {
@@ -272,21 +326,34 @@ func BuildJobExcutorContext(jobTaskSpec *commonmodels.JobTaskFreestyleSpec, job
 		}
 		envVars = append(envVars, strings.Join([]string{env.Key, env.Value}, "="))
 	}
+	envVars = append(envVars, getServiceContainerEnvs(jobTaskSpec.Properties.ServiceContainers)...)
+	services := []string{}
+	for _, service := range jobTaskSpec.Properties.ServiceContainers {
+		services = append(services, service.Name)
+	}
 
 	outputs := []string{}
 	for _, output := range job.Outputs {
 		outputs = append(outputs, output.Name)
 	}
 
+	outputStorage, err := getOutputStorage()
+	if err != nil {
+		logger.Warnf("failed to get object storage for large outputs: %v", err)
+	}
+
 	return &JobContext{
-		Name:         job.Name,
-		Envs:         envVars,
-		SecretEnvs:   secretEnvVars,
-		WorkflowName: workflowCtx.WorkflowName,
-		Workspace:    workflowCtx.Workspace,
-		TaskID:       workflowCtx.TaskID,
-		Outputs:      outputs,
-		Steps:        jobTaskSpec.Steps,
-		Paths:        jobTaskSpec.Properties.Paths,
+		Name:               job.Name,
+		Envs:               envVars,
+		SecretEnvs:         secretEnvVars,
+		WorkflowName:       workflowCtx.WorkflowName,
+		Workspace:          workflowCtx.Workspace,
+		TaskID:             workflowCtx.TaskID,
+		Outputs:            outputs,
+		Steps:              runnableSteps(jobTaskSpec.Steps),
+		Paths:              jobTaskSpec.Properties.Paths,
+		OutputStorage:      outputStorage,
+		OutputObjectPrefix: jobtypes.GetOutputObjectPrefix(workflowCtx.WorkflowName, workflowCtx.TaskID, job.Name),
+		ServiceContainers:  services,
 	}
 }
@@ -89,6 +89,19 @@ func (c *HelmDeployJobCtl) Run(ctx context.Context) {
 	c.job.Status = config.StatusRunning
 	c.ack()
 
+	images := []string{}
+	for _, imageAndModule := range c.jobTaskSpec.ImageAndModules {
+		images = append(images, imageAndModule.Image)
+	}
+	pinned, err := verifySignatures(ctx, c.jobTaskSpec.SignatureCheck, images)
+	if err != nil {
+		logError(c.job, err.Error(), c.logger)
+		return
+	}
+	for i, imageAndModule := range c.jobTaskSpec.ImageAndModules {
+		imageAndModule.Image = pinned[i]
+	}
+
 	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
 		Name:    c.workflowCtx.ProjectName,
 		EnvName: c.jobTaskSpec.Env,
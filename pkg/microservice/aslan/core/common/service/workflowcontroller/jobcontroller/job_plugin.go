@@ -175,7 +175,7 @@ func (c *PluginJobCtl) complete(ctx context.Context) {
 		c.job.Error = err.Error()
 	}
 
-	if err := saveContainerLog(c.jobTaskSpec.Properties.Namespace, c.jobTaskSpec.Properties.ClusterID, c.workflowCtx.WorkflowName, c.job.Name, c.workflowCtx.TaskID, jobLabel, c.kubeclient); err != nil {
+	if err := saveContainerLog(c.jobTaskSpec.Properties.Namespace, c.jobTaskSpec.Properties.ClusterID, c.workflowCtx.WorkflowName, c.job.Name, c.workflowCtx.TaskID, jobLabel, nil, c.kubeclient); err != nil {
 		c.logger.Error(err)
 		c.job.Error = err.Error()
 		return
@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -59,7 +60,9 @@ import (
 	s3tool "github.com/koderover/zadig/pkg/tool/s3"
 	commontypes "github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
 	"github.com/koderover/zadig/pkg/util"
+	"github.com/koderover/zadig/pkg/util/redact"
 )
 
 const (
@@ -476,6 +479,10 @@ func buildJob(jobType, jobImage, jobName, clusterID, currentNamespace string, re
 			SubPath:   jobTaskSpec.Properties.Cache.NFSProperties.Subpath,
 		})
 	}
+	setBuildEngineContainers(job, jobTaskSpec)
+	setImageScanContainers(job, jobTaskSpec)
+	setImageSigningContainers(job, jobTaskSpec)
+	setServiceContainers(job, jobTaskSpec.Properties.ServiceContainers)
 	ensureVolumeMounts(job)
 	return job, nil
 }
@@ -926,51 +933,158 @@ func getJobOutputFromTerminalMsg(namespace, containerName string, jobTask *commo
 			}
 		}
 	}
-	writeOutputs(outputs, jobTask.Key, workflowCtx)
-	return nil
+	return writeOutputs(outputs, jobTask.Key, workflowCtx)
 }
 
 func getJobOutputFromRunningPod(namespace, containerName string, jobTask *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, kubeClient crClient.Client, clientset kubernetes.Interface, restConfig *rest.Config) error {
+	outputs := []*job.JobOutput{}
+	content, found, err := readFileFromRunningPod(namespace, containerName, job.JobTerminationFile, jobTask, kubeClient, clientset, restConfig)
+	if err != nil || !found {
+		return err
+	}
+	if err := json.Unmarshal([]byte(content), &outputs); err != nil {
+		return err
+	}
+	return writeOutputs(outputs, jobTask.Key, workflowCtx)
+}
+
+// getStepResultsFromRunningPod sets the status of the steps with the results reported by the job executor.
+func getStepResultsFromRunningPod(namespace, containerName string, jobTask *commonmodels.JobTask, steps []*commonmodels.StepTask, kubeClient crClient.Client, clientset kubernetes.Interface, restConfig *rest.Config) error {
+	results := []*job.StepResult{}
+	content, found, err := readFileFromRunningPod(namespace, containerName, job.JobStepResultFile, jobTask, kubeClient, clientset, restConfig)
+	if err != nil || !found {
+		return err
+	}
+	if err := json.Unmarshal([]byte(content), &results); err != nil {
+		return err
+	}
+	setStepResults(jobTask, steps, results)
+	return nil
+}
+
+func setStepResults(jobTask *commonmodels.JobTask, steps []*commonmodels.StepTask, results []*job.StepResult) {
+	resultMap := make(map[string]*job.StepResult, len(results))
+	for _, result := range results {
+		resultMap[result.Name] = result
+	}
+	for _, step := range steps {
+		result, ok := resultMap[step.Name]
+		if !ok {
+			continue
+		}
+		step.Status = config.Status(result.Status)
+		step.StartTime = result.StartTime
+		step.EndTime = result.EndTime
+		if result.Error != "" {
+			step.Error = result.Error
+		}
+	}
+	// show the step which fails the job instead of the job status only.
+	if jobTask.Error != "" {
+		return
+	}
+	for _, result := range results {
+		switch result.Status {
+		case job.StepStatusTimeout:
+			jobTask.Error = fmt.Sprintf("step %s timed out", result.Name)
+			return
+		case job.StepStatusFailed:
+			jobTask.Error = fmt.Sprintf("step %s failed: %s", result.Name, result.Error)
+			return
+		}
+	}
+}
+
+// readFileFromRunningPod reads a file in the job container, the second return value is false if the file does not exist.
+func readFileFromRunningPod(namespace, containerName, file string, jobTask *commonmodels.JobTask, kubeClient crClient.Client, clientset kubernetes.Interface, restConfig *rest.Config) (string, bool, error) {
 	jobLabel := &JobLabel{
 		JobType: string(jobTask.JobType),
 		JobName: jobTask.K8sJobName,
 	}
-	outputs := []*job.JobOutput{}
 	ls := getJobLabels(jobLabel)
 	pods, err := getter.ListPods(namespace, labels.Set(ls).AsSelector(), kubeClient)
 	if err != nil {
-		return err
+		return "", false, err
 	}
 	for _, pod := range pods {
 		stdout, _, success, err := podexec.KubeExec(clientset, restConfig, podexec.ExecOptions{
-			Command:       []string{"/bin/sh", "-c", fmt.Sprintf("test -f %[1]s && cat %[1]s", job.JobTerminationFile)},
+			Command:       []string{"/bin/sh", "-c", fmt.Sprintf("test -f %[1]s && cat %[1]s", file)},
 			Namespace:     namespace,
 			PodName:       pod.Name,
 			ContainerName: containerName,
 		})
 		if err != nil {
-			return fmt.Errorf("failed to exec pod: %v", err)
+			return "", false, fmt.Errorf("failed to exec pod: %v", err)
 		}
-		if !success {
-			return nil
-		}
-		if err := json.Unmarshal([]byte(stdout), &outputs); err != nil {
-			return err
-		}
-		break
+		return stdout, success, nil
 	}
-	writeOutputs(outputs, jobTask.Key, workflowCtx)
-	return nil
+	return "", false, nil
 }
 
-func writeOutputs(outputs []*job.JobOutput, outputKey string, workflowCtx *commonmodels.WorkflowTaskCtx) {
+func writeOutputs(outputs []*job.JobOutput, outputKey string, workflowCtx *commonmodels.WorkflowTaskCtx) error {
 	// write jobs output info to globalcontext so other job can use like this {{.job.jobKey.output.outputName}}
 	for _, output := range outputs {
+		if output.ObjectKey != "" {
+			value, err := getSpilledOutput(output.ObjectKey)
+			if err != nil {
+				return fmt.Errorf("get output %s from object storage error: %v", output.Name, err)
+			}
+			output.Value = value
+		}
 		workflowCtx.GlobalContextSet(job.GetJobOutputKey(outputKey, output.Name), output.Value)
 	}
+	return nil
 }
 
-func saveContainerLog(namespace, clusterID, workflowName, jobName string, taskID int64, jobLabel *JobLabel, kubeClient crClient.Client) error {
+// getSpilledOutput gets the output which is too large for the termination message from the default object storage.
+func getSpilledOutput(objectKey string) (string, error) {
+	store, err := commonrepo.NewS3StorageColl().FindDefault()
+	if err != nil {
+		return "", fmt.Errorf("failed to get default s3 storage: %s", err)
+	}
+	forcedPathStyle := true
+	if store.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	s3client, err := s3tool.NewClient(store.Endpoint, store.Ak, store.Sk, store.Region, store.Insecure, forcedPathStyle)
+	if err != nil {
+		return "", err
+	}
+	object, err := s3client.GetFile(store.Bucket, objectKey, &s3tool.DownloadOption{RetryNum: 3})
+	if err != nil {
+		return "", err
+	}
+	defer object.Body.Close()
+	value, err := io.ReadAll(object.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(string(value), "\n"), nil
+}
+
+// getOutputStorage returns the default object storage for job executor to save large outputs.
+func getOutputStorage() (*step.S3, error) {
+	store, err := commonrepo.NewS3StorageColl().FindDefault()
+	if err != nil {
+		return nil, err
+	}
+	resp := &step.S3{
+		Ak:        store.Ak,
+		Sk:        store.Sk,
+		Endpoint:  store.Endpoint,
+		Bucket:    store.Bucket,
+		Subfolder: store.Subfolder,
+		Insecure:  store.Insecure,
+		Provider:  store.Provider,
+		Region:    store.Region,
+	}
+	if store.Insecure {
+		resp.Protocol = "http"
+	}
+	return resp, nil
+}
+
+func saveContainerLog(namespace, clusterID, workflowName, jobName string, taskID int64, jobLabel *JobLabel, secrets []string, kubeClient crClient.Client) error {
 	selector := labels.Set(getJobLabels(jobLabel)).AsSelector()
 	pods, err := getter.ListPods(namespace, selector, kubeClient)
 	if err != nil {
@@ -1000,7 +1114,15 @@ func saveContainerLog(namespace, clusterID, workflowName, jobName string, taskID
 	if err := containerlog.GetContainerLogs(namespace, pods[0].Name, pods[0].Spec.Containers[0].Name, false, int64(0), buf, clientSet); err != nil {
 		return fmt.Errorf("failed to get container logs: %s", err)
 	}
+	return uploadContainerLog(buf, workflowName, jobName, taskID, secrets)
+}
 
+// uploadContainerLog saves the log to the object storage, it can be found by the workflow name, task id and log name.
+// secrets in the log are masked before it is saved.
+func uploadContainerLog(buf *bytes.Buffer, workflowName, logName string, taskID int64, secrets []string) error {
+	if len(secrets) > 0 {
+		buf = bytes.NewBufferString(redact.New(secrets...).Redact(buf.String()))
+	}
 	store, err := commonrepo.NewS3StorageColl().FindDefault()
 	if err != nil {
 		return fmt.Errorf("failed to get default s3 storage: %s", err)
@@ -1025,7 +1147,7 @@ func saveContainerLog(namespace, clusterID, workflowName, jobName string, taskID
 			if err != nil {
 				return fmt.Errorf("saveContainerLog s3 create client error: %v", err)
 			}
-			fileName := strings.Replace(strings.ToLower(jobName), "_", "-", -1)
+			fileName := strings.Replace(strings.ToLower(logName), "_", "-", -1)
 			objectKey := GetObjectPath(store.Subfolder, fileName+".log")
 			if err = s3client.Upload(
 				store.Bucket,
@@ -1043,6 +1165,62 @@ func saveContainerLog(namespace, clusterID, workflowName, jobName string, taskID
 	return nil
 }
 
+// copyContainerLog copies the saved container log of the job to a new name.
+func copyContainerLog(workflowName, jobName, newName string, taskID int64) error {
+	store, err := commonrepo.NewS3StorageColl().FindDefault()
+	if err != nil {
+		return fmt.Errorf("failed to get default s3 storage: %s", err)
+	}
+	if store.Subfolder != "" {
+		store.Subfolder = fmt.Sprintf("%s/%s/%d/%s", store.Subfolder, strings.ToLower(workflowName), taskID, "log")
+	} else {
+		store.Subfolder = fmt.Sprintf("%s/%d/%s", strings.ToLower(workflowName), taskID, "log")
+	}
+	forcedPathStyle := true
+	if store.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	s3client, err := s3tool.NewClient(store.Endpoint, store.Ak, store.Sk, store.Region, store.Insecure, forcedPathStyle)
+	if err != nil {
+		return fmt.Errorf("copyContainerLog s3 create client error: %v", err)
+	}
+	oldKey := GetObjectPath(store.Subfolder, strings.Replace(strings.ToLower(jobName), "_", "-", -1)+".log")
+	newKey := GetObjectPath(store.Subfolder, strings.Replace(strings.ToLower(newName), "_", "-", -1)+".log")
+	return s3client.CopyObject(store.Bucket, oldKey, newKey)
+}
+
+// getPodFailureClass finds out why the pods of the job failed.
+func getPodFailureClass(namespace, jobName string, status config.Status, kubeClient crClient.Client) config.FailureClass {
+	pods, err := getter.ListPods(namespace, labels.Set{"job-name": jobName}.AsSelector(), kubeClient)
+	if err != nil {
+		log.Errorf("failed to find pod with label job-name=%s %v", jobName, err)
+	}
+	return podFailureClass(pods, status)
+}
+
+// podFailureClass classifies the failure of the job by the state of its pods, failures which are not caused
+// by the pods are script failures unless the job timed out.
+func podFailureClass(pods []*corev1.Pod, status config.Status) config.FailureClass {
+	for _, pod := range pods {
+		if pod.Status.Reason == "Evicted" {
+			return config.FailurePodEviction
+		}
+		for _, containerStatus := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			if containerStatus.State.Waiting == nil {
+				continue
+			}
+			switch containerStatus.State.Waiting.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+				return config.FailureImagePull
+			}
+		}
+	}
+	if status == config.StatusTimeout {
+		return config.FailureTimeout
+	}
+	return config.FailureScript
+}
+
 func GetObjectPath(subFolder, name string) string {
 	// target should not be started with /
 	if subFolder != "" {
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types/job"
+)
+
+const defaultRetryBackoff = 10 * time.Second
+
+// shouldRetry returns true if the job failed for a failure class to retry and has retries left.
+func shouldRetry(ctx context.Context, job *commonmodels.JobTask, attempt int) bool {
+	policy := job.RetryPolicy
+	if policy == nil || attempt > policy.Retries || ctx.Err() != nil {
+		return false
+	}
+	if job.Status != config.StatusFailed && job.Status != config.StatusTimeout {
+		return false
+	}
+	if len(policy.RetryOn) == 0 {
+		return true
+	}
+	for _, class := range policy.RetryOn {
+		if class == job.FailureClass {
+			return true
+		}
+	}
+	return false
+}
+
+// retryBackoff returns the time to wait before the given retry, starts from the backoff of the policy
+// and doubles for each retry.
+func retryBackoff(policy *commonmodels.RetryPolicy, retry int) time.Duration {
+	backoff := defaultRetryBackoff
+	if policy.Backoff > 0 {
+		backoff = time.Duration(policy.Backoff) * time.Second
+	}
+	maxBackoff := time.Duration(policy.MaxBackoff) * time.Second
+	for i := 1; i < retry; i++ {
+		backoff *= 2
+		if maxBackoff > 0 && backoff >= maxBackoff {
+			break
+		}
+	}
+	if maxBackoff > 0 && backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+// recordJobAttempt keeps the result and container log of the failed attempt before the job is retried.
+func recordJobAttempt(jobTask *commonmodels.JobTask, attempt int, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger) {
+	record := &commonmodels.JobAttempt{
+		Attempt:      attempt,
+		Status:       jobTask.Status,
+		Error:        jobTask.Error,
+		FailureClass: jobTask.FailureClass,
+		StartTime:    jobTask.StartTime,
+		EndTime:      jobTask.EndTime,
+	}
+	logName := job.GetJobAttemptLogName(jobTask.Name, attempt)
+	if err := copyContainerLog(workflowCtx.WorkflowName, jobTask.Name, logName, workflowCtx.TaskID); err != nil {
+		logger.Warnf("job: %s attempt %d has no container log: %v", jobTask.Name, attempt, err)
+	} else {
+		record.LogName = logName
+	}
+	jobTask.Attempts = append(jobTask.Attempts, record)
+}
+
+func defaultFailureClass(status config.Status) config.FailureClass {
+	if status == config.StatusTimeout {
+		return config.FailureTimeout
+	}
+	return config.FailureUnknown
+}
+
+// JobFailureIgnored returns true if the job failed but is allowed to fail, cancelled jobs are never ignored.
+func JobFailureIgnored(job *commonmodels.JobTask) bool {
+	return job.ContinueOnError && (job.Status == config.StatusFailed || job.Status == config.StatusTimeout)
+}
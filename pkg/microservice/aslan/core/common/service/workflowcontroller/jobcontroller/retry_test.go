@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing job retry", func() {
+
+	newPod := func(reason string, waitingReasons ...string) *corev1.Pod {
+		pod := &corev1.Pod{Status: corev1.PodStatus{Reason: reason}}
+		for _, waitingReason := range waitingReasons {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: waitingReason}},
+			})
+		}
+		return pod
+	}
+
+	table.DescribeTable("classifying the failure of the pods",
+		func(pods []*corev1.Pod, status config.Status, expected config.FailureClass) {
+			Expect(podFailureClass(pods, status)).To(Equal(expected))
+		},
+		table.Entry("evicted pod", []*corev1.Pod{newPod("Evicted")}, config.StatusFailed, config.FailurePodEviction),
+		table.Entry("eviction wins over timeout", []*corev1.Pod{newPod("Evicted")}, config.StatusTimeout, config.FailurePodEviction),
+		table.Entry("image pull error", []*corev1.Pod{newPod("", "ErrImagePull")}, config.StatusFailed, config.FailureImagePull),
+		table.Entry("image pull back off", []*corev1.Pod{newPod("", "ContainerCreating", "ImagePullBackOff")}, config.StatusTimeout, config.FailureImagePull),
+		table.Entry("invalid image name", []*corev1.Pod{newPod("", "InvalidImageName")}, config.StatusFailed, config.FailureImagePull),
+		table.Entry("init container image pull error", []*corev1.Pod{{Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImageNeverPull"}}}},
+		}}}, config.StatusFailed, config.FailureImagePull),
+		table.Entry("timeout without pod failures", []*corev1.Pod{newPod("", "ContainerCreating")}, config.StatusTimeout, config.FailureTimeout),
+		table.Entry("script failure", []*corev1.Pod{newPod("")}, config.StatusFailed, config.FailureScript),
+		table.Entry("no pods", nil, config.StatusFailed, config.FailureScript),
+	)
+
+	table.DescribeTable("computing the backoff of retries",
+		func(policy *commonmodels.RetryPolicy, retry int, expected time.Duration) {
+			Expect(retryBackoff(policy, retry)).To(Equal(expected))
+		},
+		table.Entry("default backoff", &commonmodels.RetryPolicy{}, 1, 10*time.Second),
+		table.Entry("default backoff doubled", &commonmodels.RetryPolicy{}, 3, 40*time.Second),
+		table.Entry("backoff of the policy", &commonmodels.RetryPolicy{Backoff: 3}, 1, 3*time.Second),
+		table.Entry("backoff of the policy doubled", &commonmodels.RetryPolicy{Backoff: 3}, 4, 24*time.Second),
+		table.Entry("backoff limited", &commonmodels.RetryPolicy{Backoff: 5, MaxBackoff: 30}, 4, 30*time.Second),
+		table.Entry("backoff below the limit", &commonmodels.RetryPolicy{Backoff: 5, MaxBackoff: 30}, 3, 20*time.Second),
+		table.Entry("first backoff above the limit", &commonmodels.RetryPolicy{Backoff: 60, MaxBackoff: 30}, 1, 30*time.Second),
+		table.Entry("many retries limited", &commonmodels.RetryPolicy{Backoff: 1, MaxBackoff: 60}, 100, 60*time.Second),
+	)
+
+	table.DescribeTable("deciding whether to retry",
+		func(policy *commonmodels.RetryPolicy, status config.Status, class config.FailureClass, attempt int, expected bool) {
+			job := &commonmodels.JobTask{RetryPolicy: policy, Status: status, FailureClass: class}
+			Expect(shouldRetry(context.Background(), job, attempt)).To(Equal(expected))
+		},
+		table.Entry("no policy", nil, config.StatusFailed, config.FailureScript, 1, false),
+		table.Entry("retries left", &commonmodels.RetryPolicy{Retries: 2}, config.StatusFailed, config.FailureScript, 2, true),
+		table.Entry("no retries left", &commonmodels.RetryPolicy{Retries: 2}, config.StatusFailed, config.FailureScript, 3, false),
+		table.Entry("timeout", &commonmodels.RetryPolicy{Retries: 1}, config.StatusTimeout, config.FailureTimeout, 1, true),
+		table.Entry("cancelled", &commonmodels.RetryPolicy{Retries: 1}, config.StatusCancelled, config.FailureUnknown, 1, false),
+		table.Entry("failure class to retry on", &commonmodels.RetryPolicy{Retries: 1, RetryOn: []config.FailureClass{config.FailurePodEviction, config.FailureImagePull}},
+			config.StatusFailed, config.FailureImagePull, 1, true),
+		table.Entry("failure class not to retry on", &commonmodels.RetryPolicy{Retries: 1, RetryOn: []config.FailureClass{config.FailurePodEviction}},
+			config.StatusFailed, config.FailureScript, 1, false),
+	)
+
+	It("should not retry when the workflow is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		job := &commonmodels.JobTask{RetryPolicy: &commonmodels.RetryPolicy{Retries: 1}, Status: config.StatusFailed}
+		Expect(shouldRetry(ctx, job, 1)).To(BeFalse())
+	})
+
+	It("should ignore the failure of the jobs allowed to fail", func() {
+		Expect(JobFailureIgnored(&commonmodels.JobTask{ContinueOnError: true, Status: config.StatusFailed})).To(BeTrue())
+		Expect(JobFailureIgnored(&commonmodels.JobTask{ContinueOnError: true, Status: config.StatusTimeout})).To(BeTrue())
+		Expect(JobFailureIgnored(&commonmodels.JobTask{ContinueOnError: true, Status: config.StatusCancelled})).To(BeFalse())
+		Expect(JobFailureIgnored(&commonmodels.JobTask{Status: config.StatusFailed})).To(BeFalse())
+	})
+})
@@ -0,0 +1,237 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	crClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	kubeclient "github.com/koderover/zadig/pkg/shared/kube/client"
+	"github.com/koderover/zadig/pkg/tool/kube/containerlog"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/tool/kube/podexec"
+	"github.com/koderover/zadig/pkg/types/job"
+)
+
+const (
+	serviceContainerPrefix     = "service-"
+	defaultServiceReadyTimeout = 300
+)
+
+var serviceEnvInvalidRegex = regexp.MustCompile("[^A-Z0-9_]")
+
+func serviceContainerName(service *commonmodels.ServiceContainer) string {
+	return serviceContainerPrefix + service.Name
+}
+
+// setServiceContainers adds the service containers to the job pod as sidecars, their names are resolved
+// to the pod itself, since all containers in a pod share the network.
+func setServiceContainers(job *batchv1.Job, services []*commonmodels.ServiceContainer) {
+	if len(services) == 0 {
+		return
+	}
+	podSpec := &job.Spec.Template.Spec
+	hostnames := []string{}
+	for _, service := range services {
+		container := corev1.Container{
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Name:            serviceContainerName(service),
+			Image:           service.Image,
+			Command:         service.Command,
+			Args:            service.Args,
+			ReadinessProbe:  getServiceReadinessProbe(service),
+		}
+		for _, env := range service.Envs {
+			container.Env = append(container.Env, corev1.EnvVar{Name: env.Key, Value: env.Value})
+		}
+		for _, port := range service.Ports {
+			container.Ports = append(container.Ports, corev1.ContainerPort{ContainerPort: port})
+		}
+		podSpec.Containers = append(podSpec.Containers, container)
+		hostnames = append(hostnames, service.Name)
+	}
+	podSpec.HostAliases = append(podSpec.HostAliases, corev1.HostAlias{IP: "127.0.0.1", Hostnames: hostnames})
+}
+
+func getServiceReadinessProbe(service *commonmodels.ServiceContainer) *corev1.Probe {
+	probe := service.ReadinessProbe
+	if probe == nil {
+		if len(service.Ports) == 0 {
+			return nil
+		}
+		probe = &commonmodels.ServiceReadinessProbe{Type: commonmodels.ServiceProbeTCP}
+	}
+	port := probe.Port
+	if port <= 0 && len(service.Ports) > 0 {
+		port = service.Ports[0]
+	}
+	resp := &corev1.Probe{
+		PeriodSeconds:    probe.PeriodSeconds,
+		FailureThreshold: 1,
+	}
+	if resp.PeriodSeconds <= 0 {
+		resp.PeriodSeconds = 2
+	}
+	switch probe.Type {
+	case commonmodels.ServiceProbeHTTP:
+		path := probe.Path
+		if path == "" {
+			path = "/"
+		}
+		resp.HTTPGet = &corev1.HTTPGetAction{Path: path, Port: intstr.FromInt(int(port))}
+	case commonmodels.ServiceProbeExec:
+		resp.Exec = &corev1.ExecAction{Command: probe.Command}
+	default:
+		resp.TCPSocket = &corev1.TCPSocketAction{Port: intstr.FromInt(int(port))}
+	}
+	return resp
+}
+
+// getServiceContainerEnvs returns the envs to reach the service containers, e.g. SERVICE_MYSQL_HOST and SERVICE_MYSQL_PORT.
+func getServiceContainerEnvs(services []*commonmodels.ServiceContainer) []string {
+	resp := []string{}
+	for _, service := range services {
+		prefix := "SERVICE_" + serviceEnvInvalidRegex.ReplaceAllString(strings.ToUpper(service.Name), "_")
+		resp = append(resp, fmt.Sprintf("%s_HOST=%s", prefix, service.Name))
+		if len(service.Ports) > 0 {
+			resp = append(resp, fmt.Sprintf("%s_PORT=%d", prefix, service.Ports[0]))
+		}
+	}
+	return resp
+}
+
+// waitServiceContainersReady waits until all service containers are ready, then tells the job container to
+// run the steps by creating the ready file in it.
+func waitServiceContainersReady(ctx context.Context, namespace, jobName, jobContainer string, services []*commonmodels.ServiceContainer, kubeClient crClient.Client, clientset kubernetes.Interface, restConfig *rest.Config, xl *zap.SugaredLogger) (config.Status, error) {
+	var timeout int64
+	for _, service := range services {
+		readyTimeout := service.ReadyTimeout
+		if readyTimeout <= 0 {
+			readyTimeout = defaultServiceReadyTimeout
+		}
+		if readyTimeout > timeout {
+			timeout = readyTimeout
+		}
+	}
+	xl.Infof("wait service containers of job %s to be ready in %ds", jobName, timeout)
+	readyTimeout := time.After(time.Duration(timeout) * time.Second)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return config.StatusCancelled, nil
+		case <-readyTimeout:
+			return config.StatusTimeout, fmt.Errorf("service containers are not ready in %ds", timeout)
+		default:
+		}
+
+		pods, err := getter.ListPods(namespace, labels.Set{"job-name": jobName}.AsSelector(), kubeClient)
+		if err != nil {
+			xl.Errorf("failed to find pod with label job-name=%s %v", jobName, err)
+		}
+		for _, pod := range pods {
+			ready, err := serviceContainersReady(pod, services)
+			if err != nil {
+				return config.StatusFailed, err
+			}
+			if !ready {
+				continue
+			}
+			_, stderr, _, err := podexec.KubeExec(clientset, restConfig, podexec.ExecOptions{
+				Command:       []string{"/bin/sh", "-c", "touch " + job.ServicesReadyFile},
+				Namespace:     namespace,
+				PodName:       pod.Name,
+				ContainerName: jobContainer,
+			})
+			if err != nil {
+				xl.Warnf("failed to create the services ready file in pod %s: %v %s", pod.Name, err, stderr)
+				break
+			}
+			return config.StatusRunning, nil
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func serviceContainersReady(pod *corev1.Pod, services []*commonmodels.ServiceContainer) (bool, error) {
+	statuses := map[string]corev1.ContainerStatus{}
+	for _, status := range pod.Status.ContainerStatuses {
+		statuses[status.Name] = status
+	}
+	for _, service := range services {
+		status, ok := statuses[serviceContainerName(service)]
+		if !ok {
+			return false, nil
+		}
+		if status.State.Terminated != nil {
+			return false, fmt.Errorf("service container %s exited with code %d", service.Name, status.State.Terminated.ExitCode)
+		}
+		if status.State.Waiting != nil {
+			switch status.State.Waiting.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+				return false, fmt.Errorf("failed to pull image of service container %s: %s", service.Name, status.State.Waiting.Message)
+			}
+		}
+		if !status.Ready {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// saveServiceContainerLogs saves the logs of the service containers next to the log of the job.
+func saveServiceContainerLogs(namespace, clusterID, workflowName, jobName string, taskID int64, jobLabel *JobLabel, services []*commonmodels.ServiceContainer, secrets []string, kubeClient crClient.Client) error {
+	if len(services) == 0 {
+		return nil
+	}
+	pods, err := getter.ListPods(namespace, labels.Set(getJobLabels(jobLabel)).AsSelector(), kubeClient)
+	if err != nil {
+		return err
+	}
+	if len(pods) < 1 {
+		return fmt.Errorf("no pod found for job %s", jobName)
+	}
+	clientSet, err := kubeclient.GetClientset(config.HubServerAddress(), clusterID)
+	if err != nil {
+		return err
+	}
+	for _, service := range services {
+		buf := new(bytes.Buffer)
+		if err := containerlog.GetContainerLogs(namespace, pods[0].Name, serviceContainerName(service), false, int64(0), buf, clientSet); err != nil {
+			return fmt.Errorf("failed to get logs of service container %s: %s", service.Name, err)
+		}
+		if err := uploadContainerLog(buf, workflowName, job.GetServiceContainerLogName(jobName, service.Name), taskID, secrets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
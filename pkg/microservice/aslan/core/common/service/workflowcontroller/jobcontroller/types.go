@@ -18,6 +18,7 @@ package jobcontroller
 
 import (
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types/step"
 )
 
 type JobContext struct {
@@ -38,6 +39,11 @@ type JobContext struct {
 
 	Steps   []*commonmodels.StepTask `yaml:"steps"`
 	Outputs []string                 `yaml:"outputs"`
+	// OutputStorage 超出终止信息长度限制的输出保存到对象存储 [optional]
+	OutputStorage      *step.S3 `yaml:"output_storage"`
+	OutputObjectPrefix string   `yaml:"output_object_prefix"`
+	// ServiceContainers 服务容器就绪后才开始执行步骤 [optional]
+	ServiceContainers []string `yaml:"service_containers"`
 }
 
 type EnvVar []string
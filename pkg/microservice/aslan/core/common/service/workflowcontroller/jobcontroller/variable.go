@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"strings"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/util/variable"
+)
+
+// jobOutputVariables returns outputs of finished jobs, which are saved as {{.job.<key>.output.<name>}},
+// every output can also be referred as jobs.<key>.outputs.<name>.
+func jobOutputVariables(workflowCtx *commonmodels.WorkflowTaskCtx) map[string]string {
+	vars := map[string]string{}
+	workflowCtx.GlobalContextEach(func(k, v string) bool {
+		name := strings.TrimSuffix(strings.TrimPrefix(k, "{{."), "}}")
+		v = strings.Trim(v, "\n")
+		vars[name] = v
+		parts := strings.Split(name, ".")
+		if len(parts) >= 4 && parts[0] == "job" && parts[len(parts)-2] == "output" {
+			parts[0], parts[len(parts)-2] = "jobs", "outputs"
+			vars[strings.Join(parts, ".")] = v
+		}
+		return true
+	})
+	return vars
+}
+
+// renderJobVariables renders references to outputs of upstream jobs in the job spec.
+func renderJobVariables(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx) error {
+	renderer := variable.NewRenderer().Strict("job", "jobs")
+	for name, value := range jobOutputVariables(workflowCtx) {
+		renderer.Set(name, value)
+	}
+	return renderer.Render(job)
+}
@@ -23,6 +23,8 @@ import (
 	"fmt"
 	"time"
 
+	"go.uber.org/zap"
+
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
@@ -127,16 +129,151 @@ func InitQueue() error {
 	}
 
 	for _, task := range tasks {
-		// 如果 Queue 重新初始化, 取消所有 running tasks
-		if err := CancelWorkflowTask(setting.DefaultTaskRevoker, task.WorkflowName, task.TaskID, log); err != nil {
-			log.Errorf("[CancelRunningTask] error: %v", err)
+		claimed, err := claimTask(task.WorkflowName, task.TaskID)
+		if err != nil {
+			log.Errorf("claim workflow task %s:%d error: %v", task.WorkflowName, task.TaskID, err)
+			continue
+		}
+		if !claimed {
+			// the task is running on another replica, take it over once that replica is gone.
+			go adoptTask(task.WorkflowName, task.TaskID, log)
 			continue
 		}
+		recoverTask(task, log)
 	}
 	return nil
 }
 
-// WorfklowTaskSender 监控warpdrive空闲情况, 如果有空闲, 则发现下一个waiting task给warpdrive
+// recoverTask resumes the task claimed by this replica if it is waiting for approvals, or cancels it.
+func recoverTask(task *commonmodels.WorkflowTask, logger *zap.SugaredLogger) {
+	// tasks waiting for approvals are resumed, the approvals are persisted.
+	if resumeWaitingTask(task, logger) {
+		return
+	}
+	defer releaseTask(task.WorkflowName, task.TaskID, logger)
+	// 如果 Queue 重新初始化, 取消所有 running tasks
+	if err := CancelWorkflowTask(setting.DefaultTaskRevoker, task.WorkflowName, task.TaskID, logger); err != nil {
+		logger.Errorf("[CancelRunningTask] error: %v", err)
+	}
+}
+
+// adoptTask waits until the lease of the task held by another replica expires, and recovers the task
+// if it is still not completed.
+func adoptTask(workflowName string, taskID int64, logger *zap.SugaredLogger) {
+	for {
+		time.Sleep(taskLeaseTTL)
+		task, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+		if err != nil {
+			logger.Errorf("find workflow task %s:%d error: %v", workflowName, taskID, err)
+			return
+		}
+		if task.Status != config.StatusCreated && task.Status != config.StatusRunning {
+			return
+		}
+		claimed, err := claimTask(workflowName, taskID)
+		if err != nil {
+			logger.Errorf("claim workflow task %s:%d error: %v", workflowName, taskID, err)
+			continue
+		}
+		if claimed {
+			recoverTask(task, logger)
+			return
+		}
+	}
+}
+
+// resumeWaitingTask runs the task again if it is waiting for pending approvals except lark ones,
+// finished stages and jobs are skipped and the waiting stages pick up the persisted approvals.
+func resumeWaitingTask(task *commonmodels.WorkflowTask, logger *zap.SugaredLogger) bool {
+	if task.Status != config.StatusRunning || !waitingForApproval(task) {
+		return false
+	}
+
+	sysSetting, err := commonrepo.NewSystemSettingColl().Get()
+	if err != nil {
+		logger.Errorf("get system settings error: %v", err)
+		return false
+	}
+	logger.Infof("resume workflow task %s:%d waiting for approval", task.WorkflowName, task.TaskID)
+	ctl := NewWorkflowController(task, logger)
+	ctl.resumed = true
+	go ctl.Run(context.Background(), int(sysSetting.BuildConcurrency))
+	return true
+}
+
+// waitingForApproval returns true if no job of the task is running and any stage is waiting for a pending approval.
+func waitingForApproval(task *commonmodels.WorkflowTask) bool {
+	stages := []*commonmodels.StageTask{}
+	for _, stage := range task.Stages {
+		if stage.Status == config.StatusPassed || stage.Status == config.StatusSkipped {
+			continue
+		}
+		for _, job := range stage.Jobs {
+			if job.Status != "" && job.Status != config.StatusPassed && job.Status != config.StatusSkipped && !statusFailed(job.Status) {
+				return false
+			}
+		}
+		stages = append(stages, stage)
+	}
+	// only the first unfinished stage runs if the task does not run as dag.
+	if !task.DAG && len(stages) > 1 {
+		stages = stages[:1]
+	}
+	for _, stage := range stages {
+		if stage.Status != config.StatusRunning || stage.Approval == nil || !stage.Approval.Enabled || stage.Approval.Type == config.LarkApproval {
+			continue
+		}
+		approval, err := commonrepo.NewWorkflowApprovalColl().Find(task.WorkflowName, task.TaskID, stage.Name)
+		if err == nil && approval.Status == config.ApprovalStatusPending {
+			return true
+		}
+	}
+	return false
+}
+
+const taskLeaseTTL = time.Minute
+
+func taskLeaseName(workflowName string, taskID int64) string {
+	return fmt.Sprintf("workflow-task/%s/%d", workflowName, taskID)
+}
+
+// claimTask takes the lease of the task for this replica, so the task is run by one replica only.
+func claimTask(workflowName string, taskID int64) (bool, error) {
+	return commonrepo.NewLeaseColl().Acquire(taskLeaseName(workflowName, taskID), config.PodName(), taskLeaseTTL)
+}
+
+func releaseTask(workflowName string, taskID int64, logger *zap.SugaredLogger) {
+	if err := commonrepo.NewLeaseColl().Release(taskLeaseName(workflowName, taskID), config.PodName()); err != nil {
+		logger.Errorf("release workflow task %s:%d error: %v", workflowName, taskID, err)
+	}
+}
+
+// keepTask renews the lease of the task until the returned function is called, which releases the lease.
+func keepTask(workflowName string, taskID int64, logger *zap.SugaredLogger) func() {
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(taskLeaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if claimed, err := claimTask(workflowName, taskID); err != nil || !claimed {
+				logger.Warnf("renew the lease of workflow task %s:%d failed, claimed: %v, err: %v", workflowName, taskID, claimed, err)
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		releaseTask(workflowName, taskID, logger)
+	}
+}
+
+// WorfklowTaskSender 监控warpdrive空闲情况, 如果有空闲, 则按优先级和配额调度下一个等待中的task
 // 并将task状态设置为queued
 func WorfklowTaskSender() {
 	for {
@@ -145,40 +282,18 @@ func WorfklowTaskSender() {
 		sysSetting, err := commonrepo.NewSystemSettingColl().Get()
 		if err != nil {
 			log.Errorf("get system stettings error: %v", err)
-		}
-		//c.checkAgents()
-		if !hasAgentAvaiable(int(sysSetting.WorkflowConcurrency)) {
 			continue
 		}
-		t, err := NextWaitingTask()
-		if err != nil {
-			// no waiting task found
-			blockTasks, err := BlockedTaskQueue()
-			if err != nil {
-				//no blocked task found
-				continue
+		for hasAgentAvaiable(int(sysSetting.WorkflowConcurrency)) {
+			t := NextScheduledTask()
+			if t == nil {
+				break
 			}
-			for _, blockTask := range blockTasks {
-				if hasAgentAvaiable(int(sysSetting.WorkflowConcurrency)) {
-					//判断相同的工作流是否正在运行
-					if ParallelRunningAndQueuedTasks(blockTask) {
-						continue
-					}
-					// update agent and queue
-					if err := updateQueueAndRunTask(blockTask, int(sysSetting.BuildConcurrency)); err != nil {
-						continue
-					}
-				} else {
-					break
-				}
+			// update agent and queue
+			if err := updateQueueAndRunTask(t, int(sysSetting.BuildConcurrency)); err != nil {
+				break
 			}
-			continue
-		}
-		// update agent and queue
-		if err := updateQueueAndRunTask(t, int(sysSetting.BuildConcurrency)); err != nil {
-			continue
 		}
-
 	}
 }
 
@@ -190,7 +305,7 @@ func RunningAndQueuedTasks() []*commonmodels.WorkflowQueue {
 	tasks := make([]*commonmodels.WorkflowQueue, 0)
 	for _, t := range ListTasks() {
 		// task状态为TaskQueued说明task已经被send到nsq,wd已经开始处理但是没有返回ack
-		if t.Status == config.StatusRunning || t.Status == config.StatusQueued {
+		if queueTaskRunning(t) {
 			tasks = append(tasks, t)
 		}
 	}
@@ -206,50 +321,6 @@ func ListTasks() []*commonmodels.WorkflowQueue {
 	return queues
 }
 
-// NextWaitingTask 查询下一个等待的task
-func NextWaitingTask() (*commonmodels.WorkflowQueue, error) {
-	opt := &commonrepo.ListWorfklowQueueOption{
-		Status: config.StatusWaiting,
-	}
-
-	tasks, err := commonrepo.NewWorkflowQueueColl().List(opt)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, t := range tasks {
-		return t, nil
-	}
-
-	return nil, errors.New("no waiting task found")
-}
-
-func BlockedTaskQueue() ([]*commonmodels.WorkflowQueue, error) {
-	opt := &commonrepo.ListWorfklowQueueOption{
-		Status: config.StatusBlocked,
-	}
-
-	queues, err := commonrepo.NewWorkflowQueueColl().List(opt)
-	if err != nil || len(queues) == 0 {
-		return nil, errors.New("no blocked task found")
-	}
-
-	return queues, nil
-}
-
-func ParallelRunningAndQueuedTasks(currentTask *commonmodels.WorkflowQueue) bool {
-	for _, t := range ListTasks() {
-		// task状态为TaskQueued说明task已经被send到nsq,wd已经开始处理但是没有返回ack
-		if t.Status != config.StatusRunning && t.Status != config.StatusQueued {
-			continue
-		}
-		if t.WorkflowName == currentTask.WorkflowName {
-			return true
-		}
-	}
-	return false
-}
-
 func updateQueueAndRunTask(t *commonmodels.WorkflowQueue, jobConcurrency int) error {
 	logger := log.SugaredLogger()
 	// 更新队列状态为TaskQueued
@@ -287,6 +358,8 @@ func ConvertTaskToQueue(task *commonmodels.WorkflowTask) *commonmodels.WorkflowQ
 		TaskRevoker:         task.TaskRevoker,
 		CreateTime:          task.CreateTime,
 		MultiRun:            task.MultiRun,
+		Priority:            task.Priority,
+		Concurrency:         task.Concurrency,
 	}
 }
 
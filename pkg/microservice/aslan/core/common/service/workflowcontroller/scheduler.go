@@ -0,0 +1,256 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"sort"
+	"time"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	templaterepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/template"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+const (
+	// defaultTaskDuration is used to estimate the wait time if the workflow has no finished task, in seconds.
+	defaultTaskDuration = 10 * 60
+	taskDurationSamples = 10
+)
+
+// QueuedTask is a task waiting in the queue, EstimatedWait is in seconds.
+type QueuedTask struct {
+	*commonmodels.WorkflowQueue
+	Position      int   `json:"position"`
+	EstimatedWait int64 `json:"estimated_wait"`
+}
+
+// queueState counts the running tasks of each workflow and project to apply the quotas.
+type queueState struct {
+	workflowRunning map[string]int
+	projectRunning  map[string]int
+	projectQuotas   map[string]int
+}
+
+func newQueueState(queues []*commonmodels.WorkflowQueue) *queueState {
+	s := &queueState{
+		workflowRunning: make(map[string]int),
+		projectRunning:  make(map[string]int),
+		projectQuotas:   make(map[string]int),
+	}
+	for _, t := range queues {
+		if queueTaskRunning(t) {
+			s.workflowRunning[t.WorkflowName]++
+			s.projectRunning[t.ProjectName]++
+		}
+	}
+	return s
+}
+
+func (s *queueState) projectQuota(projectName string) int {
+	if quota, ok := s.projectQuotas[projectName]; ok {
+		return quota
+	}
+	quota := 0
+	if project, err := templaterepo.NewProductColl().Find(projectName); err == nil {
+		quota = project.WorkflowConcurrency
+	}
+	s.projectQuotas[projectName] = quota
+	return quota
+}
+
+// runnable reports whether the task can run now without exceeding the quotas of its workflow and project.
+func (s *queueState) runnable(t *commonmodels.WorkflowQueue) bool {
+	workflowQuota := t.Concurrency
+	if !t.MultiRun {
+		workflowQuota = 1
+	}
+	if workflowQuota > 0 && s.workflowRunning[t.WorkflowName] >= workflowQuota {
+		return false
+	}
+	projectQuota := s.projectQuota(t.ProjectName)
+	return projectQuota <= 0 || s.projectRunning[t.ProjectName] < projectQuota
+}
+
+// before reports whether a should be scheduled before b. preempted tasks go first, then tasks with higher
+// priority, then tasks of the project with fewer running tasks to share the slots fairly, then older tasks.
+func (s *queueState) before(a, b *commonmodels.WorkflowQueue) bool {
+	if a.Preempted != b.Preempted {
+		return a.Preempted
+	}
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if s.projectRunning[a.ProjectName] != s.projectRunning[b.ProjectName] {
+		return s.projectRunning[a.ProjectName] < s.projectRunning[b.ProjectName]
+	}
+	if a.CreateTime != b.CreateTime {
+		return a.CreateTime < b.CreateTime
+	}
+	return a.TaskID < b.TaskID
+}
+
+// order sorts pending tasks in the order they are expected to run, tasks blocked by quotas go last.
+func (s *queueState) order(pending []*commonmodels.WorkflowQueue) {
+	sort.SliceStable(pending, func(i, j int) bool {
+		if ri, rj := s.runnable(pending[i]), s.runnable(pending[j]); ri != rj {
+			return ri
+		}
+		return s.before(pending[i], pending[j])
+	})
+}
+
+// NextScheduledTask returns the pending task to run next, nil if no pending task can run now.
+func NextScheduledTask() *commonmodels.WorkflowQueue {
+	queues := ListTasks()
+	return nextScheduledTask(queues, newQueueState(queues))
+}
+
+func nextScheduledTask(queues []*commonmodels.WorkflowQueue, state *queueState) *commonmodels.WorkflowQueue {
+	var next *commonmodels.WorkflowQueue
+	for _, t := range queues {
+		if !queueTaskPending(t) || !state.runnable(t) {
+			continue
+		}
+		if next == nil || state.before(t, next) {
+			next = t
+		}
+	}
+	return next
+}
+
+// ListQueuedTasks returns the pending tasks in the order they are expected to run.
+func ListQueuedTasks() ([]*QueuedTask, error) {
+	queues, err := commonrepo.NewWorkflowQueueColl().List(&commonrepo.ListWorfklowQueueOption{})
+	if err != nil {
+		return nil, err
+	}
+	sysSetting, err := commonrepo.NewSystemSettingColl().Get()
+	if err != nil {
+		return nil, err
+	}
+
+	running, pending := []*commonmodels.WorkflowQueue{}, []*commonmodels.WorkflowQueue{}
+	for _, t := range queues {
+		switch {
+		case queueTaskRunning(t):
+			running = append(running, t)
+		case queueTaskPending(t):
+			pending = append(pending, t)
+		}
+	}
+	newQueueState(queues).order(pending)
+
+	waits := estimateWaits(running, pending, int(sysSetting.WorkflowConcurrency))
+	resp := make([]*QueuedTask, 0, len(pending))
+	for i, t := range pending {
+		resp = append(resp, &QueuedTask{WorkflowQueue: t, Position: i + 1, EstimatedWait: waits[i]})
+	}
+	return resp, nil
+}
+
+// GetQueuedTask returns the position of the task in the queue, nil if it is not waiting in the queue.
+func GetQueuedTask(workflowName string, taskID int64) *QueuedTask {
+	tasks, err := ListQueuedTasks()
+	if err != nil {
+		log.Errorf("list queued tasks error: %v", err)
+		return nil
+	}
+	for _, t := range tasks {
+		if t.WorkflowName == workflowName && t.TaskID == taskID {
+			return t
+		}
+	}
+	return nil
+}
+
+func UpdateQueuedTaskPriority(workflowName string, taskID int64, priority int) error {
+	if err := commonrepo.NewWorkflowQueueColl().UpdatePriority(workflowName, taskID, priority); err != nil {
+		return err
+	}
+	return commonrepo.NewworkflowTaskv4Coll().UpdatePriority(workflowName, taskID, priority)
+}
+
+func PreemptQueuedTask(workflowName string, taskID int64) error {
+	return commonrepo.NewWorkflowQueueColl().Preempt(workflowName, taskID)
+}
+
+// estimateWaits estimates the wait time of the ordered pending tasks, every task takes the first free slot
+// of the workflow concurrency, and occupies it for the average duration of the latest tasks of its workflow.
+func estimateWaits(running, pending []*commonmodels.WorkflowQueue, concurrency int) []int64 {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	durations := make(map[string]int64)
+	duration := func(workflowName string) int64 {
+		if d, ok := durations[workflowName]; ok {
+			return d
+		}
+		durations[workflowName] = averageTaskDuration(workflowName)
+		return durations[workflowName]
+	}
+
+	now := time.Now().Unix()
+	slots := make([]int64, concurrency)
+	for i, t := range running {
+		if i >= concurrency {
+			break
+		}
+		task, err := commonrepo.NewworkflowTaskv4Coll().Find(t.WorkflowName, t.TaskID)
+		if err != nil || task.StartTime == 0 {
+			slots[i] = duration(t.WorkflowName)
+			continue
+		}
+		if remaining := duration(t.WorkflowName) - (now - task.StartTime); remaining > 0 {
+			slots[i] = remaining
+		}
+	}
+
+	resp := make([]int64, 0, len(pending))
+	for _, t := range pending {
+		free := 0
+		for i := range slots {
+			if slots[i] < slots[free] {
+				free = i
+			}
+		}
+		resp = append(resp, slots[free])
+		slots[free] += duration(t.WorkflowName)
+	}
+	return resp
+}
+
+func averageTaskDuration(workflowName string) int64 {
+	tasks, err := commonrepo.NewworkflowTaskv4Coll().ListFinished(workflowName, taskDurationSamples)
+	if err != nil || len(tasks) == 0 {
+		return defaultTaskDuration
+	}
+	var total int64
+	for _, task := range tasks {
+		total += task.EndTime - task.StartTime
+	}
+	return total / int64(len(tasks))
+}
+
+func queueTaskRunning(t *commonmodels.WorkflowQueue) bool {
+	return t.Status == config.StatusRunning || t.Status == config.StatusQueued
+}
+
+func queueTaskPending(t *commonmodels.WorkflowQueue) bool {
+	return t.Status == config.StatusWaiting || t.Status == config.StatusBlocked
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func newQueueTask(workflowName, projectName string, taskID int64, status config.Status, createTime int64) *commonmodels.WorkflowQueue {
+	return &commonmodels.WorkflowQueue{
+		WorkflowName: workflowName,
+		ProjectName:  projectName,
+		TaskID:       taskID,
+		Status:       status,
+		CreateTime:   createTime,
+		MultiRun:     true,
+	}
+}
+
+func withPriority(t *commonmodels.WorkflowQueue, priority int) *commonmodels.WorkflowQueue {
+	t.Priority = priority
+	return t
+}
+
+func preempted(t *commonmodels.WorkflowQueue) *commonmodels.WorkflowQueue {
+	t.Preempted = true
+	return t
+}
+
+func singleRun(t *commonmodels.WorkflowQueue) *commonmodels.WorkflowQueue {
+	t.MultiRun = false
+	return t
+}
+
+func withConcurrency(t *commonmodels.WorkflowQueue, concurrency int) *commonmodels.WorkflowQueue {
+	t.Concurrency = concurrency
+	return t
+}
+
+// newTestQueueState sets the quotas of the projects so that they are not loaded from the database.
+func newTestQueueState(queues []*commonmodels.WorkflowQueue, projectQuotas map[string]int) *queueState {
+	state := newQueueState(queues)
+	for _, t := range queues {
+		state.projectQuotas[t.ProjectName] = projectQuotas[t.ProjectName]
+	}
+	return state
+}
+
+var _ = Describe("Testing the workflow task scheduler", func() {
+
+	table.DescribeTable("picking the next task",
+		func(queues []*commonmodels.WorkflowQueue, projectQuotas map[string]int, expected string) {
+			next := nextScheduledTask(queues, newTestQueueState(queues, projectQuotas))
+			if expected == "" {
+				Expect(next).To(BeNil())
+				return
+			}
+			Expect(next).NotTo(BeNil())
+			Expect(next.WorkflowName).To(Equal(expected))
+		},
+		table.Entry("no pending tasks", []*commonmodels.WorkflowQueue{
+			newQueueTask("build", "web", 1, config.StatusRunning, 1),
+		}, nil, ""),
+		table.Entry("the oldest task", []*commonmodels.WorkflowQueue{
+			newQueueTask("build", "web", 1, config.StatusWaiting, 20),
+			newQueueTask("deploy", "web", 1, config.StatusWaiting, 10),
+		}, nil, "deploy"),
+		table.Entry("the task with higher priority", []*commonmodels.WorkflowQueue{
+			newQueueTask("build", "web", 1, config.StatusWaiting, 10),
+			withPriority(newQueueTask("deploy", "web", 1, config.StatusWaiting, 20), 5),
+		}, nil, "deploy"),
+		table.Entry("the preempted task before the task with higher priority", []*commonmodels.WorkflowQueue{
+			withPriority(newQueueTask("build", "web", 1, config.StatusWaiting, 10), 5),
+			preempted(newQueueTask("deploy", "web", 1, config.StatusBlocked, 20)),
+		}, nil, "deploy"),
+		table.Entry("the task of the project with fewer running tasks", []*commonmodels.WorkflowQueue{
+			newQueueTask("build", "web", 1, config.StatusRunning, 1),
+			newQueueTask("test", "web", 1, config.StatusWaiting, 10),
+			newQueueTask("deploy", "api", 1, config.StatusWaiting, 20),
+		}, nil, "deploy"),
+		table.Entry("priority before fair share", []*commonmodels.WorkflowQueue{
+			newQueueTask("build", "web", 1, config.StatusRunning, 1),
+			withPriority(newQueueTask("test", "web", 1, config.StatusWaiting, 10), 1),
+			newQueueTask("deploy", "api", 1, config.StatusWaiting, 20),
+		}, nil, "test"),
+		table.Entry("skipping the workflow which does not run concurrently", []*commonmodels.WorkflowQueue{
+			singleRun(newQueueTask("build", "web", 1, config.StatusRunning, 1)),
+			withPriority(singleRun(newQueueTask("build", "web", 2, config.StatusWaiting, 10)), 9),
+			newQueueTask("deploy", "web", 1, config.StatusWaiting, 20),
+		}, nil, "deploy"),
+		table.Entry("skipping the workflow at its concurrency", []*commonmodels.WorkflowQueue{
+			withConcurrency(newQueueTask("build", "web", 1, config.StatusRunning, 1), 2),
+			withConcurrency(newQueueTask("build", "web", 2, config.StatusQueued, 2), 2),
+			withConcurrency(newQueueTask("build", "web", 3, config.StatusWaiting, 10), 2),
+			newQueueTask("deploy", "web", 1, config.StatusWaiting, 20),
+		}, nil, "deploy"),
+		table.Entry("the workflow below its concurrency", []*commonmodels.WorkflowQueue{
+			withConcurrency(newQueueTask("build", "web", 1, config.StatusRunning, 1), 2),
+			withConcurrency(newQueueTask("build", "web", 2, config.StatusWaiting, 10), 2),
+			newQueueTask("deploy", "web", 1, config.StatusWaiting, 20),
+		}, nil, "build"),
+		table.Entry("skipping the project at its quota", []*commonmodels.WorkflowQueue{
+			newQueueTask("build", "web", 1, config.StatusRunning, 1),
+			preempted(newQueueTask("test", "web", 1, config.StatusWaiting, 10)),
+			newQueueTask("deploy", "api", 1, config.StatusWaiting, 20),
+		}, map[string]int{"web": 1}, "deploy"),
+		table.Entry("all projects at their quotas", []*commonmodels.WorkflowQueue{
+			newQueueTask("build", "web", 1, config.StatusRunning, 1),
+			newQueueTask("test", "web", 1, config.StatusWaiting, 10),
+		}, map[string]int{"web": 1}, ""),
+	)
+
+	It("should order the pending tasks with the blocked ones last", func() {
+		queues := []*commonmodels.WorkflowQueue{
+			newQueueTask("build", "web", 1, config.StatusRunning, 1),
+			newQueueTask("test", "web", 1, config.StatusWaiting, 10),
+			newQueueTask("lint", "api", 1, config.StatusWaiting, 30),
+			withPriority(newQueueTask("deploy", "api", 1, config.StatusWaiting, 40), 1),
+			newQueueTask("scan", "api", 1, config.StatusWaiting, 20),
+		}
+		pending := queues[1:]
+		newTestQueueState(queues, map[string]int{"web": 1}).order(pending)
+		names := []string{}
+		for _, t := range pending {
+			names = append(names, t.WorkflowName)
+		}
+		Expect(names).To(Equal([]string{"deploy", "scan", "lint", "test"}))
+	})
+})
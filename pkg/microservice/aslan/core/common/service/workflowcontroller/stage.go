@@ -33,26 +33,11 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/instantmessage"
 	larkservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/lark"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/jobcontroller"
 	"github.com/koderover/zadig/pkg/tool/lark"
 	"github.com/koderover/zadig/pkg/tool/log"
 )
 
-type approveMap struct {
-	m map[string]*approveWithLock
-	sync.RWMutex
-}
-
-type approveWithLock struct {
-	approval *commonmodels.NativeApproval
-	sync.RWMutex
-}
-
-var globalApproveMap approveMap
-
-func init() {
-	globalApproveMap.m = make(map[string]*approveWithLock, 0)
-}
-
 type StageCtl interface {
 	Run(ctx context.Context, concurrency int)
 }
@@ -83,6 +68,10 @@ func runStage(ctx context.Context, stage *commonmodels.StageTask, workflowCtx *c
 
 func RunStages(ctx context.Context, stages []*commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) {
 	for _, stage := range stages {
+		// stages of a resumed task may have finished before.
+		if stage.Status == config.StatusPassed || stage.Status == config.StatusSkipped {
+			continue
+		}
 		runStage(ctx, stage, workflowCtx, concurrency, logger, ack)
 		if statusFailed(stage.Status) {
 			return
@@ -90,13 +79,72 @@ func RunStages(ctx context.Context, stages []*commonmodels.StageTask, workflowCt
 	}
 }
 
-func ApproveStage(workflowName, stageName, userName, userID, comment string, taskID int64, approve bool) error {
-	approveKey := fmt.Sprintf("%s-%d-%s", workflowName, taskID, stageName)
-	approveWithL, ok := globalApproveMap.getApproval(approveKey)
-	if !ok {
-		return fmt.Errorf("workflow %s ID %d stage %s do not need approve", workflowName, taskID, stageName)
+// RunStagesAsDAG runs all jobs of the workflow in one graph, stages are only used to group jobs,
+// so a job can start before the jobs of former stages finished if it does not depend on them.
+func RunStagesAsDAG(ctx context.Context, stages []*commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) {
+	jobs := []*commonmodels.JobTask{}
+	runningStages := []*commonmodels.StageTask{}
+	jobStages := make(map[*commonmodels.JobTask]*commonmodels.StageTask)
+	gates := make(map[string]*stageGate)
+	for _, stage := range stages {
+		// stages of a resumed task may have finished before.
+		if stage.Status == config.StatusPassed || stage.Status == config.StatusSkipped {
+			continue
+		}
+		runningStages = append(runningStages, stage)
+		stage.Status = config.StatusRunning
+		if stage.StartTime == 0 {
+			stage.StartTime = time.Now().Unix()
+		}
+		if stage.Approval != nil && stage.Approval.Enabled {
+			gates[stage.Name] = &stageGate{}
+		}
+		for _, job := range stage.Jobs {
+			if job.Status != "" {
+				continue
+			}
+			jobs = append(jobs, job)
+			jobStages[job] = stage
+		}
 	}
-	return approveWithL.doApproval(userName, userID, comment, approve)
+	ack()
+	logger.Infof("start running %d jobs as dag", len(jobs))
+	defer func() {
+		for _, stage := range runningStages {
+			if gate, ok := gates[stage.Name]; ok && gate.err != nil {
+				// the stage status is set by the approval.
+				stage.Error = gate.err.Error()
+			} else {
+				updateStageStatus(stage)
+			}
+			stage.EndTime = time.Now().Unix()
+			logger.Infof("finish stage: %s,status: %s", stage.Name, stage.Status)
+		}
+		ack()
+	}()
+
+	// the approval of a stage starts when the first job of the stage is ready to run,
+	// the jobs of the stage wait for it and are skipped if it does not pass.
+	jobcontroller.NewDAGRunner(ctx, jobs, workflowCtx, concurrency, logger, ack).SetGate(func(job *commonmodels.JobTask) error {
+		stage := jobStages[job]
+		gate, ok := gates[stage.Name]
+		if !ok {
+			return nil
+		}
+		gate.once.Do(func() {
+			gate.err = waitForApprove(ctx, stage, workflowCtx, logger, ack)
+		})
+		if gate.err != nil {
+			return fmt.Errorf("approval of stage %s did not pass: %v", stage.Name, gate.err)
+		}
+		return nil
+	}).Run()
+}
+
+// stageGate holds the approval result of a stage running as dag.
+type stageGate struct {
+	once sync.Once
+	err  error
 }
 
 func waitForApprove(ctx context.Context, stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) error {
@@ -111,59 +159,13 @@ func waitForApprove(ctx context.Context, stage *commonmodels.StageTask, workflow
 		return waitForNativeApprove(ctx, stage, workflowCtx, logger, ack)
 	case config.LarkApproval:
 		return waitForLarkApprove(ctx, stage, workflowCtx, logger, ack)
+	case config.DingTalkApproval, config.WeComApproval, config.WebhookApproval:
+		return waitForExternalApprove(ctx, stage, workflowCtx, logger, ack)
 	default:
 		return errors.New("invalid approval type")
 	}
 }
 
-func waitForNativeApprove(ctx context.Context, stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) error {
-	approval := stage.Approval.NativeApproval
-	if approval == nil {
-		return errors.New("waitForApprove: native approval data not found")
-	}
-	if approval.Timeout == 0 {
-		approval.Timeout = 60
-	}
-	approveKey := fmt.Sprintf("%s-%d-%s", workflowCtx.WorkflowName, workflowCtx.TaskID, stage.Name)
-	approveWithL := &approveWithLock{approval: approval}
-	globalApproveMap.setApproval(approveKey, approveWithL)
-	defer func() {
-		globalApproveMap.deleteApproval(approveKey)
-		ack()
-	}()
-	if err := instantmessage.NewWeChatClient().SendWorkflowTaskAproveNotifications(workflowCtx.WorkflowName, workflowCtx.TaskID); err != nil {
-		logger.Errorf("send approve notification failed, error: %v", err)
-	}
-
-	timeout := time.After(time.Duration(approval.Timeout) * time.Minute)
-	latestApproveCount := 0
-	for {
-		time.Sleep(1 * time.Second)
-		select {
-		case <-ctx.Done():
-			stage.Status = config.StatusCancelled
-			return fmt.Errorf("workflow was canceled")
-
-		case <-timeout:
-			stage.Status = config.StatusCancelled
-			return fmt.Errorf("workflow timeout")
-		default:
-			approved, approveCount, err := approveWithL.isApproval()
-			if err != nil {
-				stage.Status = config.StatusReject
-				return err
-			}
-			if approved {
-				return nil
-			}
-			if approveCount > latestApproveCount {
-				ack()
-				latestApproveCount = approveCount
-			}
-		}
-	}
-}
-
 func waitForLarkApprove(ctx context.Context, stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) error {
 	log.Infof("waitForLarkApprove start")
 	approval := stage.Approval.LarkApproval
@@ -190,17 +192,6 @@ func waitForLarkApprove(ctx context.Context, stage *commonmodels.StageTask, work
 	}
 
 	log.Infof("waitForLarkApprove: ApproveUsers num %d", len(approval.ApproveUsers))
-	detailURL := fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/custom/%s/%d?display_name=%s",
-		configbase.SystemAddress(),
-		workflowCtx.ProjectName,
-		workflowCtx.WorkflowName,
-		workflowCtx.TaskID,
-		url.QueryEscape(workflowCtx.WorkflowDisplayName),
-	)
-	descForm := ""
-	if stage.Approval.Description != "" {
-		descForm = fmt.Sprintf("\n描述: %s", stage.Approval.Description)
-	}
 	instance, err := client.CreateApprovalInstance(&lark.CreateApprovalInstanceArgs{
 		ApprovalCode: data.LarkDefaultApprovalCode,
 		UserOpenID:   userID,
@@ -210,8 +201,7 @@ func waitForLarkApprove(ctx context.Context, stage *commonmodels.StageTask, work
 			}
 			return list
 		}(),
-		FormContent: fmt.Sprintf("项目名称: %s\n工作流名称: %s\n阶段名称: %s%s\n\n更多详见: %s",
-			workflowCtx.ProjectName, workflowCtx.WorkflowDisplayName, stage.Name, descForm, detailURL),
+		FormContent: approvalContent(workflowCtx, stage.Name, stage.Approval.Description),
 	})
 	if err != nil {
 		log.Errorf("waitForLarkApprove: create instance failed: %v", err)
@@ -285,6 +275,26 @@ func waitForLarkApprove(ctx context.Context, stage *commonmodels.StageTask, work
 	}
 }
 
+func approvalDetailURL(workflowCtx *commonmodels.WorkflowTaskCtx) string {
+	return fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/custom/%s/%d?display_name=%s",
+		configbase.SystemAddress(),
+		workflowCtx.ProjectName,
+		workflowCtx.WorkflowName,
+		workflowCtx.TaskID,
+		url.QueryEscape(workflowCtx.WorkflowDisplayName),
+	)
+}
+
+// approvalContent is the content of approvals in external systems.
+func approvalContent(workflowCtx *commonmodels.WorkflowTaskCtx, stageName, description string) string {
+	descForm := ""
+	if description != "" {
+		descForm = fmt.Sprintf("\n描述: %s", description)
+	}
+	return fmt.Sprintf("项目名称: %s\n工作流名称: %s\n阶段名称: %s%s\n\n更多详见: %s",
+		workflowCtx.ProjectName, workflowCtx.WorkflowDisplayName, stageName, descForm, approvalDetailURL(workflowCtx))
+}
+
 func statusFailed(status config.Status) bool {
 	if status == config.StatusCancelled || status == config.StatusFailed || status == config.StatusTimeout || status == config.StatusReject {
 		return true
@@ -311,6 +321,10 @@ func updateStageStatus(stage *commonmodels.StageTask) {
 		if !ok {
 			statusCode = -1
 		}
+		// the failure of the job which continues on error does not fail the stage.
+		if jobcontroller.JobFailureIgnored(j) {
+			statusCode = statusMap[config.StatusPassed]
+		}
 		jobStatus[i] = statusCode
 	}
 	var stageStatusCode int
@@ -328,64 +342,3 @@ func updateStageStatus(stage *commonmodels.StageTask) {
 	}
 	stage.Status = stageStatus
 }
-
-func (c *approveMap) setApproval(key string, value *approveWithLock) {
-	c.Lock()
-	defer c.Unlock()
-	c.m[key] = value
-}
-
-func (c *approveMap) getApproval(key string) (*approveWithLock, bool) {
-	c.RLock()
-	defer c.RUnlock()
-	v, existed := c.m[key]
-	return v, existed
-}
-func (c *approveMap) deleteApproval(key string) {
-	c.Lock()
-	defer c.Unlock()
-	delete(c.m, key)
-}
-
-func (c *approveWithLock) isApproval() (bool, int, error) {
-	c.Lock()
-	defer c.Unlock()
-	approveCount := 0
-	for _, user := range c.approval.ApproveUsers {
-		if user.RejectOrApprove == config.Reject {
-			c.approval.RejectOrApprove = config.Reject
-			return false, approveCount, fmt.Errorf("%s reject this task", user.UserName)
-		}
-		if user.RejectOrApprove == config.Approve {
-			approveCount++
-		}
-	}
-	if approveCount >= c.approval.NeededApprovers {
-		c.approval.RejectOrApprove = config.Approve
-		return true, approveCount, nil
-	}
-	return false, approveCount, nil
-}
-
-func (c *approveWithLock) doApproval(userName, userID, comment string, appvove bool) error {
-	c.Lock()
-	defer c.Unlock()
-	for _, user := range c.approval.ApproveUsers {
-		if user.UserID != userID {
-			continue
-		}
-		if user.RejectOrApprove != "" {
-			return fmt.Errorf("%s have %s already", userName, user.RejectOrApprove)
-		}
-		user.Comment = comment
-		user.OperationTime = time.Now().Unix()
-		if appvove {
-			user.RejectOrApprove = config.Approve
-			return nil
-		} else {
-			user.RejectOrApprove = config.Reject
-			return nil
-		}
-	}
-	return fmt.Errorf("user %s has no authority to approve", userName)
-}
@@ -83,12 +83,20 @@ func instantiateStepCtl(step *commonmodels.StepTask, workflowCtx *commonmodels.W
 		stepCtl, err = NewArchiveCtl(step, logger)
 	case config.StepJunitReport:
 		stepCtl, err = NewJunitReportCtl(step, logger)
+	case config.StepCoverageReport:
+		stepCtl, err = NewCoverageReportCtl(step, workflowCtx, jobName, logger)
 	case config.StepTarArchive:
 		stepCtl, err = NewTarArchiveCtl(step, logger)
 	case config.StepSonarCheck:
 		stepCtl, err = NewSonarCheckCtl(step, logger)
 	case config.StepDistributeImage:
 		stepCtl, err = NewDistributeCtl(step, workflowCtx, jobName, logger)
+	case config.StepUploadArtifact, config.StepDownloadArtifact:
+		stepCtl, err = NewArtifactCtl(step, workflowCtx, jobName, logger)
+	case config.StepCacheRestore, config.StepCacheSave:
+		stepCtl, err = NewCacheCtl(step, workflowCtx, logger)
+	case config.StepImageScan:
+		stepCtl, err = NewImageScanCtl(step, workflowCtx, jobName, logger)
 	default:
 		logger.Errorf("unknown step type: %s", step.StepType)
 		return stepCtl, fmt.Errorf("unknown step type: %s", step.StepType)
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcontroller
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+type artifactCtl struct {
+	step         *commonmodels.StepTask
+	workflowCtx  *commonmodels.WorkflowTaskCtx
+	jobName      string
+	artifactSpec *step.StepArtifactSpec
+	log          *zap.SugaredLogger
+}
+
+func NewArtifactCtl(stepTask *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, jobName string, log *zap.SugaredLogger) (*artifactCtl, error) {
+	yamlString, err := yaml.Marshal(stepTask.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal artifact spec error: %v", err)
+	}
+	artifactSpec := &step.StepArtifactSpec{}
+	if err := yaml.Unmarshal(yamlString, &artifactSpec); err != nil {
+		return nil, fmt.Errorf("unmarshal artifact spec error: %v", err)
+	}
+	stepTask.Spec = artifactSpec
+	return &artifactCtl{artifactSpec: artifactSpec, workflowCtx: workflowCtx, jobName: jobName, log: log, step: stepTask}, nil
+}
+
+func (s *artifactCtl) PreRun(ctx context.Context) error {
+	modelS3, err := commonrepo.NewS3StorageColl().FindDefault()
+	if err != nil {
+		return fmt.Errorf("find default object storage for artifacts error: %v", err)
+	}
+	s.artifactSpec.S3 = modelS3toS3(modelS3)
+	for _, artifact := range s.artifactSpec.Artifacts {
+		jobName, taskID := s.jobName, s.workflowCtx.TaskID
+		if s.step.StepType == config.StepDownloadArtifact {
+			if artifact.JobName == "" {
+				return fmt.Errorf("upstream job of artifact %s is not set", artifact.Name)
+			}
+			jobName = artifact.JobName
+			// artifacts of reused jobs were uploaded by the task they ran in.
+			if reusedFrom, ok := s.workflowCtx.ReusedJobs[jobName]; ok {
+				taskID = reusedFrom
+			}
+		}
+		artifact.ObjectKey = job.GetArtifactObjectKey(s.workflowCtx.WorkflowName, taskID, jobName, artifact.Name)
+		if s.artifactSpec.S3.Subfolder != "" {
+			artifact.ObjectKey = path.Join(s.artifactSpec.S3.Subfolder, artifact.ObjectKey)
+		}
+	}
+	s.step.Spec = s.artifactSpec
+	return nil
+}
+
+func (s *artifactCtl) AfterRun(ctx context.Context) error {
+	return nil
+}
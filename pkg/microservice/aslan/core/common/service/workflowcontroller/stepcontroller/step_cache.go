@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcontroller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+type cacheCtl struct {
+	step        *commonmodels.StepTask
+	workflowCtx *commonmodels.WorkflowTaskCtx
+	cacheSpec   *step.StepCacheSpec
+	log         *zap.SugaredLogger
+}
+
+func NewCacheCtl(stepTask *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, log *zap.SugaredLogger) (*cacheCtl, error) {
+	yamlString, err := yaml.Marshal(stepTask.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal cache spec error: %v", err)
+	}
+	cacheSpec := &step.StepCacheSpec{}
+	if err := yaml.Unmarshal(yamlString, &cacheSpec); err != nil {
+		return nil, fmt.Errorf("unmarshal cache spec error: %v", err)
+	}
+	stepTask.Spec = cacheSpec
+	return &cacheCtl{cacheSpec: cacheSpec, workflowCtx: workflowCtx, log: log, step: stepTask}, nil
+}
+
+func (s *cacheCtl) PreRun(ctx context.Context) error {
+	modelS3, err := commonrepo.NewS3StorageColl().FindDefault()
+	if err != nil {
+		return fmt.Errorf("find default object storage for caches error: %v", err)
+	}
+	s.cacheSpec.S3 = modelS3toS3(modelS3)
+	s.cacheSpec.ObjectPrefix = strings.TrimLeft(path.Join(s.cacheSpec.S3.Subfolder, job.GetCacheObjectPrefix(s.workflowCtx.ProjectName)), "/") + "/"
+	if s.cacheSpec.MaxSize <= 0 {
+		s.cacheSpec.MaxSize = step.DefaultCacheMaxSize
+	}
+	s.step.Spec = s.cacheSpec
+	return nil
+}
+
+func (s *cacheCtl) AfterRun(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcontroller
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/setting"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util"
+	"github.com/koderover/zadig/pkg/util/coverage"
+)
+
+type coverageReportCtl struct {
+	step               *commonmodels.StepTask
+	workflowCtx        *commonmodels.WorkflowTaskCtx
+	jobName            string
+	coverageReportSpec *step.StepCoverageReportSpec
+	log                *zap.SugaredLogger
+}
+
+func NewCoverageReportCtl(stepTask *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, jobName string, log *zap.SugaredLogger) (*coverageReportCtl, error) {
+	yamlString, err := yaml.Marshal(stepTask.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal coverage report spec error: %v", err)
+	}
+	coverageReportSpec := &step.StepCoverageReportSpec{}
+	if err := yaml.Unmarshal(yamlString, &coverageReportSpec); err != nil {
+		return nil, fmt.Errorf("unmarshal coverage report spec error: %v", err)
+	}
+	stepTask.Spec = coverageReportSpec
+	return &coverageReportCtl{coverageReportSpec: coverageReportSpec, workflowCtx: workflowCtx, jobName: jobName, log: log, step: stepTask}, nil
+}
+
+func (s *coverageReportCtl) PreRun(ctx context.Context) error {
+	spec := s.coverageReportSpec
+	if spec.S3Storage == nil {
+		modelS3, err := commonrepo.NewS3StorageColl().FindDefault()
+		if err != nil {
+			return err
+		}
+		spec.S3Storage = modelS3toS3(modelS3)
+	}
+	if spec.S3DestDir == "" {
+		spec.S3DestDir = path.Join(s.workflowCtx.WorkflowName, fmt.Sprint(s.workflowCtx.TaskID), s.jobName, "coverage")
+	}
+	if spec.FileName == "" {
+		spec.FileName = path.Base(spec.ReportPath)
+	}
+	if spec.MaxRegression > 0 && spec.TestName != "" && spec.BaseBranch != "" {
+		base, err := commonrepo.NewTestCoverageColl().FindLatestOfBranch(spec.TestName, spec.BaseBranch)
+		if err == nil {
+			spec.BaseCoverage = &step.CoverageSummary{
+				Format:          step.CoverageFormat(base.Format),
+				LinesCovered:    base.LinesCovered,
+				LinesValid:      base.LinesValid,
+				BranchesCovered: base.BranchesCovered,
+				BranchesValid:   base.BranchesValid,
+				LineCoverage:    base.LineCoverage,
+				BranchCoverage:  base.BranchCoverage,
+			}
+		} else {
+			s.log.Infof("no coverage of test %s on branch %s found, skip the regression gate", spec.TestName, spec.BaseBranch)
+		}
+	}
+	s.step.Spec = spec
+	return nil
+}
+
+// AfterRun records the coverage of the task, which makes the coverage trend of the testing.
+func (s *coverageReportCtl) AfterRun(ctx context.Context) error {
+	spec := s.coverageReportSpec
+	if spec.TestName == "" || spec.S3Storage == nil {
+		return nil
+	}
+	filename, err := util.GenerateTmpFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(filename)
+
+	forcedPathStyle := true
+	if spec.S3Storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3tool.NewClient(spec.S3Storage.Endpoint, spec.S3Storage.Ak, spec.S3Storage.Sk, spec.S3Storage.Region, spec.S3Storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return err
+	}
+	objectKey := strings.TrimLeft(path.Join(spec.S3Storage.Subfolder, spec.S3DestDir, spec.FileName), "/")
+	if err := client.Download(spec.S3Storage.Bucket, objectKey, filename); err != nil {
+		return fmt.Errorf("download coverage report error: %v", err)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	summary, err := coverage.Parse(data, spec.Format)
+	if err != nil {
+		return err
+	}
+
+	testCoverage := &commonmodels.TestCoverage{
+		TestName:        spec.TestName,
+		ProjectName:     s.workflowCtx.ProjectName,
+		WorkflowName:    s.workflowCtx.WorkflowName,
+		TaskID:          s.workflowCtx.TaskID,
+		JobName:         s.jobName,
+		Branch:          spec.Branch,
+		PR:              spec.PR,
+		Format:          string(summary.Format),
+		LinesCovered:    summary.LinesCovered,
+		LinesValid:      summary.LinesValid,
+		BranchesCovered: summary.BranchesCovered,
+		BranchesValid:   summary.BranchesValid,
+		LineCoverage:    summary.LineCoverage,
+		BranchCoverage:  summary.BranchCoverage,
+		ReportKey:       objectKey,
+		CreateTime:      time.Now().Unix(),
+	}
+	if err := commonrepo.NewTestCoverageColl().Create(testCoverage); err != nil {
+		return fmt.Errorf("create test coverage error: %v", err)
+	}
+
+	testTaskStat, _ := commonrepo.NewTestTaskStatColl().FindTestTaskStat(&commonrepo.TestTaskStatOption{Name: spec.TestName})
+	if testTaskStat == nil {
+		testTaskStat = &commonmodels.TestTaskStat{
+			Name:           spec.TestName,
+			LineCoverage:   summary.LineCoverage,
+			BranchCoverage: summary.BranchCoverage,
+			CreateTime:     time.Now().Unix(),
+			UpdateTime:     time.Now().Unix(),
+		}
+		return commonrepo.NewTestTaskStatColl().Create(testTaskStat)
+	}
+	testTaskStat.LineCoverage = summary.LineCoverage
+	testTaskStat.BranchCoverage = summary.BranchCoverage
+	testTaskStat.UpdateTime = time.Now().Unix()
+	return commonrepo.NewTestTaskStatColl().Update(testTaskStat)
+}
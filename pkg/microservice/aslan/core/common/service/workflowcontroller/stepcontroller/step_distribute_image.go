@@ -52,7 +52,12 @@ func NewDistributeCtl(stepTask *commonmodels.StepTask, workflowCtx *commonmodels
 
 func (s *distributeImageCtl) PreRun(ctx context.Context) error {
 	for _, target := range s.distributeImageSpec.DistributeTarget {
-		target.TargetImage = getImage(target.ServiceModule, target.TargetTag, s.distributeImageSpec.TargetRegistry)
+		targetTag := target.TargetTag
+		// the images of a matrix build are distributed with the same target tag, so they are told apart by the suffix.
+		if target.MatrixSuffix != "" {
+			targetTag = targetTag + "-" + target.MatrixSuffix
+		}
+		target.TargetImage = getImage(target.ServiceModule, targetTag, s.distributeImageSpec.TargetRegistry)
 		if !target.UpdateTag {
 			target.TargetImage = getImage(target.ServiceModule, getImageTag(target.SoureImage), s.distributeImageSpec.TargetRegistry)
 		}
@@ -64,6 +69,9 @@ func (s *distributeImageCtl) PreRun(ctx context.Context) error {
 func (s *distributeImageCtl) AfterRun(ctx context.Context) error {
 	for _, target := range s.distributeImageSpec.DistributeTarget {
 		targetKey := strings.Join([]string{s.jobName, target.ServiceName, target.ServiceModule}, ".")
+		if target.MatrixSuffix != "" {
+			targetKey = strings.Join([]string{targetKey, target.MatrixSuffix}, ".")
+		}
 		s.workflowCtx.GlobalContextSet(job.GetJobOutputKey(targetKey, "IMAGE"), target.TargetImage)
 	}
 	return nil
@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/setting"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+type imageScanCtl struct {
+	step          *commonmodels.StepTask
+	workflowCtx   *commonmodels.WorkflowTaskCtx
+	jobName       string
+	imageScanSpec *step.StepImageScanSpec
+	log           *zap.SugaredLogger
+}
+
+func NewImageScanCtl(stepTask *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, jobName string, log *zap.SugaredLogger) (*imageScanCtl, error) {
+	yamlString, err := yaml.Marshal(stepTask.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal image scan spec error: %v", err)
+	}
+	imageScanSpec := &step.StepImageScanSpec{}
+	if err := yaml.Unmarshal(yamlString, &imageScanSpec); err != nil {
+		return nil, fmt.Errorf("unmarshal image scan spec error: %v", err)
+	}
+	stepTask.Spec = imageScanSpec
+	return &imageScanCtl{imageScanSpec: imageScanSpec, workflowCtx: workflowCtx, jobName: jobName, log: log, step: stepTask}, nil
+}
+
+func (s *imageScanCtl) PreRun(ctx context.Context) error {
+	spec := s.imageScanSpec
+	if spec.S3Storage == nil {
+		modelS3, err := commonrepo.NewS3StorageColl().FindDefault()
+		if err != nil {
+			return err
+		}
+		spec.S3Storage = modelS3toS3(modelS3)
+	}
+	if spec.S3DestDir == "" {
+		spec.S3DestDir = path.Join(s.workflowCtx.WorkflowName, fmt.Sprint(s.workflowCtx.TaskID), s.jobName, "image-scan")
+	}
+	s.step.Spec = spec
+	return nil
+}
+
+// AfterRun records the scan results of the task, they are shown in the delivery versions of the images.
+func (s *imageScanCtl) AfterRun(ctx context.Context) error {
+	spec := s.imageScanSpec
+	if spec.S3Storage == nil {
+		return nil
+	}
+	filename, err := util.GenerateTmpFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(filename)
+
+	forcedPathStyle := true
+	if spec.S3Storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3tool.NewClient(spec.S3Storage.Endpoint, spec.S3Storage.Ak, spec.S3Storage.Sk, spec.S3Storage.Region, spec.S3Storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return err
+	}
+	objectKey := strings.TrimLeft(path.Join(spec.S3Storage.Subfolder, spec.S3DestDir, step.ImageScanSummaryFile), "/")
+	if err := client.Download(spec.S3Storage.Bucket, objectKey, filename); err != nil {
+		return fmt.Errorf("download image scan summary error: %v", err)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	results := []*step.ImageScanResult{}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		imageScan := &commonmodels.ImageScan{
+			ProjectName:     s.workflowCtx.ProjectName,
+			WorkflowName:    s.workflowCtx.WorkflowName,
+			TaskID:          s.workflowCtx.TaskID,
+			JobName:         s.jobName,
+			ServiceName:     result.ServiceName,
+			ServiceModule:   result.ServiceModule,
+			Image:           result.Image,
+			Vulnerabilities: result.Vulnerabilities,
+			Ignored:         result.Ignored,
+			SBOMFormat:      string(spec.GetSBOMFormat()),
+			ReportKey:       result.ReportKey,
+			SBOMKey:         result.SBOMKey,
+			Passed:          result.Passed,
+			CreateTime:      time.Now().Unix(),
+		}
+		if err := commonrepo.NewImageScanColl().Create(imageScan); err != nil {
+			return fmt.Errorf("create image scan error: %v", err)
+		}
+	}
+	return nil
+}
@@ -49,6 +49,8 @@ type workflowCtl struct {
 	clusterIDMutex     sync.RWMutex
 	logger             *zap.SugaredLogger
 	ack                func()
+	// resumed is true if the task was running before aslan restarted.
+	resumed bool
 }
 
 func NewWorkflowController(workflowTask *commonmodels.WorkflowTask, logger *zap.SugaredLogger) *workflowCtl {
@@ -109,6 +111,16 @@ func CancelWorkflowTask(userName, workflowName string, taskID int64, logger *zap
 }
 
 func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
+	// every replica schedules tasks, make sure the task is run by one of them only.
+	claimed, err := claimTask(c.workflowTask.WorkflowName, c.workflowTask.TaskID)
+	if err != nil {
+		c.logger.Warnf("claim workflow task %s:%d error: %v", c.workflowTask.WorkflowName, c.workflowTask.TaskID, err)
+	} else if !claimed {
+		c.logger.Infof("workflow task %s:%d is running on another replica", c.workflowTask.WorkflowName, c.workflowTask.TaskID)
+		return
+	}
+	defer keepTask(c.workflowTask.WorkflowName, c.workflowTask.TaskID, c.logger)()
+
 	if c.workflowTask.GlobalContext == nil {
 		c.workflowTask.GlobalContext = make(map[string]string)
 	}
@@ -116,7 +128,9 @@ func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
 		c.workflowTask.ClusterIDMap = make(map[string]bool)
 	}
 	c.workflowTask.Status = config.StatusRunning
-	c.workflowTask.StartTime = time.Now().Unix()
+	if !c.resumed {
+		c.workflowTask.StartTime = time.Now().Unix()
+	}
 	c.ack()
 	c.logger.Infof("start workflow: %s,status: %s", c.workflowTask.WorkflowName, c.workflowTask.Status)
 	defer func() {
@@ -144,10 +158,22 @@ func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
 		DockerMountDir:            fmt.Sprintf("/tmp/%s/docker/%d", uuid.NewV4(), time.Now().Unix()),
 		ConfigMapMountDir:         fmt.Sprintf("/tmp/%s/cm/%d", uuid.NewV4(), time.Now().Unix()),
 		WorkflowKeyVals:           c.workflowTask.KeyVals,
+		WorkflowParams:            c.workflowTask.Params,
 		GlobalContextGet:          c.getGlobalContext,
 		GlobalContextSet:          c.setGlobalContext,
 		GlobalContextEach:         c.globalContextEach,
 		ClusterIDAdd:              c.addCluterID,
+		ReusedJobs:                make(map[string]int64),
+	}
+	for _, stage := range c.workflowTask.Stages {
+		for _, job := range stage.Jobs {
+			if job.ReusedFrom > 0 {
+				workflowCtx.ReusedJobs[job.Name] = job.ReusedFrom
+			}
+		}
+	}
+	if c.workflowTask.WorkflowArgs != nil {
+		workflowCtx.HookPayload = c.workflowTask.WorkflowArgs.HookPayload
 	}
 	defer jobcontroller.CleanWorkflowJobs(ctx, c.workflowTask, workflowCtx, c.logger, c.ack)
 	if err := scmnotify.NewService().UpdateWebhookCommentForWorkflowV4(c.workflowTask, c.logger); err != nil {
@@ -156,7 +182,11 @@ func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
 	if err := scmnotify.NewService().UpdateGitCheckForWorkflowV4(c.workflowTask.WorkflowArgs, c.workflowTask.TaskID, c.logger); err != nil {
 		log.Warnf("Failed to update github check status for custom workflow %s, taskID: %d the error is: %s", c.workflowTask.WorkflowName, c.workflowTask.TaskID, err)
 	}
-	RunStages(ctx, c.workflowTask.Stages, workflowCtx, concurrency, c.logger, c.ack)
+	if c.workflowTask.DAG {
+		RunStagesAsDAG(ctx, c.workflowTask.Stages, workflowCtx, concurrency, c.logger, c.ack)
+	} else {
+		RunStages(ctx, c.workflowTask.Stages, workflowCtx, concurrency, c.logger, c.ack)
+	}
 	updateworkflowStatus(c.workflowTask)
 }
 
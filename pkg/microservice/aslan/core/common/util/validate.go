@@ -21,8 +21,11 @@ import (
 	"strings"
 
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/types/step"
 )
 
 func checkGpuResourceParam(gpuLimit string) error {
@@ -53,3 +56,58 @@ func CheckDefineResourceParam(req setting.Request, reqSpec setting.RequestSpec)
 	//}
 	return nil
 }
+
+func CheckServiceContainers(containers []*commonmodels.ServiceContainer) error {
+	names := make(map[string]bool)
+	for _, container := range containers {
+		if errs := validation.IsDNS1123Label(container.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid service container name %s: %s", container.Name, strings.Join(errs, ", "))
+		}
+		if names[container.Name] {
+			return fmt.Errorf("duplicated service container: %s", container.Name)
+		}
+		names[container.Name] = true
+		if container.Image == "" {
+			return fmt.Errorf("image of service container %s is not set", container.Name)
+		}
+		for _, port := range container.Ports {
+			if port <= 0 || port > 65535 {
+				return fmt.Errorf("invalid port %d of service container %s", port, container.Name)
+			}
+		}
+		if container.ReadyTimeout < 0 {
+			return fmt.Errorf("ready timeout of service container %s can not be negative", container.Name)
+		}
+		probe := container.ReadinessProbe
+		if probe == nil {
+			continue
+		}
+		switch probe.Type {
+		case commonmodels.ServiceProbeTCP, commonmodels.ServiceProbeHTTP:
+			if probe.Port <= 0 && len(container.Ports) == 0 {
+				return fmt.Errorf("port of the readiness probe of service container %s is not set", container.Name)
+			}
+		case commonmodels.ServiceProbeExec:
+			if len(probe.Command) == 0 {
+				return fmt.Errorf("command of the readiness probe of service container %s is not set", container.Name)
+			}
+		default:
+			return fmt.Errorf("unknown readiness probe type %s of service container %s", probe.Type, container.Name)
+		}
+	}
+	return nil
+}
+
+func CheckCoverageReport(report *commonmodels.CoverageReport) error {
+	if report == nil {
+		return nil
+	}
+	spec := &step.StepCoverageReportSpec{
+		ReportPath:        report.ReportPath,
+		Format:            step.CoverageFormat(report.Format),
+		MinLineCoverage:   report.MinLineCoverage,
+		MinBranchCoverage: report.MinBranchCoverage,
+		MaxRegression:     report.MaxRegression,
+	}
+	return spec.Validate()
+}
@@ -159,6 +159,7 @@ type ReleaseInfo struct {
 	TestInfo       []*commonmodels.DeliveryTest       `json:"testInfo,omitempty"`
 	DistributeInfo []*commonmodels.DeliveryDistribute `json:"distributeInfo,omitempty"`
 	SecurityInfo   []*DeliverySecurityStats           `json:"securityStatsInfo,omitempty"`
+	ImageScanInfo  []*commonmodels.ImageScan          `json:"imageScanInfo,omitempty"`
 }
 
 type DeliverySecurityStatsInfo struct {
@@ -287,6 +288,34 @@ func buildBriefRelease(deliveryVersion *commonmodels.DeliveryVersion, _ *zap.Sug
 	}, nil
 }
 
+// findReleaseImageScans returns the image scan results of the workflow task which creates the version,
+// and the latest results of the other images in the version.
+func findReleaseImageScans(deliveryVersion *commonmodels.DeliveryVersion, deliveryDeploys []*commonmodels.DeliveryDeploy) []*commonmodels.ImageScan {
+	resp := make([]*commonmodels.ImageScan, 0)
+	scannedImages := sets.NewString()
+	if deliveryVersion.WorkflowName != "" && deliveryVersion.TaskID > 0 {
+		imageScans, err := commonrepo.NewImageScanColl().ListByTask(deliveryVersion.WorkflowName, int64(deliveryVersion.TaskID))
+		if err == nil {
+			for _, imageScan := range imageScans {
+				resp = append(resp, imageScan)
+				scannedImages.Insert(imageScan.Image)
+			}
+		}
+	}
+	for _, deliveryDeploy := range deliveryDeploys {
+		if deliveryDeploy.Image == "" || scannedImages.Has(deliveryDeploy.Image) {
+			continue
+		}
+		imageScan, err := commonrepo.NewImageScanColl().FindLatestByImage(deliveryDeploy.Image)
+		if err != nil {
+			continue
+		}
+		resp = append(resp, imageScan)
+		scannedImages.Insert(deliveryDeploy.Image)
+	}
+	return resp
+}
+
 func buildDetailedRelease(deliveryVersion *commonmodels.DeliveryVersion, filterOpt *DeliveryVersionFilter, logger *zap.SugaredLogger) (*ReleaseInfo, error) {
 	releaseInfo := new(ReleaseInfo)
 	//versionInfo
@@ -372,6 +401,9 @@ func buildDetailedRelease(deliveryVersion *commonmodels.DeliveryVersion, filterO
 	deliveryDistributes, _ := FindDeliveryDistribute(deliveryDistributeArgs, logger)
 	releaseInfo.DistributeInfo = deliveryDistributes
 
+	//imageScanInfo
+	releaseInfo.ImageScanInfo = findReleaseImageScans(deliveryVersion, deliveryDeploys)
+
 	// fill some data for helm delivery releases
 	processReleaseRespData(releaseInfo)
 
@@ -62,6 +62,7 @@ func (*Router) Inject(router *gin.RouterGroup) {
 	cron := router.Group("cron")
 	{
 		cron.GET("/cleanproduct", CleanProductCronJob)
+		cron.GET("/sleep", ListEnvSleepConfigs)
 	}
 
 	// ---------------------------------------------------------------------------------------
@@ -173,6 +174,23 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		environments.DELETE("/:name/share/enable", DisableBaseEnv)
 		environments.GET("/:name/check/sharenv/:op/ready", CheckShareEnvReady)
 
+		environments.GET("/:name/drifts", ListEnvDrifts)
+		environments.PUT("/:name/drift/policy", UpdateDriftPolicy)
+		environments.POST("/:name/drift/scan", ScanEnvDrift)
+
+		environments.POST("/:name/snapshots", CreateEnvSnapshot)
+		environments.GET("/:name/snapshots", ListEnvSnapshots)
+		environments.GET("/:name/snapshots/:version", GetEnvSnapshot)
+		environments.DELETE("/:name/snapshots/:version", DeleteEnvSnapshot)
+		environments.POST("/:name/snapshots/:version/restore", RestoreEnvSnapshot)
+		environments.POST("/:name/snapshots/:version/rollback", RollbackEnvToSnapshot)
+
+		environments.GET("/:name/sleep/config", GetEnvSleepConfig)
+		environments.PUT("/:name/sleep/config", UpdateEnvSleepConfig)
+		environments.POST("/:name/sleep", SleepEnv)
+		environments.POST("/:name/wake", WakeEnv)
+		environments.GET("/:name/wake/:token", WakeEnvOnRequest)
+
 		environments.GET("/:name/services/:serviceName/pmexec", ConnectSshPmExec)
 
 		environments.POST("/:name/services/:serviceName/devmode/patch", PatchWorkload)
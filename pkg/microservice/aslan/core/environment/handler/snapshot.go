@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
+	"github.com/koderover/zadig/pkg/setting"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+func CreateEnvSnapshot(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can't be empty!")
+		return
+	}
+	args := new(service.CreateEnvSnapshotArgs)
+	// the description is optional, so is the body
+	if err := c.ShouldBindJSON(args); err != nil && err != io.EOF {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+
+	internalhandler.InsertDetailedOperationLog(c, ctx.UserName, projectName, setting.OperationSceneEnv, "新增", "环境-快照", c.Param("name"), "", ctx.Logger, c.Param("name"))
+
+	ctx.Resp, ctx.Err = service.CreateEnvSnapshot(projectName, c.Param("name"), ctx.UserName, args, ctx.Logger)
+}
+
+func ListEnvSnapshots(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can't be empty!")
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.ListEnvSnapshots(projectName, c.Param("name"))
+}
+
+func GetEnvSnapshot(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	projectName := c.Query("projectName")
+	version, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if projectName == "" || err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid projectName or version")
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.GetEnvSnapshotContent(projectName, c.Param("name"), version, ctx.Logger)
+}
+
+func DeleteEnvSnapshot(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	projectName := c.Query("projectName")
+	version, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if projectName == "" || err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid projectName or version")
+		return
+	}
+
+	internalhandler.InsertDetailedOperationLog(c, ctx.UserName, projectName, setting.OperationSceneEnv, "删除", "环境-快照", c.Param("name"), c.Param("version"), ctx.Logger, c.Param("name"))
+
+	ctx.Err = service.DeleteEnvSnapshot(projectName, c.Param("name"), version, ctx.Logger)
+}
+
+func RestoreEnvSnapshot(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	projectName := c.Query("projectName")
+	version, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if projectName == "" || err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid projectName or version")
+		return
+	}
+	args := new(service.RestoreEnvSnapshotArgs)
+	if err := c.BindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+
+	internalhandler.InsertDetailedOperationLog(c, ctx.UserName, projectName, setting.OperationSceneEnv, "新增", "环境-从快照恢复", args.EnvName, c.Param("version"), ctx.Logger, args.EnvName)
+
+	ctx.Err = service.RestoreEnvSnapshot(projectName, c.Param("name"), version, args, ctx.UserID, ctx.UserName, ctx.RequestID, ctx.Logger)
+}
+
+func RollbackEnvToSnapshot(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	projectName := c.Query("projectName")
+	version, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if projectName == "" || err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid projectName or version")
+		return
+	}
+
+	internalhandler.InsertDetailedOperationLog(c, ctx.UserName, projectName, setting.OperationSceneEnv, "回滚", "环境-快照", c.Param("name"), c.Param("version"), ctx.Logger, c.Param("name"))
+
+	ctx.Err = service.RollbackEnvToSnapshot(projectName, c.Param("name"), version, ctx.UserName, ctx.RequestID, ctx.Logger)
+}
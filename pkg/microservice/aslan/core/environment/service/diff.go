@@ -100,7 +100,7 @@ func GetServiceDiff(envName, productName, serviceName string, log *zap.SugaredLo
 	}
 	//resp.Current.Yaml = commonservice.RenderValueForString(oldService.Yaml, oldRender)
 
-	resp.Current.Yaml, err = kube.RenderServiceYaml(oldService.Yaml, "", "", oldRender, oldService.ServiceVars, oldService.VariableYaml)
+	resp.Current.Yaml, err = renderDiffServiceYaml(oldService, envName, oldRender, serviceInfo.Containers)
 	if err != nil {
 		log.Error("failed to RenderServiceYaml, err: %s", err)
 		return nil, err
@@ -109,7 +109,7 @@ func GetServiceDiff(envName, productName, serviceName string, log *zap.SugaredLo
 	resp.Current.Revision = oldService.Revision
 	resp.Current.UpdateBy = oldService.CreateBy
 
-	resp.Latest.Yaml, err = kube.RenderServiceYaml(newService.Yaml, "", "", newRender, newService.ServiceVars, newService.VariableYaml)
+	resp.Latest.Yaml, err = renderDiffServiceYaml(newService, envName, newRender, nil)
 	if err != nil {
 		log.Error("failed to RenderServiceYaml, err: %s", err)
 		return nil, err
@@ -120,3 +120,12 @@ func GetServiceDiff(envName, productName, serviceName string, log *zap.SugaredLo
 	resp.Latest.UpdateBy = newService.CreateBy
 	return resp, nil
 }
+
+// renderDiffServiceYaml renders the yaml of the service template, the overlay of the environment is built for kustomize
+// services with the images of the containers in the environment.
+func renderDiffServiceYaml(svc *commonmodels.Service, envName string, render *commonmodels.RenderSet, containers []*commonmodels.Container) (string, error) {
+	if svc.Kustomize != nil {
+		return kube.RenderKustomizeService(svc, envName, containers)
+	}
+	return kube.RenderServiceYaml(svc.Yaml, "", "", render, svc.ServiceVars, svc.VariableYaml)
+}
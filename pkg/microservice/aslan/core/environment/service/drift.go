@@ -0,0 +1,711 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	versionedclient "istio.io/client-go/pkg/clientset/versioned"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	templatemodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/template"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/kube"
+	commonutil "github.com/koderover/zadig/pkg/microservice/aslan/core/common/util"
+	"github.com/koderover/zadig/pkg/setting"
+	kubeclient "github.com/koderover/zadig/pkg/shared/kube/client"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	helmtool "github.com/koderover/zadig/pkg/tool/helmclient"
+	"github.com/koderover/zadig/pkg/tool/kube/informer"
+	"github.com/koderover/zadig/pkg/tool/kube/serializer"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+const (
+	driftScanInterval = 5 * time.Minute
+	// driftScanLeaseTTL is long enough for a scan with reverts, the lease is released when the scan is done.
+	driftScanLeaseTTL = 30 * time.Minute
+)
+
+// scanningEnvs avoids scanning an environment by the scanner and the api of the same aslan at the same time,
+// the scans of different replicas are excluded by a lease of the environment.
+var scanningEnvs sync.Map
+
+var (
+	// replicas are changed by the scale api of zadig, they are not treated as drift
+	driftIgnoredFields = map[string]bool{"spec.replicas": true}
+	imageFieldRegex    = regexp.MustCompile(`^spec\.template\.spec\.(initContainers|containers)\[\d+\]\.image$`)
+)
+
+type driftItem struct {
+	service *commonmodels.ProductService
+	kind    string
+	name    string
+	fields  []string
+	// live is the live workload, release is the live release for a drift of helm values
+	live    map[string]interface{}
+	release *release.Release
+	drift   *commonmodels.EnvDrift
+}
+
+// StartDriftScanner scans the environments with a drift policy periodically.
+func StartDriftScanner() {
+	for {
+		// give the cluster informers some time to sync
+		time.Sleep(driftScanInterval)
+
+		envs, err := commonrepo.NewProductColl().List(&commonrepo.ProductListOptions{})
+		if err != nil {
+			log.Errorf("failed to list envs to scan drifts, err: %s", err)
+			continue
+		}
+		for _, env := range envs {
+			// reverting a sleeping environment would scale its workloads up
+			if env.DriftPolicy == "" || env.SleepState != nil {
+				continue
+			}
+			if _, err := scanEnvDrift(env, env.DriftPolicy, log.SugaredLogger()); err != nil {
+				log.Warnf("failed to scan drifts of env %s/%s, err: %s", env.ProductName, env.EnvName, err)
+			}
+		}
+	}
+}
+
+func UpdateDriftPolicy(productName, envName string, policy config.DriftPolicy) error {
+	switch policy {
+	case "", config.DriftPolicyAlert, config.DriftPolicyRevert, config.DriftPolicyAdopt:
+	default:
+		return e.ErrInvalidParam.AddDesc(fmt.Sprintf("invalid drift policy: %s", policy))
+	}
+	if _, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName}); err != nil {
+		return e.ErrGetEnv.AddErr(err)
+	}
+	if err := commonrepo.NewProductColl().UpdateDriftPolicy(envName, productName, policy); err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	return nil
+}
+
+func ListEnvDrifts(productName, envName string, includeResolved bool) ([]*commonmodels.EnvDrift, error) {
+	opt := &commonrepo.EnvDriftListOption{ProductName: productName, EnvName: envName, Limit: 200}
+	if !includeResolved {
+		resolved := false
+		opt.Resolved = &resolved
+	}
+	return commonrepo.NewEnvDriftColl().List(opt)
+}
+
+// ScanEnvDrift scans the drifts of the environment immediately, the drifts are only recorded if the
+// environment doesn't have a drift policy. sleeping environments are not scanned.
+func ScanEnvDrift(productName, envName string, log *zap.SugaredLogger) ([]*commonmodels.EnvDrift, error) {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+	policy := env.DriftPolicy
+	if policy == "" {
+		policy = config.DriftPolicyAlert
+	}
+	drifts, err := scanEnvDrift(env, policy, log)
+	if err != nil {
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+	return drifts, nil
+}
+
+func scanEnvDrift(env *commonmodels.Product, policy config.DriftPolicy, log *zap.SugaredLogger) ([]*commonmodels.EnvDrift, error) {
+	switch env.Status {
+	case setting.ProductStatusCreating, setting.ProductStatusUpdating, setting.ProductStatusDeleting:
+		return nil, fmt.Errorf("env is %s", env.Status)
+	}
+	// the workloads of a sleeping environment are scaled to zero, reverting would wake them
+	if env.SleepState != nil {
+		return nil, fmt.Errorf("env is sleeping")
+	}
+	key := env.ProductName + "/" + env.EnvName
+	if _, loaded := scanningEnvs.LoadOrStore(key, struct{}{}); loaded {
+		return nil, fmt.Errorf("env is being scanned")
+	}
+	defer scanningEnvs.Delete(key)
+
+	leaseName := "env-drift/" + key
+	claimed, err := commonrepo.NewLeaseColl().Acquire(leaseName, config.PodName(), driftScanLeaseTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire the scan lease, err: %s", err)
+	}
+	if !claimed {
+		return nil, fmt.Errorf("env is being scanned")
+	}
+	defer func() {
+		if err := commonrepo.NewLeaseColl().Release(leaseName, config.PodName()); err != nil {
+			log.Errorf("failed to release the scan lease of env %s, err: %s", key, err)
+		}
+	}()
+
+	clusterID := env.ClusterID
+	if clusterID == "" {
+		clusterID = setting.LocalClusterID
+	}
+	factory, ok := ClusterInformersMap.Load(clusterID)
+	if !ok {
+		return nil, fmt.Errorf("informer of cluster %s is not ready", clusterID)
+	}
+	informerFactory := factory.(informers.SharedInformerFactory)
+
+	env.EnsureRenderInfo()
+	renderSet, _, err := commonrepo.NewRenderSetColl().FindRenderSet(&commonrepo.RenderSetFindOption{
+		Name:     env.Render.Name,
+		EnvName:  env.EnvName,
+		Revision: env.Render.Revision,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find renderset, err: %s", err)
+	}
+	env.ServiceRenders = renderSet.ChartInfos
+
+	var helmClient *helmtool.HelmClient
+	items := make([]*driftItem, 0)
+	// the drifts of the skipped services are unknown, they are kept as they are
+	skipped := make([]string, 0)
+	for _, group := range env.Services {
+		for _, svc := range group {
+			if !commonutil.ServiceDeployed(svc.ServiceName, env.ServiceDeployStrategy) {
+				continue
+			}
+			var desired []string
+			switch svc.Type {
+			case setting.K8SDeployType:
+				rendered, err := kube.RenderEnvService(env, renderSet, svc)
+				if err != nil {
+					log.Warnf("failed to render service %s, err: %s", svc.ServiceName, err)
+					skipped = append(skipped, svc.ServiceName)
+					continue
+				}
+				desired = manifestList(rendered)
+			case setting.HelmDeployType:
+				if helmClient == nil {
+					if helmClient, err = helmtool.NewClientFromNamespace(clusterID, env.Namespace); err != nil {
+						return nil, fmt.Errorf("failed to create helm client, err: %s", err)
+					}
+				}
+				rel, item, err := diffHelmValues(env, renderSet, svc, helmClient)
+				if err != nil {
+					log.Warnf("failed to diff values of service %s, err: %s", svc.ServiceName, err)
+					skipped = append(skipped, svc.ServiceName)
+					continue
+				}
+				if rel == nil {
+					continue
+				}
+				if item != nil {
+					items = append(items, item)
+				}
+				desired = manifestList(rel.Manifest)
+			default:
+				continue
+			}
+			items = append(items, diffWorkloads(env.Namespace, svc, desired, informerFactory, log)...)
+		}
+	}
+
+	applyDriftPolicy(env, renderSet, helmClient, policy, items, log)
+
+	drifts := make([]*commonmodels.EnvDrift, 0, len(items))
+	ids := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		drift, err := commonrepo.NewEnvDriftColl().Upsert(item.drift)
+		if err != nil {
+			log.Errorf("failed to save drift of %s/%s, err: %s", item.kind, item.name, err)
+			skipped = append(skipped, item.service.ServiceName)
+			continue
+		}
+		drifts = append(drifts, drift)
+		ids = append(ids, drift.ID)
+	}
+	if err := commonrepo.NewEnvDriftColl().ResolveOthers(env.ProductName, env.EnvName, ids, skipped); err != nil {
+		log.Errorf("failed to resolve drifts of env %s, err: %s", env.EnvName, err)
+	}
+	return drifts, nil
+}
+
+func manifestList(content string) []string {
+	manifests := releaseutil.SplitManifests(content)
+	keys := make([]string, 0, len(manifests))
+	for key := range manifests {
+		keys = append(keys, key)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	ret := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ret = append(ret, manifests[key])
+	}
+	return ret
+}
+
+func diffWorkloads(namespace string, svc *commonmodels.ProductService, manifests []string, factory informers.SharedInformerFactory, log *zap.SugaredLogger) []*driftItem {
+	ret := make([]*driftItem, 0)
+	for _, manifest := range manifests {
+		u, err := serializer.NewDecoder().YamlToUnstructured([]byte(manifest))
+		if err != nil {
+			continue
+		}
+
+		var liveObj runtime.Object
+		switch u.GetKind() {
+		case setting.Deployment:
+			liveObj, err = factory.Apps().V1().Deployments().Lister().Deployments(namespace).Get(u.GetName())
+		case setting.StatefulSet:
+			liveObj, err = factory.Apps().V1().StatefulSets().Lister().StatefulSets(namespace).Get(u.GetName())
+		default:
+			continue
+		}
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			log.Warnf("failed to get %s %s, err: %s", u.GetKind(), u.GetName(), err)
+			continue
+		}
+		live, err := runtime.DefaultUnstructuredConverter.ToUnstructured(liveObj)
+		if err != nil {
+			log.Warnf("failed to convert %s %s, err: %s", u.GetKind(), u.GetName(), err)
+			continue
+		}
+
+		fields := diffWorkload(u, live)
+		if len(fields) == 0 {
+			continue
+		}
+		ret = append(ret, &driftItem{
+			service: svc,
+			kind:    u.GetKind(),
+			name:    u.GetName(),
+			fields:  fields,
+			live:    live,
+		})
+	}
+	return ret
+}
+
+// diffWorkload returns the fields in the desired workload which are different in the live one,
+// the fields only set in the live workload are defaulted by kubernetes or added by zadig, they are ignored.
+func diffWorkload(desired *unstructured.Unstructured, live map[string]interface{}) []string {
+	fields := make([]string, 0)
+	liveMeta, _ := live["metadata"].(map[string]interface{})
+	compareFields(desired.GetLabels(), liveMeta["labels"], "metadata.labels", true, &fields)
+	compareFields(desired.GetAnnotations(), liveMeta["annotations"], "metadata.annotations", true, &fields)
+	compareFields(desired.Object["spec"], live["spec"], "spec", true, &fields)
+	return fields
+}
+
+func diffHelmValues(env *commonmodels.Product, renderSet *commonmodels.RenderSet, svc *commonmodels.ProductService, helmClient *helmtool.HelmClient) (*release.Release, *driftItem, error) {
+	var renderChart *templatemodels.ServiceRender
+	for _, chart := range renderSet.ChartInfos {
+		if chart.ServiceName == svc.ServiceName {
+			renderChart = chart
+		}
+	}
+	if renderChart == nil {
+		return nil, nil, nil
+	}
+	serviceObj, err := commonrepo.NewServiceColl().Find(&commonrepo.ServiceFindOption{
+		ServiceName: svc.ServiceName,
+		ProductName: env.ProductName,
+		Type:        svc.Type,
+		Revision:    svc.Revision,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	param, err := buildInstallParam(env.Namespace, env.EnvName, renderSet.DefaultValues, renderChart, serviceObj)
+	if err != nil {
+		return nil, nil, err
+	}
+	rel, err := helmClient.GetRelease(param.ReleaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	desired := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(param.MergedValues), &desired); err != nil {
+		return nil, nil, err
+	}
+	desiredValues, err := normalizeValues(desired)
+	if err != nil {
+		return nil, nil, err
+	}
+	liveValues, err := normalizeValues(rel.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields := make([]string, 0)
+	compareFields(desiredValues, liveValues, "", false, &fields)
+	if len(fields) == 0 {
+		return rel, nil, nil
+	}
+	return rel, &driftItem{
+		service: svc,
+		kind:    commonmodels.DriftKindHelmValues,
+		name:    param.ReleaseName,
+		fields:  fields,
+		release: rel,
+	}, nil
+}
+
+// normalizeValues makes the numbers of the values comparable.
+func normalizeValues(values map[string]interface{}) (interface{}, error) {
+	bs, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	var ret interface{}
+	err = json.Unmarshal(bs, &ret)
+	return ret, err
+}
+
+// compareFields appends the paths of the different fields to fields, if subset is true, the fields only
+// set in live are ignored.
+func compareFields(desired, live interface{}, path string, subset bool, fields *[]string) {
+	if driftIgnoredFields[path] {
+		return
+	}
+	if desired == nil || live == nil {
+		if desired == nil && live == nil {
+			return
+		}
+		if subset && (desired == nil || isEmptyValue(desired)) {
+			return
+		}
+		*fields = append(*fields, path)
+		return
+	}
+
+	switch d := desired.(type) {
+	case map[string]string:
+		m := make(map[string]interface{}, len(d))
+		for k, v := range d {
+			m[k] = v
+		}
+		compareFields(m, live, path, subset, fields)
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			*fields = append(*fields, path)
+			return
+		}
+		for _, k := range sortedKeys(d) {
+			compareFields(d[k], l[k], joinFieldPath(path, k), subset, fields)
+		}
+		if subset {
+			return
+		}
+		for _, k := range sortedKeys(l) {
+			if _, ok := d[k]; !ok {
+				*fields = append(*fields, joinFieldPath(path, k))
+			}
+		}
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			*fields = append(*fields, path)
+			return
+		}
+		for i := range d {
+			compareFields(d[i], l[i], fmt.Sprintf("%s[%d]", path, i), subset, fields)
+		}
+	default:
+		if !scalarEqual(desired, live) {
+			*fields = append(*fields, path)
+		}
+	}
+}
+
+func scalarEqual(a, b interface{}) bool {
+	as, bs := fmt.Sprint(a), fmt.Sprint(b)
+	if as == bs {
+		return true
+	}
+	// resource quantities may be written in different forms, e.g. 0.5 and 500m
+	qa, err := resource.ParseQuantity(as)
+	if err != nil {
+		return false
+	}
+	qb, err := resource.ParseQuantity(bs)
+	if err != nil {
+		return false
+	}
+	return qa.Cmp(qb) == 0
+}
+
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func applyDriftPolicy(env *commonmodels.Product, renderSet *commonmodels.RenderSet, helmClient *helmtool.HelmClient, policy config.DriftPolicy, items []*driftItem, log *zap.SugaredLogger) {
+	for _, item := range items {
+		item.drift = &commonmodels.EnvDrift{
+			ProductName:  env.ProductName,
+			EnvName:      env.EnvName,
+			ServiceName:  item.service.ServiceName,
+			Kind:         item.kind,
+			ResourceName: item.name,
+			Fields:       item.fields,
+			Policy:       policy,
+			Action:       config.DriftActionAlerted,
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	switch policy {
+	case config.DriftPolicyRevert:
+		revertDrifts(env, renderSet, helmClient, items, log)
+	case config.DriftPolicyAdopt:
+		adoptDrifts(env, renderSet, items, log)
+	}
+}
+
+func setDriftAction(items []*driftItem, action config.DriftAction, err error) {
+	for _, item := range items {
+		item.drift.Action = action
+		if err != nil {
+			item.drift.Action = config.DriftActionFailed
+			item.drift.Message = err.Error()
+		}
+	}
+}
+
+// revertDrifts applies the render of the drifted services again.
+func revertDrifts(env *commonmodels.Product, renderSet *commonmodels.RenderSet, helmClient *helmtool.HelmClient, items []*driftItem, log *zap.SugaredLogger) {
+	serviceItems := make(map[string][]*driftItem)
+	for _, item := range items {
+		serviceItems[item.service.ServiceName] = append(serviceItems[item.service.ServiceName], item)
+	}
+
+	helmItems := make([]*driftItem, 0)
+	// the clients are created once for all the services when the first one is reverted
+	var (
+		kubeClient  client.Client
+		istioClient versionedclient.Interface
+		inf         informers.SharedInformerFactory
+		clientErr   error
+	)
+	for _, svcItems := range serviceItems {
+		svc := svcItems[0].service
+		if svc.Type == setting.HelmDeployType {
+			helmItems = append(helmItems, svcItems...)
+			continue
+		}
+		if kubeClient == nil && clientErr == nil {
+			kubeClient, istioClient, inf, clientErr = newEnvKubeClients(env)
+		}
+		if clientErr != nil {
+			setDriftAction(svcItems, config.DriftActionReverted, clientErr)
+			continue
+		}
+		_, err := upsertService(env, svc, svc, renderSet, env.Render, inf, kubeClient, istioClient, log)
+		setDriftAction(svcItems, config.DriftActionReverted, err)
+	}
+
+	if len(helmItems) == 0 {
+		return
+	}
+	filter := func(svc *commonmodels.ProductService) bool {
+		_, ok := serviceItems[svc.ServiceName]
+		return ok && svc.Type == setting.HelmDeployType
+	}
+	err := proceedHelmRelease(env, renderSet, helmClient, filter, log)
+	setDriftAction(helmItems, config.DriftActionReverted, err)
+}
+
+func newEnvKubeClients(env *commonmodels.Product) (client.Client, versionedclient.Interface, informers.SharedInformerFactory, error) {
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	restConfig, err := kubeclient.GetRESTConfig(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	istioClient, err := versionedclient.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cls, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	inf, err := informer.NewInformer(env.ClusterID, env.Namespace, cls)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return kubeClient, istioClient, inf, nil
+}
+
+// adoptDrifts saves the live state into the environment, the container images of the workloads and the values
+// of the helm releases can be adopted.
+func adoptDrifts(env *commonmodels.Product, renderSet *commonmodels.RenderSet, items []*driftItem, log *zap.SugaredLogger) {
+	valuesItems := make([]*driftItem, 0)
+	updatedGroups := make(map[int]bool)
+	for _, item := range items {
+		if item.kind == commonmodels.DriftKindHelmValues {
+			valuesItems = append(valuesItems, item)
+			continue
+		}
+		if item.service.Type == setting.HelmDeployType {
+			setDriftAction([]*driftItem{item}, config.DriftActionFailed, fmt.Errorf("workloads of helm services can't be adopted, change the values instead"))
+			continue
+		}
+
+		notAdopted := make([]string, 0)
+		for _, field := range item.fields {
+			if !imageFieldRegex.MatchString(field) {
+				notAdopted = append(notAdopted, field)
+			}
+		}
+		if len(notAdopted) < len(item.fields) {
+			adoptContainerImages(item.service, item.live)
+			for i, group := range env.Services {
+				for _, svc := range group {
+					if svc == item.service {
+						updatedGroups[i] = true
+					}
+				}
+			}
+		}
+		setDriftAction([]*driftItem{item}, config.DriftActionAdopted, nil)
+		if len(notAdopted) > 0 {
+			item.drift.Message = fmt.Sprintf("only container images can be adopted, fields not adopted: %s", strings.Join(notAdopted, ", "))
+		}
+	}
+
+	for i := range updatedGroups {
+		err := commonrepo.NewProductColl().UpdateGroup(env.EnvName, env.ProductName, i, env.Services[i])
+		if err != nil {
+			log.Errorf("failed to update service group %d of env %s, err: %s", i, env.EnvName, err)
+		}
+	}
+
+	if len(valuesItems) == 0 {
+		return
+	}
+	setDriftAction(valuesItems, config.DriftActionAdopted, adoptHelmValues(env, renderSet, valuesItems, log))
+}
+
+func adoptContainerImages(svc *commonmodels.ProductService, live map[string]interface{}) {
+	images := make(map[string]string)
+	for _, key := range []string{"initContainers", "containers"} {
+		containers, _, _ := unstructured.NestedSlice(live, "spec", "template", "spec", key)
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := container["name"].(string)
+			image, _ := container["image"].(string)
+			images[name] = image
+		}
+	}
+	for _, container := range svc.Containers {
+		if image, ok := images[container.Name]; ok && image != "" {
+			container.Image = image
+		}
+	}
+}
+
+// adoptHelmValues saves the values of the live releases as the override yaml of the services, the override values
+// are cleared since they are included in the live values.
+func adoptHelmValues(env *commonmodels.Product, renderSet *commonmodels.RenderSet, items []*driftItem, log *zap.SugaredLogger) error {
+	for _, item := range items {
+		values, err := yaml.Marshal(item.release.Config)
+		if err != nil {
+			return err
+		}
+		for _, chart := range renderSet.ChartInfos {
+			if chart.ServiceName == item.service.ServiceName {
+				overrideChartValues(chart, string(values))
+			}
+		}
+	}
+
+	if err := commonservice.CreateK8sHelmRenderSet(renderSet, log); err != nil {
+		return err
+	}
+	env.Render = &commonmodels.RenderInfo{Name: renderSet.Name, Revision: renderSet.Revision, ProductTmpl: renderSet.ProductTmpl}
+	return commonrepo.NewProductColl().UpdateRender(env.EnvName, env.ProductName, env.Render)
+}
+
+// overrideChartValues uses the values of a release as the override yaml of the chart, the merged values of the chart
+// are the same as the values of the release.
+func overrideChartValues(chart *templatemodels.ServiceRender, values string) {
+	if chart.OverrideYaml == nil {
+		chart.OverrideYaml = &templatemodels.CustomYaml{}
+	}
+	chart.OverrideYaml.YamlContent = values
+	chart.OverrideValues = ""
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing env drift", func() {
+
+	table.DescribeTable("comparing scalars",
+		func(a, b interface{}, expected bool) {
+			Expect(scalarEqual(a, b)).To(Equal(expected))
+		},
+		table.Entry("same strings", "nginx:1.20", "nginx:1.20", true),
+		table.Entry("different strings", "nginx:1.20", "nginx:1.21", false),
+		table.Entry("int and float", 3, float64(3), true),
+		table.Entry("number and string", float64(80), "80", true),
+		table.Entry("different numbers", 3, float64(4), false),
+		table.Entry("bools", true, true, true),
+		table.Entry("bool and string", true, "false", false),
+		table.Entry("cpu in cores and millicores", "0.5", "500m", true),
+		table.Entry("memory in different units", "1Gi", "1024Mi", true),
+		table.Entry("different quantities", "1Gi", "1G", false),
+		table.Entry("quantity and text", "500m", "half", false),
+	)
+
+	table.DescribeTable("comparing fields",
+		func(desired, live interface{}, subset bool, expected []string) {
+			fields := make([]string, 0)
+			compareFields(desired, live, "", subset, &fields)
+			Expect(fields).To(Equal(expected))
+		},
+		table.Entry("same values",
+			map[string]interface{}{"image": "nginx", "port": float64(80)},
+			map[string]interface{}{"port": float64(80), "image": "nginx"},
+			false, []string{}),
+		table.Entry("changed nested value",
+			map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{"image": "nginx:1.20"}}},
+			map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{"image": "nginx:1.21"}}},
+			false, []string{"spec.template.image"}),
+		table.Entry("ignored replicas",
+			map[string]interface{}{"spec": map[string]interface{}{"replicas": float64(1)}},
+			map[string]interface{}{"spec": map[string]interface{}{"replicas": float64(3)}},
+			false, []string{}),
+		table.Entry("field only in live",
+			map[string]interface{}{"a": "1"},
+			map[string]interface{}{"a": "1", "b": "2"},
+			false, []string{"b"}),
+		table.Entry("field only in live of a subset",
+			map[string]interface{}{"a": "1"},
+			map[string]interface{}{"a": "1", "b": "2"},
+			true, []string{}),
+		table.Entry("field only in desired",
+			map[string]interface{}{"a": "1", "b": "2"},
+			map[string]interface{}{"a": "1"},
+			true, []string{"b"}),
+		table.Entry("empty field only in desired of a subset",
+			map[string]interface{}{"a": "1", "b": map[string]interface{}{}},
+			map[string]interface{}{"a": "1"},
+			true, []string{}),
+		table.Entry("string map",
+			map[string]interface{}{"labels": map[string]string{"app": "web"}},
+			map[string]interface{}{"labels": map[string]interface{}{"app": "api"}},
+			false, []string{"labels.app"}),
+		table.Entry("changed list item",
+			map[string]interface{}{"ports": []interface{}{float64(80), float64(443)}},
+			map[string]interface{}{"ports": []interface{}{float64(80), float64(8443)}},
+			false, []string{"ports[1]"}),
+		table.Entry("list of different length",
+			map[string]interface{}{"ports": []interface{}{float64(80)}},
+			map[string]interface{}{"ports": []interface{}{float64(80), float64(443)}},
+			false, []string{"ports"}),
+		table.Entry("different types",
+			map[string]interface{}{"env": []interface{}{"A=1"}},
+			map[string]interface{}{"env": "A=1"},
+			false, []string{"env"}),
+		table.Entry("quantities in different forms",
+			map[string]interface{}{"resources": map[string]interface{}{"cpu": "0.5", "memory": "1Gi"}},
+			map[string]interface{}{"resources": map[string]interface{}{"cpu": "500m", "memory": "1024Mi"}},
+			false, []string{}),
+		table.Entry("fields sorted by path",
+			map[string]interface{}{"b": "1", "a": "1", "c": "1"},
+			map[string]interface{}{"b": "2", "a": "2", "c": "1", "d": "1"},
+			false, []string{"a", "b", "d"}),
+	)
+})
@@ -0,0 +1,362 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rfyiamcool/cronlib"
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/setting"
+	kubeclient "github.com/koderover/zadig/pkg/shared/kube/client"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/tool/kube/updater"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+// ingressAuthURLAnnotation makes ingress-nginx request the url before proxying, it is used to wake the environment.
+const ingressAuthURLAnnotation = "nginx.ingress.kubernetes.io/auth-url"
+
+// wakingEnvs avoids waking the same environment repeatedly when it is visited by many requests.
+var wakingEnvs sync.Map
+
+type EnvSleepConfigResp struct {
+	ProductName string `json:"product_name"`
+	EnvName     string `json:"env_name"`
+	SleepCron   string `json:"sleep_cron"`
+	WakeCron    string `json:"wake_cron"`
+	TimeZone    string `json:"time_zone"`
+	Sleeping    bool   `json:"sleeping"`
+}
+
+func GetEnvSleepConfig(productName, envName string) (*commonmodels.EnvSleepConfig, error) {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+	if env.SleepConfig == nil {
+		return &commonmodels.EnvSleepConfig{}, nil
+	}
+	return env.SleepConfig, nil
+}
+
+func UpdateEnvSleepConfig(productName, envName string, args *commonmodels.EnvSleepConfig, log *zap.SugaredLogger) error {
+	if _, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName}); err != nil {
+		return e.ErrGetEnv.AddErr(err)
+	}
+	if err := validateEnvSleepConfig(args); err != nil {
+		return e.ErrInvalidParam.AddErr(err)
+	}
+	if err := commonrepo.NewProductColl().UpdateSleepConfig(envName, productName, args); err != nil {
+		log.Errorf("failed to update sleep config of env %s/%s, err: %s", productName, envName, err)
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	return nil
+}
+
+func validateEnvSleepConfig(args *commonmodels.EnvSleepConfig) error {
+	for _, spec := range []string{args.SleepCron, args.WakeCron} {
+		if spec == "" {
+			continue
+		}
+		if _, err := cronlib.ParseStandard(spec); err != nil {
+			return fmt.Errorf("invalid cron expression %s: %s", spec, err)
+		}
+	}
+	if _, err := time.LoadLocation(args.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %s: %s", args.TimeZone, err)
+	}
+	return nil
+}
+
+// ListEnvSleepConfigs lists the environments which sleep or wake on schedule, it is used by the cron service.
+func ListEnvSleepConfigs(log *zap.SugaredLogger) ([]*EnvSleepConfigResp, error) {
+	envs, err := commonrepo.NewProductColl().List(&commonrepo.ProductListOptions{
+		ExcludeStatus: []string{setting.ProductStatusDeleting, setting.ProductStatusUnknown},
+	})
+	if err != nil {
+		log.Errorf("failed to list envs, err: %s", err)
+		return nil, e.ErrListEnvs.AddErr(err)
+	}
+
+	resp := make([]*EnvSleepConfigResp, 0)
+	for _, env := range envs {
+		if env.SleepConfig == nil || !env.SleepConfig.Enable {
+			continue
+		}
+		if env.SleepConfig.SleepCron == "" && env.SleepConfig.WakeCron == "" {
+			continue
+		}
+		resp = append(resp, &EnvSleepConfigResp{
+			ProductName: env.ProductName,
+			EnvName:     env.EnvName,
+			SleepCron:   env.SleepConfig.SleepCron,
+			WakeCron:    env.SleepConfig.WakeCron,
+			TimeZone:    env.SleepConfig.TimeZone,
+			Sleeping:    env.SleepState != nil,
+		})
+	}
+	return resp, nil
+}
+
+// SleepEnv scales all the deployments and statefulsets in the environment to zero, the original replicas are
+// saved to restore them when the environment wakes.
+func SleepEnv(productName, envName string, log *zap.SugaredLogger) error {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return e.ErrGetEnv.AddErr(err)
+	}
+	if env.SleepState != nil {
+		return nil
+	}
+	if env.Status == setting.ProductStatusCreating || env.Status == setting.ProductStatusUpdating || env.Status == setting.ProductStatusDeleting {
+		return e.ErrUpdateEnv.AddDesc(e.EnvCantUpdatedMsg)
+	}
+
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+
+	state := &commonmodels.EnvSleepState{
+		WakeToken: util.UUID(),
+		SleepTime: time.Now().Unix(),
+	}
+	deployments, err := getter.ListDeployments(env.Namespace, labels.Everything(), kubeClient)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	for _, deployment := range deployments {
+		if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas == 0 {
+			continue
+		}
+		state.Workloads = append(state.Workloads, &commonmodels.SleepingWorkload{Kind: setting.Deployment, Name: deployment.Name, Replicas: *deployment.Spec.Replicas})
+	}
+	statefulSets, err := getter.ListStatefulSets(env.Namespace, labels.Everything(), kubeClient)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	for _, sts := range statefulSets {
+		if sts.Spec.Replicas == nil || *sts.Spec.Replicas == 0 {
+			continue
+		}
+		state.Workloads = append(state.Workloads, &commonmodels.SleepingWorkload{Kind: setting.StatefulSet, Name: sts.Name, Replicas: *sts.Spec.Replicas})
+	}
+
+	wakeOnRequest := env.SleepConfig != nil && env.SleepConfig.WakeOnRequest
+	if wakeOnRequest {
+		state.Ingresses, err = listSleepingIngresses(env, log)
+		if err != nil {
+			return e.ErrUpdateEnv.AddErr(err)
+		}
+	}
+
+	// the state is saved before scaling so that the environment can still be woken if scaling fails halfway
+	if err := commonrepo.NewProductColl().UpdateSleepState(envName, productName, setting.ProductStatusSleeping, state); err != nil {
+		log.Errorf("failed to save sleep state of env %s/%s, err: %s", productName, envName, err)
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+
+	errs := &multierror.Error{}
+	for _, workload := range state.Workloads {
+		if err := scaleWorkload(env.Namespace, workload.Kind, workload.Name, 0, kubeClient); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to scale %s/%s to 0: %s", workload.Kind, workload.Name, err))
+		}
+	}
+	if wakeOnRequest {
+		authURL := config.EnvWakeURL(productName, envName, state.WakeToken)
+		for _, ingress := range state.Ingresses {
+			if err := patchIngressAuthURL(env, ingress.Name, authURL); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("failed to patch ingress %s: %s", ingress.Name, err))
+			}
+		}
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		log.Errorf("failed to sleep env %s/%s, err: %s", productName, envName, err)
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	log.Infof("env %s/%s is sleeping, %d workloads are scaled to 0", productName, envName, len(state.Workloads))
+	return nil
+}
+
+// WakeEnv restores the replicas and the ingresses saved when the environment went to sleep.
+func WakeEnv(productName, envName string, log *zap.SugaredLogger) error {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return e.ErrGetEnv.AddErr(err)
+	}
+	if env.SleepState == nil {
+		return nil
+	}
+
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+
+	errs := &multierror.Error{}
+	for _, workload := range env.SleepState.Workloads {
+		err := scaleWorkload(env.Namespace, workload.Kind, workload.Name, int(workload.Replicas), kubeClient)
+		// workloads deleted during the sleep are not restored
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = multierror.Append(errs, fmt.Errorf("failed to scale %s/%s to %d: %s", workload.Kind, workload.Name, workload.Replicas, err))
+		}
+	}
+	for _, ingress := range env.SleepState.Ingresses {
+		if err := patchIngressAuthURL(env, ingress.Name, ingress.AuthURL); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to restore ingress %s: %s", ingress.Name, err))
+		}
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		log.Errorf("failed to wake env %s/%s, err: %s", productName, envName, err)
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+
+	if err := commonrepo.NewProductColl().UpdateSleepState(envName, productName, setting.ProductStatusSuccess, nil); err != nil {
+		log.Errorf("failed to clear sleep state of env %s/%s, err: %s", productName, envName, err)
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	log.Infof("env %s/%s is woken", productName, envName)
+	return nil
+}
+
+// WakeEnvOnRequest is called by the ingress controller for every request to a sleeping environment, the environment
+// is woken in background and the request is let through. the workloads are not ready at that time, so the requests
+// get 503 from the ingress controller until the pods are ready, and clients are expected to retry.
+func WakeEnvOnRequest(productName, envName, token string, log *zap.SugaredLogger) error {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return e.ErrGetEnv.AddErr(err)
+	}
+	if env.SleepState == nil {
+		return nil
+	}
+	if env.SleepConfig == nil || !env.SleepConfig.WakeOnRequest || env.SleepState.WakeToken != token {
+		return e.ErrInvalidParam.AddDesc("invalid wake token")
+	}
+
+	key := productName + "/" + envName
+	if _, waking := wakingEnvs.LoadOrStore(key, true); waking {
+		return nil
+	}
+	go func() {
+		defer wakingEnvs.Delete(key)
+		if err := WakeEnv(productName, envName, log); err != nil {
+			log.Errorf("failed to wake env %s on request, err: %s", key, err)
+		}
+	}()
+	return nil
+}
+
+func scaleWorkload(namespace, kind, name string, replicas int, kubeClient client.Client) error {
+	switch kind {
+	case setting.Deployment:
+		return updater.ScaleDeployment(namespace, name, replicas, kubeClient)
+	case setting.StatefulSet:
+		return updater.ScaleStatefulSet(namespace, name, replicas, kubeClient)
+	default:
+		return fmt.Errorf("unsupported workload kind %s", kind)
+	}
+}
+
+// listSleepingIngresses lists the ingresses to be pointed at the wake endpoint.
+func listSleepingIngresses(env *commonmodels.Product, log *zap.SugaredLogger) ([]*commonmodels.SleepingIngress, error) {
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	cls, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	version, err := cls.Discovery().ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	ingresses, err := getter.ListIngresses(env.Namespace, kubeClient, kubeclient.VersionLessThan122(version))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, skipped := wakeOnRequestIngresses(ingresses.Items)
+	if len(skipped) > 0 {
+		log.Warnf("ingresses %v of env %s/%s have their own auth url, they do not wake the env on request", skipped, env.ProductName, env.EnvName)
+	}
+	return resp, nil
+}
+
+// wakeOnRequestIngresses returns the ingresses which can wake the environment on request. ingress-nginx only supports
+// one auth url, so the ingresses which already have one are skipped to keep their authentication in effect.
+func wakeOnRequestIngresses(ingresses []unstructured.Unstructured) ([]*commonmodels.SleepingIngress, []string) {
+	resp := make([]*commonmodels.SleepingIngress, 0, len(ingresses))
+	skipped := []string{}
+	for _, ingress := range ingresses {
+		if ingress.GetAnnotations()[ingressAuthURLAnnotation] != "" {
+			skipped = append(skipped, ingress.GetName())
+			continue
+		}
+		resp = append(resp, &commonmodels.SleepingIngress{Name: ingress.GetName()})
+	}
+	return resp, skipped
+}
+
+// patchIngressAuthURL sets the auth url annotation of the ingress, the annotation is removed if authURL is empty.
+func patchIngressAuthURL(env *commonmodels.Product, name, authURL string) error {
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return err
+	}
+	cls, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return err
+	}
+	ingress, found, err := getter.GetUnstructuredIngress(env.Namespace, name, kubeClient, cls)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+
+	var value interface{}
+	if authURL != "" {
+		value = authURL
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{ingressAuthURLAnnotation: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return updater.PatchUnstructured(ingress, patch, types.MergePatchType, kubeClient)
+}
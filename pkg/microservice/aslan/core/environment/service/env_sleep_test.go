@@ -0,0 +1,48 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("Testing env sleep", func() {
+
+	newIngress := func(name, authURL string) unstructured.Unstructured {
+		ingress := unstructured.Unstructured{Object: map[string]interface{}{}}
+		ingress.SetName(name)
+		if authURL != "" {
+			ingress.SetAnnotations(map[string]string{ingressAuthURLAnnotation: authURL})
+		}
+		return ingress
+	}
+
+	It("should skip the ingresses with their own auth url", func() {
+		ingresses, skipped := wakeOnRequestIngresses([]unstructured.Unstructured{
+			newIngress("web", ""),
+			newIngress("admin", "https://auth.example.com/check"),
+			newIngress("api", ""),
+		})
+		Expect(ingresses).To(HaveLen(2))
+		Expect(ingresses[0].Name).To(Equal("web"))
+		Expect(ingresses[0].AuthURL).To(BeEmpty())
+		Expect(ingresses[1].Name).To(Equal("api"))
+		Expect(skipped).To(Equal([]string{"admin"}))
+	})
+})
@@ -293,6 +293,10 @@ func copySingleHelmProduct(templateProduct *templatemodels.Product, productInfo
 	productInfo.BaseName = arg.BaseName
 	productInfo.Namespace = commonservice.GetProductEnvNamespace(arg.EnvName, arg.ProductName, arg.Namespace)
 	productInfo.EnvConfigs = arg.EnvConfigs
+	// the copy is a sub environment if it is required, or it keeps the share env settings of the source
+	if arg.ShareEnv.Enable {
+		productInfo.ShareEnv = arg.ShareEnv
+	}
 
 	// merge chart infos, use chart info in product to override charts in template_project
 	sourceRenderSet, _, err := commonrepo.NewRenderSetColl().FindRenderSet(&commonrepo.RenderSetFindOption{
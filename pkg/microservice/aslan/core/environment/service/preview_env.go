@@ -0,0 +1,433 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	templatemodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/template"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	templaterepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/template"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
+	"github.com/koderover/zadig/pkg/setting"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// PreviewEnvArgs is the pull request which preview environments are created for, the repository is matched
+// by its namespace and name like the service templates synced from it.
+type PreviewEnvArgs struct {
+	RepoOwner     string
+	RepoNamespace string
+	RepoName      string
+	PrID          int
+	// Opened is true if the pull request is just opened or reopened.
+	Opened bool
+	// DiffFunc lists the files changed by the pull request with the codehost of the repository.
+	DiffFunc func(codehostID int) ([]string, error)
+	BaseURI  string
+}
+
+func (args *PreviewEnvArgs) findOption(projectName string) *commonrepo.PreviewEnvFindOption {
+	return &commonrepo.PreviewEnvFindOption{
+		ProjectName:   projectName,
+		RepoNamespace: args.RepoNamespace,
+		RepoName:      args.RepoName,
+		PrID:          args.PrID,
+	}
+}
+
+func (args *PreviewEnvArgs) matchRepo(namespace, name string) bool {
+	return namespace == args.RepoNamespace && name == args.RepoName
+}
+
+// preview environments of a pull request are created and deleted one at a time, so the cap of the project
+// is not exceeded by concurrent events.
+var previewEnvLock sync.Mutex
+
+// EnsurePreviewEnvs creates the preview environments of the pull request in the projects it touches, the
+// existing preview environments are marked active. The preview environments of the pull request are returned,
+// so that the code of the pull request can be deployed to them.
+func EnsurePreviewEnvs(args *PreviewEnvArgs, requestID string, log *zap.SugaredLogger) ([]*commonmodels.PreviewEnv, error) {
+	projects, err := templaterepo.NewProductColl().List()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*commonmodels.PreviewEnv, 0)
+	errs := &multierror.Error{}
+	for _, project := range projects {
+		if !project.PreviewEnv.IsEnabled() {
+			continue
+		}
+		previewEnv, err := ensurePreviewEnv(project, args, requestID, log)
+		if err != nil {
+			log.Errorf("failed to ensure preview env of %s/%s#%d in project %s, err: %s", args.RepoNamespace, args.RepoName, args.PrID, project.ProductName, err)
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if previewEnv != nil {
+			resp = append(resp, previewEnv)
+		}
+	}
+	return resp, errs.ErrorOrNil()
+}
+
+// PreviewEnvName is the name of the preview environment of the pull request, it is fixed so that it can be
+// referred to by workflows. the pull requests of different repositories may have the same id, so a short hash
+// of the repository is added.
+func PreviewEnvName(repoNamespace, repoName string, prID int) string {
+	sum := sha1.Sum([]byte(repoNamespace + "/" + repoName))
+	return fmt.Sprintf("pr-%d-%s", prID, hex.EncodeToString(sum[:])[:6])
+}
+
+func ensurePreviewEnv(project *templatemodels.Product, args *PreviewEnvArgs, requestID string, log *zap.SugaredLogger) (*commonmodels.PreviewEnv, error) {
+	previewEnvLock.Lock()
+	defer previewEnvLock.Unlock()
+
+	coll := commonrepo.NewPreviewEnvColl()
+	if previewEnv, err := coll.Find(args.findOption(project.ProductName)); err == nil {
+		return previewEnv, coll.UpdateLastActiveTime(project.ProductName, previewEnv.EnvName, time.Now().Unix())
+	}
+
+	policy := project.PreviewEnv
+	baseEnv, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: project.ProductName, EnvName: policy.BaseEnv})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find base env %s, err: %s", policy.BaseEnv, err)
+	}
+	if !baseEnv.ShareEnv.Enable || !baseEnv.ShareEnv.IsBase {
+		return nil, fmt.Errorf("env %s is not a base env of share envs", baseEnv.EnvName)
+	}
+
+	services, codehostID, err := getPreviewEnvServices(project.ProductName, baseEnv, args)
+	if err != nil {
+		return nil, err
+	}
+	// the pull request doesn't touch the project
+	if services.Len() == 0 {
+		return nil, nil
+	}
+
+	notification := &commonmodels.Notification{
+		CodehostID: codehostID,
+		PrID:       args.PrID,
+		ProjectID:  strings.TrimLeft(args.RepoNamespace+"/"+args.RepoName, "/"),
+		BaseURI:    args.BaseURI,
+		RepoOwner:  args.RepoOwner,
+		RepoName:   args.RepoName,
+		PreviewEnv: &commonmodels.PreviewEnvInfo{
+			ProductName: project.ProductName,
+			BaseEnv:     baseEnv.EnvName,
+			Services:    services.List(),
+		},
+	}
+
+	if policy.MaxEnvs > 0 {
+		count, err := coll.Count(project.ProductName)
+		if err != nil {
+			return nil, err
+		}
+		if count >= int64(policy.MaxEnvs) {
+			// only comment once for a pull request, the later events are rejected silently
+			if args.Opened {
+				notification.PreviewEnv.Status = "未创建"
+				notification.PreviewEnv.Message = fmt.Sprintf("项目 %s 的预览环境数量已达上限 %d，请关闭不再使用的合并请求后重新打开", project.ProductName, policy.MaxEnvs)
+				_ = scmnotify.NewService().UpdatePreviewEnvComment(notification, log)
+			}
+			return nil, e.ErrCreateEnv.AddDesc(fmt.Sprintf("the number of preview envs of project %s reaches the limit %d", project.ProductName, policy.MaxEnvs))
+		}
+	}
+
+	envName := PreviewEnvName(args.RepoNamespace, args.RepoName, args.PrID)
+	notification.PreviewEnv.EnvName = envName
+	if _, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: project.ProductName, EnvName: envName}); err == nil {
+		err = e.ErrCreateEnv.AddDesc(fmt.Sprintf("env %s already exists in project %s", envName, project.ProductName))
+		if args.Opened {
+			notification.PreviewEnv.Status = "未创建"
+			notification.PreviewEnv.Message = fmt.Sprintf("项目 %s 中已存在环境 %s", project.ProductName, envName)
+			_ = scmnotify.NewService().UpdatePreviewEnvComment(notification, log)
+		}
+		return nil, err
+	}
+	if err := createPreviewEnv(project, baseEnv, envName, services, requestID, log); err != nil {
+		notification.PreviewEnv.Status = "创建失败"
+		notification.PreviewEnv.Message = err.Error()
+		_ = scmnotify.NewService().UpdatePreviewEnvComment(notification, log)
+		return nil, err
+	}
+
+	notification.PreviewEnv.Status = "创建中"
+	if err := scmnotify.NewService().UpdatePreviewEnvComment(notification, log); err != nil {
+		log.Warnf("failed to comment preview env %s, err: %s", envName, err)
+	}
+
+	now := time.Now().Unix()
+	previewEnv := &commonmodels.PreviewEnv{
+		ProjectName:    project.ProductName,
+		EnvName:        envName,
+		BaseEnv:        baseEnv.EnvName,
+		CodehostID:     codehostID,
+		RepoOwner:      args.RepoOwner,
+		RepoNamespace:  args.RepoNamespace,
+		RepoName:       args.RepoName,
+		PrID:           args.PrID,
+		Services:       services.List(),
+		NotificationID: notification.ID.Hex(),
+		CreateTime:     now,
+		LastActiveTime: now,
+	}
+	return previewEnv, coll.Create(previewEnv)
+}
+
+// getPreviewEnvServices returns the services of the base environment which are built from the repository of
+// the pull request, or whose yaml are loaded from the files changed by it, and the codehost of the repository.
+func getPreviewEnvServices(projectName string, baseEnv *commonmodels.Product, args *PreviewEnvArgs) (sets.String, int, error) {
+	services := sets.NewString()
+	codehostID := 0
+
+	builds, err := commonrepo.NewBuildColl().List(&commonrepo.BuildListOption{ProductName: projectName})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list builds, err: %s", err)
+	}
+	for _, build := range builds {
+		repos := build.Repos
+		for _, target := range build.Targets {
+			repos = append(repos, target.Repos...)
+		}
+		repo := matchPreviewEnvRepo(repos, args)
+		if repo == nil {
+			continue
+		}
+		codehostID = repo.CodehostID
+		for _, target := range build.Targets {
+			if target.ProductName == projectName {
+				services.Insert(target.ServiceName)
+			}
+		}
+	}
+
+	templateServices, err := commonrepo.NewServiceColl().ListMaxRevisionsByProduct(projectName)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list services, err: %s", err)
+	}
+	var changedFiles []string
+	for _, svc := range templateServices {
+		namespace := svc.RepoNamespace
+		if namespace == "" {
+			namespace = svc.RepoOwner
+		}
+		if svc.LoadPath == "" || !args.matchRepo(namespace, svc.RepoName) {
+			continue
+		}
+		if changedFiles == nil {
+			if changedFiles, err = args.DiffFunc(svc.CodehostID); err != nil {
+				return nil, 0, fmt.Errorf("failed to list changed files, err: %s", err)
+			}
+		}
+		for _, file := range changedFiles {
+			if strings.HasPrefix(file, strings.TrimPrefix(svc.LoadPath, "/")) {
+				services.Insert(svc.ServiceName)
+				codehostID = svc.CodehostID
+				break
+			}
+		}
+	}
+
+	// the services not deployed in the base environment can't be routed to
+	deployed := sets.NewString()
+	for name := range baseEnv.GetServiceMap() {
+		deployed.Insert(name)
+	}
+	return services.Intersection(deployed), codehostID, nil
+}
+
+func matchPreviewEnvRepo(repos []*types.Repository, args *PreviewEnvArgs) *types.Repository {
+	for _, repo := range repos {
+		if args.matchRepo(repo.GetRepoNamespace(), repo.RepoName) {
+			return repo
+		}
+	}
+	return nil
+}
+
+// createPreviewEnv copies the services from the base environment into a sub environment of it.
+func createPreviewEnv(project *templatemodels.Product, baseEnv *commonmodels.Product, envName string, services sets.String, requestID string, log *zap.SugaredLogger) error {
+	renderSet, err := commonrepo.NewRenderSetColl().Find(&commonrepo.RenderSetFindOption{
+		Name:      baseEnv.Render.Name,
+		Revision:  baseEnv.Render.Revision,
+		IsDefault: false,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find renderset of base env %s, err: %s", baseEnv.EnvName, err)
+	}
+
+	arg := &CreateSingleProductArg{
+		ProductName:   project.ProductName,
+		EnvName:       envName,
+		ClusterID:     baseEnv.ClusterID,
+		RegistryID:    baseEnv.RegistryID,
+		DefaultValues: renderSet.DefaultValues,
+		ShareEnv: commonmodels.ProductShareEnv{
+			Enable:  true,
+			IsBase:  false,
+			BaseEnv: baseEnv.EnvName,
+		},
+	}
+
+	switch {
+	case project.IsHelmProduct():
+		arg.BaseName = baseEnv.EnvName
+		for _, chart := range renderSet.ChartInfos {
+			if !services.Has(chart.ServiceName) {
+				continue
+			}
+			renderArg := &commonservice.HelmSvcRenderArg{}
+			renderArg.LoadFromRenderChartModel(chart)
+			arg.ChartValues = append(arg.ChartValues, &ProductHelmServiceCreationInfo{
+				HelmSvcRenderArg: renderArg,
+				DeployStrategy:   setting.ServiceDeployStrategyDeploy,
+			})
+		}
+		return CopyHelmProduct(project.ProductName, setting.SystemUser, requestID, []*CreateSingleProductArg{arg}, log)
+	case project.IsK8sYamlProduct():
+		arg.BaseEnvName = baseEnv.EnvName
+		variableYamls := make(map[string]string)
+		for _, sv := range renderSet.ServiceVariables {
+			if sv.OverrideYaml != nil {
+				variableYamls[sv.ServiceName] = sv.OverrideYaml.YamlContent
+			}
+		}
+		for _, group := range baseEnv.Services {
+			serviceGroup := make([]*ProductK8sServiceCreationInfo, 0)
+			for _, svc := range group {
+				if !services.Has(svc.ServiceName) {
+					continue
+				}
+				svc.VariableYaml = variableYamls[svc.ServiceName]
+				serviceGroup = append(serviceGroup, &ProductK8sServiceCreationInfo{
+					ProductService: svc,
+					DeployStrategy: setting.ServiceDeployStrategyDeploy,
+				})
+			}
+			if len(serviceGroup) > 0 {
+				arg.Services = append(arg.Services, serviceGroup)
+			}
+		}
+		return CopyYamlProduct(setting.SystemUser, requestID, project.ProductName, []*CreateSingleProductArg{arg}, log)
+	default:
+		return fmt.Errorf("preview env is not supported by project %s", project.ProductName)
+	}
+}
+
+// DeletePreviewEnvs deletes the preview environments of the pull request when it is closed or merged.
+func DeletePreviewEnvs(args *PreviewEnvArgs, requestID string, log *zap.SugaredLogger) error {
+	previewEnvs, err := commonrepo.NewPreviewEnvColl().List("")
+	if err != nil {
+		return err
+	}
+
+	errs := &multierror.Error{}
+	for _, previewEnv := range previewEnvs {
+		if previewEnv.PrID != args.PrID || !args.matchRepo(previewEnv.RepoNamespace, previewEnv.RepoName) {
+			continue
+		}
+		if err := deletePreviewEnv(previewEnv, "合并请求已关闭", requestID, log); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func deletePreviewEnv(previewEnv *commonmodels.PreviewEnv, reason, requestID string, log *zap.SugaredLogger) error {
+	previewEnvLock.Lock()
+	defer previewEnvLock.Unlock()
+
+	_, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: previewEnv.ProjectName, EnvName: previewEnv.EnvName})
+	if err == nil {
+		if err := DeleteProduct(setting.SystemUser, previewEnv.EnvName, previewEnv.ProjectName, requestID, true, log); err != nil {
+			return fmt.Errorf("failed to delete preview env %s/%s, err: %s", previewEnv.ProjectName, previewEnv.EnvName, err)
+		}
+	}
+	if err := commonrepo.NewPreviewEnvColl().Delete(previewEnv.ProjectName, previewEnv.EnvName); err != nil {
+		return err
+	}
+	log.Infof("preview env %s/%s is deleted: %s", previewEnv.ProjectName, previewEnv.EnvName, reason)
+
+	if previewEnv.NotificationID == "" {
+		return nil
+	}
+	notification, err := scmnotify.NewService().Coll.Find(previewEnv.NotificationID)
+	if err != nil || notification.PreviewEnv == nil {
+		return nil
+	}
+	notification.PreviewEnv.Status = "已销毁"
+	notification.PreviewEnv.Message = reason
+	notification.PreviewEnv.Services = nil
+	_ = scmnotify.NewService().UpdatePreviewEnvComment(notification, log)
+	return nil
+}
+
+// CleanIdlePreviewEnvs deletes the preview environments which have not been active for the idle time of
+// their projects, updates of an environment, such as deployments from workflows, keep it active.
+func CleanIdlePreviewEnvs(requestID string, log *zap.SugaredLogger) {
+	previewEnvs, err := commonrepo.NewPreviewEnvColl().List("")
+	if err != nil {
+		log.Errorf("failed to list preview envs, err: %s", err)
+		return
+	}
+
+	policies := make(map[string]*templatemodels.PreviewEnvPolicy)
+	for _, previewEnv := range previewEnvs {
+		policy, ok := policies[previewEnv.ProjectName]
+		if !ok {
+			project, err := templaterepo.NewProductColl().Find(previewEnv.ProjectName)
+			if err != nil {
+				log.Errorf("failed to find project %s, err: %s", previewEnv.ProjectName, err)
+				continue
+			}
+			policy = project.PreviewEnv
+			policies[previewEnv.ProjectName] = policy
+		}
+		if policy == nil || policy.IdleMinutes <= 0 {
+			continue
+		}
+
+		lastActiveTime := previewEnv.LastActiveTime
+		env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: previewEnv.ProjectName, EnvName: previewEnv.EnvName})
+		if err == nil && env.UpdateTime > lastActiveTime {
+			lastActiveTime = env.UpdateTime
+		}
+		if time.Now().Unix()-lastActiveTime < policy.IdleMinutes*60 {
+			continue
+		}
+		reason := fmt.Sprintf("环境已闲置超过 %d 分钟", policy.IdleMinutes)
+		if err := deletePreviewEnv(previewEnv, reason, requestID, log); err != nil {
+			log.Errorf("failed to delete idle preview env %s/%s, err: %s", previewEnv.ProjectName, previewEnv.EnvName, err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing preview env name", func() {
+
+	It("should name the preview env after the pull request", func() {
+		name := PreviewEnvName("koderover", "zadig", 12)
+		Expect(name).To(MatchRegexp(`^pr-12-[0-9a-f]{6}$`))
+		Expect(PreviewEnvName("koderover", "zadig", 12)).To(Equal(name))
+	})
+
+	It("should not name the preview envs of pull requests with the same id in different repos the same", func() {
+		Expect(PreviewEnvName("koderover", "zadig", 12)).NotTo(Equal(PreviewEnvName("koderover", "zadig-portal", 12)))
+		Expect(PreviewEnvName("koderover", "zadig", 12)).NotTo(Equal(PreviewEnvName("other", "zadig", 12)))
+	})
+})
@@ -85,6 +85,8 @@ func CleanProductCronJob(requestID string, log *zap.SugaredLogger) {
 			log.Warnf("[%s] product %s deleted", product.EnvName, product.ProductName)
 		}
 	}
+
+	CleanIdlePreviewEnvs(requestID, log)
 }
 
 func GetInitProduct(productTmplName string, envType types.EnvType, isBaseEnv bool, baseEnvName string, log *zap.SugaredLogger) (*commonmodels.Product, error) {
@@ -297,6 +299,10 @@ func buildProductResp(envName string, prod *commonmodels.Product, log *zap.Sugar
 		prodResp.Status = setting.ClusterUnknown
 		return prodResp
 	}
+	if prod.Status == setting.ProductStatusSleeping {
+		prodResp.Status = setting.ProductStatusSleeping
+		return prodResp
+	}
 
 	var (
 		servicesResp = make([]*commonservice.ServiceResp, 0)
@@ -0,0 +1,512 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	fsservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/fs"
+	commonutil "github.com/koderover/zadig/pkg/microservice/aslan/core/common/util"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/shared/client/policy"
+	"github.com/koderover/zadig/pkg/tool/crypto"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	helmtool "github.com/koderover/zadig/pkg/tool/helmclient"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+const (
+	// envSnapshotFile is the plain content of the snapshots created before the content was encrypted.
+	envSnapshotFile = "snapshot.json"
+	// envSnapshotEncryptedFile is the content encrypted by the aes key of the system, since it contains the
+	// secrets and the helm values of the environment.
+	envSnapshotEncryptedFile = "snapshot.json.enc"
+)
+
+// EnvSnapshotContent is the content archived for a snapshot.
+type EnvSnapshotContent struct {
+	Product      *commonmodels.Product       `json:"product"`
+	RenderSet    *commonmodels.RenderSet     `json:"renderset"`
+	Services     []*commonmodels.Service     `json:"services"`
+	EnvResources []*commonmodels.EnvResource `json:"env_resources"`
+	// HelmValues are the values of the helm releases, the key is the service name
+	HelmValues map[string]string `json:"helm_values,omitempty"`
+}
+
+type CreateEnvSnapshotArgs struct {
+	Description string `json:"description"`
+}
+
+type RestoreEnvSnapshotArgs struct {
+	EnvName   string `json:"env_name"`
+	ClusterID string `json:"cluster_id"`
+	Namespace string `json:"namespace"`
+}
+
+func envSnapshotArchiveName(envName string, version int64) string {
+	return fmt.Sprintf("%s-%d", envName, version)
+}
+
+func CreateEnvSnapshot(productName, envName, userName string, args *CreateEnvSnapshotArgs, log *zap.SugaredLogger) (*commonmodels.EnvSnapshot, error) {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+	if env.Source == setting.SourceFromExternal || env.Source == setting.SourceFromPM {
+		return nil, e.ErrInvalidParam.AddDesc("snapshots are only supported by k8s yaml and helm environments")
+	}
+	switch env.Status {
+	case setting.ProductStatusCreating, setting.ProductStatusUpdating, setting.ProductStatusDeleting:
+		return nil, e.ErrInvalidParam.AddDesc(e.EnvCantUpdatedMsg)
+	}
+
+	content, err := buildEnvSnapshotContent(env)
+	if err != nil {
+		log.Errorf("failed to build snapshot of env %s/%s, err: %s", productName, envName, err)
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+
+	version, err := commonrepo.NewCounterColl().GetNextSeq(fmt.Sprintf("env_snapshot:%s&env:%s", productName, envName))
+	if err != nil {
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+	name := envSnapshotArchiveName(envName, version)
+	s3Base := config.ObjectStorageEnvSnapshotPath(productName, envName)
+	if err := uploadEnvSnapshotContent(content, name, s3Base, log); err != nil {
+		return nil, e.ErrGetEnv.AddErr(fmt.Errorf("failed to upload snapshot, err: %s", err))
+	}
+
+	snapshot := &commonmodels.EnvSnapshot{
+		ProductName: productName,
+		EnvName:     envName,
+		Version:     version,
+		Source:      env.Source,
+		ClusterID:   env.ClusterID,
+		Namespace:   env.Namespace,
+		Description: args.Description,
+		ObjectKey:   filepath.Join(s3Base, name+".tar.gz"),
+		CreatedBy:   userName,
+		CreateTime:  time.Now().Unix(),
+	}
+	for _, svc := range content.Services {
+		snapshot.Services = append(snapshot.Services, svc.ServiceName)
+	}
+	if err := commonrepo.NewEnvSnapshotColl().Create(snapshot); err != nil {
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+	return snapshot, nil
+}
+
+func buildEnvSnapshotContent(env *commonmodels.Product) (*EnvSnapshotContent, error) {
+	env.EnsureRenderInfo()
+	renderSet, _, err := commonrepo.NewRenderSetColl().FindRenderSet(&commonrepo.RenderSetFindOption{
+		Name:     env.Render.Name,
+		EnvName:  env.EnvName,
+		Revision: env.Render.Revision,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find renderset, err: %s", err)
+	}
+	content := &EnvSnapshotContent{
+		Product:    env,
+		RenderSet:  renderSet,
+		HelmValues: make(map[string]string),
+	}
+
+	var helmClient *helmtool.HelmClient
+	if env.Source == setting.SourceFromHelm {
+		if helmClient, err = helmtool.NewClientFromNamespace(env.ClusterID, env.Namespace); err != nil {
+			return nil, fmt.Errorf("failed to create helm client, err: %s", err)
+		}
+	}
+	for _, group := range env.Services {
+		for _, svc := range group {
+			serviceObj, err := commonrepo.NewServiceColl().Find(&commonrepo.ServiceFindOption{
+				ServiceName: svc.ServiceName,
+				ProductName: svc.ProductName,
+				Type:        svc.Type,
+				Revision:    svc.Revision,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to find service %s of revision %d, err: %s", svc.ServiceName, svc.Revision, err)
+			}
+			content.Services = append(content.Services, serviceObj)
+
+			if helmClient == nil || !commonutil.ServiceDeployed(svc.ServiceName, env.ServiceDeployStrategy) {
+				continue
+			}
+			releaseName := util.GeneReleaseName(serviceObj.GetReleaseNaming(), env.ProductName, env.Namespace, env.EnvName, svc.ServiceName)
+			rel, err := helmClient.GetRelease(releaseName)
+			if errors.Is(err, driver.ErrReleaseNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to get release %s, err: %s", releaseName, err)
+			}
+			values, err := yaml.Marshal(rel.Config)
+			if err != nil {
+				return nil, err
+			}
+			content.HelmValues[svc.ServiceName] = string(values)
+		}
+	}
+
+	resources, err := commonrepo.NewEnvResourceColl().ListLatestResource(&commonrepo.QueryEnvResourceOption{
+		ProductName: env.ProductName,
+		EnvName:     env.EnvName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list env resources, err: %s", err)
+	}
+	for _, res := range resources {
+		resource, err := getLatestEnvResource(res.ID.Name, res.ID.Type, env.EnvName, env.ProductName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find env resource %s, err: %s", res.ID.Name, err)
+		}
+		content.EnvResources = append(content.EnvResources, resource)
+	}
+	return content, nil
+}
+
+func uploadEnvSnapshotContent(content *EnvSnapshotContent, name, s3Base string, log *zap.SugaredLogger) error {
+	tmpDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	bs, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	encrypted, err := crypto.AesEncrypt(string(bs))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt snapshot, err: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, envSnapshotEncryptedFile), []byte(encrypted), 0600); err != nil {
+		return err
+	}
+	return fsservice.ArchiveAndUploadFilesToS3(os.DirFS(tmpDir), []string{name}, s3Base, log)
+}
+
+func ListEnvSnapshots(productName, envName string) ([]*commonmodels.EnvSnapshot, error) {
+	return commonrepo.NewEnvSnapshotColl().List(productName, envName)
+}
+
+// GetEnvSnapshotContent downloads the content of the snapshot from the object storage.
+func GetEnvSnapshotContent(productName, envName string, version int64, log *zap.SugaredLogger) (*EnvSnapshotContent, error) {
+	if _, err := commonrepo.NewEnvSnapshotColl().Find(productName, envName, version); err != nil {
+		return nil, e.ErrInvalidParam.AddDesc(fmt.Sprintf("snapshot %d of env %s is not found", version, envName))
+	}
+
+	tmpDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	s3Base := config.ObjectStorageEnvSnapshotPath(productName, envName)
+	if err := fsservice.DownloadAndExtractFilesFromS3(envSnapshotArchiveName(envName, version), tmpDir, s3Base, log); err != nil {
+		return nil, e.ErrGetEnv.AddErr(fmt.Errorf("failed to download snapshot, err: %s", err))
+	}
+	bs, err := readEnvSnapshotFile(tmpDir)
+	if err != nil {
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+	content := new(EnvSnapshotContent)
+	if err := json.Unmarshal(bs, content); err != nil {
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+	if content.Product == nil || content.RenderSet == nil {
+		return nil, e.ErrGetEnv.AddDesc("invalid snapshot content")
+	}
+	return content, nil
+}
+
+// readEnvSnapshotFile returns the decrypted content of the snapshot extracted to dir.
+func readEnvSnapshotFile(dir string) ([]byte, error) {
+	encrypted, err := os.ReadFile(filepath.Join(dir, envSnapshotEncryptedFile))
+	if os.IsNotExist(err) {
+		return os.ReadFile(filepath.Join(dir, envSnapshotFile))
+	}
+	if err != nil {
+		return nil, err
+	}
+	decrypted, err := crypto.AesDecrypt(string(encrypted))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt snapshot, err: %s", err)
+	}
+	return []byte(decrypted), nil
+}
+
+func DeleteEnvSnapshot(productName, envName string, version int64, log *zap.SugaredLogger) error {
+	if _, err := commonrepo.NewEnvSnapshotColl().Find(productName, envName, version); err != nil {
+		return e.ErrInvalidParam.AddDesc(fmt.Sprintf("snapshot %d of env %s is not found", version, envName))
+	}
+	s3Base := config.ObjectStorageEnvSnapshotPath(productName, envName)
+	if err := fsservice.DeleteArchivedFileFromS3([]string{envSnapshotArchiveName(envName, version)}, s3Base, log); err != nil {
+		log.Warnf("failed to delete snapshot archive %d of env %s, err: %s", version, envName, err)
+	}
+	return commonrepo.NewEnvSnapshotColl().Delete(productName, envName, version)
+}
+
+// ensureSnapshotServices creates the service revisions used by the snapshot if they have been removed.
+func ensureSnapshotServices(content *EnvSnapshotContent, log *zap.SugaredLogger) error {
+	for _, svc := range content.Services {
+		_, err := commonrepo.NewServiceColl().Find(&commonrepo.ServiceFindOption{
+			ServiceName: svc.ServiceName,
+			ProductName: svc.ProductName,
+			Type:        svc.Type,
+			Revision:    svc.Revision,
+		})
+		if err == nil {
+			continue
+		}
+		if !commonrepo.IsErrNoDocuments(err) {
+			return err
+		}
+		log.Infof("service %s of revision %d is restored from snapshot", svc.ServiceName, svc.Revision)
+		if err := commonrepo.NewServiceColl().Create(svc); err != nil {
+			return fmt.Errorf("failed to restore service %s, err: %s", svc.ServiceName, err)
+		}
+	}
+	return nil
+}
+
+// snapshotRenderSet returns the renderset of the snapshot for the environment, the values of the helm releases
+// are used to make the releases the same as the ones in the snapshot.
+func snapshotRenderSet(content *EnvSnapshotContent, renderName, envName string) *commonmodels.RenderSet {
+	renderSet := *content.RenderSet
+	renderSet.Name = renderName
+	renderSet.EnvName = envName
+	renderSet.Revision = 0
+	for _, chart := range renderSet.ChartInfos {
+		if values, ok := content.HelmValues[chart.ServiceName]; ok {
+			overrideChartValues(chart, values)
+		}
+	}
+	return &renderSet
+}
+
+func snapshotEnvConfigs(content *EnvSnapshotContent, envName string) []*commonmodels.CreateUpdateCommonEnvCfgArgs {
+	ret := make([]*commonmodels.CreateUpdateCommonEnvCfgArgs, 0, len(content.EnvResources))
+	for _, res := range content.EnvResources {
+		ret = append(ret, &commonmodels.CreateUpdateCommonEnvCfgArgs{
+			EnvName:          envName,
+			ProductName:      res.ProductName,
+			Name:             res.Name,
+			YamlData:         res.YamlData,
+			CommonEnvCfgType: config.CommonEnvCfgType(res.Type),
+			AutoSync:         res.AutoSync,
+		})
+	}
+	return ret
+}
+
+// RestoreEnvSnapshot creates a new environment from the snapshot, the environment may be in another cluster.
+func RestoreEnvSnapshot(productName, envName string, version int64, args *RestoreEnvSnapshotArgs, userID, userName, requestID string, log *zap.SugaredLogger) error {
+	if args.EnvName == "" {
+		return e.ErrInvalidParam.AddDesc("env_name can't be empty")
+	}
+	// the snapshot has the configurations of the source environment, only the users who can view it can restore it.
+	allowed, err := canViewEnv(userID, productName, envName)
+	if err != nil {
+		return e.ErrCreateEnv.AddErr(err)
+	}
+	if !allowed {
+		return e.ErrForbidden.AddDesc(fmt.Sprintf("permission denied for env %s", envName))
+	}
+	content, err := GetEnvSnapshotContent(productName, envName, version, log)
+	if err != nil {
+		return err
+	}
+	if err := ensureSnapshotServices(content, log); err != nil {
+		return e.ErrCreateEnv.AddErr(err)
+	}
+
+	env := content.Product
+	env.ID = primitive.NilObjectID
+	env.EnvName = args.EnvName
+	env.Namespace = commonservice.GetProductEnvNamespace(args.EnvName, productName, args.Namespace)
+	if args.ClusterID != "" {
+		env.ClusterID = args.ClusterID
+	}
+	env.Revision = 1
+	env.Status = ""
+	env.Error = ""
+	env.BaseName = ""
+	// the base environment of a sub environment may not exist in the cluster
+	env.ShareEnv = commonmodels.ProductShareEnv{}
+	env.EnvConfigs = snapshotEnvConfigs(content, args.EnvName)
+	for _, group := range env.Services {
+		for _, svc := range group {
+			svc.Error = ""
+		}
+	}
+
+	renderSet := snapshotRenderSet(content, env.Namespace, args.EnvName)
+	if err := commonservice.ForceCreateReaderSet(renderSet, log); err != nil {
+		return e.ErrCreateEnv.AddErr(err)
+	}
+	env.Render = &commonmodels.RenderInfo{Name: renderSet.Name, Revision: renderSet.Revision, ProductTmpl: productName}
+	env.ServiceRenders = renderSet.ChartInfos
+
+	return CreateProduct(userName, requestID, env, log)
+}
+
+// verbGetEnvironment is the action in the policy metas to view an environment.
+const verbGetEnvironment = "get_environment"
+
+// canViewEnv returns whether the user can view the environment of the project.
+func canViewEnv(userID, productName, envName string) (bool, error) {
+	rules, err := policy.NewDefault().GetUserRulesByProject(userID, productName)
+	if err != nil {
+		return false, fmt.Errorf("failed to get the permissions of user %s, err: %s", userID, err)
+	}
+	if rules.IsSystemAdmin || rules.IsProjectAdmin {
+		return true, nil
+	}
+	return sets.NewString(rules.ProjectVerbs...).Has(verbGetEnvironment) || sets.NewString(rules.EnvironmentVerbsMap[envName]...).Has(verbGetEnvironment), nil
+}
+
+// RollbackEnvToSnapshot updates the environment to the services, variables and env resources of the snapshot,
+// the services which are not in the snapshot are removed.
+func RollbackEnvToSnapshot(productName, envName string, version int64, userName, requestID string, log *zap.SugaredLogger) error {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return e.ErrGetEnv.AddErr(err)
+	}
+	switch env.Status {
+	case setting.ProductStatusCreating, setting.ProductStatusUpdating, setting.ProductStatusDeleting:
+		return e.ErrUpdateEnv.AddDesc(e.EnvCantUpdatedMsg)
+	}
+	content, err := GetEnvSnapshotContent(productName, envName, version, log)
+	if err != nil {
+		return err
+	}
+	if err := ensureSnapshotServices(content, log); err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+
+	env.EnsureRenderInfo()
+	renderSet := snapshotRenderSet(content, env.Render.Name, envName)
+	if err := commonservice.ForceCreateReaderSet(renderSet, log); err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+
+	if err := commonrepo.NewProductColl().UpdateStatus(envName, productName, setting.ProductStatusUpdating); err != nil {
+		return e.ErrUpdateEnv.AddDesc(e.UpdateEnvStatusErrMsg)
+	}
+	go func() {
+		status, errMsg := setting.ProductStatusSuccess, ""
+		if err := rollbackEnvToSnapshot(env, content, renderSet, userName, log); err != nil {
+			log.Errorf("failed to rollback env %s/%s to snapshot %d, err: %s", productName, envName, version, err)
+			title := fmt.Sprintf("回滚 [%s] 的 [%s] 环境失败", productName, envName)
+			commonservice.SendErrorMessage(userName, title, requestID, err, log)
+			status, errMsg = setting.ProductStatusFailed, err.Error()
+		}
+		if err := commonrepo.NewProductColl().UpdateStatusAndError(envName, productName, status, errMsg); err != nil {
+			log.Errorf("failed to update status of env %s/%s, err: %s", productName, envName, err)
+		}
+	}()
+	return nil
+}
+
+func rollbackEnvToSnapshot(env *commonmodels.Product, content *EnvSnapshotContent, renderSet *commonmodels.RenderSet, userName string, log *zap.SugaredLogger) error {
+	kubeClient, istioClient, inf, err := newEnvKubeClients(env)
+	if err != nil {
+		return err
+	}
+	var helmClient *helmtool.HelmClient
+	if env.Source == setting.SourceFromHelm {
+		if helmClient, err = helmtool.NewClientFromNamespace(env.ClusterID, env.Namespace); err != nil {
+			return err
+		}
+	}
+
+	// remove the services added after the snapshot
+	snapshotServices := content.Product.GetServiceMap()
+	for name, svc := range env.GetServiceMap() {
+		if _, ok := snapshotServices[name]; ok || !commonutil.ServiceDeployed(name, env.ServiceDeployStrategy) {
+			continue
+		}
+		if helmClient != nil {
+			if err := UninstallServiceByName(helmClient, name, env, svc.Revision, true); err != nil {
+				return fmt.Errorf("failed to uninstall service %s, err: %s", name, err)
+			}
+			continue
+		}
+		selector := labels.Set{setting.ProductLabel: env.ProductName, setting.ServiceLabel: name}.AsSelector()
+		if err := commonservice.DeleteNamespacedResource(env.Namespace, selector, env.ClusterID, log); err != nil {
+			log.Errorf("failed to delete resources of service %s, err: %s", name, err)
+		}
+		clusterSelector := labels.Set{setting.ProductLabel: env.ProductName, setting.ServiceLabel: name, setting.EnvNameLabel: env.EnvName}.AsSelector()
+		if err := commonservice.DeleteClusterResource(clusterSelector, env.ClusterID, log); err != nil {
+			log.Errorf("failed to delete cluster resources of service %s, err: %s", name, err)
+		}
+	}
+
+	updateProd := *env
+	updateProd.Services = content.Product.Services
+	updateProd.ServiceDeployStrategy = content.Product.ServiceDeployStrategy
+	updateProd.Render = &commonmodels.RenderInfo{Name: renderSet.Name, Revision: renderSet.Revision, ProductTmpl: renderSet.ProductTmpl}
+	updateProd.ServiceRenders = renderSet.ChartInfos
+	updateProd.Status = setting.ProductStatusUpdating
+	if err := commonrepo.NewProductColl().Update(&updateProd); err != nil {
+		return err
+	}
+
+	if helmClient != nil {
+		if err := proceedHelmRelease(&updateProd, renderSet, helmClient, nil, log); err != nil {
+			return err
+		}
+	} else {
+		existedServices := env.GetServiceMap()
+		for groupIndex, group := range updateProd.Services {
+			for _, svc := range group {
+				if !commonutil.ServiceDeployed(svc.ServiceName, updateProd.ServiceDeployStrategy) {
+					continue
+				}
+				svc.Error = ""
+				if _, err := upsertService(&updateProd, svc, existedServices[svc.ServiceName], renderSet, env.Render, inf, kubeClient, istioClient, log); err != nil {
+					svc.Error = err.Error()
+				}
+			}
+			if err := commonrepo.NewProductColl().UpdateGroup(env.EnvName, env.ProductName, groupIndex, group); err != nil {
+				return err
+			}
+		}
+	}
+
+	return initEnvConfigSetAction(env.EnvName, env.Namespace, env.ProductName, userName, snapshotEnvConfigs(content, env.EnvName), false, kubeClient)
+}
@@ -27,6 +27,7 @@ import (
 	logservice "github.com/koderover/zadig/pkg/microservice/aslan/core/log/service"
 	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
 	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/types/job"
 )
 
 func GetBuildJobContainerLogs(c *gin.Context) {
@@ -69,8 +70,23 @@ func GetWorkflowV4JobContainerLogs(c *gin.Context) {
 		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
 		return
 	}
+	jobName := c.Param("jobName")
+	taskID = logservice.GetWorkflowV4JobTaskID(c.Param("workflowName"), jobName, taskID)
+	// logs of the failed attempts of a retried job.
+	if c.Query("attempt") != "" {
+		attempt, err := strconv.Atoi(c.Query("attempt"))
+		if err != nil || attempt <= 0 {
+			ctx.Err = e.ErrInvalidParam.AddDesc("invalid attempt")
+			return
+		}
+		jobName = job.GetJobAttemptLogName(jobName, attempt)
+	}
+	// logs of a service container of the job.
+	if c.Query("service") != "" {
+		jobName = job.GetServiceContainerLogName(jobName, c.Query("service"))
+	}
 	// Use all lowercase job names to avoid subdomain errors
-	ctx.Resp, ctx.Err = logservice.GetWorkflowV4JobContainerLogs(strings.ToLower(c.Param("workflowName")), c.Param("jobName"), taskID, ctx.Logger)
+	ctx.Resp, ctx.Err = logservice.GetWorkflowV4JobContainerLogs(strings.ToLower(c.Param("workflowName")), jobName, taskID, ctx.Logger)
 }
 
 func GetTestJobContainerLogs(c *gin.Context) {
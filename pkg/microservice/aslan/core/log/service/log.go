@@ -64,6 +64,23 @@ func GetWorkflowV4JobContainerLogs(workflowName, jobName string, taskID int64, l
 	return buildLog, nil
 }
 
+// GetWorkflowV4JobTaskID returns the id of the task in which the job ran, it is not the given task
+// if the result of the job was reused by a retried task.
+func GetWorkflowV4JobTaskID(workflowName, jobName string, taskID int64) int64 {
+	task, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+	if err != nil {
+		return taskID
+	}
+	for _, stage := range task.Stages {
+		for _, job := range stage.Jobs {
+			if job.Name == jobName && job.ReusedFrom > 0 {
+				return job.ReusedFrom
+			}
+		}
+	}
+	return taskID
+}
+
 func GetTestJobContainerLogs(pipelineName, serviceName string, taskID int64, log *zap.SugaredLogger) (string, error) {
 	taskName := fmt.Sprintf("%s-%s-%d-%s-%s", config.SingleType, pipelineName, taskID, config.TaskTestingV2, serviceName)
 	return getContainerLogFromS3(pipelineName, taskName, taskID, log)
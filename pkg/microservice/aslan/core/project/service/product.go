@@ -903,6 +903,10 @@ func ensureProductTmpl(args *template.Product) error {
 		}
 	}
 
+	if err := ensurePreviewEnvPolicy(args); err != nil {
+		return err
+	}
+
 	// 设置新的版本号
 	rev, err := commonrepo.NewCounterColl().GetNextSeq("product:" + args.ProductName)
 	if err != nil {
@@ -913,6 +917,39 @@ func ensureProductTmpl(args *template.Product) error {
 	return nil
 }
 
+// ensurePreviewEnvPolicy checks the base env of the preview envs, preview envs are sub envs of it, and the
+// workflow deploying to them.
+func ensurePreviewEnvPolicy(args *template.Product) error {
+	policy := args.PreviewEnv
+	if !policy.IsEnabled() {
+		return nil
+	}
+	if policy.MaxEnvs < 0 || policy.IdleMinutes < 0 {
+		return fmt.Errorf("max envs and idle minutes of preview env can't be negative")
+	}
+	if !args.IsK8sYamlProduct() && !args.IsHelmProduct() {
+		return fmt.Errorf("preview env is only supported by k8s yaml and helm projects")
+	}
+	baseEnv, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: args.ProductName, EnvName: policy.BaseEnv})
+	if err != nil {
+		return fmt.Errorf("base env %s of preview env not found: %s", policy.BaseEnv, err)
+	}
+	if !baseEnv.ShareEnv.Enable || !baseEnv.ShareEnv.IsBase {
+		return fmt.Errorf("env %s is not a base env of share envs", policy.BaseEnv)
+	}
+	if policy.Workflow == "" {
+		return nil
+	}
+	workflow, err := commonrepo.NewWorkflowV4Coll().Find(policy.Workflow)
+	if err != nil {
+		return fmt.Errorf("workflow %s of preview env not found: %s", policy.Workflow, err)
+	}
+	if workflow.Project != args.ProductName {
+		return fmt.Errorf("workflow %s of preview env is not in project %s", policy.Workflow, args.ProductName)
+	}
+	return nil
+}
+
 func DeleteProductsAsync(userName, productName, requestID string, isDelete bool, log *zap.SugaredLogger) error {
 	envs, err := commonrepo.NewProductColl().List(&commonrepo.ProductListOptions{Name: productName})
 	if err != nil {
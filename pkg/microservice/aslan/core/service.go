@@ -157,6 +157,8 @@ func Start(ctx context.Context) {
 
 	//Parse the workload dependencies configMap, PVC, ingress, secret
 	go environmentservice.StartClusterInformer()
+	// Detect the changes made to the environments outside of zadig
+	go environmentservice.StartDriftScanner()
 
 	go StartControllers(ctx.Done())
 
@@ -252,16 +254,21 @@ func initDatabase() {
 		commonrepo.NewDeliveryVersionColl(),
 		commonrepo.NewDiffNoteColl(),
 		commonrepo.NewDindCleanColl(),
+		commonrepo.NewEnvDriftColl(),
+		commonrepo.NewEnvSnapshotColl(),
 		commonrepo.NewIMAppColl(),
 		commonrepo.NewFavoriteColl(),
 		commonrepo.NewGithubAppColl(),
 		commonrepo.NewHelmRepoColl(),
+		commonrepo.NewImageScanColl(),
 		commonrepo.NewInstallColl(),
 		commonrepo.NewItReportColl(),
 		commonrepo.NewK8SClusterColl(),
+		commonrepo.NewLeaseColl(),
 		commonrepo.NewNotificationColl(),
 		commonrepo.NewNotifyColl(),
 		commonrepo.NewPipelineColl(),
+		commonrepo.NewPreviewEnvColl(),
 		commonrepo.NewPrivateKeyColl(),
 		commonrepo.NewProductColl(),
 		commonrepo.NewProxyColl(),
@@ -275,6 +282,7 @@ func initDatabase() {
 		commonrepo.NewSubscriptionColl(),
 		commonrepo.NewSystemSettingColl(),
 		commonrepo.NewTaskColl(),
+		commonrepo.NewTestCoverageColl(),
 		commonrepo.NewTestTaskStatColl(),
 		commonrepo.NewTestingColl(),
 		commonrepo.NewWebHookColl(),
@@ -294,6 +302,7 @@ func initDatabase() {
 		commonrepo.NewWorkflowV4Coll(),
 		commonrepo.NewworkflowTaskv4Coll(),
 		commonrepo.NewWorkflowQueueColl(),
+		commonrepo.NewWorkflowApprovalColl(),
 		commonrepo.NewPluginRepoColl(),
 		commonrepo.NewWorkflowViewColl(),
 		commonrepo.NewWorkflowV4TemplateColl(),
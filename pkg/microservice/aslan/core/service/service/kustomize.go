@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/shared/client/systemconfig"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+// loadKustomizeService loads the directory as a service whose yaml is built from the kustomization in it.
+func loadKustomizeService(username string, ch *systemconfig.CodeHost, owner, namespace, repo, branch string, args *LoadServiceReq, force bool, logger *zap.SugaredLogger) error {
+	logger.Infof("Loading kustomize service from %s with owner %s, namespace %s, repo %s, branch %s and path %s", ch.Type, owner, namespace, repo, branch, args.LoadPath)
+
+	if !args.LoadFromDir {
+		return e.ErrLoadServiceTemplate.AddDesc("kustomize 服务只能从目录加载")
+	}
+	if args.Type != setting.K8SDeployType {
+		return e.ErrLoadServiceTemplate.AddDesc("kustomize 服务只支持 k8s 部署类型")
+	}
+	for _, overlay := range args.Kustomize.Overlays {
+		if overlay.EnvName == "" {
+			return e.ErrLoadServiceTemplate.AddDesc("overlay 的环境名称不能为空")
+		}
+	}
+
+	loader, err := getLoader(ch)
+	if err != nil {
+		logger.Errorf("Failed to create loader client, err: %s", err)
+		return e.ErrLoadServiceTemplate.AddDesc(err.Error())
+	}
+	commit, err := loader.GetLatestRepositoryCommit(namespace, repo, args.LoadPath, branch)
+	if err != nil {
+		logger.Errorf("Failed to get latest commit under path %s, error: %s", args.LoadPath, err)
+		return e.ErrLoadServiceTemplate.AddDesc(err.Error())
+	}
+
+	createSvcArgs := &models.Service{
+		CodehostID:    ch.ID,
+		RepoName:      repo,
+		RepoOwner:     owner,
+		RepoNamespace: namespace,
+		BranchName:    branch,
+		LoadPath:      args.LoadPath,
+		LoadFromDir:   true,
+		SrcPath:       fmt.Sprintf("%s/%s/%s/%s/%s/%s", ch.Address, namespace, repo, "tree", branch, args.LoadPath),
+		CreateBy:      username,
+		ServiceName:   getFileName(args.LoadPath),
+		Type:          args.Type,
+		ProductName:   args.ProductName,
+		Source:        ch.Type,
+		Commit:        &models.Commit{SHA: commit.SHA, Message: commit.Message},
+		Visibility:    args.Visibility,
+		Kustomize:     args.Kustomize,
+	}
+	tree, yaml, err := commonservice.LoadKustomizeService(createSvcArgs)
+	if err != nil {
+		return e.ErrLoadServiceTemplate.AddDesc(err.Error())
+	}
+	createSvcArgs.Yaml = yaml
+	createSvcArgs.KubeYamls = util.SplitManifests(yaml)
+
+	if _, err = CreateServiceTemplate(username, createSvcArgs, force, logger); err != nil {
+		logger.Errorf("Failed to create service template, err: %s", err)
+		_, messageMap := e.ErrorMessage(err)
+		if description, ok := messageMap["description"]; ok {
+			return e.ErrLoadServiceTemplate.AddDesc(description.(string))
+		}
+		return e.ErrLoadServiceTemplate.AddDesc("Load Service Error for unknown reason")
+	}
+
+	// the files are saved after the revision of the service is assigned.
+	if err = commonservice.SaveKustomizeService(createSvcArgs, tree); err != nil {
+		return e.ErrLoadServiceTemplate.AddDesc(err.Error())
+	}
+	return nil
+}
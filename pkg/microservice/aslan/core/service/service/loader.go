@@ -45,11 +45,12 @@ import (
 )
 
 type LoadServiceReq struct {
-	Type        string `json:"type"`
-	ProductName string `json:"product_name"`
-	Visibility  string `json:"visibility"`
-	LoadFromDir bool   `json:"is_dir"`
-	LoadPath    string `json:"path"`
+	Type        string            `json:"type"`
+	ProductName string            `json:"product_name"`
+	Visibility  string            `json:"visibility"`
+	LoadFromDir bool              `json:"is_dir"`
+	LoadPath    string            `json:"path"`
+	Kustomize   *models.Kustomize `json:"kustomize,omitempty"`
 }
 
 func PreloadServiceFromCodeHost(codehostID int, repoOwner, repoName, repoUUID, branchName, remoteName, path string, isDir bool, log *zap.SugaredLogger) ([]string, error) {
@@ -82,6 +83,12 @@ func LoadServiceFromCodeHost(username string, codehostID int, repoOwner, namespa
 		log.Errorf("Failed to load codehost for preload service list, the error is: %+v", err)
 		return e.ErrLoadServiceTemplate.AddDesc(err.Error())
 	}
+	if args.Kustomize != nil {
+		if ch.Type != setting.SourceFromGithub && ch.Type != setting.SourceFromGitlab {
+			return e.ErrLoadServiceTemplate.AddDesc("kustomize 服务只支持从 GitHub 和 GitLab 加载")
+		}
+		return loadKustomizeService(username, ch, repoOwner, namespace, repoName, branchName, args, force, log)
+	}
 	switch ch.Type {
 	case setting.SourceFromGithub, setting.SourceFromGitlab:
 		return loadService(username, ch, repoOwner, namespace, repoName, branchName, args, force, log)
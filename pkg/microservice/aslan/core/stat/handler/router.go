@@ -48,6 +48,7 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		quality.POST("/testDeliveryDeploy", GetTestDeliveryDeployMeasure)
 		quality.POST("/testHealthMeasure", GetTestHealthMeasure)
 		quality.POST("/testTrend", GetTestTrendMeasure)
+		quality.POST("/testCoverageTrend", GetTestCoverageTrendMeasure)
 		//deployStat
 		quality.POST("/initDeployStat", InitDeployStat)
 		quality.POST("/pipelineHealthMeasure", GetPipelineHealthMeasure)
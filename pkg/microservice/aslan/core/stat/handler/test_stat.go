@@ -91,6 +91,23 @@ func GetTestTrendMeasure(c *gin.Context) {
 	ctx.Resp, ctx.Err = service.GetTestTrendMeasure(args.StartDate, args.EndDate, args.ProductNames, ctx.Logger)
 }
 
+type getTestCoverageTrendReq struct {
+	getStatReq
+	TestNames []string `json:"testNames"`
+}
+
+func GetTestCoverageTrendMeasure(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+	//params validate
+	args := new(getTestCoverageTrendReq)
+	if err := c.BindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+	ctx.Resp, ctx.Err = service.GetTestCoverageTrendMeasure(args.StartDate, args.EndDate, args.ProductNames, args.TestNames, ctx.Logger)
+}
+
 //func GetTestTrendOpenAPI(c *gin.Context) {
 //	ctx := internalhandler.NewContext(c)
 //	defer func() { internalhandler.JSONResponse(c, ctx) }()
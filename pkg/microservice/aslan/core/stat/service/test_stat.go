@@ -526,3 +526,80 @@ func GetTestTrendMeasure(startDate, endDate int64, productNames []string, log *z
 
 	return testTrend, nil
 }
+
+type testCoverageTrend struct {
+	TestName       string               `json:"testName"`
+	PassRate       float64              `json:"passRate"`
+	LineCoverage   float64              `json:"lineCoverage"`
+	BranchCoverage float64              `json:"branchCoverage"`
+	Daily          []*testCoverageDaily `json:"daily"`
+}
+
+type testCoverageDaily struct {
+	Date           string  `json:"date"`
+	LineCoverage   float64 `json:"lineCoverage"`
+	BranchCoverage float64 `json:"branchCoverage"`
+}
+
+// GetTestCoverageTrendMeasure returns the daily average coverages of testings on branches, runs of pull requests
+// are not included, with the pass rates and the coverages of the latest runs.
+func GetTestCoverageTrendMeasure(startDate, endDate int64, productNames, testNames []string, log *zap.SugaredLogger) ([]*testCoverageTrend, error) {
+	coverages, err := commonmongodb.NewTestCoverageColl().List(&commonmongodb.TestCoverageListOption{
+		TestNames:    testNames,
+		ProjectNames: productNames,
+		StartTime:    startDate,
+		EndTime:      endDate,
+	})
+	if err != nil {
+		log.Errorf("ListTestCoverage err:%v", err)
+		return nil, fmt.Errorf("ListTestCoverage err:%v", err)
+	}
+
+	coverageMap := make(map[string]map[string][]*commonmodels.TestCoverage)
+	for _, coverage := range coverages {
+		if coverage.PR != 0 {
+			continue
+		}
+		if _, ok := coverageMap[coverage.TestName]; !ok {
+			coverageMap[coverage.TestName] = make(map[string][]*commonmodels.TestCoverage)
+		}
+		date := time.Unix(coverage.CreateTime, 0).Format(config.Date)
+		coverageMap[coverage.TestName][date] = append(coverageMap[coverage.TestName][date], coverage)
+	}
+
+	resp := make([]*testCoverageTrend, 0)
+	for testName, dateMap := range coverageMap {
+		trend := &testCoverageTrend{TestName: testName, Daily: make([]*testCoverageDaily, 0)}
+		if testTaskStat, err := commonmongodb.NewTestTaskStatColl().FindTestTaskStat(&commonmongodb.TestTaskStatOption{Name: testName}); err == nil {
+			if total := testTaskStat.TotalSuccess + testTaskStat.TotalFailure; total > 0 {
+				trend.PassRate = math.Round(float64(testTaskStat.TotalSuccess)*10000/float64(total)) / 100
+			}
+			trend.LineCoverage = testTaskStat.LineCoverage
+			trend.BranchCoverage = testTaskStat.BranchCoverage
+		}
+
+		dates := make([]string, 0, len(dateMap))
+		for date := range dateMap {
+			dates = append(dates, date)
+		}
+		sort.Strings(dates)
+		for _, date := range dates {
+			var lineCoverage, branchCoverage float64
+			for _, coverage := range dateMap[date] {
+				lineCoverage += coverage.LineCoverage
+				branchCoverage += coverage.BranchCoverage
+			}
+			count := float64(len(dateMap[date]))
+			trend.Daily = append(trend.Daily, &testCoverageDaily{
+				Date:           date,
+				LineCoverage:   math.Round(lineCoverage*100/count) / 100,
+				BranchCoverage: math.Round(branchCoverage*100/count) / 100,
+			})
+		}
+		resp = append(resp, trend)
+	}
+	sort.Slice(resp, func(i, j int) bool {
+		return resp[i].TestName < resp[j].TestName
+	})
+	return resp, nil
+}
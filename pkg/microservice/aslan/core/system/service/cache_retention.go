@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"sort"
+	"time"
+
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+)
+
+// handleDependencyCacheRetention evicts the dependency caches which are not used in MaxDays, then evicts the
+// least recently used ones until the total size is no more than MaxSize. the last modified time of a cache
+// object is its last used time, which is updated every time it is restored.
+func handleDependencyCacheRetention(strategy *commonmodels.CapacityStrategy, dryRun bool) error {
+	s3Server, err := s3.FindDefaultS3()
+	if err != nil {
+		log.Errorf("Failed to find default s3, error: %s", err)
+		return err
+	}
+	forcedPathStyle := true
+	if s3Server.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3tool.NewClient(s3Server.Endpoint, s3Server.Ak, s3Server.Sk, s3Server.Region, s3Server.Insecure, forcedPathStyle)
+	if err != nil {
+		log.Errorf("Failed to create s3 client, error: %s", err)
+		return err
+	}
+	objects, err := client.ListObjectInfos(s3Server.Bucket, s3Server.GetObjectPath("cache")+"/")
+	if err != nil {
+		return err
+	}
+
+	expired := evictCaches(objects, strategy.Retention, time.Now())
+	log.Infof("%d of %d dependency caches will be evicted", len(expired), len(objects))
+	if dryRun || len(expired) == 0 {
+		return nil
+	}
+	const batch = 1000
+	for i := 0; i < len(expired); i += batch {
+		end := i + batch
+		if end > len(expired) {
+			end = len(expired)
+		}
+		if err := client.DeleteObjects(s3Server.Bucket, expired[i:end]); err != nil {
+			log.Errorf("Failed to delete dependency caches, error: %s", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func evictCaches(objects []*awss3.Object, retention *commonmodels.RetentionConfig, now time.Time) []string {
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].LastModified.After(*objects[j].LastModified)
+	})
+	resp := make([]string, 0)
+	var total int64
+	for _, object := range objects {
+		if retention.MaxDays > 0 && object.LastModified.Before(now.AddDate(0, 0, -retention.MaxDays)) {
+			resp = append(resp, *object.Key)
+			continue
+		}
+		total += *object.Size
+		if retention.MaxSize > 0 && total > retention.MaxSize*1024*1024 {
+			resp = append(resp, *object.Key)
+		}
+	}
+	return resp
+}
@@ -43,7 +43,11 @@ func UpdateSysCapStrategy(strategy *commonmodels.CapacityStrategy) error {
 	}
 
 	// 更新成功后，立即按照新的配置清理数据
-	go handleWorkflowTaskRetentionCenter(strategy, false)
+	if strategy.Target == commonmodels.DependencyCacheRetention {
+		go handleDependencyCacheRetention(strategy, false)
+	} else {
+		go handleWorkflowTaskRetentionCenter(strategy, false)
+	}
 
 	return nil
 }
@@ -53,6 +57,9 @@ func GetCapacityStrategy(target commonmodels.CapacityTarget) (*commonmodels.Capa
 	if err != nil && target == commonmodels.WorkflowTaskRetention {
 		return commonmodels.DefaultWorkflowTaskRetention, nil // Return default setup
 	}
+	if err != nil && target == commonmodels.DependencyCacheRetention {
+		return commonmodels.DefaultDependencyCacheRetention, nil
+	}
 	return result, err
 }
 
@@ -65,7 +72,18 @@ func HandleSystemGC(dryRun bool) error {
 		return err
 	}
 
-	return handleWorkflowTaskRetentionCenter(strategy, dryRun)
+	if err := handleWorkflowTaskRetentionCenter(strategy, dryRun); err != nil {
+		return err
+	}
+
+	cacheStrategy, err := GetCapacityStrategy(commonmodels.DependencyCacheRetention)
+	if err != nil {
+		return err
+	}
+	if err := validateStrategy(cacheStrategy); err != nil {
+		return err
+	}
+	return handleDependencyCacheRetention(cacheStrategy, dryRun)
 }
 
 func CleanCache() error {
@@ -397,6 +415,16 @@ func validateStrategy(strategy *commonmodels.CapacityStrategy) error {
 				"can only set one positive value at a time. days: %v, items: %v",
 				retention.MaxDays, retention.MaxItems)
 		}
+	} else if strategy.Target == commonmodels.DependencyCacheRetention {
+		retention := strategy.Retention
+		if retention == nil {
+			return errors.New("SysCap strategy: nil retention config for DependencyCacheRetention")
+		}
+		if retention.MaxDays < 0 || retention.MaxSize < 0 || (retention.MaxDays == 0 && retention.MaxSize == 0) {
+			return fmt.Errorf("SysCap strategy: max days or size value invalid, "+
+				"at least one positive value should be set. days: %v, size: %v",
+				retention.MaxDays, retention.MaxSize)
+		}
 	} else {
 		// Note: currently doesn't support other strategies yet.
 		return fmt.Errorf("SysCap strategy target is invalid - passed in value: %v", strategy.Target)
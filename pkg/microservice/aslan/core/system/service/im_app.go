@@ -18,6 +18,7 @@ package service
 
 import (
 	"context"
+	"net/url"
 
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -25,8 +26,10 @@ import (
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/dingtalk"
 	e "github.com/koderover/zadig/pkg/tool/errors"
 	"github.com/koderover/zadig/pkg/tool/lark"
+	"github.com/koderover/zadig/pkg/tool/wecom"
 )
 
 func ListIMApp(_type string, log *zap.SugaredLogger) ([]*commonmodels.IMApp, error) {
@@ -40,11 +43,20 @@ func ListIMApp(_type string, log *zap.SugaredLogger) ([]*commonmodels.IMApp, err
 }
 
 func CreateIMApp(args *commonmodels.IMApp, log *zap.SugaredLogger) (string, error) {
+	if err := validateIMApp(args); err != nil {
+		return "", e.ErrCreateIMApp.AddErr(errors.Wrap(err, "validate"))
+	}
+	if args.Type == setting.IMWebhook && args.AppID == "" {
+		args.AppID = args.WebhookAddress
+	}
 	oid, err := mongodb.NewIMAppColl().Create(context.Background(), args)
 	if err != nil {
 		log.Errorf("create external approval error: %v", err)
 		return "", e.ErrCreateIMApp.AddErr(err)
 	}
+	if args.Type != setting.IMLark {
+		return oid, nil
+	}
 
 	client := lark.NewClient(args.AppID, args.AppSecret)
 
@@ -68,24 +80,29 @@ func CreateIMApp(args *commonmodels.IMApp, log *zap.SugaredLogger) (string, erro
 }
 
 func UpdateIMApp(id string, args *commonmodels.IMApp, log *zap.SugaredLogger) error {
-	if err := lark.Validate(args.AppID, args.AppSecret); err != nil {
+	if err := validateIMApp(args); err != nil {
 		return e.ErrUpdateIMApp.AddErr(errors.Wrap(err, "validate"))
 	}
-
-	client := lark.NewClient(args.AppID, args.AppSecret)
-	approvalCode, err := createLarkDefaultApprovalDefinition(client)
-	if err != nil {
-		return e.ErrUpdateIMApp.AddErr(errors.Wrap(err, "create definition"))
+	if args.Type == setting.IMWebhook && args.AppID == "" {
+		args.AppID = args.WebhookAddress
 	}
-	err = client.SubscribeApprovalDefinition(&lark.SubscribeApprovalDefinitionArgs{
-		ApprovalID: approvalCode,
-	})
-	if err != nil {
-		return e.ErrUpdateIMApp.AddErr(errors.Wrap(err, "subscribe"))
+
+	if args.Type == setting.IMLark {
+		client := lark.NewClient(args.AppID, args.AppSecret)
+		approvalCode, err := createLarkDefaultApprovalDefinition(client)
+		if err != nil {
+			return e.ErrUpdateIMApp.AddErr(errors.Wrap(err, "create definition"))
+		}
+		err = client.SubscribeApprovalDefinition(&lark.SubscribeApprovalDefinitionArgs{
+			ApprovalID: approvalCode,
+		})
+		if err != nil {
+			return e.ErrUpdateIMApp.AddErr(errors.Wrap(err, "subscribe"))
+		}
+		args.LarkDefaultApprovalCode = approvalCode
 	}
-	args.LarkDefaultApprovalCode = approvalCode
 
-	err = mongodb.NewIMAppColl().Update(context.Background(), id, args)
+	err := mongodb.NewIMAppColl().Update(context.Background(), id, args)
 	if err != nil {
 		log.Errorf("update external approval error: %v", err)
 		return e.ErrUpdateIMApp.AddErr(err)
@@ -103,12 +120,35 @@ func DeleteIMApp(id string, log *zap.SugaredLogger) error {
 }
 
 func ValidateIMApp(approval *commonmodels.IMApp, log *zap.SugaredLogger) error {
+	if err := validateIMApp(approval); err != nil {
+		return e.ErrValidateIMApp.AddErr(err)
+	}
+	return nil
+}
+
+func validateIMApp(approval *commonmodels.IMApp) error {
 	switch approval.Type {
 	case setting.IMLark:
 		return lark.Validate(approval.AppID, approval.AppSecret)
 	case setting.IMDingding:
+		if approval.DingTalkProcessCode == "" {
+			return errors.New("process code is empty")
+		}
+		return dingtalk.Validate(approval.AppID, approval.AppSecret)
+	case setting.IMWeCom:
+		if approval.WeComTemplateID == "" || approval.WeComControlID == "" {
+			return errors.New("template id or control id is empty")
+		}
+		return wecom.Validate(approval.WeComCorpID, approval.AppSecret)
+	case setting.IMWebhook:
+		if _, err := url.ParseRequestURI(approval.WebhookAddress); err != nil {
+			return errors.Wrap(err, "invalid webhook address")
+		}
+		if approval.WebhookSecret == "" {
+			return errors.New("webhook secret is empty")
+		}
 	default:
-		return e.ErrValidateIMApp.AddDesc("invalid type")
+		return errors.New("invalid type")
 	}
 	return nil
 }
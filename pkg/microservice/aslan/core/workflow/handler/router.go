@@ -175,6 +175,7 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		workflowV4.POST("/output/:jobName", GetWorkflowGlabalVars)
 		workflowV4.GET("/name/:name", FindWorkflowV4)
 		workflowV4.PUT("/:name", UpdateWorkflowV4)
+		workflowV4.POST("/code/sync/:name", SyncWorkflowV4FromCode)
 		workflowV4.DELETE("/:name", DeleteWorkflowV4)
 		workflowV4.GET("/preset/:name", GetWorkflowV4Preset)
 		workflowV4.GET("/webhook/preset", GetWebhookForWorkflowV4Preset)
@@ -211,9 +212,18 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		taskV4.POST("", CreateWorkflowTaskV4)
 		taskV4.GET("", ListWorkflowTaskV4)
 		taskV4.GET("/workflow/:workflowName/task/:taskID", GetWorkflowTaskV4)
+		taskV4.GET("/workflow/:workflowName/task/:taskID/job/:jobName/debug", GetWorkflowTaskV4JobDebugContext)
 		taskV4.DELETE("/workflow/:workflowName/task/:taskID", CancelWorkflowTaskV4)
 		taskV4.GET("/clone/workflow/:workflowName/task/:taskID", CloneWorkflowTaskV4)
+		taskV4.POST("/retry/workflow/:workflowName/task/:taskID", RetryWorkflowTaskV4)
+		taskV4.GET("/queue", ListQueuedWorkflowTasks)
+		taskV4.PUT("/queue/workflow/:workflowName/task/:taskID/priority", UpdateQueuedTaskPriority)
+		taskV4.POST("/queue/workflow/:workflowName/task/:taskID/preempt", PreemptQueuedTask)
 		taskV4.POST("/approve", ApproveStage)
+		taskV4.POST("/approval/delegate", DelegateApproval)
+		taskV4.GET("/approval/pending", ListPendingApprovals)
+		taskV4.POST("/approval/callback/:id", WebhookApprovalCallback)
+		taskV4.GET("/approval/workflow/:workflowName/task/:taskID/stage/:stageName", GetStageApproval)
 		taskV4.GET("/workflow/:workflowName/taskId/:taskId/job/:jobName", GetWorkflowV4ArtifactFileContent)
 	}
 
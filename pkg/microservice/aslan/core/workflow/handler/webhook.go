@@ -93,5 +93,11 @@ func processGithub(payload []byte, req *http.Request, requestID string, log *zap
 		log.Errorf("error happens to trigger workflowV4 for github %v", err)
 		errs = multierror.Append(errs, err)
 	}
+	// webhooks for preview envs
+	err = webhook.ProcessGithubWebHookForPreviewEnv(payload, req, requestID, log)
+	if err != nil {
+		log.Errorf("error happens to process preview envs for github %v", err)
+		errs = multierror.Append(errs, err)
+	}
 	return errs.ErrorOrNil()
 }
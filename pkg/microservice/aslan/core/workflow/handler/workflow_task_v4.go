@@ -25,6 +25,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
 	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
 	e "github.com/koderover/zadig/pkg/tool/errors"
@@ -50,6 +51,14 @@ type ApproveRequest struct {
 	Comment      string `json:"comment"`
 }
 
+type DelegateApprovalRequest struct {
+	StageName    string             `json:"stage_name"`
+	WorkflowName string             `json:"workflow_name"`
+	TaskID       int64              `json:"task_id"`
+	DelegateTo   *commonmodels.User `json:"delegate_to"`
+	Comment      string             `json:"comment"`
+}
+
 func CreateWorkflowTaskV4(c *gin.Context) {
 	ctx := internalhandler.NewContext(c)
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
@@ -69,9 +78,20 @@ func CreateWorkflowTaskV4(c *gin.Context) {
 		ctx.Err = e.ErrInvalidParam.AddDesc(err.Error())
 		return
 	}
+	// the priority in query overrides the one of the workflow for this task only.
+	var priority *int
+	if c.Query("priority") != "" {
+		p, err := strconv.Atoi(c.Query("priority"))
+		if err != nil {
+			ctx.Err = e.ErrInvalidParam.AddDesc("invalid priority")
+			return
+		}
+		priority = &p
+	}
 	ctx.Resp, ctx.Err = workflow.CreateWorkflowTaskV4(&workflow.CreateWorkflowTaskV4Args{
-		Name:   ctx.UserName,
-		UserID: ctx.UserID,
+		Name:     ctx.UserName,
+		UserID:   ctx.UserID,
+		Priority: priority,
 	}, args, ctx.Logger)
 }
 
@@ -105,6 +125,18 @@ func GetWorkflowTaskV4(c *gin.Context) {
 	ctx.Resp, ctx.Err = workflow.GetWorkflowTaskV4(c.Param("workflowName"), taskID, ctx.Logger)
 }
 
+func GetWorkflowTaskV4JobDebugContext(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	taskID, err := strconv.ParseInt(c.Param("taskID"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+	ctx.Resp, ctx.Err = workflow.GetWorkflowTaskV4JobDebugContext(c.Param("workflowName"), taskID, c.Param("jobName"), ctx.Logger)
+}
+
 func CancelWorkflowTaskV4(c *gin.Context) {
 	ctx := internalhandler.NewContext(c)
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
@@ -129,6 +161,63 @@ func CloneWorkflowTaskV4(c *gin.Context) {
 	ctx.Resp, ctx.Err = workflow.CloneWorkflowTaskV4(c.Param("workflowName"), taskID, ctx.Logger)
 }
 
+// RetryWorkflowTaskV4 runs the jobs of a failed or cancelled task again in a new task,
+// the job in query restarts the task from it.
+func RetryWorkflowTaskV4(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	taskID, err := strconv.ParseInt(c.Param("taskID"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+	ctx.Resp, ctx.Err = workflow.RetryWorkflowTaskV4(&workflow.CreateWorkflowTaskV4Args{
+		Name:   ctx.UserName,
+		UserID: ctx.UserID,
+	}, c.Param("workflowName"), taskID, c.Query("job"), ctx.Logger)
+}
+
+func ListQueuedWorkflowTasks(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	ctx.Resp, ctx.Err = workflow.ListQueuedWorkflowTasks(c.Query("projectName"), ctx.Logger)
+}
+
+type updateQueuedTaskPriorityReq struct {
+	Priority int `json:"priority"`
+}
+
+func UpdateQueuedTaskPriority(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	taskID, err := strconv.ParseInt(c.Param("taskID"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+	args := &updateQueuedTaskPriorityReq{}
+	if err := c.ShouldBindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc(err.Error())
+		return
+	}
+	ctx.Err = workflow.UpdateQueuedTaskPriority(c.Query("projectName"), c.Param("workflowName"), taskID, args.Priority, ctx.Logger)
+}
+
+func PreemptQueuedTask(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	taskID, err := strconv.ParseInt(c.Param("taskID"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+	ctx.Err = workflow.PreemptQueuedTask(c.Param("workflowName"), taskID, ctx.Logger)
+}
+
 func ApproveStage(c *gin.Context) {
 	ctx := internalhandler.NewContext(c)
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
@@ -152,6 +241,52 @@ func ApproveStage(c *gin.Context) {
 	ctx.Err = workflow.ApproveStage(args.WorkflowName, args.StageName, ctx.UserName, ctx.UserID, args.Comment, args.TaskID, args.Approve, ctx.Logger)
 }
 
+func DelegateApproval(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	args := &DelegateApprovalRequest{}
+	if err := c.ShouldBindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc(err.Error())
+		return
+	}
+
+	ctx.Err = workflow.DelegateApproval(args.WorkflowName, args.StageName, ctx.UserName, ctx.UserID, args.Comment, args.TaskID, args.DelegateTo, ctx.Logger)
+}
+
+func ListPendingApprovals(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	ctx.Resp, ctx.Err = workflow.ListPendingApprovals(ctx.UserID, ctx.Logger)
+}
+
+// WebhookApprovalCallback is called by the approval system of webhook approvals, it is verified by the signature.
+func WebhookApprovalCallback(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	body, err := c.GetRawData()
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+	ctx.Err = workflow.HandleWebhookApprovalCallback(c.Param("id"), c.GetHeader(workflowcontroller.WebhookSignatureHeader), body, ctx.Logger)
+}
+
+func GetStageApproval(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	taskID, err := strconv.ParseInt(c.Param("taskID"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+
+	ctx.Resp, ctx.Err = workflow.GetStageApproval(c.Param("workflowName"), c.Param("stageName"), taskID, ctx.Logger)
+}
+
 func GetWorkflowV4ArtifactFileContent(c *gin.Context) {
 	ctx := internalhandler.NewContext(c)
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
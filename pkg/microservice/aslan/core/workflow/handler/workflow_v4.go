@@ -140,6 +140,13 @@ func UpdateWorkflowV4(c *gin.Context) {
 	ctx.Err = workflow.UpdateWorkflowV4(c.Param("name"), ctx.UserName, args, ctx.Logger)
 }
 
+func SyncWorkflowV4FromCode(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	ctx.Resp, ctx.Err = workflow.SyncWorkflowV4FromCode(c.Param("name"), c.Query("ref"), ctx.UserName, ctx.Logger)
+}
+
 func DeleteWorkflowV4(c *gin.Context) {
 	ctx := internalhandler.NewContext(c)
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
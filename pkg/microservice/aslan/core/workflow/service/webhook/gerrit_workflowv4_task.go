@@ -263,6 +263,9 @@ func TriggerWorkflowV4ByGerritEvent(event *gerritTypeEvent, body []byte, uri, ba
 			if notification != nil {
 				workflow.NotificationID = notification.ID.Hex()
 			}
+			if hookPayload == nil || !hookPayload.IsPr {
+				hookPayload = getPushHookPayload(eventRepo)
+			}
 			workflow.HookPayload = hookPayload
 			if resp, err := workflowservice.CreateWorkflowTaskV4(&workflowservice.CreateWorkflowTaskV4Args{
 				Name: setting.WebhookTaskCreator,
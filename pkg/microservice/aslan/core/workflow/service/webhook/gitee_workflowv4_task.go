@@ -308,6 +308,9 @@ func TriggerWorkflowV4ByGiteeEvent(event interface{}, baseURI, requestID string,
 			if notification != nil {
 				workflow.NotificationID = notification.ID.Hex()
 			}
+			if hookPayload == nil || !hookPayload.IsPr {
+				hookPayload = getPushHookPayload(eventRepo)
+			}
 			workflow.HookPayload = hookPayload
 			if resp, err := workflowservice.CreateWorkflowTaskV4(&workflowservice.CreateWorkflowTaskV4Args{
 				Name: setting.WebhookTaskCreator,
@@ -231,6 +231,8 @@ func TriggerWorkflowV4ByGithubEvent(event interface{}, baseURI, deliveryID, requ
 		log.Error(errMsg)
 		return fmt.Errorf(errMsg)
 	}
+	codeEvent := getGithubWorkflowCodeEvent(event)
+	syncWorkflowsV4FromCode(workflows, codeEvent, log)
 
 	mErr := &multierror.Error{}
 	diffSrv := func(pullRequestEvent *github.PullRequestEvent, codehostId int) ([]string, error) {
@@ -293,30 +295,39 @@ func TriggerWorkflowV4ByGithubEvent(event interface{}, baseURI, deliveryID, requ
 			}
 			log.Infof("event match hook %v of %s", item.MainRepo, workflow.Name)
 			eventRepo := matcher.GetHookRepo(item.MainRepo)
-			if err := job.MergeArgs(workflow, item.WorkflowArg); err != nil {
+			taskWorkflow, err := getWorkflowV4ForEvent(workflow, codeEvent, log)
+			if err != nil {
+				log.Error(err)
+				mErr = multierror.Append(mErr, err)
+				continue
+			}
+			if err := job.MergeArgs(taskWorkflow, item.WorkflowArg); err != nil {
 				errMsg := fmt.Sprintf("merge workflow args error: %v", err)
 				log.Error(errMsg)
 				mErr = multierror.Append(mErr, fmt.Errorf(errMsg))
 				continue
 			}
-			if err := job.MergeWebhookRepo(workflow, eventRepo); err != nil {
+			if err := job.MergeWebhookRepo(taskWorkflow, eventRepo); err != nil {
 				errMsg := fmt.Sprintf("merge webhook repo info to workflowargs error: %v", err)
 				log.Error(errMsg)
 				mErr = multierror.Append(mErr, fmt.Errorf(errMsg))
 				continue
 			}
-			workflow.HookPayload = hookPayload
+			if hookPayload == nil || !hookPayload.IsPr {
+				hookPayload = getPushHookPayload(eventRepo)
+			}
+			taskWorkflow.HookPayload = hookPayload
 			if resp, err := workflowservice.CreateWorkflowTaskV4(&workflowservice.CreateWorkflowTaskV4Args{
 				Name: setting.WebhookTaskCreator,
-			}, workflow, log); err != nil {
+			}, taskWorkflow, log); err != nil {
 				errMsg := fmt.Sprintf("failed to create workflow task when receive push event due to %v ", err)
 				log.Error(errMsg)
 				mErr = multierror.Append(mErr, fmt.Errorf(errMsg))
 			} else {
-				if workflow.HookPayload.IsPr {
+				if taskWorkflow.HookPayload.IsPr {
 					// Updating the comment in the git repository, this will not cause the function to return error if this function call fails
-					if err := scmnotify.NewService().CreateGitCheckForWorkflowV4(workflow, resp.TaskID, log); err != nil {
-						log.Warnf("Failed to create github check status for custom workflow %s, taskID: %d the error is: %s", workflow.Name, resp.TaskID, err)
+					if err := scmnotify.NewService().CreateGitCheckForWorkflowV4(taskWorkflow, resp.TaskID, log); err != nil {
+						log.Warnf("Failed to create github check status for custom workflow %s, taskID: %d the error is: %s", taskWorkflow.Name, resp.TaskID, err)
 					}
 				}
 				log.Infof("succeed to create task %v", resp)
@@ -291,6 +291,8 @@ func TriggerWorkflowV4ByGitlabEvent(event interface{}, baseURI, requestID string
 		log.Error(errMsg)
 		return fmt.Errorf(errMsg)
 	}
+	codeEvent := getGitlabWorkflowCodeEvent(event)
+	syncWorkflowsV4FromCode(workflows, codeEvent, log)
 
 	mErr := &multierror.Error{}
 	diffSrv := func(mergeEvent *gitlab.MergeEvent, codehostId int) ([]string, error) {
@@ -381,25 +383,34 @@ func TriggerWorkflowV4ByGitlabEvent(event interface{}, baseURI, requestID string
 					)
 				}
 			}
-			if err := job.MergeArgs(workflow, item.WorkflowArg); err != nil {
+			taskWorkflow, err := getWorkflowV4ForEvent(workflow, codeEvent, log)
+			if err != nil {
+				log.Error(err)
+				mErr = multierror.Append(mErr, err)
+				continue
+			}
+			if err := job.MergeArgs(taskWorkflow, item.WorkflowArg); err != nil {
 				errMsg := fmt.Sprintf("merge workflow args error: %v", err)
 				log.Error(errMsg)
 				mErr = multierror.Append(mErr, fmt.Errorf(errMsg))
 				continue
 			}
-			if err := job.MergeWebhookRepo(workflow, eventRepo); err != nil {
+			if err := job.MergeWebhookRepo(taskWorkflow, eventRepo); err != nil {
 				errMsg := fmt.Sprintf("merge webhook repo info to workflowargs error: %v", err)
 				log.Error(errMsg)
 				mErr = multierror.Append(mErr, fmt.Errorf(errMsg))
 				continue
 			}
 			if notification != nil {
-				workflow.NotificationID = notification.ID.Hex()
+				taskWorkflow.NotificationID = notification.ID.Hex()
+			}
+			if hookPayload == nil || !hookPayload.IsPr {
+				hookPayload = getPushHookPayload(eventRepo)
 			}
-			workflow.HookPayload = hookPayload
+			taskWorkflow.HookPayload = hookPayload
 			if resp, err := workflowservice.CreateWorkflowTaskV4(&workflowservice.CreateWorkflowTaskV4Args{
 				Name: setting.WebhookTaskCreator,
-			}, workflow, log); err != nil {
+			}, taskWorkflow, log); err != nil {
 				errMsg := fmt.Sprintf("failed to create workflow task when receive push event due to %v ", err)
 				log.Error(errMsg)
 				mErr = multierror.Append(mErr, fmt.Errorf(errMsg))
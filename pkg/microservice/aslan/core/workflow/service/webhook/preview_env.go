@@ -0,0 +1,259 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/hashicorp/go-multierror"
+	"github.com/xanzy/go-gitlab"
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	templaterepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/template"
+	gitservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/git"
+	environmentservice "github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
+	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow/job"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// TriggerPreviewEnvByGitlabEvent creates the preview envs of the merge request when it is opened or updated,
+// and deletes them when it is closed or merged. The code of the merge request is deployed to the preview envs
+// when it is opened or new commits are pushed to it.
+func TriggerPreviewEnvByGitlabEvent(event *gitlab.MergeEvent, baseURI, requestID string, log *zap.SugaredLogger) error {
+	attrs := event.ObjectAttributes
+	namespace, name := splitRepoPath(attrs.Target.PathWithNamespace)
+	args := &environmentservice.PreviewEnvArgs{
+		RepoOwner:     namespace,
+		RepoNamespace: namespace,
+		RepoName:      name,
+		PrID:          attrs.IID,
+		Opened:        attrs.Action == "open" || attrs.Action == "reopen",
+		DiffFunc: func(codehostID int) ([]string, error) {
+			return findChangedFilesOfMergeRequest(event, codehostID)
+		},
+		BaseURI: baseURI,
+	}
+
+	switch attrs.Action {
+	case "open", "reopen", "update":
+		previewEnvs, err := environmentservice.EnsurePreviewEnvs(args, requestID, log)
+		// the merge request is updated without new commits.
+		if attrs.Action == "update" && attrs.OldRev == "" {
+			return err
+		}
+		repo := &types.Repository{
+			Source:        setting.SourceFromGitlab,
+			RepoOwner:     namespace,
+			RepoNamespace: namespace,
+			RepoName:      name,
+			Branch:        attrs.TargetBranch,
+			PR:            attrs.IID,
+		}
+		hookPayload := &commonmodels.HookPayload{
+			Owner:          namespace,
+			Repo:           name,
+			Branch:         attrs.TargetBranch,
+			Ref:            attrs.LastCommit.ID,
+			IsPr:           true,
+			MergeRequestID: strconv.Itoa(attrs.IID),
+			CommitID:       attrs.LastCommit.ID,
+		}
+		if deployErr := deployPreviewEnvs(previewEnvs, repo, hookPayload, log); deployErr != nil {
+			err = multierror.Append(err, deployErr)
+		}
+		return err
+	case "close", "merge":
+		return environmentservice.DeletePreviewEnvs(args, requestID, log)
+	}
+	return nil
+}
+
+// ProcessGithubWebHookForPreviewEnv creates the preview envs of the pull request and deploys its code to them
+// when it is opened or synchronized, and deletes them when it is closed.
+func ProcessGithubWebHookForPreviewEnv(payload []byte, req *http.Request, requestID string, log *zap.SugaredLogger) error {
+	hookType := github.WebHookType(req)
+	if hookType != "pull_request" {
+		return nil
+	}
+
+	err := validateSecret(payload, []byte(gitservice.GetHookSecret()), req)
+	if err != nil {
+		return err
+	}
+
+	event, err := github.ParseWebHook(hookType, payload)
+	if err != nil {
+		return err
+	}
+	prEvent, ok := event.(*github.PullRequestEvent)
+	if !ok {
+		return nil
+	}
+
+	args := &environmentservice.PreviewEnvArgs{
+		RepoOwner:     prEvent.GetRepo().GetOwner().GetLogin(),
+		RepoNamespace: prEvent.GetRepo().GetOwner().GetLogin(),
+		RepoName:      prEvent.GetRepo().GetName(),
+		PrID:          prEvent.GetNumber(),
+		Opened:        prEvent.GetAction() == "opened" || prEvent.GetAction() == "reopened",
+		DiffFunc: func(codehostID int) ([]string, error) {
+			return findChangedFilesOfPullRequest(prEvent, codehostID)
+		},
+		BaseURI: config.SystemAddress(),
+	}
+
+	switch prEvent.GetAction() {
+	case "opened", "reopened", "synchronize":
+		previewEnvs, err := environmentservice.EnsurePreviewEnvs(args, requestID, log)
+		repo := &types.Repository{
+			Source:        setting.SourceFromGithub,
+			RepoOwner:     args.RepoOwner,
+			RepoNamespace: args.RepoNamespace,
+			RepoName:      args.RepoName,
+			Branch:        prEvent.GetPullRequest().GetBase().GetRef(),
+			PR:            args.PrID,
+		}
+		hookPayload := &commonmodels.HookPayload{
+			Owner:          args.RepoOwner,
+			Repo:           args.RepoName,
+			Branch:         prEvent.GetPullRequest().GetBase().GetRef(),
+			Ref:            prEvent.GetPullRequest().GetHead().GetSHA(),
+			IsPr:           true,
+			MergeRequestID: strconv.Itoa(args.PrID),
+			CommitID:       prEvent.GetPullRequest().GetHead().GetSHA(),
+		}
+		if deployErr := deployPreviewEnvs(previewEnvs, repo, hookPayload, log); deployErr != nil {
+			err = multierror.Append(err, deployErr)
+		}
+		return err
+	case "closed":
+		return environmentservice.DeletePreviewEnvs(args, requestID, log)
+	}
+	return nil
+}
+
+// previewEnvCreationTimeout is how long to wait for a preview env to be created before deploying to it.
+const previewEnvCreationTimeout = 30 * time.Minute
+
+// deployPreviewEnvs runs the preview env workflows of the projects with the code of the pull request, the
+// services of the preview envs are built and deployed to them. the preview envs which are still being created
+// are deployed in background once they are created, so that the deployments are not overwritten by the creation.
+func deployPreviewEnvs(previewEnvs []*commonmodels.PreviewEnv, repo *types.Repository, hookPayload *commonmodels.HookPayload, log *zap.SugaredLogger) error {
+	errs := &multierror.Error{}
+	for _, previewEnv := range previewEnvs {
+		env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: previewEnv.ProjectName, EnvName: previewEnv.EnvName})
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to find preview env %s/%s, err: %s", previewEnv.ProjectName, previewEnv.EnvName, err))
+			continue
+		}
+		if env.Status != setting.ProductStatusCreating {
+			if err := deployPreviewEnv(previewEnv, repo, hookPayload, log); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+			continue
+		}
+		go func(previewEnv *commonmodels.PreviewEnv) {
+			if err := waitForPreviewEnvCreated(previewEnv, previewEnvCreationTimeout); err != nil {
+				log.Errorf("failed to deploy preview env %s/%s, err: %s", previewEnv.ProjectName, previewEnv.EnvName, err)
+				return
+			}
+			if err := deployPreviewEnv(previewEnv, repo, hookPayload, log); err != nil {
+				log.Error(err)
+			}
+		}(previewEnv)
+	}
+	return errs.ErrorOrNil()
+}
+
+// waitForPreviewEnvCreated returns nil once the preview env is created successfully.
+func waitForPreviewEnvCreated(previewEnv *commonmodels.PreviewEnv, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: previewEnv.ProjectName, EnvName: previewEnv.EnvName})
+		if err != nil {
+			return err
+		}
+		switch env.Status {
+		case setting.ProductStatusCreating:
+		case setting.ProductStatusFailed:
+			return fmt.Errorf("preview env failed to be created: %s", env.Error)
+		default:
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("preview env is not created in %s", timeout)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// deployPreviewEnv runs the preview env workflow of the project of the preview env.
+func deployPreviewEnv(previewEnv *commonmodels.PreviewEnv, repo *types.Repository, hookPayload *commonmodels.HookPayload, log *zap.SugaredLogger) error {
+	project, err := templaterepo.NewProductColl().Find(previewEnv.ProjectName)
+	if err != nil {
+		return fmt.Errorf("failed to find project %s, err: %s", previewEnv.ProjectName, err)
+	}
+	if !project.PreviewEnv.IsEnabled() || project.PreviewEnv.Workflow == "" {
+		return nil
+	}
+	workflow, err := commonrepo.NewWorkflowV4Coll().Find(project.PreviewEnv.Workflow)
+	if err != nil {
+		return fmt.Errorf("failed to find workflow %s of preview env %s, err: %s", project.PreviewEnv.Workflow, previewEnv.EnvName, err)
+	}
+	if workflow.Project != previewEnv.ProjectName {
+		return fmt.Errorf("workflow %s of preview env %s is not in project %s", workflow.Name, previewEnv.EnvName, previewEnv.ProjectName)
+	}
+
+	eventRepo := *repo
+	eventRepo.CodehostID = previewEnv.CodehostID
+	if err := job.MergeWebhookRepo(workflow, &eventRepo); err != nil {
+		return fmt.Errorf("failed to merge the repo of the pull request into workflow %s, err: %s", workflow.Name, err)
+	}
+	if err := job.SetDeployTarget(workflow, previewEnv.EnvName, previewEnv.Services); err != nil {
+		return fmt.Errorf("failed to deploy workflow %s to preview env %s, err: %s", workflow.Name, previewEnv.EnvName, err)
+	}
+	payload := *hookPayload
+	payload.CodehostID = previewEnv.CodehostID
+	workflow.HookPayload = &payload
+	resp, err := workflowservice.CreateWorkflowTaskV4(&workflowservice.CreateWorkflowTaskV4Args{
+		Name: setting.WebhookTaskCreator,
+	}, workflow, log)
+	if err != nil {
+		return fmt.Errorf("failed to create task of workflow %s for preview env %s, err: %s", workflow.Name, previewEnv.EnvName, err)
+	}
+	log.Infof("workflow %s task %d deploys to preview env %s/%s", workflow.Name, resp.TaskID, previewEnv.ProjectName, previewEnv.EnvName)
+	return nil
+}
+
+// splitRepoPath splits the path of a gitlab project into its namespace and name.
+func splitRepoPath(pathWithNamespace string) (string, string) {
+	index := strings.LastIndex(pathWithNamespace, "/")
+	if index < 0 {
+		return "", pathWithNamespace
+	}
+	return pathWithNamespace[:index], pathWithNamespace[index+1:]
+}
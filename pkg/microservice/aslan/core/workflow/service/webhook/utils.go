@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
@@ -37,6 +38,7 @@ import (
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	templaterepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/template"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/codehub"
 	environmentservice "github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/service/service"
@@ -124,8 +126,25 @@ func fillServiceTmpl(userName string, args *commonmodels.Service, log *zap.Sugar
 		if args.Containers == nil {
 			args.Containers = make([]*commonmodels.Container, 0)
 		}
-		// 配置来源为Gitlab，需要从Gitlab同步配置，并设置KubeYamls.
-		if args.Source == setting.SourceFromGitlab {
+		var kustomizeFiles fs.FS
+		if args.Kustomize != nil {
+			if args.Source == setting.SourceFromGitlab {
+				if err := syncLatestCommit(args); err != nil {
+					log.Errorf("Sync change log from gitlab failed, error: %v", err)
+					return err
+				}
+			}
+			// the yaml is built from the kustomization files in the load path
+			tree, yaml, err := commonservice.LoadKustomizeService(args)
+			if err != nil {
+				log.Errorf("Sync kustomization of service %s failed, error: %v", args.ServiceName, err)
+				return err
+			}
+			kustomizeFiles = tree
+			args.Yaml = yaml
+			args.KubeYamls = util.SplitManifests(yaml)
+		} else if args.Source == setting.SourceFromGitlab {
+			// 配置来源为Gitlab，需要从Gitlab同步配置，并设置KubeYamls.
 			// Set args.Commit
 			if err := syncLatestCommit(args); err != nil {
 				log.Errorf("Sync change log from gitlab failed, error: %v", err)
@@ -170,6 +189,11 @@ func fillServiceTmpl(userName string, args *commonmodels.Service, log *zap.Sugar
 			return fmt.Errorf("get next service template revision error: %v", err)
 		}
 		args.Revision = rev
+		if kustomizeFiles != nil {
+			if err := commonservice.SaveKustomizeService(args, kustomizeFiles); err != nil {
+				return err
+			}
+		}
 		// update service template
 		if err := commonrepo.NewServiceColl().Create(args); err != nil {
 			log.Errorf("Failed to sync service %s from github path %s error: %v", args.ServiceName, args.SrcPath, err)
@@ -612,6 +636,20 @@ func EventConfigured(m *commonmodels.MainHookRepo, event config.HookEventType) b
 	return false
 }
 
+// getPushHookPayload returns the hook payload of a push or tag event, which is used by
+// conditions of workflow v4 jobs to decide whether they should run.
+func getPushHookPayload(repo *types.Repository) *commonmodels.HookPayload {
+	return &commonmodels.HookPayload{
+		Owner:      repo.RepoOwner,
+		Repo:       repo.RepoName,
+		Branch:     repo.Branch,
+		Tag:        repo.Tag,
+		Ref:        repo.CommitID,
+		CommitID:   repo.CommitID,
+		CodehostID: repo.CodehostID,
+	}
+}
+
 func ServicesMatchChangesFiles(mf *MatchFoldersElem, files []string) []BuildServices {
 	resMactchSvr := []BuildServices{}
 	var wg sync.WaitGroup
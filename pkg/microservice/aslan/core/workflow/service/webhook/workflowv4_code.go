@@ -0,0 +1,219 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/xanzy/go-gitlab"
+	"go.uber.org/zap"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	githubservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/github"
+	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/shared/client/systemconfig"
+	gitlabtool "github.com/koderover/zadig/pkg/tool/git/gitlab"
+)
+
+// max length of the description of a commit status allowed by github.
+const maxCommitStatusDescriptionLength = 140
+
+var commitSHARegex = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// workflowCodeEvent is the commit of a webhook event, where the workflows defined in the repository are loaded.
+type workflowCodeEvent struct {
+	repoPath string
+	branch   string
+	ref      string
+	isPush   bool
+}
+
+func (ev *workflowCodeEvent) matches(source *commonmodels.WorkflowCodeSource) bool {
+	return ev != nil && ev.ref != "" && source.GetRepoNamespace()+"/"+source.RepoName == ev.repoPath
+}
+
+func getGitlabWorkflowCodeEvent(event interface{}) *workflowCodeEvent {
+	switch ev := event.(type) {
+	case *gitlab.PushEvent:
+		return &workflowCodeEvent{repoPath: ev.Project.PathWithNamespace, branch: getBranchFromRef(ev.Ref), ref: ev.After, isPush: true}
+	case *gitlab.MergeEvent:
+		ref := ev.ObjectAttributes.LastCommit.ID
+		// the definition in a merge request from a fork is not trusted, the one in the target branch is used.
+		if ev.ObjectAttributes.SourceProjectID != ev.ObjectAttributes.TargetProjectID {
+			ref = ev.ObjectAttributes.TargetBranch
+		}
+		return &workflowCodeEvent{repoPath: ev.ObjectAttributes.Target.PathWithNamespace, branch: ev.ObjectAttributes.TargetBranch, ref: ref}
+	case *gitlab.TagEvent:
+		return &workflowCodeEvent{repoPath: ev.Project.PathWithNamespace, ref: ev.CheckoutSHA}
+	}
+	return nil
+}
+
+func getGithubWorkflowCodeEvent(event interface{}) *workflowCodeEvent {
+	switch ev := event.(type) {
+	case *github.PushEvent:
+		return &workflowCodeEvent{repoPath: ev.GetRepo().GetFullName(), branch: getBranchFromRef(ev.GetRef()), ref: ev.GetAfter(), isPush: true}
+	case *github.PullRequestEvent:
+		pr := ev.GetPullRequest()
+		ref := pr.GetHead().GetSHA()
+		// the definition in a pull request from a fork is not trusted, the one in the base branch is used.
+		if pr.GetHead().GetRepo().GetFullName() != pr.GetBase().GetRepo().GetFullName() {
+			ref = pr.GetBase().GetSHA()
+		}
+		return &workflowCodeEvent{repoPath: ev.GetRepo().GetFullName(), branch: pr.GetBase().GetRef(), ref: ref}
+	case *github.CreateEvent:
+		return &workflowCodeEvent{repoPath: ev.GetRepo().GetFullName(), ref: ev.GetRef()}
+	}
+	return nil
+}
+
+// syncWorkflowsV4FromCode updates the workflows in zadig when their files are pushed to the branches of the code sources,
+// the synced workflows replace the listed ones so that the tasks triggered by the same event use them.
+func syncWorkflowsV4FromCode(workflows []*commonmodels.WorkflowV4, ev *workflowCodeEvent, log *zap.SugaredLogger) {
+	if ev == nil || !ev.isPush {
+		return
+	}
+	for i, workflow := range workflows {
+		source := workflow.CodeSource
+		if !source.IsEnabled() || !source.SyncToZadig || !ev.matches(source) || source.Branch != ev.branch {
+			continue
+		}
+		synced, err := workflowservice.SyncWorkflowV4FromCode(workflow.Name, ev.ref, setting.WebhookTaskCreator, log)
+		setWorkflowCodeStatus(workflow, ev.ref, err, log)
+		if err != nil {
+			log.Errorf("failed to sync workflow %s from %s at %s: %s", workflow.Name, source.Path, ev.ref, err)
+			continue
+		}
+		log.Infof("workflow %s is synced from %s at %s", workflow.Name, source.Path, ev.ref)
+		workflows[i] = synced
+	}
+}
+
+// getWorkflowV4ForEvent returns the workflow used by the task triggered by the event. If the workflow is defined in the
+// repository of the event, the file at the commit of the event is used, so each branch runs its own definition.
+func getWorkflowV4ForEvent(workflow *commonmodels.WorkflowV4, ev *workflowCodeEvent, log *zap.SugaredLogger) (*commonmodels.WorkflowV4, error) {
+	source := workflow.CodeSource
+	if !source.IsEnabled() || !ev.matches(source) {
+		return workflow, nil
+	}
+	// the workflow has been synced from the commit.
+	if source.SyncToZadig && ev.isPush && ev.branch == source.Branch {
+		return workflow, nil
+	}
+	// branch and tag names are resolved to the commit, so that the file loaded and the commit status are of the same commit.
+	sha, err := resolveWorkflowCodeRef(source, ev.ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s of workflow %s: %s", ev.ref, workflow.Name, err)
+	}
+	codeWorkflow, err := workflowservice.LoadWorkflowV4FromCode(workflow, sha, log)
+	setWorkflowCodeStatus(workflow, sha, err, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow %s from %s at %s: %s", workflow.Name, source.Path, sha, err)
+	}
+	return codeWorkflow, nil
+}
+
+// resolveWorkflowCodeRef returns the commit sha of the ref in the repository of the source.
+func resolveWorkflowCodeRef(source *commonmodels.WorkflowCodeSource, ref string) (string, error) {
+	if commitSHARegex.MatchString(ref) {
+		return ref, nil
+	}
+	codehost, err := systemconfig.New().GetCodeHost(source.CodehostID)
+	if err != nil {
+		return "", err
+	}
+	switch codehost.Type {
+	case setting.SourceFromGithub:
+		client := githubservice.NewClient(codehost.AccessToken, config.ProxyHTTPSAddr(), codehost.EnableProxy)
+		sha, _, err := client.Repositories.GetCommitSHA1(context.TODO(), source.GetRepoNamespace(), source.RepoName, ref, "")
+		return sha, err
+	case setting.SourceFromGitlab:
+		client, err := gitlabtool.NewClient(codehost.ID, codehost.Address, codehost.AccessToken, config.ProxyHTTPSAddr(), codehost.EnableProxy)
+		if err != nil {
+			return "", err
+		}
+		commit, err := client.GetSingleCommitOfProject(source.GetRepoNamespace(), source.RepoName, ref)
+		if err != nil {
+			return "", err
+		}
+		return commit.ID, nil
+	default:
+		return ref, nil
+	}
+}
+
+// setWorkflowCodeStatus posts the validation result of the workflow file as a commit status.
+func setWorkflowCodeStatus(workflow *commonmodels.WorkflowV4, ref string, lintErr error, log *zap.SugaredLogger) {
+	source := workflow.CodeSource
+	codehost, err := systemconfig.New().GetCodeHost(source.CodehostID)
+	if err != nil {
+		log.Errorf("failed to get codehost %d, err: %s", source.CodehostID, err)
+		return
+	}
+
+	statusContext := fmt.Sprintf("%s/workflow/%s", setting.ProductName, workflow.Name)
+	targetURL := fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/custom/%s?display_name=%s", configbase.SystemAddress(), workflow.Project, workflow.Name, url.QueryEscape(workflow.DisplayName))
+	description := fmt.Sprintf("Workflow file %s is valid.", source.Path)
+	if lintErr != nil {
+		description = lintErr.Error()
+	}
+	if len(description) > maxCommitStatusDescriptionLength {
+		description = description[:maxCommitStatusDescriptionLength-3] + "..."
+	}
+
+	switch codehost.Type {
+	case setting.SourceFromGithub:
+		state := githubservice.StateSuccess
+		if lintErr != nil {
+			state = githubservice.StateFailure
+		}
+		client := githubservice.NewClient(codehost.AccessToken, config.ProxyHTTPSAddr(), codehost.EnableProxy)
+		_, err = client.CreateStatus(context.TODO(), source.GetRepoNamespace(), source.RepoName, ref, &github.RepoStatus{
+			State:       github.String(state),
+			Description: github.String(description),
+			TargetURL:   github.String(targetURL),
+			Context:     github.String(statusContext),
+		})
+	case setting.SourceFromGitlab:
+		state := gitlab.Success
+		if lintErr != nil {
+			state = gitlab.Failed
+		}
+		var client *gitlabtool.Client
+		client, err = gitlabtool.NewClient(codehost.ID, codehost.Address, codehost.AccessToken, config.ProxyHTTPSAddr(), codehost.EnableProxy)
+		if err == nil {
+			err = client.SetCommitStatus(source.GetRepoNamespace(), source.RepoName, ref, &gitlab.SetCommitStatusOptions{
+				State:       state,
+				Name:        gitlab.String(statusContext),
+				Description: gitlab.String(description),
+				TargetURL:   gitlab.String(targetURL),
+			})
+		}
+	default:
+		return
+	}
+	if err != nil {
+		log.Warnf("failed to set commit status of workflow %s at %s, err: %s", workflow.Name, ref, err)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/util/expression"
+)
+
+// LintJobConditions checks the if expressions of jobs and freestyle steps, an expression can only
+// refer to workflow params, key values, trigger info and outputs of jobs which run before it.
+func LintJobConditions(workflow *commonmodels.WorkflowV4) error {
+	jobs := map[string]*commonmodels.Job{}
+	stageIndex := map[string]int{}
+	for i, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			jobs[job.Name] = job
+			stageIndex[job.Name] = i
+		}
+	}
+	staticVars := map[string]bool{"project": true, "workflow.name": true, "workflow.task.id": true}
+	var hook *commonmodels.HookPayload
+	for name := range hook.TriggerVariables() {
+		staticVars[name] = true
+	}
+	for _, param := range workflow.Params {
+		staticVars["workflow.params."+param.Name] = true
+	}
+	for _, kv := range workflow.KeyVals {
+		staticVars["env."+kv.Key] = true
+	}
+
+	for _, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			upstreams := jobAncestors(job.Name, jobs)
+			if !workflow.DAG {
+				for name, index := range stageIndex {
+					if index < stageIndex[job.Name] {
+						upstreams[name] = true
+					}
+				}
+			}
+			lint := func(raw string) error {
+				return lintCondition(raw, staticVars, upstreams)
+			}
+			if err := lint(job.If); err != nil {
+				return fmt.Errorf("job %s: %v", job.Name, err)
+			}
+			if job.JobType != config.JobFreestyle {
+				continue
+			}
+			spec := &commonmodels.FreestyleJobSpec{}
+			if err := commonmodels.IToi(job.Spec, spec); err != nil {
+				return err
+			}
+			for _, step := range spec.Steps {
+				if err := lint(step.If); err != nil {
+					return fmt.Errorf("job %s step %s: %v", job.Name, step.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func lintCondition(raw string, staticVars, upstreams map[string]bool) error {
+	if raw == "" {
+		return nil
+	}
+	expr, err := expression.Parse(raw)
+	if err != nil {
+		return err
+	}
+	for _, name := range expr.Variables() {
+		if staticVars[name] {
+			continue
+		}
+		// job outputs are referred as job.<job key>.output.<output name> or jobs.<job key>.outputs.<output name>,
+		// and job key starts with job name.
+		if jobName, ok := outputJobName(name); ok {
+			if !upstreams[jobName] {
+				return fmt.Errorf("variable %s refers to job %s which does not run before", name, jobName)
+			}
+			continue
+		}
+		return fmt.Errorf("undefined variable %s in condition %s", name, raw)
+	}
+	return nil
+}
+
+func outputJobName(name string) (string, bool) {
+	for _, prefix := range []string{"job.", "jobs."} {
+		suffix := ".output."
+		if prefix == "jobs." {
+			suffix = ".outputs."
+		}
+		if strings.HasPrefix(name, prefix) && strings.Contains(name, suffix) {
+			return strings.SplitN(strings.TrimPrefix(name, prefix), ".", 2)[0], true
+		}
+	}
+	return "", false
+}
+
+// jobAncestors returns all jobs the given job depends on directly or indirectly.
+func jobAncestors(name string, jobs map[string]*commonmodels.Job) map[string]bool {
+	resp := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		job, ok := jobs[name]
+		if !ok {
+			return
+		}
+		for _, upstream := range job.DependsOn {
+			if resp[upstream] {
+				continue
+			}
+			resp[upstream] = true
+			visit(upstream)
+		}
+	}
+	visit(name)
+	return resp
+}
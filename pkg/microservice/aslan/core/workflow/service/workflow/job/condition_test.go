@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing job conditions", func() {
+
+	Context("LintJobConditions", func() {
+		It("should be passed for known variables", func() {
+			workflow := newDAGWorkflow(false,
+				[]*commonmodels.Job{{Name: "build"}},
+				[]*commonmodels.Job{{Name: "deploy", If: `trigger.branch == "main" && workflow.params.env != "" && job.build.svc.svc.output.IMAGE`}},
+			)
+			workflow.Params = []*commonmodels.Param{{Name: "env"}}
+			Expect(LintJobConditions(workflow)).ShouldNot(HaveOccurred())
+		})
+		It("should raise error for invalid expression", func() {
+			workflow := newDAGWorkflow(false, []*commonmodels.Job{{Name: "deploy", If: `trigger.branch = "main"`}})
+			Expect(LintJobConditions(workflow)).Should(HaveOccurred())
+		})
+		It("should raise error for undefined variables", func() {
+			workflow := newDAGWorkflow(false, []*commonmodels.Job{{Name: "deploy", If: `workflow.params.env == "prod"`}})
+			Expect(LintJobConditions(workflow)).Should(HaveOccurred())
+		})
+		It("should only allow outputs of upstream jobs", func() {
+			stage := []*commonmodels.Job{
+				{Name: "build"},
+				{Name: "deploy", If: `job.build.svc.svc.output.IMAGE != ""`},
+			}
+			Expect(LintJobConditions(newDAGWorkflow(false, stage))).Should(HaveOccurred())
+
+			stage[1].DependsOn = []string{"build"}
+			Expect(LintJobConditions(newDAGWorkflow(false, stage))).ShouldNot(HaveOccurred())
+		})
+	})
+})
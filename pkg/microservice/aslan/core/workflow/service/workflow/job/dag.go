@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+	"strings"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+// LintJobDependencies checks the depends_on edges of all jobs in the workflow,
+// every upstream job must exist, and the jobs should form a directed acyclic graph.
+// if the workflow does not run as a dag, stages still run one after another, so
+// a job can only depend on jobs in the same stage or former stages.
+func LintJobDependencies(workflow *commonmodels.WorkflowV4) error {
+	stageIndex := make(map[string]int)
+	for i, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			stageIndex[job.Name] = i
+		}
+	}
+
+	graph := make(map[string][]string)
+	for i, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			for _, dependency := range job.DependsOn {
+				if dependency == job.Name {
+					return fmt.Errorf("job %s can not depend on itself", job.Name)
+				}
+				upstreamStage, ok := stageIndex[dependency]
+				if !ok {
+					return fmt.Errorf("job %s depends on job %s which does not exist", job.Name, dependency)
+				}
+				if !workflow.DAG && upstreamStage > i {
+					return fmt.Errorf("job %s depends on job %s in a later stage", job.Name, dependency)
+				}
+				graph[job.Name] = append(graph[job.Name], dependency)
+			}
+		}
+	}
+	if cycle := findDependencyCycle(graph); len(cycle) > 0 {
+		return fmt.Errorf("cyclic job dependencies found: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// findDependencyCycle returns the job names on the first cycle found in graph, or nil if there is none.
+func findDependencyCycle(graph map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	path := []string{}
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		path = append(path, name)
+		for _, next := range graph[name] {
+			switch state[next] {
+			case visiting:
+				for i, p := range path {
+					if p == next {
+						return append(append([]string{}, path[i:]...), next)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); len(cycle) > 0 {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+
+	for name := range graph {
+		if state[name] != unvisited {
+			continue
+		}
+		if cycle := visit(name); len(cycle) > 0 {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// isUpstreamJob returns whether the upstream job always finishes before the job starts, which is true if the job
+// depends on it directly or indirectly, or if the workflow does not run as a dag and it is in a former stage.
+func isUpstreamJob(workflow *commonmodels.WorkflowV4, jobName, upstream string) bool {
+	stageIndex := make(map[string]int)
+	dependencies := make(map[string][]string)
+	for i, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			stageIndex[job.Name] = i
+			dependencies[job.Name] = job.DependsOn
+		}
+	}
+	jobStage, ok := stageIndex[jobName]
+	if !ok {
+		return false
+	}
+	if upstreamStage, ok := stageIndex[upstream]; ok && !workflow.DAG && upstreamStage < jobStage {
+		return true
+	}
+
+	visited := map[string]bool{}
+	queue := append([]string{}, dependencies[jobName]...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if name == upstream {
+			return true
+		}
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+		queue = append(queue, dependencies[name]...)
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func newDAGWorkflow(dag bool, stages ...[]*commonmodels.Job) *commonmodels.WorkflowV4 {
+	workflow := &commonmodels.WorkflowV4{DAG: dag}
+	for _, jobs := range stages {
+		workflow.Stages = append(workflow.Stages, &commonmodels.WorkflowStage{Jobs: jobs})
+	}
+	return workflow
+}
+
+var _ = Describe("Testing job dependencies", func() {
+
+	Context("LintJobDependencies", func() {
+		It("should be passed for a fan out and fan in graph", func() {
+			workflow := newDAGWorkflow(false, []*commonmodels.Job{
+				{Name: "build"},
+				{Name: "scan", DependsOn: []string{"build"}},
+				{Name: "test", DependsOn: []string{"build"}},
+				{Name: "deploy", DependsOn: []string{"scan", "test"}},
+			})
+			Expect(LintJobDependencies(workflow)).ShouldNot(HaveOccurred())
+		})
+		It("should raise error for unknown upstream job", func() {
+			workflow := newDAGWorkflow(false, []*commonmodels.Job{
+				{Name: "deploy", DependsOn: []string{"build"}},
+			})
+			Expect(LintJobDependencies(workflow)).Should(HaveOccurred())
+		})
+		It("should raise error for self dependency", func() {
+			workflow := newDAGWorkflow(false, []*commonmodels.Job{
+				{Name: "build", DependsOn: []string{"build"}},
+			})
+			Expect(LintJobDependencies(workflow)).Should(HaveOccurred())
+		})
+		It("should raise error for cycles", func() {
+			workflow := newDAGWorkflow(false, []*commonmodels.Job{
+				{Name: "a", DependsOn: []string{"c"}},
+				{Name: "b", DependsOn: []string{"a"}},
+				{Name: "c", DependsOn: []string{"b"}},
+			})
+			err := LintJobDependencies(workflow)
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).Should(ContainSubstring("cyclic"))
+		})
+		It("should only allow depending on later stages in dag mode", func() {
+			stages := [][]*commonmodels.Job{
+				{{Name: "deploy", DependsOn: []string{"build"}}},
+				{{Name: "build"}},
+			}
+			Expect(LintJobDependencies(newDAGWorkflow(false, stages...))).Should(HaveOccurred())
+			Expect(LintJobDependencies(newDAGWorkflow(true, stages...))).ShouldNot(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("Testing upstream jobs", func() {
+
+	It("should take jobs in former stages as upstream jobs unless the workflow runs as a dag", func() {
+		stages := [][]*commonmodels.Job{
+			{{Name: "build"}, {Name: "scan", DependsOn: []string{"build"}}},
+			{{Name: "test"}, {Name: "deploy", DependsOn: []string{"scan"}}},
+		}
+		workflow := newDAGWorkflow(false, stages...)
+		Expect(isUpstreamJob(workflow, "test", "build")).To(BeTrue())
+		Expect(isUpstreamJob(workflow, "scan", "build")).To(BeTrue())
+		Expect(isUpstreamJob(workflow, "build", "scan")).To(BeFalse())
+		Expect(isUpstreamJob(workflow, "build", "test")).To(BeFalse())
+		Expect(isUpstreamJob(workflow, "deploy", "test")).To(BeFalse())
+
+		workflow = newDAGWorkflow(true, stages...)
+		Expect(isUpstreamJob(workflow, "test", "build")).To(BeFalse())
+		Expect(isUpstreamJob(workflow, "deploy", "scan")).To(BeTrue())
+		Expect(isUpstreamJob(workflow, "deploy", "build")).To(BeTrue())
+		Expect(isUpstreamJob(workflow, "deploy", "unknown")).To(BeFalse())
+	})
+})
@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+	"strings"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util/signature"
+)
+
+// getImageSigning returns the signing of the steps which push images, nil is returned if signing is disabled.
+func getImageSigning(signing *commonmodels.ImageSigning, provenance *step.Provenance) (*step.ImageSigning, error) {
+	if signing == nil || !signing.Enabled {
+		return nil, nil
+	}
+	// the key is checked here and mounted to the job pod when the job runs, it is not saved in the task.
+	if _, err := commonservice.GetImageSigningKey(signing.KeyID); err != nil {
+		return nil, err
+	}
+	resp := &step.ImageSigning{KeyID: signing.KeyID}
+	if signing.Provenance {
+		resp.Provenance = provenance
+	}
+	return resp, nil
+}
+
+func getProvenance(workflow *commonmodels.WorkflowV4, taskID int64, jobName string, repos []*types.Repository) *step.Provenance {
+	resp := &step.Provenance{
+		BuilderID:     configbase.SystemAddress(),
+		InvocationURL: fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/custom/%s/%d", configbase.SystemAddress(), workflow.Project, workflow.Name, taskID),
+		ProjectName:   workflow.Project,
+		WorkflowName:  workflow.Name,
+		TaskID:        taskID,
+		JobName:       jobName,
+	}
+	for _, repo := range repos {
+		owner := repo.RepoOwner
+		if repo.RepoNamespace != "" {
+			owner = repo.RepoNamespace
+		}
+		ref := repo.Ref()
+		if repo.PR > 0 {
+			ref = repo.PRRef()
+		}
+		checkoutPath := repo.RepoName
+		if repo.CheckoutPath != "" {
+			checkoutPath = repo.CheckoutPath
+		}
+		resp.Repos = append(resp.Repos, &step.ProvenanceRepo{
+			URL:          fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(repo.Address, "/"), owner, repo.RepoName),
+			Ref:          ref,
+			CommitID:     repo.CommitID,
+			CheckoutPath: checkoutPath,
+		})
+	}
+	return resp
+}
+
+// getSignatureCheck returns the signature check of the deploy job, nil is returned if verification is disabled.
+func getSignatureCheck(verification *commonmodels.ImageVerification) (*commonmodels.SignatureCheck, error) {
+	if verification == nil || !verification.Enabled {
+		return nil, nil
+	}
+	key, err := commonservice.GetImageSigningKey(verification.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := signature.PublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	pubPEM, err := signature.MarshalPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	registries, err := commonservice.ListRegistryNamespaces("", true, log.SugaredLogger())
+	if err != nil {
+		return nil, fmt.Errorf("list image registries error: %v", err)
+	}
+	return &commonmodels.SignatureCheck{PublicKey: string(pubPEM), Registries: registries}, nil
+}
+
+func lintSigningKey(enabled bool, keyID, jobName string) error {
+	if !enabled {
+		return nil
+	}
+	if keyID == "" {
+		return fmt.Errorf("signing key of job %s is not set", jobName)
+	}
+	if _, err := commonservice.GetImageSigningKey(keyID); err != nil {
+		return fmt.Errorf("job %s: %v", jobName, err)
+	}
+	return nil
+}
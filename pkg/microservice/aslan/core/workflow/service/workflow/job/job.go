@@ -27,12 +27,14 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/util/variable"
 )
 
 const (
@@ -84,6 +86,8 @@ func InitJobCtl(job *commonmodels.Job, workflow *commonmodels.WorkflowV4) (JobCt
 		resp = &ScanningJob{job: job, workflow: workflow}
 	case config.JobZadigDistributeImage:
 		resp = &ImageDistributeJob{job: job, workflow: workflow}
+	case config.JobZadigImageScan:
+		resp = &ImageScanJob{job: job, workflow: workflow}
 	case config.JobIstioRelease:
 		resp = &IstioReleaseJob{job: job, workflow: workflow}
 	case config.JobIstioRollback:
@@ -117,7 +121,15 @@ func ToJobs(job *commonmodels.Job, workflow *commonmodels.WorkflowV4, taskID int
 	if err != nil {
 		return []*commonmodels.JobTask{}, err
 	}
-	return jobCtl.ToJobs(taskID)
+	jobTasks, err := jobCtl.ToJobs(taskID)
+	if err != nil {
+		return jobTasks, err
+	}
+	jobTasks, err = expandMatrix(job, jobTasks)
+	if err != nil {
+		return jobTasks, err
+	}
+	return jobTasks, setMatrixBuildImages(job, jobTasks)
 }
 
 func LintJob(job *commonmodels.Job, workflow *commonmodels.WorkflowV4) error {
@@ -125,6 +137,12 @@ func LintJob(job *commonmodels.Job, workflow *commonmodels.WorkflowV4) error {
 	if err != nil {
 		return err
 	}
+	if err := lintMatrix(job); err != nil {
+		return err
+	}
+	if err := lintRetryPolicy(job); err != nil {
+		return err
+	}
 	return jobCtl.LintJob()
 }
 
@@ -160,6 +178,46 @@ func MergeWebhookRepo(workflow *commonmodels.WorkflowV4, repo *types.Repository)
 	return nil
 }
 
+// SetDeployTarget makes the build jobs of the workflow build the services only, and the deploy jobs deploy
+// them to the env, it is used to deploy the code of a pull request to its preview env.
+func SetDeployTarget(workflow *commonmodels.WorkflowV4, envName string, services []string) error {
+	serviceSet := sets.NewString(services...)
+	for _, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			switch job.JobType {
+			case config.JobZadigBuild:
+				spec := &commonmodels.ZadigBuildJobSpec{}
+				if err := commonmodels.IToi(job.Spec, spec); err != nil {
+					return err
+				}
+				builds := make([]*commonmodels.ServiceAndBuild, 0, len(spec.ServiceAndBuilds))
+				for _, build := range spec.ServiceAndBuilds {
+					if serviceSet.Has(build.ServiceName) {
+						builds = append(builds, build)
+					}
+				}
+				spec.ServiceAndBuilds = builds
+				job.Spec = spec
+			case config.JobZadigDeploy:
+				spec := &commonmodels.ZadigDeployJobSpec{}
+				if err := commonmodels.IToi(job.Spec, spec); err != nil {
+					return err
+				}
+				spec.Env = envName
+				images := make([]*commonmodels.ServiceAndImage, 0, len(spec.ServiceAndImages))
+				for _, image := range spec.ServiceAndImages {
+					if serviceSet.Has(image.ServiceName) {
+						images = append(images, image)
+					}
+				}
+				spec.ServiceAndImages = images
+				job.Spec = spec
+			}
+		}
+	}
+	return nil
+}
+
 func GetWorkflowOutputs(workflow *commonmodels.WorkflowV4, currentJobName string, log *zap.SugaredLogger) []string {
 	resp := []string{}
 	jobRankMap := getJobRankMap(workflow.Stages)
@@ -337,25 +395,33 @@ func RemoveFixedValueMarks(workflow *commonmodels.WorkflowV4) error {
 }
 
 func RenderGlobalVariables(workflow *commonmodels.WorkflowV4, taskID int64, creator string) error {
-	b, err := json.Marshal(workflow)
-	if err != nil {
-		return fmt.Errorf("marshal workflow error: %v", err)
+	if err := NewWorkflowRenderer(workflow, taskID, creator).Render(workflow); err != nil {
+		return fmt.Errorf("render workflow variables error: %v", err)
 	}
-	replacedString := renderMultiLineString(string(b), setting.RenderValueTemplate, getWorkflowDefaultParams(workflow, taskID, creator))
-	return json.Unmarshal([]byte(replacedString), &workflow)
+	return nil
 }
 
-func renderString(value, template string, inputs []*commonmodels.Param) string {
-	for _, input := range inputs {
-		value = strings.ReplaceAll(value, fmt.Sprintf(template, input.Name), input.Value)
+// NewWorkflowRenderer returns the renderer of workflow.params, env and trigger scopes for task creation,
+// references of job outputs and matrix values are kept and rendered when the job runs or expands.
+func NewWorkflowRenderer(workflow *commonmodels.WorkflowV4, taskID int64, creator string) *variable.Renderer {
+	renderer := variable.NewRenderer().
+		Strict("workflow", "env", "trigger").
+		Defer("job", "jobs", "matrix")
+	for _, param := range getWorkflowDefaultParams(workflow, taskID, creator) {
+		renderer.Set(param.Name, param.Value)
 	}
-	return value
+	for _, kv := range workflow.KeyVals {
+		renderer.Set("env."+kv.Key, kv.Value)
+	}
+	for name, value := range workflow.HookPayload.TriggerVariables() {
+		renderer.Set(name, value)
+	}
+	return renderer
 }
 
-func renderMultiLineString(value, template string, inputs []*commonmodels.Param) string {
+func renderString(value, template string, inputs []*commonmodels.Param) string {
 	for _, input := range inputs {
-		inputValue := strings.ReplaceAll(input.Value, "\n", "\\n")
-		value = strings.ReplaceAll(value, fmt.Sprintf(template, input.Name), inputValue)
+		value = strings.ReplaceAll(value, fmt.Sprintf(template, input.Name), input.Value)
 	}
 	return value
 }
@@ -238,14 +238,18 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 		}
 		jobTaskSpec.Properties.Envs = append(jobTaskSpec.Properties.CustomEnvs, getBuildJobVariables(build, taskID, j.workflow.Project, j.workflow.Name, image, registry, logger)...)
 		jobTaskSpec.Properties.UseHostDockerDaemon = buildInfo.PreBuild.UseHostDockerDaemon
+		jobTaskSpec.Properties.ServiceContainers = buildInfo.PreBuild.ServiceContainers
 
 		if jobTaskSpec.Properties.CacheEnable && jobTaskSpec.Properties.Cache.MediumType == types.NFSMedium {
 			jobTaskSpec.Properties.CacheUserDir = renderEnv(jobTaskSpec.Properties.CacheUserDir, jobTaskSpec.Properties.Envs)
 			jobTaskSpec.Properties.Cache.NFSProperties.Subpath = renderEnv(jobTaskSpec.Properties.Cache.NFSProperties.Subpath, jobTaskSpec.Properties.Envs)
 		}
 
-		// for other job refer current latest image.
-		build.Image = job.GetJobOutputKey(jobTask.Key, "IMAGE")
+		// for other job refer current latest image, the images of a matrix build are set after the job tasks are expanded.
+		build.Image = ""
+		if j.job.Matrix == nil {
+			build.Image = job.GetJobOutputKey(jobTask.Key, "IMAGE")
+		}
 
 		// init tools install step
 		tools := []*step.Tool{}
@@ -263,11 +267,12 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 		}
 		jobTaskSpec.Steps = append(jobTaskSpec.Steps, toolInstallStep)
 		// init git clone step
+		repos := renderRepos(build.Repos, buildInfo.Repos, jobTaskSpec.Properties.Envs)
 		gitStep := &commonmodels.StepTask{
 			Name:     build.ServiceName + "-git",
 			JobName:  jobTask.Name,
 			StepType: config.StepGit,
-			Spec:     step.StepGitSpec{Repos: renderRepos(build.Repos, buildInfo.Repos, jobTaskSpec.Properties.Envs)},
+			Spec:     step.StepGitSpec{Repos: repos},
 		}
 		jobTaskSpec.Steps = append(jobTaskSpec.Steps, gitStep)
 
@@ -294,6 +299,18 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 				}
 			}
 
+			cacheRef := ""
+			if buildInfo.PostBuild.DockerBuild.RemoteCache {
+				cacheRef = buildInfo.PostBuild.DockerBuild.CacheRef
+				if cacheRef == "" {
+					cacheRef = imageCacheRef(image)
+				}
+			}
+
+			signing, err := getImageSigning(j.spec.Signing, getProvenance(j.workflow, taskID, jobTask.Name, repos))
+			if err != nil {
+				return resp, err
+			}
 			dockerBuildStep := &commonmodels.StepTask{
 				Name:     build.ServiceName + "-docker-build",
 				JobName:  jobTask.Name,
@@ -306,6 +323,10 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 					ImageReleaseTag:       imageTag,
 					BuildArgs:             buildInfo.PostBuild.DockerBuild.BuildArgs,
 					DockerTemplateContent: dockefileContent,
+					BuildEngine:           step.BuildEngine(buildInfo.PostBuild.DockerBuild.BuildEngine),
+					Platforms:             buildInfo.PostBuild.DockerBuild.Platforms,
+					CacheRef:              cacheRef,
+					Signing:               signing,
 					DockerRegistry: &step.DockerRegistry{
 						DockerRegistryID: j.spec.DockerRegistryID,
 						Host:             registry.RegAddr,
@@ -441,6 +462,15 @@ func getBuildJobVariables(build *commonmodels.ServiceAndBuild, taskID int64, pro
 	return ret
 }
 
+// imageCacheRef returns the default remote cache reference of an image, which is the buildcache tag of its repository.
+func imageCacheRef(image string) string {
+	repo := image
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repo = image[:i]
+	}
+	return repo + ":buildcache"
+}
+
 func modelS3toS3(modelS3 *commonmodels.S3Storage) *step.S3 {
 	resp := &step.S3{
 		Ak:        modelS3.Ak,
@@ -537,6 +567,13 @@ func mergeRepos(templateRepos []*types.Repository, customRepos []*types.Reposito
 }
 
 func (j *BuildJob) LintJob() error {
+	j.spec = &commonmodels.ZadigBuildJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	if j.spec.Signing != nil {
+		return lintSigningKey(j.spec.Signing.Enabled, j.spec.Signing.KeyID, j.job.Name)
+	}
 	return nil
 }
 
@@ -129,43 +129,15 @@ func (j *DeployJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 	// get deploy info from previous build job
 	if j.spec.Source == config.SourceFromJob {
 		// clear service and image list to prevent old data from remaining
-		j.spec.ServiceAndImages = []*commonmodels.ServiceAndImage{}
-		for _, stage := range j.workflow.Stages {
-			for _, job := range stage.Jobs {
-				if job.Name != j.spec.JobName {
-					continue
-				}
-				// get deploy target from previous build job
-				if job.JobType == config.JobZadigBuild {
-					buildSpec := &commonmodels.ZadigBuildJobSpec{}
-					if err := commonmodels.IToi(job.Spec, buildSpec); err != nil {
-						return resp, err
-					}
-					for _, build := range buildSpec.ServiceAndBuilds {
-						j.spec.ServiceAndImages = append(j.spec.ServiceAndImages, &commonmodels.ServiceAndImage{
-							ServiceName:   build.ServiceName,
-							ServiceModule: build.ServiceModule,
-							Image:         build.Image,
-						})
-					}
-				}
-				// get deploy target from previous distribute job
-				if job.JobType == config.JobZadigDistributeImage {
-					distributeSpec := &commonmodels.ZadigDistributeImageJobSpec{}
-					if err := commonmodels.IToi(job.Spec, distributeSpec); err != nil {
-						return resp, err
-					}
-					for _, distribute := range distributeSpec.Tatgets {
-						j.spec.ServiceAndImages = append(j.spec.ServiceAndImages, &commonmodels.ServiceAndImage{
-							ServiceName:   distribute.ServiceName,
-							ServiceModule: distribute.ServiceModule,
-							Image:         distribute.TargetImage,
-						})
-					}
-				}
-			}
+		j.spec.ServiceAndImages, err = getQuoteDeployTargets(j.spec.JobName, j.workflow)
+		if err != nil {
+			return resp, err
 		}
 	}
+	signatureCheck, err := getSignatureCheck(j.spec.Verification)
+	if err != nil {
+		return resp, err
+	}
 	if j.spec.DeployType == setting.K8SDeployType {
 		for _, deploy := range j.spec.ServiceAndImages {
 			if err := checkServiceExsistsInEnv(productServiceMap, deploy.ServiceName, j.spec.Env); err != nil {
@@ -179,10 +151,17 @@ func (j *DeployJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 				ServiceModule:      deploy.ServiceModule,
 				ClusterID:          product.ClusterID,
 				Image:              deploy.Image,
+				SignatureCheck:     signatureCheck,
+			}
+			name := deploy.ServiceName + "-" + deploy.ServiceModule + "-" + j.job.Name
+			key := strings.Join([]string{j.job.Name, deploy.ServiceName, deploy.ServiceModule}, ".")
+			if deploy.MatrixSuffix != "" {
+				name = name + "-" + deploy.MatrixSuffix
+				key = strings.Join([]string{key, deploy.MatrixSuffix}, ".")
 			}
 			jobTask := &commonmodels.JobTask{
-				Name:    jobNameFormat(deploy.ServiceName + "-" + deploy.ServiceModule + "-" + j.job.Name),
-				Key:     strings.Join([]string{j.job.Name, deploy.ServiceName, deploy.ServiceModule}, "."),
+				Name:    jobNameFormat(name),
+				Key:     key,
 				JobType: string(config.JobZadigDeploy),
 				Spec:    jobTaskSpec,
 			}
@@ -217,6 +196,7 @@ func (j *DeployJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 				ServiceType:        setting.HelmDeployType,
 				ClusterID:          product.ClusterID,
 				ReleaseName:        releaseName,
+				SignatureCheck:     signatureCheck,
 			}
 			for _, deploy := range deploys {
 				if err := checkServiceExsistsInEnv(productServiceMap, serviceName, j.spec.Env); err != nil {
@@ -241,6 +221,52 @@ func (j *DeployJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 	return resp, nil
 }
 
+// getQuoteDeployTargets returns the services and images built or distributed by the referred job,
+// every expanded job task of a matrix build job has its own target.
+func getQuoteDeployTargets(jobName string, workflow *commonmodels.WorkflowV4) ([]*commonmodels.ServiceAndImage, error) {
+	resp := []*commonmodels.ServiceAndImage{}
+	for _, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			if job.Name != jobName {
+				continue
+			}
+			// get deploy target from previous build job
+			if job.JobType == config.JobZadigBuild {
+				buildSpec := &commonmodels.ZadigBuildJobSpec{}
+				if err := commonmodels.IToi(job.Spec, buildSpec); err != nil {
+					return resp, err
+				}
+				for _, build := range buildSpec.ServiceAndBuilds {
+					for _, image := range buildImages(build) {
+						resp = append(resp, &commonmodels.ServiceAndImage{
+							ServiceName:   build.ServiceName,
+							ServiceModule: build.ServiceModule,
+							Image:         image.Image,
+							MatrixSuffix:  image.Suffix,
+						})
+					}
+				}
+			}
+			// get deploy target from previous distribute job
+			if job.JobType == config.JobZadigDistributeImage {
+				distributeSpec := &commonmodels.ZadigDistributeImageJobSpec{}
+				if err := commonmodels.IToi(job.Spec, distributeSpec); err != nil {
+					return resp, err
+				}
+				for _, distribute := range distributeSpec.Tatgets {
+					resp = append(resp, &commonmodels.ServiceAndImage{
+						ServiceName:   distribute.ServiceName,
+						ServiceModule: distribute.ServiceModule,
+						Image:         distribute.TargetImage,
+						MatrixSuffix:  distribute.MatrixSuffix,
+					})
+				}
+			}
+		}
+	}
+	return resp, nil
+}
+
 func checkServiceExsistsInEnv(serviceMap map[string]*commonmodels.ProductService, serviceName, env string) error {
 	if _, ok := serviceMap[serviceName]; !ok {
 		return fmt.Errorf("service %s not exists in env %s", serviceName, env)
@@ -253,6 +279,11 @@ func (j *DeployJob) LintJob() error {
 	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
 		return err
 	}
+	if j.spec.Verification != nil {
+		if err := lintSigningKey(j.spec.Verification.Enabled, j.spec.Verification.KeyID, j.job.Name); err != nil {
+			return err
+		}
+	}
 	if j.spec.Source != config.SourceFromJob {
 		return nil
 	}
@@ -120,13 +120,16 @@ func (j *ImageDistributeJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, erro
 		}
 		newTargets := []*commonmodels.DistributeTarget{}
 		for _, svc := range refJobSpec.ServiceAndBuilds {
-			newTargets = append(newTargets, &commonmodels.DistributeTarget{
-				ServiceName:   svc.ServiceName,
-				ServiceModule: svc.ServiceModule,
-				SourceImage:   svc.Image,
-				TargetTag:     targetTagMap[getServiceKey(svc.ServiceName, svc.ServiceModule)].TargetTag,
-				UpdateTag:     targetTagMap[getServiceKey(svc.ServiceName, svc.ServiceModule)].UpdateTag,
-			})
+			for _, image := range buildImages(svc) {
+				newTargets = append(newTargets, &commonmodels.DistributeTarget{
+					ServiceName:   svc.ServiceName,
+					ServiceModule: svc.ServiceModule,
+					SourceImage:   image.Image,
+					TargetTag:     targetTagMap[getServiceKey(svc.ServiceName, svc.ServiceModule)].TargetTag,
+					UpdateTag:     targetTagMap[getServiceKey(svc.ServiceName, svc.ServiceModule)].UpdateTag,
+					MatrixSuffix:  image.Suffix,
+				})
+			}
 		}
 		j.spec.Tatgets = newTargets
 	}
@@ -138,13 +141,21 @@ func (j *ImageDistributeJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, erro
 		}
 	}
 
+	signing, err := getImageSigning(j.spec.Signing, getProvenance(j.workflow, taskID, j.job.Name, nil))
+	if err != nil {
+		return resp, err
+	}
 	stepSpec := &step.StepImageDistributeSpec{
 		SourceRegistry: getRegistry(sourceReg),
 		TargetRegistry: getRegistry(targetReg),
+		Signing:        signing,
 	}
 	for _, target := range j.spec.Tatgets {
 		// for other job refer current latest image.
 		targetKey := strings.Join([]string{j.job.Name, target.ServiceName, target.ServiceModule}, ".")
+		if target.MatrixSuffix != "" {
+			targetKey = strings.Join([]string{targetKey, target.MatrixSuffix}, ".")
+		}
 		target.TargetImage = job.GetJobOutputKey(targetKey, "IMAGE")
 
 		stepSpec.DistributeTarget = append(stepSpec.DistributeTarget, &step.DistributeTaskTarget{
@@ -153,6 +164,7 @@ func (j *ImageDistributeJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, erro
 			ServiceModule: target.ServiceModule,
 			TargetTag:     target.TargetTag,
 			UpdateTag:     target.UpdateTag,
+			MatrixSuffix:  target.MatrixSuffix,
 		})
 	}
 
@@ -189,6 +201,11 @@ func (j *ImageDistributeJob) LintJob() error {
 	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
 		return err
 	}
+	if j.spec.Signing != nil {
+		if err := lintSigningKey(j.spec.Signing.Enabled, j.spec.Signing.KeyID, j.job.Name); err != nil {
+			return err
+		}
+	}
 	if j.spec.Source != config.SourceFromJob {
 		return nil
 	}
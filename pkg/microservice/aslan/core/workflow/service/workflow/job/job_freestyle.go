@@ -47,6 +47,9 @@ func (j *FreeStyleJob) Instantiate() error {
 	if err := util.CheckDefineResourceParam(j.spec.Properties.ResourceRequest, j.spec.Properties.ResReqSpec); err != nil {
 		return err
 	}
+	if err := util.CheckServiceContainers(j.spec.Properties.ServiceContainers); err != nil {
+		return err
+	}
 
 	for _, step := range j.spec.Steps {
 		switch step.StepType {
@@ -219,6 +222,9 @@ func stepsToStepTasks(step []*commonmodels.Step, outputs []*commonmodels.Output)
 			Name:     step.Name,
 			StepType: step.StepType,
 			Spec:     step.Spec,
+			If:       step.If,
+			RunIf:    step.RunIf,
+			Timeout:  step.Timeout,
 		}
 		if stepTask.StepType == config.StepDockerBuild {
 			stepTaskSpec := &steptypes.StepDockerBuildSpec{}
@@ -284,7 +290,156 @@ func (j *FreeStyleJob) LintJob() error {
 	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
 		return err
 	}
-	return checkOutputNames(j.spec.Outputs)
+	if err := checkOutputNames(j.spec.Outputs); err != nil {
+		return err
+	}
+	if err := checkArtifactSteps(j.spec.Steps); err != nil {
+		return err
+	}
+	if err := checkArtifactOrigins(j.workflow, j.job.Name, j.spec.Steps); err != nil {
+		return err
+	}
+	if err := checkCacheSteps(j.spec.Steps); err != nil {
+		return err
+	}
+	return checkCoverageSteps(j.spec.Steps)
+}
+
+func checkCoverageSteps(steps []*commonmodels.Step) error {
+	for _, step := range steps {
+		if step.StepType != config.StepCoverageReport {
+			continue
+		}
+		spec := &steptypes.StepCoverageReportSpec{}
+		if err := commonmodels.IToi(step.Spec, spec); err != nil {
+			return fmt.Errorf("step %s: invalid coverage report spec: %v", step.Name, err)
+		}
+		if err := spec.Validate(); err != nil {
+			return fmt.Errorf("step %s: %v", step.Name, err)
+		}
+	}
+	return nil
+}
+
+func checkCacheSteps(steps []*commonmodels.Step) error {
+	for _, step := range steps {
+		if step.StepType != config.StepCacheRestore && step.StepType != config.StepCacheSave {
+			continue
+		}
+		spec := &steptypes.StepCacheSpec{}
+		if err := commonmodels.IToi(step.Spec, spec); err != nil {
+			return fmt.Errorf("step %s: invalid cache spec: %v", step.Name, err)
+		}
+		if spec.Key == "" {
+			return fmt.Errorf("step %s: cache key is not set", step.Name)
+		}
+		if step.StepType == config.StepCacheSave && len(spec.Paths) == 0 {
+			return fmt.Errorf("step %s: cache paths are not set", step.Name)
+		}
+		if spec.MaxSize < 0 {
+			return fmt.Errorf("step %s: max size of cache can not be negative", step.Name)
+		}
+	}
+	return nil
+}
+
+func checkArtifactSteps(steps []*commonmodels.Step) error {
+	uploaded := map[string]bool{}
+	for _, step := range steps {
+		if step.StepType != config.StepUploadArtifact && step.StepType != config.StepDownloadArtifact {
+			continue
+		}
+		spec := &steptypes.StepArtifactSpec{}
+		if err := commonmodels.IToi(step.Spec, spec); err != nil {
+			return fmt.Errorf("step %s: invalid artifact spec: %v", step.Name, err)
+		}
+		for _, artifact := range spec.Artifacts {
+			if !OutputNameRegex.MatchString(artifact.Name) {
+				return fmt.Errorf("step %s: artifact name must match %s", step.Name, OutputNameRegexString)
+			}
+			if step.StepType == config.StepDownloadArtifact {
+				if artifact.JobName == "" {
+					return fmt.Errorf("step %s: upstream job of artifact %s is not set", step.Name, artifact.Name)
+				}
+				continue
+			}
+			if artifact.Path == "" {
+				return fmt.Errorf("step %s: path of artifact %s is not set", step.Name, artifact.Name)
+			}
+			if uploaded[artifact.Name] {
+				return fmt.Errorf("step %s: duplicated artifact %s", step.Name, artifact.Name)
+			}
+			uploaded[artifact.Name] = true
+		}
+	}
+	return nil
+}
+
+// checkArtifactOrigins checks that the artifacts to download are uploaded by upstream freestyle jobs. artifacts of
+// matrix jobs are uploaded by each expanded job task under its own name, so they can not be downloaded by job name.
+func checkArtifactOrigins(workflow *commonmodels.WorkflowV4, jobName string, steps []*commonmodels.Step) error {
+	for _, step := range steps {
+		if step.StepType != config.StepDownloadArtifact {
+			continue
+		}
+		spec := &steptypes.StepArtifactSpec{}
+		if err := commonmodels.IToi(step.Spec, spec); err != nil {
+			return fmt.Errorf("step %s: invalid artifact spec: %v", step.Name, err)
+		}
+		for _, artifact := range spec.Artifacts {
+			if !isUpstreamJob(workflow, jobName, artifact.JobName) {
+				return fmt.Errorf("step %s: job %s of artifact %s is not an upstream job", step.Name, artifact.JobName, artifact.Name)
+			}
+			upstream := findWorkflowJob(workflow, artifact.JobName)
+			if upstream.JobType != config.JobFreestyle {
+				return fmt.Errorf("step %s: artifact %s can only be downloaded from a freestyle job", step.Name, artifact.Name)
+			}
+			if upstream.Matrix != nil {
+				return fmt.Errorf("step %s: artifact %s can not be downloaded from matrix job %s", step.Name, artifact.Name, upstream.Name)
+			}
+			uploaded, err := uploadedArtifacts(upstream)
+			if err != nil {
+				return fmt.Errorf("step %s: %v", step.Name, err)
+			}
+			if !uploaded[artifact.Name] {
+				return fmt.Errorf("step %s: artifact %s is not uploaded by job %s", step.Name, artifact.Name, upstream.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func findWorkflowJob(workflow *commonmodels.WorkflowV4, jobName string) *commonmodels.Job {
+	for _, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			if job.Name == jobName {
+				return job
+			}
+		}
+	}
+	return nil
+}
+
+// uploadedArtifacts returns the names of the artifacts uploaded by the freestyle job.
+func uploadedArtifacts(job *commonmodels.Job) (map[string]bool, error) {
+	spec := &commonmodels.FreestyleJobSpec{}
+	if err := commonmodels.IToiYaml(job.Spec, spec); err != nil {
+		return nil, fmt.Errorf("invalid spec of job %s: %v", job.Name, err)
+	}
+	resp := map[string]bool{}
+	for _, step := range spec.Steps {
+		if step.StepType != config.StepUploadArtifact {
+			continue
+		}
+		artifactSpec := &steptypes.StepArtifactSpec{}
+		if err := commonmodels.IToi(step.Spec, artifactSpec); err != nil {
+			return nil, fmt.Errorf("invalid artifact spec of step %s in job %s: %v", step.Name, job.Name, err)
+		}
+		for _, artifact := range artifactSpec.Artifacts {
+			resp[artifact.Name] = true
+		}
+	}
+	return resp, nil
 }
 
 func (j *FreeStyleJob) GetOutPuts(log *zap.SugaredLogger) []string {
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	steptypes "github.com/koderover/zadig/pkg/types/step"
+)
+
+func newArtifactJob(name string, matrix *commonmodels.Matrix, stepType config.StepType, artifacts ...*steptypes.Artifact) *commonmodels.Job {
+	return &commonmodels.Job{
+		Name:    name,
+		JobType: config.JobFreestyle,
+		Matrix:  matrix,
+		Spec: &commonmodels.FreestyleJobSpec{
+			Steps: []*commonmodels.Step{{Name: "artifact", StepType: stepType, Spec: &steptypes.StepArtifactSpec{Artifacts: artifacts}}},
+		},
+	}
+}
+
+var _ = Describe("Testing freestyle job artifacts", func() {
+
+	download := func(workflow *commonmodels.WorkflowV4, jobName string) error {
+		job := findWorkflowJob(workflow, jobName)
+		return checkArtifactOrigins(workflow, job.Name, job.Spec.(*commonmodels.FreestyleJobSpec).Steps)
+	}
+	upload := newArtifactJob("build", nil, config.StepUploadArtifact, &steptypes.Artifact{Name: "dist", Path: "dist"})
+
+	It("should download artifacts uploaded by upstream jobs", func() {
+		workflow := newDAGWorkflow(false,
+			[]*commonmodels.Job{upload},
+			[]*commonmodels.Job{newArtifactJob("test", nil, config.StepDownloadArtifact, &steptypes.Artifact{Name: "dist", Path: "dist", JobName: "build"})},
+		)
+		Expect(download(workflow, "test")).ShouldNot(HaveOccurred())
+	})
+
+	It("should raise error for artifacts of jobs which are not upstream", func() {
+		workflow := newDAGWorkflow(false, []*commonmodels.Job{
+			upload,
+			newArtifactJob("test", nil, config.StepDownloadArtifact, &steptypes.Artifact{Name: "dist", Path: "dist", JobName: "build"}),
+		})
+		Expect(download(workflow, "test")).Should(HaveOccurred())
+
+		workflow = newDAGWorkflow(false,
+			[]*commonmodels.Job{newArtifactJob("test", nil, config.StepDownloadArtifact, &steptypes.Artifact{Name: "dist", Path: "dist", JobName: "build"})},
+			[]*commonmodels.Job{upload},
+		)
+		Expect(download(workflow, "test")).Should(HaveOccurred())
+	})
+
+	It("should raise error for artifacts not uploaded by the upstream job", func() {
+		workflow := newDAGWorkflow(false,
+			[]*commonmodels.Job{upload},
+			[]*commonmodels.Job{newArtifactJob("test", nil, config.StepDownloadArtifact, &steptypes.Artifact{Name: "report", Path: "report", JobName: "build"})},
+		)
+		Expect(download(workflow, "test")).Should(HaveOccurred())
+	})
+
+	It("should raise error for artifacts of matrix jobs", func() {
+		matrix := &commonmodels.Matrix{Axes: []*commonmodels.MatrixAxis{{Name: "os", Values: []string{"linux", "darwin"}}}}
+		workflow := newDAGWorkflow(false,
+			[]*commonmodels.Job{newArtifactJob("build", matrix, config.StepUploadArtifact, &steptypes.Artifact{Name: "dist", Path: "dist"})},
+			[]*commonmodels.Job{newArtifactJob("test", nil, config.StepDownloadArtifact, &steptypes.Artifact{Name: "dist", Path: "dist", JobName: "build"})},
+		)
+		Expect(download(workflow, "test")).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,226 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+type ImageScanJob struct {
+	job      *commonmodels.Job
+	workflow *commonmodels.WorkflowV4
+	spec     *commonmodels.ZadigImageScanJobSpec
+}
+
+func (j *ImageScanJob) Instantiate() error {
+	j.spec = &commonmodels.ZadigImageScanJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	j.job.Spec = j.spec
+	return nil
+}
+
+func (j *ImageScanJob) SetPreset() error {
+	j.spec = &commonmodels.ZadigImageScanJobSpec{}
+	if err := commonmodels.IToi(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	if j.spec.Source == config.SourceFromJob {
+		targets, err := getQuoteImageScanTargets(j.spec.JobName, j.workflow)
+		if err != nil {
+			log.Error(err)
+		}
+		for _, target := range targets {
+			target.Image = ""
+		}
+		j.spec.Targets = targets
+	}
+	j.job.Spec = j.spec
+	return nil
+}
+
+func (j *ImageScanJob) MergeArgs(args *commonmodels.Job) error {
+	if j.job.Name == args.Name && j.job.JobType == args.JobType {
+		j.spec = &commonmodels.ZadigImageScanJobSpec{}
+		if err := commonmodels.IToi(j.job.Spec, j.spec); err != nil {
+			return err
+		}
+		argsSpec := &commonmodels.ZadigImageScanJobSpec{}
+		if err := commonmodels.IToi(args.Spec, argsSpec); err != nil {
+			return err
+		}
+		j.spec.Targets = argsSpec.Targets
+		j.job.Spec = j.spec
+	}
+	return nil
+}
+
+func (j *ImageScanJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
+	logger := log.SugaredLogger()
+	resp := []*commonmodels.JobTask{}
+
+	j.spec = &commonmodels.ZadigImageScanJobSpec{}
+	if err := commonmodels.IToi(j.job.Spec, j.spec); err != nil {
+		return resp, err
+	}
+
+	// get scan targets from previous build or distribute job, the images are rendered when the job runs.
+	if j.spec.Source == config.SourceFromJob {
+		targets, err := getQuoteImageScanTargets(j.spec.JobName, j.workflow)
+		if err != nil {
+			return resp, err
+		}
+		j.spec.Targets = targets
+	}
+
+	// the images may be in any registry, so the credentials of all registries are provided.
+	registries, err := commonservice.ListRegistryNamespaces("", true, logger)
+	if err != nil {
+		return resp, fmt.Errorf("list image registries error: %v", err)
+	}
+	stepSpec := &step.StepImageScanSpec{
+		SBOMFormat:     step.SBOMFormat(j.spec.SBOMFormat),
+		FailSeverities: j.spec.FailSeverities,
+		WarnSeverities: j.spec.WarnSeverities,
+		IgnoredCVEs:    j.spec.IgnoredCVEs,
+	}
+	for _, reg := range registries {
+		stepSpec.Registries = append(stepSpec.Registries, getRegistry(reg))
+	}
+	for _, target := range j.spec.Targets {
+		if target.Image == "" {
+			return resp, fmt.Errorf("image of %s/%s is empty", target.ServiceName, target.ServiceModule)
+		}
+		stepSpec.Targets = append(stepSpec.Targets, &step.ImageScanTarget{
+			ServiceName:   target.ServiceName,
+			ServiceModule: target.ServiceModule,
+			Image:         target.Image,
+		})
+	}
+
+	jobTaskSpec := &commonmodels.JobTaskFreestyleSpec{
+		Properties: commonmodels.JobProperties{
+			Timeout:         j.spec.Timeout,
+			ResourceRequest: setting.MinRequest,
+			ClusterID:       j.spec.ClusterID,
+			BuildOS:         "focal",
+			ImageFrom:       commonmodels.ImageFromKoderover,
+		},
+		Steps: []*commonmodels.StepTask{
+			{
+				Name:     "image-scan",
+				StepType: config.StepImageScan,
+				Spec:     stepSpec,
+			},
+		},
+	}
+	jobTask := &commonmodels.JobTask{
+		Name:    j.job.Name,
+		Key:     j.job.Name,
+		JobType: string(config.JobZadigImageScan),
+		Spec:    jobTaskSpec,
+		Timeout: getTimeout(j.spec.Timeout),
+	}
+	resp = append(resp, jobTask)
+	j.job.Spec = j.spec
+	return resp, nil
+}
+
+func (j *ImageScanJob) LintJob() error {
+	j.spec = &commonmodels.ZadigImageScanJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	stepSpec := &step.StepImageScanSpec{
+		SBOMFormat:     step.SBOMFormat(j.spec.SBOMFormat),
+		FailSeverities: j.spec.FailSeverities,
+		WarnSeverities: j.spec.WarnSeverities,
+	}
+	if err := stepSpec.Validate(); err != nil {
+		return fmt.Errorf("job %s: %v", j.job.Name, err)
+	}
+	if j.spec.Source != config.SourceFromJob {
+		return nil
+	}
+	jobRankMap := getJobRankMap(j.workflow.Stages)
+	quoteJobRank, ok := jobRankMap[j.spec.JobName]
+	if !ok || quoteJobRank >= jobRankMap[j.job.Name] {
+		return fmt.Errorf("can not quote job %s in job %s", j.spec.JobName, j.job.Name)
+	}
+	if _, err := getQuoteImageScanTargets(j.spec.JobName, j.workflow); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (j *ImageScanJob) GetOutPuts(log *zap.SugaredLogger) []string {
+	return []string{}
+}
+
+// getQuoteImageScanTargets returns the images of the upstream build or distribute job.
+func getQuoteImageScanTargets(jobName string, workflow *commonmodels.WorkflowV4) ([]*commonmodels.ImageScanTarget, error) {
+	resp := []*commonmodels.ImageScanTarget{}
+	for _, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			if job.Name != jobName {
+				continue
+			}
+			switch job.JobType {
+			case config.JobZadigBuild:
+				buildSpec := &commonmodels.ZadigBuildJobSpec{}
+				if err := commonmodels.IToi(job.Spec, buildSpec); err != nil {
+					return resp, err
+				}
+				for _, build := range buildSpec.ServiceAndBuilds {
+					for _, image := range buildImages(build) {
+						resp = append(resp, &commonmodels.ImageScanTarget{
+							ServiceName:   build.ServiceName,
+							ServiceModule: build.ServiceModule,
+							Image:         image.Image,
+						})
+					}
+				}
+			case config.JobZadigDistributeImage:
+				distributeSpec := &commonmodels.ZadigDistributeImageJobSpec{}
+				if err := commonmodels.IToi(job.Spec, distributeSpec); err != nil {
+					return resp, err
+				}
+				for _, distribute := range distributeSpec.Tatgets {
+					resp = append(resp, &commonmodels.ImageScanTarget{
+						ServiceName:   distribute.ServiceName,
+						ServiceModule: distribute.ServiceModule,
+						Image:         distribute.TargetImage,
+					})
+				}
+			default:
+				return resp, fmt.Errorf("cannot reference job: %s that is not a build or distribute job", jobName)
+			}
+			return resp, nil
+		}
+	}
+	return resp, fmt.Errorf("reference job: %s not found", jobName)
+}
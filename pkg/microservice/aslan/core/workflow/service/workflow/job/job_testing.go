@@ -172,6 +172,7 @@ func (j *TestingJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 			ImageFrom:           testingInfo.PreTest.ImageFrom,
 			Registries:          registries,
 			ShareStorageDetails: getShareStorageDetail(j.workflow.ShareStorages, testing.ShareStorageInfo, j.workflow.Name, taskID),
+			ServiceContainers:   testingInfo.PreTest.ServiceContainers,
 		}
 		clusterInfo, err := commonrepo.NewK8SClusterColl().Get(testingInfo.PreTest.ClusterID)
 		if err != nil {
@@ -286,6 +287,33 @@ func (j *TestingJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 			jobTaskSpec.Steps = append(jobTaskSpec.Steps, junitStep)
 		}
 
+		// init coverage report step
+		if testingInfo.CoverageReport != nil && len(testingInfo.CoverageReport.ReportPath) > 0 {
+			coverageSpec := &step.StepCoverageReportSpec{
+				ReportPath:        testingInfo.CoverageReport.ReportPath,
+				Format:            step.CoverageFormat(testingInfo.CoverageReport.Format),
+				S3DestDir:         path.Join(j.workflow.Name, fmt.Sprint(taskID), jobTask.Name, "coverage"),
+				TestName:          testing.Name,
+				MinLineCoverage:   testingInfo.CoverageReport.MinLineCoverage,
+				MinBranchCoverage: testingInfo.CoverageReport.MinBranchCoverage,
+				MaxRegression:     testingInfo.CoverageReport.MaxRegression,
+			}
+			// the base branch is the target branch of pull requests and the branch itself otherwise.
+			if len(testing.Repos) > 0 {
+				coverageSpec.Branch = testing.Repos[0].Branch
+				coverageSpec.PR = testing.Repos[0].PR
+				coverageSpec.BaseBranch = testing.Repos[0].Branch
+			}
+			coverageStep := &commonmodels.StepTask{
+				Name:      config.TestJobCoverageReportStepName,
+				JobName:   jobTask.Name,
+				StepType:  config.StepCoverageReport,
+				Onfailure: true,
+				Spec:      coverageSpec,
+			}
+			jobTaskSpec.Steps = append(jobTaskSpec.Steps, coverageStep)
+		}
+
 		resp = append(resp, jobTask)
 	}
 	j.job.Spec = j.spec
@@ -0,0 +1,283 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	jobspec "github.com/koderover/zadig/pkg/types/job"
+	stepspec "github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util/variable"
+)
+
+const maxMatrixCombinations = 256
+
+var (
+	matrixAxisNameRegex   = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+	matrixSuffixInvalidRe = regexp.MustCompile("[^a-z0-9]+")
+)
+
+func lintMatrix(job *commonmodels.Job) error {
+	matrix := job.Matrix
+	if matrix == nil {
+		return nil
+	}
+	switch job.JobType {
+	case config.JobFreestyle, config.JobZadigBuild, config.JobZadigTesting:
+	default:
+		return fmt.Errorf("job %s: matrix is not supported by %s job", job.Name, job.JobType)
+	}
+	if len(matrix.Axes) == 0 {
+		return fmt.Errorf("job %s: matrix has no axes", job.Name)
+	}
+	if matrix.MaxParallel < 0 {
+		return fmt.Errorf("job %s: matrix max parallel can not be negative", job.Name)
+	}
+	axisNames := map[string]bool{}
+	for _, axis := range matrix.Axes {
+		if !matrixAxisNameRegex.MatchString(axis.Name) {
+			return fmt.Errorf("job %s: matrix axis name [%s] did not match %s", job.Name, axis.Name, matrixAxisNameRegex.String())
+		}
+		if axisNames[axis.Name] {
+			return fmt.Errorf("job %s: duplicated matrix axis: %s", job.Name, axis.Name)
+		}
+		axisNames[axis.Name] = true
+		if len(axis.Values) == 0 {
+			return fmt.Errorf("job %s: matrix axis %s has no values", job.Name, axis.Name)
+		}
+		values := map[string]bool{}
+		for _, value := range axis.Values {
+			if values[value] {
+				return fmt.Errorf("job %s: duplicated value %s in matrix axis %s", job.Name, value, axis.Name)
+			}
+			values[value] = true
+		}
+	}
+	for _, exclude := range matrix.Exclude {
+		for name := range exclude {
+			if !axisNames[name] {
+				return fmt.Errorf("job %s: matrix exclude refers to unknown axis %s", job.Name, name)
+			}
+		}
+	}
+	switch count := len(matrixCombinations(matrix)); {
+	case count == 0:
+		return fmt.Errorf("job %s: all matrix combinations are excluded", job.Name)
+	case count > maxMatrixCombinations:
+		return fmt.Errorf("job %s: matrix has %d combinations, no more than %d are allowed", job.Name, count, maxMatrixCombinations)
+	}
+	return nil
+}
+
+// matrixCombinations returns all combinations which are not excluded, the first axis changes slowest.
+func matrixCombinations(matrix *commonmodels.Matrix) []map[string]string {
+	combinations := []map[string]string{{}}
+	for _, axis := range matrix.Axes {
+		expanded := make([]map[string]string, 0, len(combinations)*len(axis.Values))
+		for _, combination := range combinations {
+			for _, value := range axis.Values {
+				next := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					next[k] = v
+				}
+				next[axis.Name] = value
+				expanded = append(expanded, next)
+			}
+		}
+		combinations = expanded
+	}
+
+	resp := []map[string]string{}
+	for _, combination := range combinations {
+		if !matrixExcluded(matrix.Exclude, combination) {
+			resp = append(resp, combination)
+		}
+	}
+	return resp
+}
+
+func matrixExcluded(excludes []map[string]string, combination map[string]string) bool {
+	for _, exclude := range excludes {
+		if len(exclude) == 0 {
+			continue
+		}
+		matched := true
+		for name, value := range exclude {
+			if combination[name] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// expandMatrix makes a copy of every job task for each matrix combination. the copies are named after the
+// axis values, so that the names and output keys of them keep the same between tasks of the workflow.
+func expandMatrix(job *commonmodels.Job, jobTasks []*commonmodels.JobTask) ([]*commonmodels.JobTask, error) {
+	if job.Matrix == nil {
+		return jobTasks, nil
+	}
+	combinations := matrixCombinations(job.Matrix)
+	resp := []*commonmodels.JobTask{}
+	for _, jobTask := range jobTasks {
+		suffixes := map[string]bool{}
+		for i, combination := range combinations {
+			suffix := matrixSuffix(job.Matrix, combination)
+			if suffix == "" || suffixes[suffix] {
+				suffix = strconv.Itoa(i)
+			}
+			suffixes[suffix] = true
+
+			task, err := renderMatrixJobTask(jobTask, job.Matrix, combination)
+			if err != nil {
+				return resp, fmt.Errorf("expand matrix of job %s error: %v", job.Name, err)
+			}
+			task.Name = jobNameFormat(jobTask.Name + "-" + suffix)
+			task.Key = strings.Join([]string{jobTask.Key, suffix}, ".")
+			task.Matrix = combination
+			task.MaxParallel = job.Matrix.MaxParallel
+			spec := task.Spec.(*commonmodels.JobTaskFreestyleSpec)
+			for _, step := range spec.Steps {
+				step.JobName = task.Name
+			}
+			if job.JobType == config.JobZadigBuild {
+				if err := setMatrixImageTag(spec, suffix); err != nil {
+					return resp, fmt.Errorf("expand matrix of job %s error: %v", job.Name, err)
+				}
+			}
+			resp = append(resp, task)
+		}
+	}
+	return resp, nil
+}
+
+// setMatrixImageTag adds the suffix of the combination to the image tag of an expanded build job task, so that
+// the combinations push their own images instead of overwriting the same tag.
+func setMatrixImageTag(spec *commonmodels.JobTaskFreestyleSpec, suffix string) error {
+	for _, env := range spec.Properties.Envs {
+		if env.Key == "IMAGE" {
+			env.Value = matrixImageTag(env.Value, suffix)
+		}
+	}
+	for _, step := range spec.Steps {
+		if step.StepType != config.StepDockerBuild {
+			continue
+		}
+		dockerBuildSpec := &stepspec.StepDockerBuildSpec{}
+		if err := commonmodels.IToi(step.Spec, dockerBuildSpec); err != nil {
+			return err
+		}
+		dockerBuildSpec.ImageReleaseTag = matrixImageTag(dockerBuildSpec.ImageReleaseTag, suffix)
+		step.Spec = dockerBuildSpec
+	}
+	return nil
+}
+
+// matrixImageTag appends the suffix to the tag of the image, the suffix is used as the tag if the image has none.
+func matrixImageTag(image, suffix string) string {
+	if image == "" {
+		return image
+	}
+	if strings.LastIndex(image, ":") > strings.LastIndex(image, "/") {
+		return image + "-" + suffix
+	}
+	return image + ":" + suffix
+}
+
+// setMatrixBuildImages points the images of a matrix build job to the outputs of the expanded job tasks,
+// so that the jobs referring to it get the image of every combination.
+func setMatrixBuildImages(buildJob *commonmodels.Job, jobTasks []*commonmodels.JobTask) error {
+	if buildJob.Matrix == nil || buildJob.JobType != config.JobZadigBuild {
+		return nil
+	}
+	spec := &commonmodels.ZadigBuildJobSpec{}
+	if err := commonmodels.IToi(buildJob.Spec, spec); err != nil {
+		return err
+	}
+	builds := map[string]*commonmodels.ServiceAndBuild{}
+	for _, build := range spec.ServiceAndBuilds {
+		build.Image = ""
+		build.MatrixImages = nil
+		builds[strings.Join([]string{buildJob.Name, build.ServiceName, build.ServiceModule}, ".")] = build
+	}
+	for _, task := range jobTasks {
+		i := strings.LastIndex(task.Key, ".")
+		if i < 0 {
+			continue
+		}
+		build, ok := builds[task.Key[:i]]
+		if !ok {
+			continue
+		}
+		build.MatrixImages = append(build.MatrixImages, &commonmodels.MatrixImage{
+			Suffix: task.Key[i+1:],
+			Image:  jobspec.GetJobOutputKey(task.Key, "IMAGE"),
+		})
+	}
+	buildJob.Spec = spec
+	return nil
+}
+
+// buildImages returns the images of the build, there is one for each combination if the build job has a matrix.
+func buildImages(build *commonmodels.ServiceAndBuild) []*commonmodels.MatrixImage {
+	if len(build.MatrixImages) > 0 {
+		return build.MatrixImages
+	}
+	return []*commonmodels.MatrixImage{{Image: build.Image}}
+}
+
+func matrixSuffix(matrix *commonmodels.Matrix, combination map[string]string) string {
+	values := []string{}
+	for _, axis := range matrix.Axes {
+		values = append(values, combination[axis.Name])
+	}
+	suffix := matrixSuffixInvalidRe.ReplaceAllString(strings.ToLower(strings.Join(values, "-")), "-")
+	return strings.Trim(suffix, "-")
+}
+
+// renderMatrixJobTask renders {{.matrix.<axis>}} in a copy of the job task and adds the axis values to its envs.
+func renderMatrixJobTask(jobTask *commonmodels.JobTask, matrix *commonmodels.Matrix, combination map[string]string) (*commonmodels.JobTask, error) {
+	renderer := variable.NewRenderer()
+	for _, axis := range matrix.Axes {
+		renderer.Set("matrix."+axis.Name, combination[axis.Name])
+	}
+	task := &commonmodels.JobTask{}
+	if err := commonmodels.IToi(jobTask, task); err != nil {
+		return nil, err
+	}
+	if err := renderer.Render(task); err != nil {
+		return nil, err
+	}
+	spec := &commonmodels.JobTaskFreestyleSpec{}
+	if err := commonmodels.IToi(task.Spec, spec); err != nil {
+		return nil, err
+	}
+	for _, axis := range matrix.Axes {
+		spec.Properties.Envs = append(spec.Properties.Envs, &commonmodels.KeyVal{Key: axis.Name, Value: combination[axis.Name]})
+	}
+	task.Spec = spec
+	return task, nil
+}
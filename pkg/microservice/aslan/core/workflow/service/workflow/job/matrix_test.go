@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+var _ = Describe("Testing matrix", func() {
+
+	matrix := &commonmodels.Matrix{
+		Axes: []*commonmodels.MatrixAxis{
+			{Name: "OS", Values: []string{"linux", "darwin"}},
+			{Name: "GO_VERSION", Values: []string{"1.18", "1.19"}},
+		},
+		Exclude:     []map[string]string{{"OS": "darwin", "GO_VERSION": "1.18"}},
+		MaxParallel: 2,
+	}
+
+	It("should lint matrix", func() {
+		Expect(lintMatrix(&commonmodels.Job{Name: "test", JobType: config.JobFreestyle, Matrix: matrix})).ShouldNot(HaveOccurred())
+		Expect(lintMatrix(&commonmodels.Job{Name: "deploy", JobType: config.JobZadigDeploy, Matrix: matrix})).Should(HaveOccurred())
+		Expect(lintMatrix(&commonmodels.Job{Name: "test", JobType: config.JobFreestyle, Matrix: &commonmodels.Matrix{
+			Axes: []*commonmodels.MatrixAxis{{Name: "go-version", Values: []string{"1.19"}}},
+		}})).Should(HaveOccurred())
+	})
+
+	It("should expand job tasks with stable names", func() {
+		jobTask := &commonmodels.JobTask{
+			Name: "test",
+			Key:  "test",
+			Spec: &commonmodels.JobTaskFreestyleSpec{
+				Properties: commonmodels.JobProperties{Envs: []*commonmodels.KeyVal{{Key: "TARGET", Value: "{{.matrix.OS}}"}}},
+			},
+		}
+		jobTasks, err := expandMatrix(&commonmodels.Job{Name: "test", Matrix: matrix}, []*commonmodels.JobTask{jobTask})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(jobTasks).To(HaveLen(3))
+
+		names := []string{}
+		for _, task := range jobTasks {
+			names = append(names, task.Name)
+		}
+		Expect(names).To(Equal([]string{"test-linux-1-18", "test-linux-1-19", "test-darwin-1-19"}))
+		Expect(jobTasks[2].Key).To(Equal("test.darwin-1-19"))
+		Expect(jobTasks[2].MaxParallel).To(Equal(2))
+
+		spec := jobTasks[2].Spec.(*commonmodels.JobTaskFreestyleSpec)
+		Expect(spec.Properties.Envs).To(ContainElement(&commonmodels.KeyVal{Key: "TARGET", Value: "darwin"}))
+		Expect(spec.Properties.Envs).To(ContainElement(&commonmodels.KeyVal{Key: "GO_VERSION", Value: "1.19"}))
+	})
+
+	It("should push distinct image tags for the combinations of a matrix build", func() {
+		buildJob := &commonmodels.Job{Name: "build", JobType: config.JobZadigBuild, Matrix: matrix}
+		jobTask := &commonmodels.JobTask{
+			Name: "svc-app-build",
+			Key:  "build.svc.app",
+			Spec: &commonmodels.JobTaskFreestyleSpec{
+				Properties: commonmodels.JobProperties{Envs: []*commonmodels.KeyVal{{Key: "IMAGE", Value: "koderover.tencentcloudcr.com/test/app:20221010-1"}}},
+				Steps: []*commonmodels.StepTask{{
+					Name:     "svc-docker-build",
+					StepType: config.StepDockerBuild,
+					Spec:     step.StepDockerBuildSpec{ImageName: "$IMAGE", ImageReleaseTag: "app:20221010-1"},
+				}},
+			},
+		}
+		jobTasks, err := expandMatrix(buildJob, []*commonmodels.JobTask{jobTask})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(jobTasks).To(HaveLen(3))
+
+		images, tags := map[string]bool{}, map[string]bool{}
+		for _, task := range jobTasks {
+			spec := task.Spec.(*commonmodels.JobTaskFreestyleSpec)
+			for _, env := range spec.Properties.Envs {
+				if env.Key == "IMAGE" {
+					images[env.Value] = true
+				}
+			}
+			tags[spec.Steps[0].Spec.(*step.StepDockerBuildSpec).ImageReleaseTag] = true
+		}
+		Expect(images).To(HaveLen(3))
+		Expect(images).To(HaveKey("koderover.tencentcloudcr.com/test/app:20221010-1-darwin-1-19"))
+		Expect(tags).To(HaveLen(3))
+		Expect(tags).To(HaveKey("app:20221010-1-linux-1-18"))
+		Expect(matrixImageTag("registry:5000/app", "linux")).To(Equal("registry:5000/app:linux"))
+	})
+
+	It("should deploy the images of all combinations of a matrix build", func() {
+		buildJob := &commonmodels.Job{
+			Name:    "build",
+			JobType: config.JobZadigBuild,
+			Matrix:  matrix,
+			Spec: &commonmodels.ZadigBuildJobSpec{
+				ServiceAndBuilds: []*commonmodels.ServiceAndBuild{{ServiceName: "svc", ServiceModule: "app"}},
+			},
+		}
+		jobTask := &commonmodels.JobTask{
+			Name: "svc-app-build",
+			Key:  "build.svc.app",
+			Spec: &commonmodels.JobTaskFreestyleSpec{},
+		}
+		jobTasks, err := expandMatrix(buildJob, []*commonmodels.JobTask{jobTask})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(setMatrixBuildImages(buildJob, jobTasks)).ShouldNot(HaveOccurred())
+
+		workflow := &commonmodels.WorkflowV4{Stages: []*commonmodels.WorkflowStage{
+			{Name: "build", Jobs: []*commonmodels.Job{buildJob}},
+			{Name: "deploy", Jobs: []*commonmodels.Job{{Name: "deploy", JobType: config.JobZadigDeploy}}},
+		}}
+		targets, err := getQuoteDeployTargets("build", workflow)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(targets).To(HaveLen(3))
+		Expect(targets[0].Image).To(Equal("{{.job.build.svc.app.linux-1-18.output.IMAGE}}"))
+		Expect(targets[0].MatrixSuffix).To(Equal("linux-1-18"))
+		Expect(targets[2].Image).To(Equal("{{.job.build.svc.app.darwin-1-19.output.IMAGE}}"))
+	})
+})
@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+const maxJobRetries = 10
+
+var retryableFailureClasses = map[config.FailureClass]bool{
+	config.FailurePodEviction:    true,
+	config.FailureImagePull:      true,
+	config.FailureTimeout:        true,
+	config.FailureScript:         true,
+	config.FailureInfrastructure: true,
+	config.FailureUnknown:        true,
+}
+
+func lintRetryPolicy(job *commonmodels.Job) error {
+	policy := job.Retry
+	if policy == nil {
+		return nil
+	}
+	if policy.Retries < 0 || policy.Retries > maxJobRetries {
+		return fmt.Errorf("job %s: retries should be between 0 and %d", job.Name, maxJobRetries)
+	}
+	if policy.Backoff < 0 || policy.MaxBackoff < 0 {
+		return fmt.Errorf("job %s: retry backoff can not be negative", job.Name)
+	}
+	for _, class := range policy.RetryOn {
+		if !retryableFailureClasses[class] {
+			return fmt.Errorf("job %s: unknown failure class %s to retry on", job.Name, class)
+		}
+	}
+	return nil
+}
+
+// JobRetryPolicy returns the retry policy of the job task, the retry times in the properties of
+// freestyle, build and testing jobs are used when the job has no retry policy.
+func JobRetryPolicy(job *commonmodels.Job, jobTask *commonmodels.JobTask) *commonmodels.RetryPolicy {
+	if job.Retry != nil {
+		return job.Retry
+	}
+	spec, ok := jobTask.Spec.(*commonmodels.JobTaskFreestyleSpec)
+	if !ok || spec.Properties.Retry <= 0 {
+		return nil
+	}
+	return &commonmodels.RetryPolicy{Retries: int(spec.Properties.Retry)}
+}
+
+// JobContinueOnError returns whether the failure of the job does not fail the stage, allow_failure is an alias
+// of continue_on_error.
+func JobContinueOnError(job *commonmodels.Job) bool {
+	return job.ContinueOnError || job.AllowFailure
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing job retry policies", func() {
+
+	It("should take allow_failure as an alias of continue_on_error", func() {
+		for _, content := range []string{"name: lint\ncontinue_on_error: true\n", "name: lint\nallow_failure: true\n"} {
+			job := &commonmodels.Job{}
+			Expect(yaml.Unmarshal([]byte(content), job)).To(Succeed())
+			Expect(JobContinueOnError(job)).To(BeTrue())
+		}
+		Expect(JobContinueOnError(&commonmodels.Job{Name: "lint"})).To(BeFalse())
+	})
+
+	It("should lint the retry policy", func() {
+		Expect(lintRetryPolicy(&commonmodels.Job{Name: "build"})).To(Succeed())
+		Expect(lintRetryPolicy(&commonmodels.Job{Name: "build", Retry: &commonmodels.RetryPolicy{Retries: 3, Backoff: 5}})).To(Succeed())
+		Expect(lintRetryPolicy(&commonmodels.Job{Name: "build", Retry: &commonmodels.RetryPolicy{Retries: maxJobRetries + 1}})).NotTo(Succeed())
+		Expect(lintRetryPolicy(&commonmodels.Job{Name: "build", Retry: &commonmodels.RetryPolicy{Retries: 1, Backoff: -1}})).NotTo(Succeed())
+		Expect(lintRetryPolicy(&commonmodels.Job{Name: "build", Retry: &commonmodels.RetryPolicy{Retries: 1, RetryOn: []config.FailureClass{"oom"}}})).NotTo(Succeed())
+	})
+})
@@ -34,6 +34,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/jobcontroller"
 	jobctl "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow/job"
 	"github.com/koderover/zadig/pkg/microservice/user/core"
 	"github.com/koderover/zadig/pkg/microservice/user/core/repository/orm"
@@ -69,6 +70,31 @@ type WorkflowTaskPreview struct {
 	ProjectName         string                `bson:"project_name"              json:"project_name"`
 	Error               string                `bson:"error,omitempty"           json:"error,omitempty"`
 	IsRestart           bool                  `bson:"is_restart"                json:"is_restart"`
+	DAG                 bool                  `bson:"dag"                       json:"dag"`
+	Graph               *WorkflowTaskGraph    `bson:"graph"                     json:"graph"`
+	RetryFrom           int64                 `bson:"retry_from"                json:"retry_from,omitempty"`
+	Priority            int                   `bson:"priority"                  json:"priority"`
+	QueuePosition       int                   `bson:"queue_position"            json:"queue_position,omitempty"`
+	EstimatedWait       int64                 `bson:"estimated_wait"            json:"estimated_wait,omitempty"`
+}
+
+// WorkflowTaskGraph describes the job dependencies of a workflow task, edges point from upstream to downstream job.
+type WorkflowTaskGraph struct {
+	Nodes []*JobGraphNode `json:"nodes"`
+	Edges []*JobGraphEdge `json:"edges"`
+}
+
+type JobGraphNode struct {
+	Name       string        `json:"name"`
+	OriginName string        `json:"origin_name"`
+	StageName  string        `json:"stage_name"`
+	JobType    string        `json:"type"`
+	Status     config.Status `json:"status"`
+}
+
+type JobGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
 type StageTaskPreview struct {
@@ -89,6 +115,14 @@ type JobTaskPreview struct {
 	EndTime   int64         `bson:"end_time"       json:"end_time,omitempty"`
 	Error     string        `bson:"error"          json:"error"`
 	Spec      interface{}   `bson:"spec"           json:"spec"`
+	// OriginName is the name of the workflow job, DependsOn is the origin names of its upstream jobs.
+	OriginName string   `bson:"origin_name"    json:"origin_name"`
+	DependsOn  []string `bson:"depends_on"     json:"depends_on"`
+	// Condition shows why the job was skipped by its if expression.
+	Condition *commonmodels.ConditionResult `bson:"condition"      json:"condition,omitempty"`
+	Matrix    map[string]string             `bson:"matrix"         json:"matrix,omitempty"`
+	// ReusedFrom is the id of the task in which the job ran if its result was kept by a retried task.
+	ReusedFrom int64 `bson:"reused_from"    json:"reused_from,omitempty"`
 }
 
 type ZadigBuildJobSpec struct {
@@ -178,6 +212,10 @@ type DistributeImageJobSpec struct {
 	DistributeTarget []*step.DistributeTaskTarget `bson:"distribute_target"            json:"distribute_target"`
 }
 
+type ImageScanJobSpec struct {
+	Targets []*step.ImageScanTarget `bson:"targets"            json:"targets"`
+}
+
 func GetWorkflowv4Preset(encryptedKey, workflowName, uid string, log *zap.SugaredLogger) (*commonmodels.WorkflowV4, error) {
 	workflow, err := commonrepo.NewWorkflowV4Coll().Find(workflowName)
 	if err != nil {
@@ -229,6 +267,8 @@ func CheckWorkflowV4LarkApproval(workflowName, uid string, log *zap.SugaredLogge
 type CreateWorkflowTaskV4Args struct {
 	Name   string
 	UserID string
+	// Priority overrides the priority of the workflow for the task if it is set, e.g. for a hotfix run.
+	Priority *int
 }
 
 func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels.WorkflowV4, log *zap.SugaredLogger) (*CreateTaskV4Resp, error) {
@@ -239,6 +279,9 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 	if err := LintWorkflowV4(workflow, log); err != nil {
 		return resp, err
 	}
+	if args.Priority != nil && (*args.Priority > config.MaxTaskPriority || *args.Priority < -config.MaxTaskPriority) {
+		return resp, e.ErrInvalidParam.AddDesc(fmt.Sprintf("priority should be between %d and %d", -config.MaxTaskPriority, config.MaxTaskPriority))
+	}
 
 	workflowTask := &commonmodels.WorkflowTask{}
 
@@ -286,6 +329,12 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 	workflowTask.KeyVals = workflow.KeyVals
 	workflowTask.MultiRun = workflow.MultiRun
 	workflowTask.ShareStorages = workflow.ShareStorages
+	workflowTask.DAG = workflow.DAG
+	workflowTask.Priority = workflow.Priority
+	if args.Priority != nil {
+		workflowTask.Priority = *args.Priority
+	}
+	workflowTask.Concurrency = workflow.Concurrency
 
 	for _, stage := range workflow.Stages {
 		stageTask := &commonmodels.StageTask{
@@ -329,6 +378,13 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 				log.Errorf("cannot create workflow %s, the error is: %v", workflow.Name, err)
 				return resp, e.ErrCreateTask.AddDesc(err.Error())
 			}
+			for _, jobTask := range jobs {
+				jobTask.OriginName = job.Name
+				jobTask.DependsOn = job.DependsOn
+				jobTask.If = job.If
+				jobTask.RetryPolicy = jobctl.JobRetryPolicy(job, jobTask)
+				jobTask.ContinueOnError = jobctl.JobContinueOnError(job)
+			}
 			stageTask.Jobs = append(stageTask.Jobs, jobs...)
 		}
 		if len(stageTask.Jobs) > 0 {
@@ -390,9 +446,36 @@ func ListWorkflowTaskV4(workflowName string, pageNum, pageSize int64, logger *za
 		return resp, total, err
 	}
 	cleanWorkflowV4Tasks(resp)
+	setQueuePositions(resp)
 	return resp, total, nil
 }
 
+// setQueuePositions sets the queue position and estimated wait time of the tasks waiting in the queue.
+func setQueuePositions(tasks []*commonmodels.WorkflowTask) {
+	pending := false
+	for _, task := range tasks {
+		if task.Status == config.StatusWaiting || task.Status == config.StatusBlocked {
+			pending = true
+		}
+	}
+	if !pending {
+		return
+	}
+	queuedTasks, err := workflowcontroller.ListQueuedTasks()
+	if err != nil {
+		log.Errorf("list queued tasks error: %v", err)
+		return
+	}
+	for _, task := range tasks {
+		for _, queuedTask := range queuedTasks {
+			if queuedTask.WorkflowName == task.WorkflowName && queuedTask.TaskID == task.TaskID {
+				task.QueuePosition = queuedTask.Position
+				task.EstimatedWait = queuedTask.EstimatedWait
+			}
+		}
+	}
+}
+
 func getLatestWorkflowTaskV4(workflowName string) (*commonmodels.WorkflowTask, error) {
 	resp, err := commonrepo.NewworkflowTaskv4Coll().GetLatest(workflowName)
 	if err != nil {
@@ -413,6 +496,50 @@ func cleanWorkflowV4Tasks(workflows []*commonmodels.WorkflowTask) {
 	}
 }
 
+// ListQueuedWorkflowTasks lists the tasks waiting in the queue, only the tasks of the project are listed if it is given,
+// their positions are still the ones in the whole queue.
+func ListQueuedWorkflowTasks(projectName string, logger *zap.SugaredLogger) ([]*workflowcontroller.QueuedTask, error) {
+	tasks, err := workflowcontroller.ListQueuedTasks()
+	if err != nil {
+		logger.Errorf("list queued tasks error: %v", err)
+		return nil, e.ErrListTasks.AddErr(err)
+	}
+	if projectName == "" {
+		return tasks, nil
+	}
+	resp := make([]*workflowcontroller.QueuedTask, 0)
+	for _, task := range tasks {
+		if task.ProjectName == projectName {
+			resp = append(resp, task)
+		}
+	}
+	return resp, nil
+}
+
+func UpdateQueuedTaskPriority(projectName, workflowName string, taskID int64, priority int, logger *zap.SugaredLogger) error {
+	if priority > config.MaxTaskPriority || priority < -config.MaxTaskPriority {
+		return e.ErrInvalidParam.AddDesc(fmt.Sprintf("priority should be between %d and %d", -config.MaxTaskPriority, config.MaxTaskPriority))
+	}
+	// the permission is checked by the project in query, the task must be in it.
+	task, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+	if err != nil || task.ProjectName != projectName {
+		return e.ErrUpdateQueuedTask.AddDesc(fmt.Sprintf("task %s:%d not found in project %s", workflowName, taskID, projectName))
+	}
+	if err := workflowcontroller.UpdateQueuedTaskPriority(workflowName, taskID, priority); err != nil {
+		logger.Errorf("update priority of task %s:%d error: %v", workflowName, taskID, err)
+		return e.ErrUpdateQueuedTask.AddDesc(fmt.Sprintf("task %s:%d is not waiting in the queue", workflowName, taskID))
+	}
+	return nil
+}
+
+func PreemptQueuedTask(workflowName string, taskID int64, logger *zap.SugaredLogger) error {
+	if err := workflowcontroller.PreemptQueuedTask(workflowName, taskID); err != nil {
+		logger.Errorf("preempt task %s:%d error: %v", workflowName, taskID, err)
+		return e.ErrUpdateQueuedTask.AddDesc(fmt.Sprintf("task %s:%d is not waiting in the queue", workflowName, taskID))
+	}
+	return nil
+}
+
 func CancelWorkflowTaskV4(userName, workflowName string, taskID int64, logger *zap.SugaredLogger) error {
 	if err := workflowcontroller.CancelWorkflowTask(userName, workflowName, taskID, logger); err != nil {
 		logger.Errorf("cancel workflowTaskV4 error: %s", err)
@@ -421,6 +548,34 @@ func CancelWorkflowTaskV4(userName, workflowName string, taskID int64, logger *z
 	return nil
 }
 
+// GetWorkflowTaskV4JobDebugContext returns the rendered context of a job in the task, which is used to run the job locally.
+func GetWorkflowTaskV4JobDebugContext(workflowName string, taskID int64, jobName string, logger *zap.SugaredLogger) (*types.JobDebugContext, error) {
+	task, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+	if err != nil {
+		logger.Errorf("find workflowTaskV4 error: %s", err)
+		return nil, err
+	}
+	workflowCtx := &commonmodels.WorkflowTaskCtx{
+		WorkflowName: task.WorkflowName,
+		ProjectName:  task.ProjectName,
+		TaskID:       task.TaskID,
+		Workspace:    "/workspace",
+	}
+	for _, stage := range task.Stages {
+		for _, job := range stage.Jobs {
+			if job.Name != jobName {
+				continue
+			}
+			resp, err := jobcontroller.GetJobDebugContext(job, workflowCtx, logger)
+			if err != nil {
+				return nil, e.ErrInvalidParam.AddErr(err)
+			}
+			return resp, nil
+		}
+	}
+	return nil, e.ErrInvalidParam.AddDesc(fmt.Sprintf("job %s not found in task %d", jobName, taskID))
+}
+
 func GetWorkflowTaskV4(workflowName string, taskID int64, logger *zap.SugaredLogger) (*WorkflowTaskPreview, error) {
 	task, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
 	if err != nil {
@@ -441,6 +596,16 @@ func GetWorkflowTaskV4(workflowName string, taskID int64, logger *zap.SugaredLog
 		EndTime:             task.EndTime,
 		Error:               task.Error,
 		IsRestart:           task.IsRestart,
+		DAG:                 task.DAG,
+		Graph:               buildWorkflowTaskGraph(task.Stages),
+		RetryFrom:           task.RetryFrom,
+		Priority:            task.Priority,
+	}
+	if task.Status == config.StatusWaiting || task.Status == config.StatusBlocked {
+		if queuedTask := workflowcontroller.GetQueuedTask(task.WorkflowName, task.TaskID); queuedTask != nil {
+			resp.QueuePosition = queuedTask.Position
+			resp.EstimatedWait = queuedTask.EstimatedWait
+		}
 	}
 	for _, stage := range task.Stages {
 		resp.Stages = append(resp.Stages, &StageTaskPreview{
@@ -469,16 +634,95 @@ func ApproveStage(workflowName, stageName, userName, userID, comment string, tas
 	return nil
 }
 
+func DelegateApproval(workflowName, stageName, userName, userID, comment string, taskID int64, delegateTo *commonmodels.User, logger *zap.SugaredLogger) error {
+	if workflowName == "" || stageName == "" || taskID == 0 {
+		errMsg := fmt.Sprintf("can not find approved workflow: %s, taskID: %d,stage: %s", workflowName, taskID, stageName)
+		logger.Error(errMsg)
+		return e.ErrApproveTask.AddDesc(errMsg)
+	}
+	if err := workflowcontroller.DelegateApproval(workflowName, stageName, userName, userID, comment, taskID, delegateTo); err != nil {
+		logger.Error(err)
+		return e.ErrApproveTask.AddErr(err)
+	}
+	return nil
+}
+
+func HandleWebhookApprovalCallback(approvalID, signature string, body []byte, logger *zap.SugaredLogger) error {
+	if err := workflowcontroller.HandleWebhookApprovalCallback(approvalID, signature, body); err != nil {
+		logger.Errorf("handle callback of webhook approval %s error: %v", approvalID, err)
+		return e.ErrApproveTask.AddErr(err)
+	}
+	return nil
+}
+
+// ListPendingApprovals lists the approvals of all projects which are waiting for the decision of the user.
+func ListPendingApprovals(userID string, logger *zap.SugaredLogger) ([]*commonmodels.WorkflowApproval, error) {
+	resp, err := workflowcontroller.ListPendingApprovals(userID)
+	if err != nil {
+		logger.Errorf("list pending approvals of user %s error: %v", userID, err)
+		return nil, e.ErrListApproval.AddErr(err)
+	}
+	return resp, nil
+}
+
+func GetStageApproval(workflowName, stageName string, taskID int64, logger *zap.SugaredLogger) (*commonmodels.WorkflowApproval, error) {
+	resp, err := commonrepo.NewWorkflowApprovalColl().Find(workflowName, taskID, stageName)
+	if err != nil {
+		logger.Errorf("find approval of workflow %s task %d stage %s error: %v", workflowName, taskID, stageName, err)
+		return nil, e.ErrGetApproval.AddErr(err)
+	}
+	return resp, nil
+}
+
+func buildWorkflowTaskGraph(stages []*commonmodels.StageTask) *WorkflowTaskGraph {
+	resp := &WorkflowTaskGraph{
+		Nodes: []*JobGraphNode{},
+		Edges: []*JobGraphEdge{},
+	}
+	originJobs := make(map[string][]string)
+	for _, stage := range stages {
+		for _, job := range stage.Jobs {
+			originName := job.OriginName
+			if originName == "" {
+				originName = job.Name
+			}
+			originJobs[originName] = append(originJobs[originName], job.Name)
+			resp.Nodes = append(resp.Nodes, &JobGraphNode{
+				Name:       job.Name,
+				OriginName: originName,
+				StageName:  stage.Name,
+				JobType:    job.JobType,
+				Status:     job.Status,
+			})
+		}
+	}
+	for _, stage := range stages {
+		for _, job := range stage.Jobs {
+			for _, dependency := range job.DependsOn {
+				for _, upstream := range originJobs[dependency] {
+					resp.Edges = append(resp.Edges, &JobGraphEdge{From: upstream, To: job.Name})
+				}
+			}
+		}
+	}
+	return resp
+}
+
 func jobsToJobPreviews(jobs []*commonmodels.JobTask, context map[string]string) []*JobTaskPreview {
 	resp := []*JobTaskPreview{}
 	for _, job := range jobs {
 		jobPreview := &JobTaskPreview{
-			Name:      job.Name,
-			Status:    job.Status,
-			StartTime: job.StartTime,
-			EndTime:   job.EndTime,
-			Error:     job.Error,
-			JobType:   job.JobType,
+			Name:       job.Name,
+			Status:     job.Status,
+			StartTime:  job.StartTime,
+			EndTime:    job.EndTime,
+			Error:      job.Error,
+			JobType:    job.JobType,
+			OriginName: job.OriginName,
+			DependsOn:  job.DependsOn,
+			Condition:  job.Condition,
+			Matrix:     job.Matrix,
+			ReusedFrom: job.ReusedFrom,
 		}
 		switch job.JobType {
 		case string(config.JobFreestyle):
@@ -531,6 +775,22 @@ func jobsToJobPreviews(jobs []*commonmodels.JobTask, context map[string]string)
 				}
 			}
 			jobPreview.Spec = spec
+		case string(config.JobZadigImageScan):
+			spec := &ImageScanJobSpec{}
+			taskJobSpec := &commonmodels.JobTaskFreestyleSpec{}
+			if err := commonmodels.IToi(job.Spec, taskJobSpec); err != nil {
+				continue
+			}
+
+			for _, step := range taskJobSpec.Steps {
+				if step.StepType == config.StepImageScan {
+					stepSpec := &stepspec.StepImageScanSpec{}
+					commonmodels.IToi(step.Spec, &stepSpec)
+					spec.Targets = stepSpec.Targets
+					break
+				}
+			}
+			jobPreview.Spec = spec
 		case string(config.JobZadigTesting):
 			spec := &ZadigTestingJobSpec{}
 			jobPreview.Spec = spec
@@ -0,0 +1,210 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/instantmessage"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller"
+	"github.com/koderover/zadig/pkg/microservice/user/core"
+	"github.com/koderover/zadig/pkg/microservice/user/core/repository/orm"
+	"github.com/koderover/zadig/pkg/setting"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+// RetryWorkflowTaskV4 creates a new task from a finished but not passed task. the passed jobs keep their
+// results and outputs and are not run again, the others are queued again with the global context of the
+// original task. if restartJob is set, the job and all jobs after it are run again too.
+func RetryWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflowName string, taskID int64, restartJob string, logger *zap.SugaredLogger) (*CreateTaskV4Resp, error) {
+	task, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+	if err != nil {
+		logger.Errorf("find workflowTaskV4 error: %s", err)
+		return nil, e.ErrGetTask.AddErr(err)
+	}
+	switch task.Status {
+	case config.StatusFailed, config.StatusCancelled, config.StatusTimeout, config.StatusReject:
+	default:
+		return nil, e.ErrRestartTask.AddDesc(fmt.Sprintf("task with status %s can not be retried", task.Status))
+	}
+
+	rerun, err := getRerunJobs(task, restartJob)
+	if err != nil {
+		return nil, e.ErrRestartTask.AddErr(err)
+	}
+	if len(rerun) == 0 {
+		return nil, e.ErrRestartTask.AddDesc("all jobs of the task passed")
+	}
+	for _, stage := range task.Stages {
+		resetStageTask(stage, task, rerun)
+	}
+
+	if args.UserID != "" {
+		userInfo, err := orm.GetUserByUid(args.UserID, core.DB)
+		if err != nil || userInfo == nil {
+			return nil, e.ErrRestartTask.AddDesc("failed to get user info by uid")
+		}
+		task.TaskCreatorEmail = userInfo.Email
+		task.TaskCreatorPhone = userInfo.Phone
+	}
+	nextTaskID, err := commonrepo.NewCounterColl().GetNextSeq(fmt.Sprintf(setting.WorkflowTaskV4Fmt, task.WorkflowName))
+	if err != nil {
+		logger.Errorf("Counter.GetNextSeq error: %v", err)
+		return nil, e.ErrGetCounter.AddDesc(err.Error())
+	}
+
+	task.ID = primitive.NilObjectID
+	task.TaskID = nextTaskID
+	task.RetryFrom = taskID
+	task.TaskCreator = args.Name
+	task.TaskRevoker = args.Name
+	task.CreateTime = time.Now().Unix()
+	task.StartTime = time.Now().Unix()
+	task.EndTime = 0
+	task.Error = ""
+	task.IsArchived = false
+	task.Status = config.StatusCreated
+	if err := instantmessage.NewWeChatClient().SendWorkflowTaskNotifications(task); err != nil {
+		logger.Errorf("send workflow task notification failed, error: %v", err)
+	}
+
+	if err := workflowcontroller.CreateTask(task); err != nil {
+		logger.Errorf("create workflow task error: %v", err)
+		return nil, e.ErrRestartTask.AddErr(err)
+	}
+	return &CreateTaskV4Resp{
+		ProjectName:  task.ProjectName,
+		WorkflowName: task.WorkflowName,
+		TaskID:       task.TaskID,
+	}, nil
+}
+
+// getRerunJobs returns the names of the jobs to run again, which are the jobs not passed, and the
+// restart job with the jobs after it, or its downstream jobs if the task runs as a dag.
+func getRerunJobs(task *commonmodels.WorkflowTask, restartJob string) (map[string]bool, error) {
+	jobs := []*commonmodels.JobTask{}
+	for _, stage := range task.Stages {
+		jobs = append(jobs, stage.Jobs...)
+	}
+
+	rerun := make(map[string]bool)
+	for _, job := range jobs {
+		if job.Status != config.StatusPassed {
+			rerun[job.Name] = true
+		}
+	}
+	if restartJob == "" {
+		return rerun, nil
+	}
+
+	// the restart job may be a matrix job, all of its job tasks are run again.
+	restartIndex := -1
+	origins := map[string]bool{}
+	for i, job := range jobs {
+		if job.Name == restartJob || job.OriginName == restartJob {
+			if restartIndex < 0 {
+				restartIndex = i
+			}
+			rerun[job.Name] = true
+			origins[jobOriginName(job)] = true
+		}
+	}
+	if restartIndex < 0 {
+		return nil, fmt.Errorf("job %s not found in task %d", restartJob, task.TaskID)
+	}
+	if !task.DAG {
+		for _, job := range jobs[restartIndex:] {
+			rerun[job.Name] = true
+		}
+		return rerun, nil
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, job := range jobs {
+			if origins[jobOriginName(job)] {
+				continue
+			}
+			for _, dependency := range job.DependsOn {
+				if origins[dependency] {
+					origins[jobOriginName(job)] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	for _, job := range jobs {
+		if origins[jobOriginName(job)] {
+			rerun[job.Name] = true
+		}
+	}
+	return rerun, nil
+}
+
+func resetStageTask(stage *commonmodels.StageTask, task *commonmodels.WorkflowTask, rerun map[string]bool) {
+	reused := true
+	for _, job := range stage.Jobs {
+		if !rerun[job.Name] {
+			// keep the id of the task in which the job ran if it was reused before.
+			if job.ReusedFrom == 0 {
+				job.ReusedFrom = task.TaskID
+			}
+			continue
+		}
+		reused = false
+		job.Status = ""
+		job.StartTime = 0
+		job.EndTime = 0
+		job.Error = ""
+		job.K8sJobName = ""
+		job.Condition = nil
+		job.FailureClass = ""
+		job.Attempts = nil
+		job.ReusedFrom = 0
+	}
+	if reused {
+		stage.Status = config.StatusPassed
+		return
+	}
+	stage.Status = ""
+	stage.StartTime = 0
+	stage.EndTime = 0
+	stage.Error = ""
+	// the stage should be approved again, reset the approval to the one in workflow args.
+	if task.OriginWorkflowArgs == nil {
+		return
+	}
+	for _, originStage := range task.OriginWorkflowArgs.Stages {
+		if originStage.Name == stage.Name {
+			stage.Approval = originStage.Approval
+		}
+	}
+}
+
+func jobOriginName(job *commonmodels.JobTask) string {
+	if job.OriginName != "" {
+		return job.OriginName
+	}
+	return job.Name
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func newRetryJob(name, origin string, status config.Status, dependsOn ...string) *commonmodels.JobTask {
+	return &commonmodels.JobTask{Name: name, OriginName: origin, Status: status, DependsOn: dependsOn}
+}
+
+func newRetryTask(dag bool, stages ...[]*commonmodels.JobTask) *commonmodels.WorkflowTask {
+	task := &commonmodels.WorkflowTask{TaskID: 3, DAG: dag}
+	for _, jobs := range stages {
+		task.Stages = append(task.Stages, &commonmodels.StageTask{Jobs: jobs})
+	}
+	return task
+}
+
+var _ = Describe("Testing retrying workflow tasks", func() {
+
+	serialTask := func() *commonmodels.WorkflowTask {
+		return newRetryTask(false,
+			[]*commonmodels.JobTask{newRetryJob("build-a", "build", config.StatusPassed), newRetryJob("build-b", "build", config.StatusPassed)},
+			[]*commonmodels.JobTask{newRetryJob("test", "", config.StatusPassed), newRetryJob("lint", "", config.StatusFailed)},
+			[]*commonmodels.JobTask{newRetryJob("deploy", "", "")},
+		)
+	}
+	dagTask := func() *commonmodels.WorkflowTask {
+		return newRetryTask(true,
+			[]*commonmodels.JobTask{
+				newRetryJob("build-a", "build", config.StatusPassed),
+				newRetryJob("build-b", "build", config.StatusPassed),
+				newRetryJob("scan", "", config.StatusPassed),
+				newRetryJob("test", "", config.StatusPassed, "build"),
+				newRetryJob("deploy", "", config.StatusPassed, "test"),
+				newRetryJob("report", "", config.StatusPassed, "scan"),
+			},
+		)
+	}
+
+	table.DescribeTable("finding the jobs to run again",
+		func(task *commonmodels.WorkflowTask, restartJob string, expected []string) {
+			rerun, err := getRerunJobs(task, restartJob)
+			Expect(err).NotTo(HaveOccurred())
+			names := []string{}
+			for name := range rerun {
+				names = append(names, name)
+			}
+			Expect(names).To(ConsistOf(expected))
+		},
+		table.Entry("the jobs not passed", serialTask(), "", []string{"lint", "deploy"}),
+		table.Entry("the restart job and the jobs after it", serialTask(), "test", []string{"test", "lint", "deploy"}),
+		table.Entry("all job tasks of a restarted matrix job", serialTask(), "build", []string{"build-a", "build-b", "test", "lint", "deploy"}),
+		table.Entry("a job task of a matrix job and the jobs after it", serialTask(), "build-b", []string{"build-b", "test", "lint", "deploy"}),
+		table.Entry("nothing in a passed task", dagTask(), "", []string{}),
+		table.Entry("the downstream jobs in a dag", dagTask(), "test", []string{"test", "deploy"}),
+		table.Entry("the downstream jobs of a matrix job in a dag", dagTask(), "build", []string{"build-a", "build-b", "test", "deploy"}),
+		table.Entry("a job without downstream jobs in a dag", dagTask(), "report", []string{"report"}),
+	)
+
+	It("should fail to restart a job not in the task", func() {
+		_, err := getRerunJobs(serialTask(), "package")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reset the jobs to run again and keep the passed ones", func() {
+		task := serialTask()
+		task.Stages[0].Status = config.StatusPassed
+		task.Stages[1].Status = config.StatusFailed
+		task.Stages[1].Jobs[1].Error = "exit 1"
+		task.Stages[1].Jobs[1].FailureClass = config.FailureScript
+		task.Stages[1].Jobs[1].Attempts = []*commonmodels.JobAttempt{{Attempt: 1}}
+		task.Stages[1].Jobs[0].ReusedFrom = 1
+		task.OriginWorkflowArgs = &commonmodels.WorkflowV4{Stages: []*commonmodels.WorkflowStage{{Name: "check", Approval: &commonmodels.Approval{Enabled: true}}}}
+		task.Stages[1].Name = "check"
+
+		rerun, err := getRerunJobs(task, "")
+		Expect(err).NotTo(HaveOccurred())
+		for _, stage := range task.Stages {
+			resetStageTask(stage, task, rerun)
+		}
+
+		Expect(task.Stages[0].Status).To(Equal(config.StatusPassed))
+		Expect(task.Stages[0].Jobs[0].ReusedFrom).To(Equal(int64(3)))
+		Expect(task.Stages[1].Status).To(BeEmpty())
+		Expect(task.Stages[1].Approval.Enabled).To(BeTrue())
+		Expect(task.Stages[1].Jobs[0].Status).To(Equal(config.StatusPassed))
+		Expect(task.Stages[1].Jobs[0].ReusedFrom).To(Equal(int64(1)))
+		lint := task.Stages[1].Jobs[1]
+		Expect(lint.Status).To(BeEmpty())
+		Expect(lint.Error).To(BeEmpty())
+		Expect(lint.FailureClass).To(BeEmpty())
+		Expect(lint.Attempts).To(BeNil())
+	})
+})
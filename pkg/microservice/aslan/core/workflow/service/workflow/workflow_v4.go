@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -451,6 +452,19 @@ func LintWorkflowV4(workflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger
 			return e.ErrUpsertWorkflow.AddDesc("common workflow only support k8s and helm project")
 		}
 	}
+	if workflow.Priority > config.MaxTaskPriority || workflow.Priority < -config.MaxTaskPriority {
+		errMsg := fmt.Sprintf("priority should be between %d and %d", -config.MaxTaskPriority, config.MaxTaskPriority)
+		logger.Error(errMsg)
+		return e.ErrUpsertWorkflow.AddDesc(errMsg)
+	}
+	if workflow.Concurrency < 0 {
+		logger.Error("concurrency can not be negative")
+		return e.ErrUpsertWorkflow.AddDesc("concurrency can not be negative")
+	}
+	if err := lintWorkflowCodeSource(workflow.CodeSource); err != nil {
+		logger.Errorf("workflow code source error: %v", err)
+		return e.ErrUpsertWorkflow.AddDesc(fmt.Sprintf("workflow code source error: %v", err))
+	}
 	stageNameMap := make(map[string]bool)
 	jobNameMap := make(map[string]string)
 
@@ -487,6 +501,14 @@ func LintWorkflowV4(workflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger
 			}
 		}
 	}
+	if err := jobctl.LintJobDependencies(workflow); err != nil {
+		logger.Errorf("lint job dependencies failed: %v", err)
+		return e.ErrUpsertWorkflow.AddErr(err)
+	}
+	if err := jobctl.LintJobConditions(workflow); err != nil {
+		logger.Errorf("lint job conditions failed: %v", err)
+		return e.ErrUpsertWorkflow.AddErr(err)
+	}
 	return nil
 }
 
@@ -512,6 +534,24 @@ func lintApprovals(approval *commonmodels.Approval) error {
 		if len(approval.LarkApproval.ApproveUsers) == 0 {
 			return errors.New("num of approver is 0")
 		}
+	case config.DingTalkApproval, config.WeComApproval, config.WebhookApproval:
+		external := approval.GetExternalApproval()
+		if external == nil {
+			return errors.New("approval not found")
+		}
+		if external.ApprovalID == "" {
+			return errors.New("approval app is not specified")
+		}
+		if approval.Type != config.WebhookApproval && len(external.ApproveUsers) == 0 {
+			return errors.New("num of approver is 0")
+		}
+		if len(external.ApproveUsers) > 0 && external.NeededApprovers > len(external.ApproveUsers) {
+			return errors.New("all approve users should not less than needed approvers")
+		}
+		// dingtalk and wecom approvals are decided by any one or all of the approvers, n of m is not supported.
+		if approval.Type != config.WebhookApproval && external.NeededApprovers > 1 && external.NeededApprovers != len(external.ApproveUsers) {
+			return fmt.Errorf("needed approvers of %s approval should be 1 or all of the approve users", approval.Type)
+		}
 	default:
 		return errors.New("invalid approval type")
 	}
@@ -1158,12 +1198,21 @@ func getDefaultVars(workflow *commonmodels.WorkflowV4) []string {
 	vars := []string{}
 	vars = append(vars, fmt.Sprintf(setting.RenderValueTemplate, "project"))
 	vars = append(vars, fmt.Sprintf(setting.RenderValueTemplate, "workflow.name"))
+	vars = append(vars, fmt.Sprintf(setting.RenderValueTemplate, "workflow.task.id"))
 	vars = append(vars, fmt.Sprintf(setting.RenderValueTemplate, "workflow.task.creator"))
 	vars = append(vars, fmt.Sprintf(setting.RenderValueTemplate, "workflow.task.timestamp"))
 	for _, param := range workflow.Params {
 		vars = append(vars, fmt.Sprintf(setting.RenderValueTemplate, strings.Join([]string{"workflow", "params", param.Name}, ".")))
 	}
-	return vars
+	for _, kv := range workflow.KeyVals {
+		vars = append(vars, fmt.Sprintf(setting.RenderValueTemplate, "env."+kv.Key))
+	}
+	triggerVars := []string{}
+	for name := range (*commonmodels.HookPayload)(nil).TriggerVariables() {
+		triggerVars = append(triggerVars, fmt.Sprintf(setting.RenderValueTemplate, name))
+	}
+	sort.Strings(triggerVars)
+	return append(vars, triggerVars...)
 }
 
 func CheckShareStorageEnabled(clusterID, jobType, identifyName, project string, logger *zap.SugaredLogger) (bool, error) {
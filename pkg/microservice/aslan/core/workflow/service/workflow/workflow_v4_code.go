@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/fs"
+	jobctl "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow/job"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/shared/client/systemconfig"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+func lintWorkflowCodeSource(source *commonmodels.WorkflowCodeSource) error {
+	if !source.IsEnabled() {
+		return nil
+	}
+	if source.RepoName == "" || source.GetRepoNamespace() == "" {
+		return fmt.Errorf("repository should not be empty")
+	}
+	if source.Branch == "" || source.Path == "" {
+		return fmt.Errorf("branch and path should not be empty")
+	}
+	codehost, err := systemconfig.New().GetCodeHost(source.CodehostID)
+	if err != nil {
+		return fmt.Errorf("failed to get codehost %d: %v", source.CodehostID, err)
+	}
+	// files are loaded by the tree getters, which only support github and gitlab.
+	if codehost.Type != setting.SourceFromGithub && codehost.Type != setting.SourceFromGitlab {
+		return fmt.Errorf("codehost type %s is not supported", codehost.Type)
+	}
+	return nil
+}
+
+// LoadWorkflowV4FromCode loads the workflow defined in the repository at the ref, and validates it like the workflows
+// edited in zadig. The identity, hooks and code source of the workflow are always kept from zadig.
+func LoadWorkflowV4FromCode(workflow *commonmodels.WorkflowV4, ref string, logger *zap.SugaredLogger) (*commonmodels.WorkflowV4, error) {
+	source := workflow.CodeSource
+	if !source.IsEnabled() {
+		return nil, fmt.Errorf("workflow %s is not defined in a repository", workflow.Name)
+	}
+	content, err := fs.DownloadFileFromSource(&fs.DownloadFromSourceArgs{
+		CodehostID: source.CodehostID,
+		Owner:      source.RepoOwner,
+		Namespace:  source.RepoNamespace,
+		Repo:       source.RepoName,
+		Path:       source.Path,
+		Branch:     ref,
+	})
+	if err != nil {
+		logger.Errorf("Failed to load workflow file %s at %s, err: %s", source.Path, ref, err)
+		return nil, fmt.Errorf("failed to load workflow file %s at %s: %v", source.Path, ref, err)
+	}
+	codeWorkflow := &commonmodels.WorkflowV4{}
+	if err := yaml.Unmarshal(content, codeWorkflow); err != nil {
+		return nil, fmt.Errorf("invalid workflow file %s at %s: %v", source.Path, ref, err)
+	}
+
+	codeWorkflow.ID = workflow.ID
+	codeWorkflow.Name = workflow.Name
+	codeWorkflow.Project = workflow.Project
+	if codeWorkflow.DisplayName == "" {
+		codeWorkflow.DisplayName = workflow.DisplayName
+	}
+	codeWorkflow.CreatedBy = workflow.CreatedBy
+	codeWorkflow.CreateTime = workflow.CreateTime
+	codeWorkflow.UpdatedBy = workflow.UpdatedBy
+	codeWorkflow.UpdateTime = workflow.UpdateTime
+	codeWorkflow.HookCtls = workflow.HookCtls
+	codeWorkflow.JiraHookCtls = workflow.JiraHookCtls
+	codeWorkflow.GeneralHookCtls = workflow.GeneralHookCtls
+	codeWorkflow.CodeSource = workflow.CodeSource
+
+	if err := LintWorkflowV4(codeWorkflow, logger); err != nil {
+		return nil, err
+	}
+	for _, stage := range codeWorkflow.Stages {
+		for _, job := range stage.Jobs {
+			if err := jobctl.Instantiate(job, codeWorkflow); err != nil {
+				return nil, fmt.Errorf("failed to instantiate job %s: %v", job.Name, err)
+			}
+		}
+	}
+	return codeWorkflow, nil
+}
+
+// SyncWorkflowV4FromCode updates the workflow in zadig with the file in the repository at the ref,
+// the branch of the code source is used if the ref is empty.
+func SyncWorkflowV4FromCode(name, ref, user string, logger *zap.SugaredLogger) (*commonmodels.WorkflowV4, error) {
+	workflow, err := commonrepo.NewWorkflowV4Coll().Find(name)
+	if err != nil {
+		logger.Errorf("Failed to find WorkflowV4: %s, the error is: %v", name, err)
+		return nil, e.ErrFindWorkflow.AddErr(err)
+	}
+	if !workflow.CodeSource.IsEnabled() {
+		return nil, e.ErrInvalidParam.AddDesc(fmt.Sprintf("workflow %s is not defined in a repository", name))
+	}
+	if ref == "" {
+		ref = workflow.CodeSource.Branch
+	}
+	codeWorkflow, err := LoadWorkflowV4FromCode(workflow, ref, logger)
+	if err != nil {
+		return nil, e.ErrUpsertWorkflow.AddErr(err)
+	}
+	if err := UpdateWorkflowV4(name, user, codeWorkflow, logger); err != nil {
+		return nil, err
+	}
+	return codeWorkflow, nil
+}
@@ -53,6 +53,12 @@ func CreateTesting(username string, testing *commonmodels.Testing, log *zap.Suga
 	if err := commonutil.CheckDefineResourceParam(testing.PreTest.ResReq, testing.PreTest.ResReqSpec); err != nil {
 		return e.ErrCreateTestModule.AddDesc(err.Error())
 	}
+	if err := commonutil.CheckServiceContainers(testing.PreTest.ServiceContainers); err != nil {
+		return e.ErrCreateTestModule.AddDesc(err.Error())
+	}
+	if err := commonutil.CheckCoverageReport(testing.CoverageReport); err != nil {
+		return e.ErrCreateTestModule.AddDesc(err.Error())
+	}
 	err := HandleCronjob(testing, log)
 	if err != nil {
 		return e.ErrCreateTestModule.AddErr(err)
@@ -113,6 +119,12 @@ func UpdateTesting(username string, testing *commonmodels.Testing, log *zap.Suga
 	if err := commonutil.CheckDefineResourceParam(testing.PreTest.ResReq, testing.PreTest.ResReqSpec); err != nil {
 		return e.ErrUpdateTestModule.AddDesc(err.Error())
 	}
+	if err := commonutil.CheckServiceContainers(testing.PreTest.ServiceContainers); err != nil {
+		return e.ErrUpdateTestModule.AddDesc(err.Error())
+	}
+	if err := commonutil.CheckCoverageReport(testing.CoverageReport); err != nil {
+		return e.ErrUpdateTestModule.AddDesc(err.Error())
+	}
 	err := HandleCronjob(testing, log)
 	if err != nil {
 		return e.ErrUpdateTestModule.AddErr(err)
@@ -140,20 +152,22 @@ func UpdateTesting(username string, testing *commonmodels.Testing, log *zap.Suga
 }
 
 type TestingOpt struct {
-	Name        string                     `bson:"name"                   json:"name"`
-	ProductName string                     `bson:"product_name"           json:"product_name"`
-	Desc        string                     `bson:"desc"                   json:"desc"`
-	UpdateTime  int64                      `bson:"update_time"            json:"update_time"`
-	UpdateBy    string                     `bson:"update_by"              json:"update_by"`
-	TestCaseNum int                        `bson:"-"                      json:"test_case_num,omitempty"`
-	ExecuteNum  int                        `bson:"-"                      json:"execute_num,omitempty"`
-	PassRate    float64                    `bson:"-"                      json:"pass_rate,omitempty"`
-	AvgDuration float64                    `bson:"-"                      json:"avg_duration,omitempty"`
-	Workflows   []*commonmodels.Workflow   `bson:"-"                      json:"workflows,omitempty"`
-	Schedules   *commonmodels.ScheduleCtrl `bson:"-"                      json:"schedules,omitempty"`
-	Repos       []*types.Repository        `bson:"repos"                  json:"repos"`
-	KeyVals     []*commonmodels.KeyVal     `bson:"key_vals"               json:"key_vals"`
-	ClusterID   string                     `bson:"cluster_id"             json:"cluster_id"`
+	Name           string                     `bson:"name"                   json:"name"`
+	ProductName    string                     `bson:"product_name"           json:"product_name"`
+	Desc           string                     `bson:"desc"                   json:"desc"`
+	UpdateTime     int64                      `bson:"update_time"            json:"update_time"`
+	UpdateBy       string                     `bson:"update_by"              json:"update_by"`
+	TestCaseNum    int                        `bson:"-"                      json:"test_case_num,omitempty"`
+	ExecuteNum     int                        `bson:"-"                      json:"execute_num,omitempty"`
+	PassRate       float64                    `bson:"-"                      json:"pass_rate,omitempty"`
+	LineCoverage   float64                    `bson:"-"                      json:"line_coverage,omitempty"`
+	BranchCoverage float64                    `bson:"-"                      json:"branch_coverage,omitempty"`
+	AvgDuration    float64                    `bson:"-"                      json:"avg_duration,omitempty"`
+	Workflows      []*commonmodels.Workflow   `bson:"-"                      json:"workflows,omitempty"`
+	Schedules      *commonmodels.ScheduleCtrl `bson:"-"                      json:"schedules,omitempty"`
+	Repos          []*types.Repository        `bson:"repos"                  json:"repos"`
+	KeyVals        []*commonmodels.KeyVal     `bson:"key_vals"               json:"key_vals"`
+	ClusterID      string                     `bson:"cluster_id"             json:"cluster_id"`
 }
 
 func ListTestingOpt(productNames []string, testType string, log *zap.SugaredLogger) ([]*TestingOpt, error) {
@@ -186,6 +200,8 @@ func ListTestingOpt(productNames []string, testType string, log *zap.SugaredLogg
 					avgDuration := float64(testTaskStat.TotalDuration) / float64(totalNum)
 					testing.AvgDuration = decimal(avgDuration)
 				}
+				testing.LineCoverage = testTaskStat.LineCoverage
+				testing.BranchCoverage = testTaskStat.BranchCoverage
 
 				testing.Workflows, _ = ListAllWorkflows(testing.Name, log)
 			}
@@ -196,20 +212,22 @@ func ListTestingOpt(productNames []string, testType string, log *zap.SugaredLogg
 	testingOpts := make([]*TestingOpt, 0)
 	for _, t := range allTestings {
 		testingOpts = append(testingOpts, &TestingOpt{
-			Name:        t.Name,
-			ProductName: t.ProductName,
-			Desc:        t.Desc,
-			UpdateTime:  t.UpdateTime,
-			UpdateBy:    t.UpdateBy,
-			TestCaseNum: t.TestCaseNum,
-			ExecuteNum:  t.ExecuteNum,
-			PassRate:    t.PassRate,
-			AvgDuration: t.AvgDuration,
-			Workflows:   t.Workflows,
-			Schedules:   t.Schedules,
-			Repos:       t.Repos,
-			KeyVals:     t.PreTest.Envs,
-			ClusterID:   t.PreTest.ClusterID,
+			Name:           t.Name,
+			ProductName:    t.ProductName,
+			Desc:           t.Desc,
+			UpdateTime:     t.UpdateTime,
+			UpdateBy:       t.UpdateBy,
+			TestCaseNum:    t.TestCaseNum,
+			ExecuteNum:     t.ExecuteNum,
+			PassRate:       t.PassRate,
+			LineCoverage:   t.LineCoverage,
+			BranchCoverage: t.BranchCoverage,
+			AvgDuration:    t.AvgDuration,
+			Workflows:      t.Workflows,
+			Schedules:      t.Schedules,
+			Repos:          t.Repos,
+			KeyVals:        t.PreTest.Envs,
+			ClusterID:      t.PreTest.ClusterID,
 		})
 	}
 
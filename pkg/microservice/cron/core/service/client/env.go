@@ -230,6 +230,57 @@ func (c *Client) SyncEnvResource(productName, envName, resType, resName string,
 	return nil
 }
 
+func (c *Client) ListEnvSleepConfigs(log *zap.SugaredLogger) ([]*service.EnvSleepConfig, error) {
+	var (
+		err  error
+		resp = make([]*service.EnvSleepConfig, 0)
+	)
+	url := fmt.Sprintf("%s/environment/cron/sleep", c.APIBase)
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		log.Errorf("ListEnvSleepConfigs new http request error: %v", err)
+		return nil, err
+	}
+
+	var ret *http.Response
+	if ret, err = c.Conn.Do(request); err == nil {
+		defer func() { _ = ret.Body.Close() }()
+		var body []byte
+		body, err = io.ReadAll(ret.Body)
+		if err == nil {
+			if err = json.Unmarshal(body, &resp); err == nil {
+				return resp, nil
+			}
+		}
+	}
+	return resp, errors.WithMessage(err, "failed to list env sleep configs")
+}
+
+// SleepEnv scales the workloads of the env to zero when sleep is true, otherwise it wakes the env.
+func (c *Client) SleepEnv(productName, envName string, sleep bool, log *zap.SugaredLogger) error {
+	action := "wake"
+	if sleep {
+		action = "sleep"
+	}
+	url := fmt.Sprintf("%s/environment/environments/%s/%s?projectName=%s", c.APIBase, envName, action, productName)
+	request, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		log.Errorf("SleepEnv new http request error: %v", err)
+		return err
+	}
+
+	ret, err := c.Conn.Do(request)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to %s env", action)
+	}
+	defer func() { _ = ret.Body.Close() }()
+	if ret.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(ret.Body)
+		return fmt.Errorf("failed to %s env, status: %d, response: %s", action, ret.StatusCode, string(body))
+	}
+	return nil
+}
+
 func (c *Client) GetHostInfo(hostID string, log *zap.SugaredLogger) (*service.PrivateKey, error) {
 	var (
 		err        error
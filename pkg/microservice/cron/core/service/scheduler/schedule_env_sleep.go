@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"time"
+
+	"github.com/rfyiamcool/cronlib"
+	"go.uber.org/zap"
+)
+
+// EnvSleepCheckInterval is the interval in minutes to check whether the environments should sleep or wake.
+const EnvSleepCheckInterval = 1
+
+// UpsertEnvSleepScheduler sleeps or wakes the environments whose cron expressions fired since the last check,
+// the expressions are evaluated in the time zone of each environment.
+func (c *CronClient) UpsertEnvSleepScheduler(log *zap.SugaredLogger) {
+	now := time.Now()
+	last := c.lastEnvSleepCheck
+	c.lastEnvSleepCheck = now
+	if last.IsZero() {
+		return
+	}
+
+	configs, err := c.AslanCli.ListEnvSleepConfigs(log)
+	if err != nil {
+		log.Errorf("failed to list env sleep configs: %s", err)
+		return
+	}
+	for _, cfg := range configs {
+		loc, err := time.LoadLocation(cfg.TimeZone)
+		if err != nil {
+			log.Errorf("invalid time zone %s of env %s/%s: %s", cfg.TimeZone, cfg.ProductName, cfg.EnvName, err)
+			continue
+		}
+
+		switch {
+		case !cfg.Sleeping && cronFired(cfg.SleepCron, last, now, loc, log):
+			log.Infof("env %s/%s goes to sleep on schedule", cfg.ProductName, cfg.EnvName)
+			if err := c.AslanCli.SleepEnv(cfg.ProductName, cfg.EnvName, true, log); err != nil {
+				log.Errorf("failed to sleep env %s/%s: %s", cfg.ProductName, cfg.EnvName, err)
+			}
+		case cfg.Sleeping && cronFired(cfg.WakeCron, last, now, loc, log):
+			log.Infof("env %s/%s wakes on schedule", cfg.ProductName, cfg.EnvName)
+			if err := c.AslanCli.SleepEnv(cfg.ProductName, cfg.EnvName, false, log); err != nil {
+				log.Errorf("failed to wake env %s/%s: %s", cfg.ProductName, cfg.EnvName, err)
+			}
+		}
+	}
+}
+
+// cronFired checks whether the standard cron expression has an activation time in (last, now].
+func cronFired(spec string, last, now time.Time, loc *time.Location, log *zap.SugaredLogger) bool {
+	if spec == "" {
+		return false
+	}
+	schedule, err := cronlib.ParseStandard(spec)
+	if err != nil {
+		log.Errorf("invalid cron expression %s: %s", spec, err)
+		return false
+	}
+	return !schedule.Next(last.In(loc)).After(now)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+var _ = Describe("Testing env sleep scheduler", func() {
+
+	cst := time.FixedZone("CST", 8*60*60)
+	at := func(day, hour, minute int) time.Time {
+		// 2022-08-01 is a monday.
+		return time.Date(2022, 8, day, hour, minute, 0, 0, cst)
+	}
+
+	table.DescribeTable("checking whether the cron expression fired",
+		func(spec string, last, now time.Time, loc *time.Location, expected bool) {
+			Expect(cronFired(spec, last, now, loc, log.NopSugaredLogger())).To(Equal(expected))
+		},
+		table.Entry("fired at now", "0 22 * * *", at(1, 21, 59), at(1, 22, 0), cst, true),
+		table.Entry("fired between the checks", "0 22 * * *", at(1, 21, 30), at(1, 22, 30), cst, true),
+		table.Entry("fired at the last check", "0 22 * * *", at(1, 22, 0), at(1, 22, 1), cst, false),
+		table.Entry("not fired yet", "0 22 * * *", at(1, 21, 0), at(1, 21, 59), cst, false),
+		table.Entry("fired while the scheduler was down", "0 22 * * *", at(1, 21, 0), at(2, 23, 0), cst, true),
+		table.Entry("fired in the time zone of the env", "0 22 * * *", at(1, 21, 59).In(time.UTC), at(1, 22, 0).In(time.UTC), cst, true),
+		table.Entry("not fired in another time zone", "0 22 * * *", at(1, 21, 59), at(1, 22, 0), time.UTC, false),
+		table.Entry("fired on a weekday", "0 8 * * 1-5", at(5, 7, 59), at(5, 8, 0), cst, true),
+		table.Entry("not fired on weekends", "0 8 * * 1-5", at(6, 7, 59), at(6, 8, 0), cst, false),
+		table.Entry("empty expression", "", at(1, 21, 59), at(1, 22, 0), cst, false),
+		table.Entry("invalid expression", "0 25 * * *", at(1, 0, 0), at(2, 0, 0), cst, false),
+	)
+})
@@ -52,6 +52,7 @@ type CronClient struct {
 	enabledMap                   map[string]bool
 	lastPMProductRevisions       []*service.ProductRevision
 	lastHelmProductRevisions     []*service.ProductRevision
+	lastEnvSleepCheck            time.Time
 	log                          *zap.SugaredLogger
 }
 
@@ -133,6 +134,8 @@ const (
 	InitHelmEnvSyncValuesScheduler = "InitHelmEnvSyncValuesScheduler"
 
 	EnvResourceSyncScheduler = "EnvResourceSyncScheduler"
+
+	EnvSleepScheduler = "EnvSleepScheduler"
 )
 
 // NewCronClient ...
@@ -208,6 +211,8 @@ func (c *CronClient) Init() {
 	c.InitHelmEnvSyncValuesScheduler()
 	// sync env resources from git at regular intervals
 	c.InitEnvResourceSyncScheduler()
+	// sleep and wake envs on their schedules
+	c.InitEnvSleepScheduler()
 }
 
 func (c *CronClient) InitCleanJobScheduler() {
@@ -324,3 +329,11 @@ func (c *CronClient) InitEnvResourceSyncScheduler() {
 
 	c.Schedulers[EnvResourceSyncScheduler].Start()
 }
+
+func (c *CronClient) InitEnvSleepScheduler() {
+	c.Schedulers[EnvSleepScheduler] = gocron.NewScheduler()
+
+	c.Schedulers[EnvSleepScheduler].Every(EnvSleepCheckInterval).Minutes().Do(c.UpsertEnvSleepScheduler, c.log)
+
+	c.Schedulers[EnvSleepScheduler].Start()
+}
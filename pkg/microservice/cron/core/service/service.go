@@ -65,6 +65,15 @@ type EnvResource struct {
 	AutoSync       bool               `bson:"auto_sync"                 json:"auto_sync"`
 }
 
+type EnvSleepConfig struct {
+	ProductName string `json:"product_name"`
+	EnvName     string `json:"env_name"`
+	SleepCron   string `json:"sleep_cron"`
+	WakeCron    string `json:"wake_cron"`
+	TimeZone    string `json:"time_zone"`
+	Sleeping    bool   `json:"sleeping"`
+}
+
 type ProductResp struct {
 	ID          string      `json:"id"`
 	ProductName string      `json:"product_name"`
@@ -19,18 +19,24 @@ package job
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/config"
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/meta"
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/step"
+	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/s3"
 	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/util/redact"
 	"gopkg.in/yaml.v3"
 )
 
@@ -45,6 +51,9 @@ const (
 	// MaxContainerTerminationMessageLength is the upper bound any one container may write to
 	// its termination message path. Contents above this length will cause a failure.
 	MaxContainerTerminationMessageLength = 1024 * 4
+
+	// cleanupStepTimeout limits the always and on_failure steps without their own timeouts.
+	cleanupStepTimeout = 10 * time.Minute
 )
 
 func NewJob() (*Job, error) {
@@ -135,20 +144,96 @@ func (j *Job) Run(ctx context.Context) error {
 	if err := os.MkdirAll(job.JobOutputDir, os.ModePerm); err != nil {
 		return err
 	}
-	hasFailed := false
+	if err := j.waitServiceContainers(ctx); err != nil {
+		return err
+	}
+	hasFailed, notStopped := false, false
 	var respErr error
+	results := make([]*job.StepResult, 0, len(j.Ctx.Steps))
 	for _, stepInfo := range j.Ctx.Steps {
-		if hasFailed && !stepInfo.Onfailure {
+		result := &job.StepResult{Name: stepInfo.Name, Status: job.StepStatusSkipped}
+		results = append(results, result)
+		if notStopped {
+			log.Infof("Step %s is skipped, a previous step is still running.", stepInfo.Name)
 			continue
 		}
-		if err := step.RunStep(ctx, stepInfo, j.ActiveWorkspace, j.Ctx.Paths, j.getUserEnvs(), j.Ctx.SecretEnvs); err != nil {
+		if !stepInfo.GetRunIf().ShouldRun(hasFailed) {
+			log.Infof("Step %s is skipped, run if: %s.", stepInfo.Name, stepInfo.GetRunIf())
+			continue
+		}
+		result.StartTime = time.Now().Unix()
+		stepCtx, cancel := stepContext(ctx, stepInfo)
+		err := step.RunStep(stepCtx, stepInfo, j.ActiveWorkspace, j.Ctx.Paths, j.getUserEnvs(), j.Ctx.SecretEnvs)
+		cancel()
+		result.EndTime = time.Now().Unix()
+		switch {
+		case err == nil:
+			result.Status = job.StepStatusPassed
+		case errors.Is(err, context.DeadlineExceeded):
+			result.Status = job.StepStatusTimeout
+		default:
+			result.Status = job.StepStatusFailed
+		}
+		if err != nil {
+			result.Error = err.Error()
+			// the first failure fails the job, the errors of the steps running after it are only shown in the results.
+			if !hasFailed {
+				respErr = err
+			}
 			hasFailed = true
-			respErr = err
 		}
+		// a step still running may change the workspace, none of the following steps can run safely.
+		var notStoppedErr *step.StepNotStoppedError
+		notStopped = errors.As(err, &notStoppedErr)
+	}
+	if err := writeStepResults(results); err != nil {
+		log.Errorf("write step results error: %v", err)
 	}
 	return respErr
 }
 
+// stepContext returns the context to run the step with. The always and on_failure steps clean up after failures,
+// including the timeout or cancellation of the job, so they run with a fresh context which has its own timeout.
+func stepContext(ctx context.Context, stepInfo *meta.Step) (context.Context, context.CancelFunc) {
+	if stepInfo.GetRunIf() != job.RunIfAlways && stepInfo.GetRunIf() != job.RunIfOnFailure {
+		return context.WithCancel(ctx)
+	}
+	timeout := cleanupStepTimeout
+	if stepInfo.Timeout > 0 {
+		timeout = time.Duration(stepInfo.Timeout) * time.Minute
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// writeStepResults saves the results of the steps, they are written whether the job fails or not.
+func writeStepResults(results []*job.StepResult) error {
+	content, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(job.JobStepResultFile, content, 0644)
+}
+
+// waitServiceContainers waits for the ready file, which is created by aslan when all service containers are ready.
+func (j *Job) waitServiceContainers(ctx context.Context) error {
+	if len(j.Ctx.ServiceContainers) == 0 {
+		return nil
+	}
+	log.Infof("Waiting for service containers: %s.", strings.Join(j.Ctx.ServiceContainers, ", "))
+	start := time.Now()
+	for {
+		if _, err := os.Stat(job.ServicesReadyFile); err == nil {
+			log.Infof("Service containers are ready. Duration: %.2f seconds.", time.Since(start).Seconds())
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
 func (j *Job) AfterRun(ctx context.Context) error {
 	return j.collectJobResult(ctx)
 }
@@ -164,7 +249,9 @@ func (j *Job) collectJobResult(ctx context.Context) error {
 	}
 
 	if len(jsonOutput) > MaxContainerTerminationMessageLength {
-		return fmt.Errorf("termination message is above max allowed size 4096, caused by large task result")
+		if jsonOutput, err = j.spillJobOutputs(outputs); err != nil {
+			return err
+		}
 	}
 
 	f, err := os.OpenFile(job.JobTerminationFile, os.O_WRONLY|os.O_CREATE, 0666)
@@ -180,16 +267,86 @@ func (j *Job) collectJobResult(ctx context.Context) error {
 }
 
 func (j *Job) getJobOutputVars(ctx context.Context) ([]*job.JobOutput, error) {
+	return j.readJobOutputs(job.JobOutputDir)
+}
+
+func (j *Job) readJobOutputs(dir string) ([]*job.JobOutput, error) {
 	outputs := []*job.JobOutput{}
+	// outputs are shown in the task detail and passed to other jobs, secrets in them are masked like in logs.
+	redactor := redact.New(redact.EnvValues(j.Ctx.SecretEnvs)...)
 	for _, outputName := range j.Ctx.Outputs {
-		fileContents, err := ioutil.ReadFile(filepath.Join(job.JobOutputDir, outputName))
+		fileContents, err := ioutil.ReadFile(filepath.Join(dir, outputName))
 		if os.IsNotExist(err) {
 			continue
 		} else if err != nil {
 			return outputs, err
 		}
-		value := strings.Trim(string(fileContents), "\n")
+		value := redactor.Redact(strings.Trim(string(fileContents), "\n"))
 		outputs = append(outputs, &job.JobOutput{Name: outputName, Value: value})
 	}
 	return outputs, nil
 }
+
+// spillJobOutputs saves the largest outputs to object storage until the rest fit in the termination message,
+// the spilled outputs only keep their object keys.
+func (j *Job) spillJobOutputs(outputs []*job.JobOutput) ([]byte, error) {
+	storage := j.Ctx.OutputStorage
+	if storage == nil {
+		return nil, fmt.Errorf("termination message is above max allowed size %d, caused by large task result", MaxContainerTerminationMessageLength)
+	}
+	forcedPathStyle := true
+	if storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3.NewClient(storage.Endpoint, storage.Ak, storage.Sk, storage.Region, storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client to save outputs, err: %s", err)
+	}
+	prefix := path.Join(storage.Subfolder, j.Ctx.OutputObjectPrefix)
+	return spillOutputs(outputs, prefix, func(src, objectKey string) error {
+		return client.Upload(storage.Bucket, src, objectKey)
+	})
+}
+
+// spillOutputs uploads the values of the outputs instead of the output files, the values are redacted already.
+func spillOutputs(outputs []*job.JobOutput, prefix string, upload func(src, objectKey string) error) ([]byte, error) {
+	sorted := make([]*job.JobOutput, len(outputs))
+	copy(sorted, outputs)
+	sort.SliceStable(sorted, func(i, k int) bool {
+		return len(sorted[i].Value) > len(sorted[k].Value)
+	})
+	for _, output := range sorted {
+		objectKey := path.Join(prefix, output.Name)
+		if err := uploadOutput(output, objectKey, upload); err != nil {
+			return nil, fmt.Errorf("failed to save output %s, err: %s", output.Name, err)
+		}
+		log.Infof("output %s is saved to object storage since it is too large", output.Name)
+		output.Value = ""
+		output.ObjectKey = objectKey
+
+		jsonOutput, err := json.Marshal(outputs)
+		if err != nil {
+			return nil, err
+		}
+		if len(jsonOutput) <= MaxContainerTerminationMessageLength {
+			return jsonOutput, nil
+		}
+	}
+	return nil, fmt.Errorf("termination message is above max allowed size %d, caused by too many outputs", MaxContainerTerminationMessageLength)
+}
+
+func uploadOutput(output *job.JobOutput, objectKey string, upload func(src, objectKey string) error) error {
+	f, err := ioutil.TempFile("", "output-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(output.Value); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return upload(f.Name(), objectKey)
+}
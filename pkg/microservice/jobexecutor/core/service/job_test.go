@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/meta"
+	"github.com/koderover/zadig/pkg/util/redact"
+)
+
+var _ = Describe("Testing job outputs", func() {
+
+	It("should spill the redacted outputs", func() {
+		dir, err := ioutil.TempDir("", "outputs")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		secret := "s3cr3t-t0ken-value"
+		large := strings.Repeat("token="+secret+"\n", MaxContainerTerminationMessageLength/10)
+		Expect(ioutil.WriteFile(filepath.Join(dir, "REPORT"), []byte(large), 0644)).ShouldNot(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "VERSION"), []byte("1.0.0\n"), 0644)).ShouldNot(HaveOccurred())
+
+		j := &Job{Ctx: &meta.JobContext{
+			Outputs:    []string{"REPORT", "VERSION"},
+			SecretEnvs: []string{"TOKEN=" + secret},
+		}}
+		outputs, err := j.readJobOutputs(dir)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(outputs).To(HaveLen(2))
+
+		uploaded := map[string]string{}
+		_, err = spillOutputs(outputs, "outputs/task-1", func(src, objectKey string) error {
+			content, err := ioutil.ReadFile(src)
+			uploaded[objectKey] = string(content)
+			return err
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(uploaded).To(HaveLen(1))
+		Expect(uploaded["outputs/task-1/REPORT"]).To(ContainSubstring("token=" + redact.Mask))
+		Expect(uploaded["outputs/task-1/REPORT"]).NotTo(ContainSubstring(secret))
+		Expect(outputs[0].ObjectKey).To(Equal("outputs/task-1/REPORT"))
+		Expect(outputs[1].Value).To(Equal("1.0.0"))
+	})
+})
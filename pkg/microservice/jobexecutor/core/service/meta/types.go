@@ -16,6 +16,11 @@ limitations under the License.
 
 package meta
 
+import (
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
 type JobContext struct {
 	Name string `yaml:"name"`
 	// Workspace 容器工作目录 [必填]
@@ -33,13 +38,33 @@ type JobContext struct {
 
 	Steps   []*Step  `yaml:"steps"`
 	Outputs []string `yaml:"outputs"`
+	// OutputStorage 超出终止信息长度限制的输出保存到对象存储 [optional]
+	OutputStorage      *step.S3 `yaml:"output_storage"`
+	OutputObjectPrefix string   `yaml:"output_object_prefix"`
+	// ServiceContainers 服务容器就绪后才开始执行步骤 [optional]
+	ServiceContainers []string `yaml:"service_containers"`
 }
 
 type Step struct {
-	Name      string      `yaml:"name"`
-	StepType  string      `yaml:"type"`
-	Onfailure bool        `yaml:"on_failure"`
-	Spec      interface{} `yaml:"spec"`
+	Name      string `yaml:"name"`
+	StepType  string `yaml:"type"`
+	Onfailure bool   `yaml:"on_failure"`
+	// RunIf 根据之前步骤的结果决定是否执行, 默认 on_success [optional]
+	RunIf job.RunIf `yaml:"run_if"`
+	// Timeout 步骤超时时间, 单位分钟, 0 表示不限制 [optional]
+	Timeout int64       `yaml:"timeout"`
+	Spec    interface{} `yaml:"spec"`
+}
+
+// GetRunIf returns when the step runs, steps with on_failure set run always as before.
+func (s *Step) GetRunIf() job.RunIf {
+	if s.RunIf != "" {
+		return s.RunIf
+	}
+	if s.Onfailure {
+		return job.RunIfAlways
+	}
+	return job.RunIfOnSuccess
 }
 
 type EnvVar []string
@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/meta"
+	"github.com/koderover/zadig/pkg/types/job"
+)
+
+var _ = Describe("Testing run if of steps", func() {
+
+	table.DescribeTable("deciding whether a step runs",
+		func(stepInfo *meta.Step, failed, expected bool) {
+			Expect(stepInfo.GetRunIf().ShouldRun(failed)).To(Equal(expected))
+		},
+		table.Entry("default after success", &meta.Step{}, false, true),
+		table.Entry("default after failure", &meta.Step{}, true, false),
+		table.Entry("on_success after success", &meta.Step{RunIf: job.RunIfOnSuccess}, false, true),
+		table.Entry("on_success after failure", &meta.Step{RunIf: job.RunIfOnSuccess}, true, false),
+		table.Entry("on_failure after success", &meta.Step{RunIf: job.RunIfOnFailure}, false, false),
+		table.Entry("on_failure after failure", &meta.Step{RunIf: job.RunIfOnFailure}, true, true),
+		table.Entry("always after success", &meta.Step{RunIf: job.RunIfAlways}, false, true),
+		table.Entry("always after failure", &meta.Step{RunIf: job.RunIfAlways}, true, true),
+		table.Entry("legacy on_failure flag after success", &meta.Step{Onfailure: true}, false, true),
+		table.Entry("legacy on_failure flag after failure", &meta.Step{Onfailure: true}, true, true),
+		table.Entry("run_if over the legacy flag", &meta.Step{Onfailure: true, RunIf: job.RunIfOnSuccess}, true, false),
+	)
+
+	table.DescribeTable("running steps after the job is done",
+		func(stepInfo *meta.Step, keepsRunning bool, timeout time.Duration) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			stepCtx, stepCancel := stepContext(ctx, stepInfo)
+			defer stepCancel()
+			if !keepsRunning {
+				Expect(stepCtx.Err()).To(Equal(context.Canceled))
+				return
+			}
+			Expect(stepCtx.Err()).NotTo(HaveOccurred())
+			deadline, ok := stepCtx.Deadline()
+			Expect(ok).To(BeTrue())
+			Expect(time.Until(deadline)).To(BeNumerically("~", timeout, time.Second))
+		},
+		table.Entry("on_success step", &meta.Step{}, false, time.Duration(0)),
+		table.Entry("on_failure step", &meta.Step{RunIf: job.RunIfOnFailure}, true, cleanupStepTimeout),
+		table.Entry("always step", &meta.Step{RunIf: job.RunIfAlways}, true, cleanupStepTimeout),
+		table.Entry("always step with its own timeout", &meta.Step{RunIf: job.RunIfAlways, Timeout: 3}, true, 3*time.Minute),
+	)
+})
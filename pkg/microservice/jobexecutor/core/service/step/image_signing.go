@@ -0,0 +1,273 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/config"
+	"github.com/regclient/regclient/types/ref"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const provenanceBuildType = "https://github.com/koderover/zadig/workflow/v4"
+
+var cosignExe = filepath.Join(step.ImageSigningDir, "bin", "cosign")
+
+// slsaProvenance is the predicate of the SLSA v0.2 provenance attestation.
+type slsaProvenance struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	BuildType  string `json:"buildType"`
+	Invocation struct {
+		Parameters map[string]interface{} `json:"parameters"`
+	} `json:"invocation"`
+	Metadata struct {
+		BuildInvocationID string    `json:"buildInvocationId"`
+		BuildStartedOn    time.Time `json:"buildStartedOn"`
+		BuildFinishedOn   time.Time `json:"buildFinishedOn"`
+	} `json:"metadata"`
+	Materials []*provenanceMaterial `json:"materials"`
+}
+
+type provenanceMaterial struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// imageSigner signs the images pushed by a step with cosign, images are signed by digest so that the
+// signature does not follow the tag to another image.
+type imageSigner struct {
+	signing    *step.ImageSigning
+	registries []*step.RegistryNamespace
+	envs       []string
+	workspace  string
+	startTime  time.Time
+	client     *regclient.RegClient
+}
+
+func newImageSigner(signing *step.ImageSigning, registries []*step.RegistryNamespace, envs []string, workspace string, startTime time.Time) *imageSigner {
+	hosts := []config.Host{}
+	for _, reg := range registries {
+		hosts = append(hosts, getDockerHost(reg))
+	}
+	return &imageSigner{
+		signing:    signing,
+		registries: registries,
+		envs:       envs,
+		workspace:  workspace,
+		startTime:  startTime,
+		client:     regclient.New(regclient.WithConfigHosts(hosts)),
+	}
+}
+
+// resolveDigest returns the digest reference of the image.
+func (s *imageSigner) resolveDigest(ctx context.Context, image string) (string, string, error) {
+	imageRef, err := ref.New(image)
+	if err != nil {
+		return "", "", fmt.Errorf("parse image %s error: %s", image, err)
+	}
+	digest := imageRef.Digest
+	if digest == "" {
+		m, err := s.client.ManifestHead(ctx, imageRef)
+		if err != nil {
+			return "", "", fmt.Errorf("get manifest of image %s error: %s", image, err)
+		}
+		digest = m.GetDescriptor().Digest.String()
+	}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	return name + "@" + digest, digest, nil
+}
+
+// sign signs the image and attaches the provenance, materials are added to the materials of the provenance.
+func (s *imageSigner) sign(ctx context.Context, image string, materials ...*provenanceMaterial) error {
+	if s.signing == nil {
+		return nil
+	}
+	log.Infof("Signing image %s.", image)
+	digestRef, _, err := s.resolveDigest(ctx, image)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(step.ImageSigningDir, "work")
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	// the imported key is only kept during signing.
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := s.writeDockerConfig(filepath.Join(dir, "docker")); err != nil {
+		return fmt.Errorf("failed to write registry credential: %s", err)
+	}
+	password := make([]byte, 16)
+	if _, err := rand.Read(password); err != nil {
+		return err
+	}
+	envs := append(s.envs, "COSIGN_PASSWORD="+hex.EncodeToString(password), "DOCKER_CONFIG="+filepath.Join(dir, "docker"))
+
+	// cosign only signs with its own encrypted keys, so the key mounted from the secret is imported first.
+	keyFile := filepath.Join(step.ImageSigningKeyDir, s.signing.KeyID)
+	if out, err := s.cosign(ctx, envs, "import-key-pair", "--key", keyFile, "--output-key-prefix", filepath.Join(dir, "cosign")).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to import signing key: %s %s", err, out)
+	}
+	cosignKey := filepath.Join(dir, "cosign.key")
+
+	args := []string{"sign", "--yes", "--key", cosignKey, "--tlog-upload=false"}
+	if s.insecure(image) {
+		args = append(args, "--allow-insecure-registry")
+	}
+	if err := s.run(s.cosign(ctx, envs, append(args, digestRef)...)); err != nil {
+		return fmt.Errorf("failed to sign image %s: %s", image, err)
+	}
+	log.Infof("Image %s is signed.", digestRef)
+
+	if s.signing.Provenance == nil {
+		return nil
+	}
+	predicate, err := json.Marshal(s.provenance(materials))
+	if err != nil {
+		return err
+	}
+	predicateFile := filepath.Join(dir, "provenance.json")
+	if err := os.WriteFile(predicateFile, predicate, 0644); err != nil {
+		return err
+	}
+	args = []string{"attest", "--yes", "--key", cosignKey, "--tlog-upload=false", "--type", "slsaprovenance", "--predicate", predicateFile}
+	if s.insecure(image) {
+		args = append(args, "--allow-insecure-registry")
+	}
+	if err := s.run(s.cosign(ctx, envs, append(args, digestRef)...)); err != nil {
+		return fmt.Errorf("failed to attest provenance of image %s: %s", image, err)
+	}
+	log.Infof("Provenance of image %s is attached.", digestRef)
+	return nil
+}
+
+func (s *imageSigner) provenance(materials []*provenanceMaterial) *slsaProvenance {
+	p := s.signing.Provenance
+	resp := &slsaProvenance{BuildType: provenanceBuildType}
+	resp.Builder.ID = p.BuilderID
+	resp.Invocation.Parameters = map[string]interface{}{
+		"project":  p.ProjectName,
+		"workflow": p.WorkflowName,
+		"task_id":  p.TaskID,
+		"job":      p.JobName,
+	}
+	resp.Metadata.BuildInvocationID = p.InvocationURL
+	resp.Metadata.BuildStartedOn = s.startTime.UTC()
+	resp.Metadata.BuildFinishedOn = time.Now().UTC()
+
+	for _, repo := range p.Repos {
+		material := &provenanceMaterial{URI: fmt.Sprintf("git+%s@%s", repo.URL, repo.Ref)}
+		commitID := repo.CommitID
+		if commitID == "" {
+			commitID = s.headCommit(repo.CheckoutPath)
+		}
+		if commitID != "" {
+			material.Digest = map[string]string{"sha1": commitID}
+		}
+		resp.Materials = append(resp.Materials, material)
+	}
+	resp.Materials = append(resp.Materials, materials...)
+	return resp
+}
+
+// headCommit returns the commit checked out by the git step, it is empty if the repo is not checked out.
+func (s *imageSigner) headCommit(checkoutPath string) string {
+	out, err := exec.Command("git", "-C", filepath.Join(s.workspace, checkoutPath), "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// imageMaterial returns the image with its digest as a material of the provenance.
+func (s *imageSigner) imageMaterial(ctx context.Context, image string) *provenanceMaterial {
+	material := &provenanceMaterial{URI: "docker://" + image}
+	if _, digest, err := s.resolveDigest(ctx, image); err == nil {
+		if algorithm, encoded, ok := strings.Cut(digest, ":"); ok {
+			material.Digest = map[string]string{algorithm: encoded}
+		}
+	}
+	return material
+}
+
+func (s *imageSigner) insecure(image string) bool {
+	for _, reg := range s.registries {
+		host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(reg.RegAddr, "http://"), "https://"), "/")
+		if host != "" && strings.HasPrefix(image, host+"/") {
+			return !reg.TLSEnabled || strings.HasPrefix(reg.RegAddr, "http://")
+		}
+	}
+	return false
+}
+
+func (s *imageSigner) writeDockerConfig(dir string) error {
+	auths := map[string]interface{}{}
+	for _, reg := range s.registries {
+		if reg.AccessKey == "" {
+			continue
+		}
+		host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(reg.RegAddr, "http://"), "https://"), "/")
+		auth := base64.StdEncoding.EncodeToString([]byte(reg.AccessKey + ":" + reg.SecretKey))
+		auths[host] = map[string]string{"auth": auth}
+	}
+	content, err := json.Marshal(map[string]interface{}{"auths": auths})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "config.json"), content, 0600)
+}
+
+func (s *imageSigner) cosign(ctx context.Context, envs []string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, cosignExe, args...)
+	cmd.Dir = s.workspace
+	cmd.Env = envs
+	return cmd
+}
+
+func (s *imageSigner) run(cmd *exec.Cmd) error {
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
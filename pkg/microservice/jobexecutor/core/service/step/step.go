@@ -18,17 +18,24 @@ package step
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/config"
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/cmd"
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/meta"
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/util"
+	"github.com/koderover/zadig/pkg/util/redact"
 )
 
 type Step interface {
@@ -39,6 +46,12 @@ func RunStep(ctx context.Context, step *meta.Step, workspace, paths string, envs
 	var stepInstance Step
 	var err error
 
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(step.Timeout)*time.Minute)
+		defer cancel()
+	}
+
 	switch step.StepType {
 	case "shell":
 		stepInstance, err = NewShellStep(step.Spec, workspace, paths, envs, secretEnvs)
@@ -70,6 +83,11 @@ func RunStep(ctx context.Context, step *meta.Step, workspace, paths string, envs
 		if err != nil {
 			return err
 		}
+	case "coverage_report":
+		stepInstance, err = NewCoverageReportStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
 	case "tar_archive":
 		stepInstance, err = NewTararchiveStep(step.Spec, workspace, envs, secretEnvs)
 		if err != nil {
@@ -85,18 +103,123 @@ func RunStep(ctx context.Context, step *meta.Step, workspace, paths string, envs
 		if err != nil {
 			return err
 		}
+	case "upload_artifact", "download_artifact":
+		stepInstance, err = NewArtifactStep(step.Spec, step.StepType == "upload_artifact", workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
+	case "cache_restore", "cache_save":
+		stepInstance, err = NewCacheStep(step.Spec, step.StepType == "cache_save", workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
+	case "image_scan":
+		stepInstance, err = NewImageScanStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
 	default:
 		err := fmt.Errorf("step type: %s does not match any known type", step.StepType)
 		log.Error(err)
 		return err
 	}
-	if err := stepInstance.Run(ctx); err != nil {
+	if err := runWithDeadline(ctx, stepInstance); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("step %s timed out after %d minutes: %w", step.Name, step.Timeout, err)
+		}
 		log.Error(err)
 		return err
 	}
 	return nil
 }
 
+// stepKillGracePeriod is how long a step may take to return after its context is done before its processes are killed.
+var stepKillGracePeriod = 10 * time.Second
+
+// StepNotStoppedError means a step kept running after its context was done and its processes were killed, the job
+// must not run further steps since they may change the same workspace.
+type StepNotStoppedError struct {
+	Err error
+}
+
+func (e *StepNotStoppedError) Error() string {
+	return fmt.Sprintf("%s, the step can not be stopped", e.Err)
+}
+
+func (e *StepNotStoppedError) Unwrap() error {
+	return e.Err
+}
+
+// runWithDeadline returns when the step is done. When the context is done first, the step is given a grace period to
+// return, then the processes started by the executor are killed so that nothing keeps running in the workspace.
+func runWithDeadline(ctx context.Context, stepInstance Step) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- stepInstance.Run(ctx)
+	}()
+	select {
+	case err := <-errCh:
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	select {
+	case <-errCh:
+		return ctx.Err()
+	case <-time.After(stepKillGracePeriod):
+	}
+	log.Warnf("step does not return %s after its context is done, killing its processes", stepKillGracePeriod)
+	killChildProcesses()
+	select {
+	case <-errCh:
+		return ctx.Err()
+	case <-time.After(stepKillGracePeriod):
+		return &StepNotStoppedError{Err: ctx.Err()}
+	}
+}
+
+// killChildProcesses kills all the descendant processes of the executor.
+func killChildProcesses() {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		log.Errorf("failed to list processes: %s", err)
+		return
+	}
+	children := map[int][]int{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// the command name in the second field may contain spaces, the parent pid is the second field after it.
+		fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		ppid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		children[ppid] = append(children[ppid], pid)
+	}
+
+	pids := children[os.Getpid()]
+	for len(pids) > 0 {
+		pid := pids[0]
+		pids = append(pids[1:], children[pid]...)
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			log.Warnf("failed to kill process %d: %s", pid, err)
+		}
+	}
+}
+
 func prepareScriptsEnv() []string {
 	scripts := []string{}
 	scripts = append(scripts, "eval $(ssh-agent -s) > /dev/null")
@@ -112,6 +235,7 @@ func prepareScriptsEnv() []string {
 
 func handleCmdOutput(pipe io.ReadCloser, needPersistentLog bool, logFile string, secretEnvs []string) {
 	reader := bufio.NewReader(pipe)
+	redactor := redact.New(redact.EnvValues(secretEnvs)...)
 
 	for {
 		lineBytes, err := reader.ReadBytes('\n')
@@ -124,10 +248,11 @@ func handleCmdOutput(pipe io.ReadCloser, needPersistentLog bool, logFile string,
 			break
 		}
 
-		fmt.Printf("%s", maskSecretEnvs(string(lineBytes), secretEnvs))
+		line := redactor.Redact(string(lineBytes))
+		fmt.Printf("%s", line)
 
 		if needPersistentLog {
-			err := util.WriteFile(logFile, lineBytes, 0700)
+			err := util.WriteFile(logFile, []byte(line), 0700)
 			if err != nil {
 				log.Warnf("Failed to write file when processing cmd output: %s", err)
 			}
@@ -135,44 +260,6 @@ func handleCmdOutput(pipe io.ReadCloser, needPersistentLog bool, logFile string,
 	}
 }
 
-const (
-	secretEnvMask = "********"
-)
-
-func maskSecret(secrets []string, message string) string {
-	out := message
-
-	for _, val := range secrets {
-		if len(val) == 0 {
-			continue
-		}
-		out = strings.Replace(out, val, "********", -1)
-	}
-	return out
-}
-
-func maskSecretEnvs(message string, secretEnvs []string) string {
-	out := message
-
-	for _, val := range secretEnvs {
-		if len(val) == 0 {
-			continue
-		}
-		sl := strings.Split(val, "=")
-
-		if len(sl) != 2 {
-			continue
-		}
-
-		if len(sl[0]) == 0 || len(sl[1]) == 0 {
-			// invalid key value pair received
-			continue
-		}
-		out = strings.Replace(out, strings.Join(sl[1:], "="), secretEnvMask, -1)
-	}
-	return out
-}
-
 func isDirEmpty(dir string) bool {
 	f, err := os.Open(dir)
 	if err != nil {
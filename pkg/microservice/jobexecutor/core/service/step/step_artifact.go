@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+	"gopkg.in/yaml.v2"
+)
+
+type ArtifactStep struct {
+	spec       *step.StepArtifactSpec
+	upload     bool
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewArtifactStep(spec interface{}, upload bool, workspace string, envs, secretEnvs []string) (*ArtifactStep, error) {
+	artifactStep := &ArtifactStep{upload: upload, workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return artifactStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &artifactStep.spec); err != nil {
+		return artifactStep, fmt.Errorf("unmarshal spec %s to artifact spec failed", yamlBytes)
+	}
+	return artifactStep, nil
+}
+
+func (s *ArtifactStep) Run(ctx context.Context) error {
+	if len(s.spec.Artifacts) == 0 {
+		return nil
+	}
+	if s.spec.S3 == nil {
+		return fmt.Errorf("object storage of artifacts is not set")
+	}
+	client, err := newS3Client(s.spec.S3)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client for artifacts, err: %s", err)
+	}
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "artifact")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, artifact := range s.spec.Artifacts {
+		tarName := filepath.Join(tmpDir, artifact.Name+".tar.gz")
+		if s.upload {
+			if err := s.uploadArtifact(client, artifact, tarName); err != nil {
+				return fmt.Errorf("failed to upload artifact %s: %s", artifact.Name, err)
+			}
+			continue
+		}
+		if err := s.downloadArtifact(client, artifact, tarName); err != nil {
+			return fmt.Errorf("failed to download artifact %s of job %s: %s", artifact.Name, artifact.JobName, err)
+		}
+	}
+	return nil
+}
+
+// uploadArtifact archives the file or directory with its base name, so that it keeps the name after downloaded.
+func (s *ArtifactStep) uploadArtifact(client *s3.Client, artifact *step.Artifact, tarName string) error {
+	log.Infof("Start uploading artifact %s from %s.", artifact.Name, artifact.Path)
+	src := filepath.Join(s.workspace, strings.TrimPrefix(artifact.Path, "/"))
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	cmd := exec.Command("tar", "-czf", tarName, "-C", filepath.Dir(src), filepath.Base(src))
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("compress %s error: %s", src, err)
+	}
+	if err := client.Upload(s.spec.S3.Bucket, tarName, artifact.ObjectKey); err != nil {
+		return err
+	}
+	log.Infof("Finish uploading artifact %s.", artifact.Name)
+	return nil
+}
+
+func (s *ArtifactStep) downloadArtifact(client *s3.Client, artifact *step.Artifact, tarName string) error {
+	log.Infof("Start downloading artifact %s of job %s to %s.", artifact.Name, artifact.JobName, artifact.Path)
+	if err := client.Download(s.spec.S3.Bucket, artifact.ObjectKey, tarName); err != nil {
+		return err
+	}
+	dest := filepath.Join(s.workspace, strings.TrimPrefix(artifact.Path, "/"))
+	if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+		return err
+	}
+	cmd := exec.Command("tar", "-xzf", tarName, "-C", dest)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("extract %s error: %s", tarName, err)
+	}
+	log.Infof("Finish downloading artifact %s.", artifact.Name)
+	return nil
+}
+
+func newS3Client(storage *step.S3) (*s3.Client, error) {
+	forcedPathStyle := true
+	if storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	return s3.NewClient(storage.Endpoint, storage.Ak, storage.Sk, storage.Region, storage.Insecure, forcedPathStyle)
+}
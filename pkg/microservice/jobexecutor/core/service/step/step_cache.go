@@ -0,0 +1,352 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const cacheFileSuffix = ".tar.gz"
+
+var cacheKeyInvalidRegex = regexp.MustCompile(`[^a-zA-Z0-9._\-]+`)
+
+type CacheStep struct {
+	spec       *step.StepCacheSpec
+	save       bool
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewCacheStep(spec interface{}, save bool, workspace string, envs, secretEnvs []string) (*CacheStep, error) {
+	cacheStep := &CacheStep{save: save, workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return cacheStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &cacheStep.spec); err != nil {
+		return cacheStep, fmt.Errorf("unmarshal spec %s to cache spec failed", yamlBytes)
+	}
+	return cacheStep, nil
+}
+
+func (s *CacheStep) Run(ctx context.Context) error {
+	if s.spec.S3 == nil {
+		return fmt.Errorf("object storage of caches is not set")
+	}
+	client, err := newS3Client(s.spec.S3)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client for caches, err: %s", err)
+	}
+	key, err := s.renderKey(s.spec.Key)
+	if err != nil {
+		return fmt.Errorf("failed to render cache key %s: %s", s.spec.Key, err)
+	}
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "cache")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tarName := filepath.Join(tmpDir, "cache"+cacheFileSuffix)
+	if s.save {
+		return s.saveCache(client, key, tarName)
+	}
+	return s.restoreCache(client, key, tarName)
+}
+
+func (s *CacheStep) objectKey(key string) string {
+	return s.spec.ObjectPrefix + key + cacheFileSuffix
+}
+
+func (s *CacheStep) restoreCache(client *s3.Client, key, tarName string) error {
+	objectKey, matchedKey, err := s.findCache(client, key)
+	if err != nil {
+		return err
+	}
+	if objectKey == "" {
+		log.Infof("Cache not found for key %s.", key)
+		return nil
+	}
+
+	log.Infof("Start restoring cache %s.", matchedKey)
+	start := time.Now()
+	if err := client.Download(s.spec.S3.Bucket, objectKey, tarName); err != nil {
+		return fmt.Errorf("failed to download cache %s: %s", matchedKey, err)
+	}
+	cmd := exec.Command("tar", "-xzf", tarName, "-C", "/")
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("extract cache %s error: %s", matchedKey, err)
+	}
+	// the last modified time is the last used time of the cache for the eviction.
+	if err := client.TouchObject(s.spec.S3.Bucket, objectKey, map[string]string{"last-used": strconv.FormatInt(time.Now().Unix(), 10)}); err != nil {
+		log.Warnf("Failed to update the last used time of cache %s: %s", matchedKey, err)
+	}
+	log.Infof("Finish restoring cache %s. Duration: %.2f seconds.", matchedKey, time.Since(start).Seconds())
+	return nil
+}
+
+// findCache returns the object of the key, or the latest one matching the first restore key which has any.
+func (s *CacheStep) findCache(client *s3.Client, key string) (string, string, error) {
+	exists, err := client.ObjectExists(s.spec.S3.Bucket, s.objectKey(key))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to find cache %s: %s", key, err)
+	}
+	if exists {
+		return s.objectKey(key), key, nil
+	}
+	for _, restoreKey := range s.spec.RestoreKeys {
+		prefix, err := s.renderKey(restoreKey)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to render restore key %s: %s", restoreKey, err)
+		}
+		objects, err := client.ListObjectInfos(s.spec.S3.Bucket, s.spec.ObjectPrefix+prefix)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to list caches with prefix %s: %s", prefix, err)
+		}
+		var latest string
+		var latestTime time.Time
+		for _, object := range objects {
+			objectKey := *object.Key
+			if !strings.HasSuffix(objectKey, cacheFileSuffix) || strings.Contains(strings.TrimPrefix(objectKey, s.spec.ObjectPrefix), "/") {
+				continue
+			}
+			if latest == "" || object.LastModified.After(latestTime) {
+				latest, latestTime = objectKey, *object.LastModified
+			}
+		}
+		if latest != "" {
+			return latest, strings.TrimSuffix(strings.TrimPrefix(latest, s.spec.ObjectPrefix), cacheFileSuffix), nil
+		}
+	}
+	return "", "", nil
+}
+
+// saveCache saves the paths with their absolute paths, caches are immutable so an existing one is never overwritten.
+func (s *CacheStep) saveCache(client *s3.Client, key, tarName string) error {
+	exists, err := client.ObjectExists(s.spec.S3.Bucket, s.objectKey(key))
+	if err != nil {
+		return fmt.Errorf("failed to find cache %s: %s", key, err)
+	}
+	if exists {
+		log.Infof("Cache %s already exists, skip saving.", key)
+		return nil
+	}
+
+	paths := []string{}
+	for _, p := range s.spec.Paths {
+		p = s.resolvePath(p)
+		if _, err := os.Stat(p); err != nil {
+			log.Warnf("Cache path %s does not exist, skipped.", p)
+			continue
+		}
+		paths = append(paths, strings.TrimPrefix(p, "/"))
+	}
+	if len(paths) == 0 {
+		log.Warnf("No cache path exists, skip saving cache %s.", key)
+		return nil
+	}
+
+	log.Infof("Start saving cache %s.", key)
+	start := time.Now()
+	cmd := exec.Command("tar", append([]string{"-czf", tarName, "-C", "/"}, paths...)...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("compress cache %s error: %s", key, err)
+	}
+	info, err := os.Stat(tarName)
+	if err != nil {
+		return err
+	}
+	maxSize := s.spec.MaxSize
+	if maxSize <= 0 {
+		maxSize = step.DefaultCacheMaxSize
+	}
+	if info.Size() > maxSize*1024*1024 {
+		log.Warnf("Cache %s is %d MB, larger than the limit %d MB, skip saving.", key, info.Size()/1024/1024, maxSize)
+		return nil
+	}
+	if err := client.Upload(s.spec.S3.Bucket, tarName, s.objectKey(key)); err != nil {
+		return fmt.Errorf("failed to upload cache %s: %s", key, err)
+	}
+	log.Infof("Finish saving cache %s, size: %d KB. Duration: %.2f seconds.", key, info.Size()/1024, time.Since(start).Seconds())
+	return nil
+}
+
+func (s *CacheStep) lookupEnv(name string) string {
+	for _, env := range append(s.envs, s.secretEnvs...) {
+		if k, v, ok := strings.Cut(env, "="); ok && k == name {
+			return v
+		}
+	}
+	return os.Getenv(name)
+}
+
+// resolvePath expands the envs and ~ in p, relative paths are relative to the workspace.
+func (s *CacheStep) resolvePath(p string) string {
+	p = os.Expand(p, s.lookupEnv)
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		p = filepath.Join(s.lookupEnv("HOME"), strings.TrimPrefix(p, "~"))
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(s.workspace, p)
+	}
+	return filepath.Clean(p)
+}
+
+// renderKey renders the key template, in which hashFiles, env, os and arch can be used.
+func (s *CacheStep) renderKey(key string) (string, error) {
+	tmpl, err := template.New("key").Option("missingkey=error").Funcs(template.FuncMap{
+		"hashFiles": s.hashFiles,
+		"env":       s.lookupEnv,
+		"os":        func() string { return runtime.GOOS },
+		"arch":      func() string { return runtime.GOARCH },
+	}).Parse(key)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, nil); err != nil {
+		return "", err
+	}
+	resp := cacheKeyInvalidRegex.ReplaceAllString(buf.String(), "-")
+	if resp == "" {
+		return "", fmt.Errorf("cache key is empty")
+	}
+	return resp, nil
+}
+
+// hashFiles returns the sha256 of the paths and contents of the files matching the patterns in the workspace,
+// ** matches any directories. the paths are hashed too, so moving or renaming a file changes the hash.
+func (s *CacheStep) hashFiles(patterns ...string) (string, error) {
+	files := map[string]bool{}
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(filepath.Clean(strings.TrimPrefix(pattern, "./")))
+		if !strings.ContainsAny(pattern, "*?[") {
+			if info, err := os.Stat(filepath.Join(s.workspace, pattern)); err == nil && !info.IsDir() {
+				files[pattern] = true
+			}
+			continue
+		}
+		re, err := globRegex(pattern)
+		if err != nil {
+			return "", err
+		}
+		err = filepath.WalkDir(s.workspace, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(s.workspace, p)
+			if err != nil {
+				return err
+			}
+			if re.MatchString(filepath.ToSlash(rel)) {
+				files[filepath.ToSlash(rel)] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(files) == 0 {
+		log.Warnf("No file matches %v, the hash is empty.", patterns)
+		return "", nil
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	for _, name := range names {
+		file, err := os.Open(filepath.Join(s.workspace, name))
+		if err != nil {
+			return "", err
+		}
+		fileHash := sha256.New()
+		_, err = io.Copy(fileHash, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+		hash.Write([]byte(name))
+		hash.Write([]byte{0})
+		hash.Write(fileHash.Sum(nil))
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func globRegex(pattern string) (*regexp.Regexp, error) {
+	re := strings.Builder{}
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					re.WriteString("(.*/)?")
+				} else {
+					re.WriteString(".*")
+				}
+				continue
+			}
+			re.WriteString("[^/]*")
+		case '?':
+			re.WriteString("[^/]")
+		case '[', ']':
+			re.WriteByte(c)
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// newCacheWorkspace creates a workspace with the files, the keys are the paths relative to the workspace.
+func newCacheWorkspace(files map[string]string) string {
+	dir, err := ioutil.TempDir("", "cache-workspace")
+	Expect(err).ShouldNot(HaveOccurred())
+	for name, content := range files {
+		Expect(os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)).ShouldNot(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).ShouldNot(HaveOccurred())
+	}
+	return dir
+}
+
+func hashWorkspaceFiles(files map[string]string, patterns ...string) string {
+	dir := newCacheWorkspace(files)
+	defer os.RemoveAll(dir)
+	hash, err := (&CacheStep{workspace: dir}).hashFiles(patterns...)
+	Expect(err).ShouldNot(HaveOccurred())
+	return hash
+}
+
+var _ = Describe("Testing cache step", func() {
+
+	files := map[string]string{
+		"go.sum":               "a",
+		"go.mod":               "b",
+		"web/package.json":     "c",
+		"web/lib/package.json": "d",
+		".git/go.sum":          "e",
+	}
+
+	table.DescribeTable("hashing the same files",
+		func(patterns ...string) {
+			Expect(hashWorkspaceFiles(files, patterns...)).To(Equal(hashWorkspaceFiles(map[string]string{
+				"go.sum":               "a",
+				"go.mod":               "b",
+				"web/package.json":     "c",
+				"web/lib/package.json": "d",
+			}, patterns...)))
+		},
+		table.Entry("a file", "go.sum"),
+		table.Entry("a file with ./", "./go.sum"),
+		table.Entry("a glob", "go.*"),
+		table.Entry("files in any directory", "**/package.json"),
+		table.Entry("files in the git directory are skipped", "**/go.sum"),
+	)
+
+	table.DescribeTable("hashing different files",
+		func(a, b map[string]string, patterns ...string) {
+			Expect(hashWorkspaceFiles(a, patterns...)).NotTo(Equal(hashWorkspaceFiles(b, patterns...)))
+		},
+		table.Entry("changed content", map[string]string{"go.sum": "a"}, map[string]string{"go.sum": "b"}, "*.sum"),
+		table.Entry("renamed file", map[string]string{"a.sum": "a"}, map[string]string{"b.sum": "a"}, "*.sum"),
+		table.Entry("moved file", map[string]string{"web/package.json": "a"}, map[string]string{"api/package.json": "a"}, "**/package.json"),
+		table.Entry("swapped contents", map[string]string{"a.sum": "a", "b.sum": "b"}, map[string]string{"a.sum": "b", "b.sum": "a"}, "*.sum"),
+		table.Entry("added file", map[string]string{"a.sum": "a"}, map[string]string{"a.sum": "a", "b.sum": ""}, "*.sum"),
+	)
+
+	It("should not depend on the order of the patterns", func() {
+		Expect(hashWorkspaceFiles(files, "go.sum", "**/package.json")).To(Equal(hashWorkspaceFiles(files, "**/package.json", "go.sum")))
+		Expect(hashWorkspaceFiles(files, "go.sum", "go.*")).To(Equal(hashWorkspaceFiles(files, "go.*")))
+	})
+
+	It("should return an empty hash if no file matches", func() {
+		Expect(hashWorkspaceFiles(files, "*.lock")).To(BeEmpty())
+		Expect(hashWorkspaceFiles(files, "web")).To(BeEmpty())
+	})
+
+	It("should hash files in the key", func() {
+		dir := newCacheWorkspace(files)
+		defer os.RemoveAll(dir)
+		s := &CacheStep{workspace: dir}
+		hash, err := s.hashFiles("go.sum")
+		Expect(err).ShouldNot(HaveOccurred())
+		key, err := s.renderKey(`go-{{ hashFiles "go.sum" }}`)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(key).To(Equal("go-" + hash))
+	})
+})
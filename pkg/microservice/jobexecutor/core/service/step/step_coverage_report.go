@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util/coverage"
+)
+
+type CoverageReportStep struct {
+	spec       *step.StepCoverageReportSpec
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewCoverageReportStep(spec interface{}, workspace string, envs, secretEnvs []string) (*CoverageReportStep, error) {
+	coverageReportStep := &CoverageReportStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return coverageReportStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &coverageReportStep.spec); err != nil {
+		return coverageReportStep, fmt.Errorf("unmarshal spec %s to coverage report spec failed", yamlBytes)
+	}
+	return coverageReportStep, nil
+}
+
+func (s *CoverageReportStep) Run(ctx context.Context) error {
+	reportPath := os.Expand(s.spec.ReportPath, func(name string) string {
+		for _, env := range s.envs {
+			if k, v, ok := strings.Cut(env, "="); ok && k == name {
+				return v
+			}
+		}
+		return os.Getenv(name)
+	})
+	if !filepath.IsAbs(reportPath) {
+		reportPath = filepath.Join(s.workspace, reportPath)
+	}
+	data, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		return fmt.Errorf("failed to read coverage report %s: %s", reportPath, err)
+	}
+	summary, err := coverage.Parse(data, s.spec.Format)
+	if err != nil {
+		return err
+	}
+	log.Infof("Line coverage: %.2f%% (%d/%d).", summary.LineCoverage, summary.LinesCovered, summary.LinesValid)
+	if summary.BranchesValid > 0 {
+		log.Infof("Branch coverage: %.2f%% (%d/%d).", summary.BranchCoverage, summary.BranchesCovered, summary.BranchesValid)
+	}
+	if s.spec.BaseCoverage != nil {
+		log.Infof("Line coverage of branch %s: %.2f%%.", s.spec.BaseBranch, s.spec.BaseCoverage.LineCoverage)
+	}
+
+	if err := s.upload(reportPath); err != nil {
+		return err
+	}
+	return s.spec.CheckGates(summary)
+}
+
+func (s *CoverageReportStep) upload(reportPath string) error {
+	if s.spec.S3DestDir == "" || s.spec.FileName == "" || s.spec.S3Storage == nil {
+		return nil
+	}
+	log.Infof("Start archive coverage report %s.", reportPath)
+	forcedPathStyle := true
+	if s.spec.S3Storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3.NewClient(s.spec.S3Storage.Endpoint, s.spec.S3Storage.Ak, s.spec.S3Storage.Sk, s.spec.S3Storage.Region, s.spec.S3Storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client to upload file, err: %s", err)
+	}
+	key := strings.TrimLeft(path.Join(s.spec.S3Storage.Subfolder, s.spec.S3DestDir, s.spec.FileName), "/")
+	if err := client.Upload(s.spec.S3Storage.Bucket, reportPath, key); err != nil {
+		return fmt.Errorf("failed to upload coverage report: %s", err)
+	}
+	log.Infof("Finish archive coverage report.")
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/regclient/regclient"
@@ -53,6 +54,7 @@ func NewDistributeImageStep(spec interface{}, workspace string, envs, secretEnvs
 }
 
 func (s *DistributeImageStep) Run(ctx context.Context) error {
+	start := time.Now()
 	log.Info("Start distribute images.")
 	if s.spec.SourceRegistry == nil || s.spec.TargetRegistry == nil {
 		return errors.New("image registry infos are missing")
@@ -75,6 +77,14 @@ func (s *DistributeImageStep) Run(ctx context.Context) error {
 	if err := errList.ErrorOrNil(); err != nil {
 		return fmt.Errorf("copy images error: %v", err)
 	}
+	if s.spec.Signing != nil {
+		signer := newImageSigner(s.spec.Signing, []*step.RegistryNamespace{s.spec.SourceRegistry, s.spec.TargetRegistry}, s.envs, s.workspace, start)
+		for _, target := range s.spec.DistributeTarget {
+			if err := signer.sign(ctx, target.TargetImage, signer.imageMaterial(ctx, target.SoureImage)); err != nil {
+				return err
+			}
+		}
+	}
 	log.Info("Finish distribute images.")
 	return nil
 }
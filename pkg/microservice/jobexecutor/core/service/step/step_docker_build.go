@@ -61,10 +61,53 @@ func (s *DockerBuildStep) Run(ctx context.Context) error {
 		log.Infof("Docker build ended. Duration: %.2f seconds.", time.Since(start).Seconds())
 	}()
 
+	if err := s.spec.Validate(); err != nil {
+		return err
+	}
+	if s.spec.GetBuildEngine() != step.BuildEngineDocker {
+		if err := prepareDockerfile(s.spec.Source, s.spec.DockerTemplateContent); err != nil {
+			return fmt.Errorf("failed to prepare dockerfile: %s", err)
+		}
+		if s.spec.Proxy != nil {
+			setProxy(s.spec)
+		}
+		var err error
+		if s.spec.GetBuildEngine() == step.BuildEngineKaniko {
+			err = s.runKanikoBuild(ctx)
+		} else {
+			err = s.runBuildKitBuild(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		return s.signImage(ctx, start)
+	}
+
 	if err := s.dockerLogin(); err != nil {
 		return err
 	}
-	return s.runDockerBuild()
+	if err := s.runDockerBuild(); err != nil {
+		return err
+	}
+	return s.signImage(ctx, start)
+}
+
+// signImage signs the pushed image if signing is enabled.
+func (s *DockerBuildStep) signImage(ctx context.Context, start time.Time) error {
+	if s.spec.Signing == nil {
+		return nil
+	}
+	registries := []*step.RegistryNamespace{}
+	if s.spec.DockerRegistry != nil {
+		registries = append(registries, &step.RegistryNamespace{
+			RegAddr:    s.spec.DockerRegistry.Host,
+			AccessKey:  s.spec.DockerRegistry.UserName,
+			SecretKey:  s.spec.DockerRegistry.Password,
+			TLSEnabled: true,
+		})
+	}
+	signer := newImageSigner(s.spec.Signing, registries, s.envs, s.workspace, start)
+	return signer.sign(ctx, s.expandEnvs(s.spec.ImageName))
 }
 
 func (s DockerBuildStep) dockerLogin() error {
@@ -129,7 +172,7 @@ func (s *DockerBuildStep) dockerCommands() []*exec.Cmd {
 			s.spec.GetDockerFile(),
 			s.spec.ImageName,
 			s.spec.WorkDir,
-			s.spec.BuildArgs,
+			s.buildArgs(),
 			s.spec.IgnoreCache,
 		),
 		dockerPush(s.spec.ImageName),
@@ -137,6 +180,13 @@ func (s *DockerBuildStep) dockerCommands() []*exec.Cmd {
 	return cmds
 }
 
+func (s *DockerBuildStep) buildArgs() string {
+	if len(s.spec.Platforms) == 1 {
+		return s.spec.BuildArgs + " --platform " + s.spec.Platforms[0]
+	}
+	return s.spec.BuildArgs
+}
+
 func dockerBuildCmd(dockerfile, fullImage, ctx, buildArgs string, ignoreCache bool) *exec.Cmd {
 	args := []string{"-c"}
 	dockerCommand := "docker build --rm=true"
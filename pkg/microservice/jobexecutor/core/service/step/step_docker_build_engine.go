@@ -0,0 +1,345 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const (
+	imageOutputName       = "IMAGE"
+	buildKitReadyTimeout  = 2 * time.Minute
+	buildKitDigestKey     = "containerimage.digest"
+	buildEngineLogRefresh = time.Second
+)
+
+var (
+	buildctlExe = filepath.Join(step.BuildEngineDir, "bin", "buildctl")
+	kanikoDir   = filepath.Join(step.BuildEngineDir, string(step.BuildEngineKaniko))
+)
+
+// buildOptions are the docker build args which are understood by the daemonless build engines.
+type buildOptions struct {
+	buildArgs []string
+	target    string
+}
+
+func parseBuildArgs(buildArgs string) *buildOptions {
+	resp := &buildOptions{}
+	fields := strings.Fields(buildArgs)
+	for i := 0; i < len(fields); i++ {
+		name, value, hasValue := strings.Cut(fields[i], "=")
+		if !hasValue && i+1 < len(fields) {
+			value = fields[i+1]
+		}
+		switch name {
+		case "--build-arg":
+			resp.buildArgs = append(resp.buildArgs, value)
+		case "--target":
+			resp.target = value
+		default:
+			log.Warnf("build arg %s is not supported by the build engine, ignored", fields[i])
+			continue
+		}
+		if !hasValue {
+			i++
+		}
+	}
+	return resp
+}
+
+func (s *DockerBuildStep) expandEnvs(value string) string {
+	envs := make(map[string]string)
+	for _, env := range append(s.envs, s.secretEnvs...) {
+		if k, v, ok := strings.Cut(env, "="); ok {
+			envs[k] = v
+		}
+	}
+	return os.Expand(value, func(key string) string {
+		if v, ok := envs[key]; ok {
+			return v
+		}
+		return os.Getenv(key)
+	})
+}
+
+// buildPaths returns the absolute paths of the build context and the dockerfile, which are relative to the workspace.
+func (s *DockerBuildStep) buildPaths() (string, string) {
+	buildContext := filepath.Join(s.workspace, s.spec.WorkDir)
+	dockerfile := s.spec.GetDockerFile()
+	if !filepath.IsAbs(dockerfile) {
+		dockerfile = filepath.Join(s.workspace, dockerfile)
+	}
+	return buildContext, dockerfile
+}
+
+// writeDockerConfig writes the registry credential to dir/config.json, which is read by buildctl and kaniko.
+func (s *DockerBuildStep) writeDockerConfig(dir string) error {
+	auths := map[string]interface{}{}
+	if s.spec.DockerRegistry != nil && s.spec.DockerRegistry.UserName != "" {
+		host := strings.TrimPrefix(strings.TrimPrefix(s.spec.DockerRegistry.Host, "http://"), "https://")
+		auth := base64.StdEncoding.EncodeToString([]byte(s.spec.DockerRegistry.UserName + ":" + s.spec.DockerRegistry.Password))
+		auths[host] = map[string]string{"auth": auth}
+	}
+	content, err := json.Marshal(map[string]interface{}{"auths": auths})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "config.json"), content, 0600)
+}
+
+// writeImageOutput points the IMAGE output of the job at the pushed digest, which is the manifest list digest
+// of a multi-platform build, it is only written when the step pushes the image of the IMAGE env.
+func (s *DockerBuildStep) writeImageOutput(image, digest string) error {
+	digest = strings.TrimSpace(digest)
+	if digest == "" || image != s.expandEnvs("$"+imageOutputName) {
+		return nil
+	}
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	fmt.Printf("Image pushed: %s@%s\n", image, digest)
+	if err := os.MkdirAll(job.JobOutputDir, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(job.JobOutputDir, imageOutputName), []byte(image+"@"+digest), 0644)
+}
+
+func (s *DockerBuildStep) runBuildKitBuild(ctx context.Context) error {
+	buildContext, dockerfile := s.buildPaths()
+	image := s.expandEnvs(s.spec.ImageName)
+	configDir := filepath.Join(step.BuildEngineDir, "docker")
+	if err := s.writeDockerConfig(configDir); err != nil {
+		return fmt.Errorf("failed to write registry credential: %s", err)
+	}
+
+	fmt.Printf("Waiting for buildkitd.\n")
+	if err := waitBuildKitReady(ctx); err != nil {
+		return err
+	}
+
+	metadataFile := filepath.Join(step.BuildEngineDir, "metadata.json")
+	args := []string{"--addr", step.BuildKitAddr, "build",
+		"--frontend", "dockerfile.v0",
+		"--local", "context=" + buildContext,
+		"--local", "dockerfile=" + filepath.Dir(dockerfile),
+		"--opt", "filename=" + filepath.Base(dockerfile),
+		"--output", fmt.Sprintf("type=image,name=%s,push=true", image),
+		"--metadata-file", metadataFile,
+	}
+	options := parseBuildArgs(s.expandEnvs(s.spec.BuildArgs))
+	for _, buildArg := range options.buildArgs {
+		args = append(args, "--opt", "build-arg:"+buildArg)
+	}
+	if options.target != "" {
+		args = append(args, "--opt", "target="+options.target)
+	}
+	if len(s.spec.Platforms) > 0 {
+		args = append(args, "--opt", "platform="+strings.Join(s.spec.Platforms, ","))
+	}
+	if s.spec.IgnoreCache {
+		args = append(args, "--no-cache")
+	}
+	if s.spec.CacheRef != "" {
+		cacheRef := s.expandEnvs(s.spec.CacheRef)
+		args = append(args,
+			"--import-cache", "type=registry,ref="+cacheRef,
+			"--export-cache", fmt.Sprintf("type=registry,ref=%s,mode=max", cacheRef),
+		)
+	}
+
+	fmt.Printf("Running BuildKit Build.\n")
+	startTimeBuild := time.Now()
+	cmd := exec.CommandContext(ctx, buildctlExe, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Dir = s.workspace
+	cmd.Env = append(s.envs, "DOCKER_CONFIG="+configDir)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run buildkit build: %s", err)
+	}
+	fmt.Printf("BuildKit build ended. Duration: %.2f seconds.\n", time.Since(startTimeBuild).Seconds())
+
+	metadata := map[string]interface{}{}
+	content, err := os.ReadFile(metadataFile)
+	if err != nil {
+		return fmt.Errorf("failed to read build metadata: %s", err)
+	}
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return fmt.Errorf("failed to parse build metadata: %s", err)
+	}
+	digest, _ := metadata[buildKitDigestKey].(string)
+	return s.writeImageOutput(image, digest)
+}
+
+func waitBuildKitReady(ctx context.Context) error {
+	timeout := time.After(buildKitReadyTimeout)
+	for {
+		if err := exec.CommandContext(ctx, buildctlExe, "--addr", step.BuildKitAddr, "debug", "workers").Run(); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("buildkitd is not ready in %s", buildKitReadyTimeout)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (s *DockerBuildStep) runKanikoBuild(ctx context.Context) error {
+	buildContext, dockerfile := s.buildPaths()
+	image := s.expandEnvs(s.spec.ImageName)
+	configDir := filepath.Join(kanikoDir, ".docker")
+	if err := s.writeDockerConfig(configDir); err != nil {
+		return fmt.Errorf("failed to write registry credential: %s", err)
+	}
+
+	// the kaniko container runs every kaniko build of the job, clean up the files of the former one.
+	kanikoContext := filepath.Join(kanikoDir, "context")
+	kanikoDockerfile := filepath.Join(kanikoDir, "Dockerfile")
+	digestFile := filepath.Join(kanikoDir, "digest")
+	for _, file := range []string{kanikoContext, digestFile, filepath.Join(kanikoDir, "exit"), filepath.Join(kanikoDir, "build.log")} {
+		if err := os.RemoveAll(file); err != nil {
+			return err
+		}
+	}
+
+	// the kaniko container can only see the shared volume, so the build context is copied to it.
+	fmt.Printf("Copying build context.\n")
+	if err := os.MkdirAll(kanikoContext, os.ModePerm); err != nil {
+		return err
+	}
+	if out, err := exec.Command("cp", "-a", buildContext+"/.", kanikoContext).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to copy build context: %s %s", err, out)
+	}
+	if out, err := exec.Command("cp", dockerfile, kanikoDockerfile).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to copy dockerfile: %s %s", err, out)
+	}
+
+	args := []string{"/kaniko/executor",
+		"--context=dir://" + kanikoContext,
+		"--dockerfile=" + kanikoDockerfile,
+		"--destination=" + image,
+		"--digest-file=" + digestFile,
+		// the filesystem of the kaniko container is cleaned up for the next build.
+		"--cleanup",
+	}
+	options := parseBuildArgs(s.expandEnvs(s.spec.BuildArgs))
+	for _, buildArg := range options.buildArgs {
+		args = append(args, "--build-arg="+buildArg)
+	}
+	if options.target != "" {
+		args = append(args, "--target="+options.target)
+	}
+	if len(s.spec.Platforms) > 0 {
+		args = append(args, "--custom-platform="+s.spec.Platforms[0])
+	}
+	if s.spec.CacheRef != "" && !s.spec.IgnoreCache {
+		// kaniko stores the cache layers as tags of a repository.
+		cacheRepo := s.expandEnvs(s.spec.CacheRef)
+		if i := strings.LastIndex(cacheRepo, ":"); i > strings.LastIndex(cacheRepo, "/") {
+			cacheRepo = cacheRepo[:i]
+		}
+		args = append(args, "--cache=true", "--cache-repo="+cacheRepo)
+	}
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+	script := fmt.Sprintf("export DOCKER_CONFIG=%s\n%s\n", configDir, strings.Join(quoted, " "))
+
+	// the script is renamed into place so that the kaniko container never reads a partial one.
+	tmpScript := filepath.Join(kanikoDir, "build.sh.tmp")
+	if err := os.WriteFile(tmpScript, []byte(script), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Running Kaniko Build.\n")
+	startTimeBuild := time.Now()
+	if err := os.Rename(tmpScript, filepath.Join(kanikoDir, "build.sh")); err != nil {
+		return err
+	}
+	exitCode, err := waitKanikoBuild(ctx)
+	if err != nil {
+		return err
+	}
+	if exitCode != "0" {
+		return fmt.Errorf("failed to run kaniko build: exit code %s", exitCode)
+	}
+	fmt.Printf("Kaniko build ended. Duration: %.2f seconds.\n", time.Since(startTimeBuild).Seconds())
+
+	digest, err := os.ReadFile(digestFile)
+	if err != nil {
+		return fmt.Errorf("failed to read image digest: %s", err)
+	}
+	return s.writeImageOutput(image, string(digest))
+}
+
+// waitKanikoBuild prints the log of the kaniko container until it writes the exit code.
+func waitKanikoBuild(ctx context.Context) (string, error) {
+	var offset int64
+	printLog := func() {
+		file, err := os.Open(filepath.Join(kanikoDir, "build.log"))
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return
+		}
+		n, _ := io.Copy(os.Stdout, file)
+		offset += n
+	}
+	for {
+		printLog()
+		if exitCode, err := os.ReadFile(filepath.Join(kanikoDir, "exit")); err == nil {
+			printLog()
+			return strings.TrimSpace(string(exitCode)), nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(buildEngineLogRefresh):
+		}
+	}
+}
+
+// StopBuildEngines tells the kaniko container to exit, it runs until the job ends since
+// it does not know how many kaniko builds the job has.
+func StopBuildEngines() error {
+	if err := os.MkdirAll(kanikoDir, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(kanikoDir, "stop"), nil, 0644)
+}
@@ -37,6 +37,7 @@ import (
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util/redact"
 )
 
 type GitStep struct {
@@ -151,6 +152,7 @@ func (s *GitStep) runGitCmds() error {
 		}
 	}
 
+	redactor := redact.New(append(tokens, redact.EnvValues(s.secretEnvs)...)...)
 	for _, c := range cmds {
 		cmdOutReader, err := c.Cmd.StdoutPipe()
 		if err != nil {
@@ -160,7 +162,7 @@ func (s *GitStep) runGitCmds() error {
 		outScanner := bufio.NewScanner(cmdOutReader)
 		go func() {
 			for outScanner.Scan() {
-				fmt.Printf("%s\n", maskSecret(tokens, outScanner.Text()))
+				fmt.Printf("%s\n", redactor.Redact(outScanner.Text()))
 			}
 		}()
 
@@ -172,7 +174,7 @@ func (s *GitStep) runGitCmds() error {
 		errScanner := bufio.NewScanner(cmdErrReader)
 		go func() {
 			for errScanner.Scan() {
-				fmt.Printf("%s\n", maskSecret(tokens, errScanner.Text()))
+				fmt.Printf("%s\n", redactor.Redact(errScanner.Text()))
 			}
 		}()
 
@@ -0,0 +1,239 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+var trivyExe = path.Join(step.ImageScanDir, "bin", "trivy")
+
+type ImageScanStep struct {
+	spec       *step.StepImageScanSpec
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+// trivyReport is the part of the trivy json report used by the gates.
+type trivyReport struct {
+	Results []struct {
+		Target          string `json:"Target"`
+		Vulnerabilities []struct {
+			VulnerabilityID  string `json:"VulnerabilityID"`
+			PkgName          string `json:"PkgName"`
+			InstalledVersion string `json:"InstalledVersion"`
+			FixedVersion     string `json:"FixedVersion"`
+			Severity         string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+func NewImageScanStep(spec interface{}, workspace string, envs, secretEnvs []string) (*ImageScanStep, error) {
+	imageScanStep := &ImageScanStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return imageScanStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &imageScanStep.spec); err != nil {
+		return imageScanStep, fmt.Errorf("unmarshal spec %s to image scan spec failed", yamlBytes)
+	}
+	return imageScanStep, nil
+}
+
+func (s *ImageScanStep) Run(ctx context.Context) error {
+	log.Info("Start scan images.")
+	outputDir := filepath.Join(step.ImageScanDir, "reports")
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return err
+	}
+	configDir := filepath.Join(step.ImageScanDir, "docker")
+	if err := s.writeDockerConfig(configDir); err != nil {
+		return fmt.Errorf("failed to write registry credential: %s", err)
+	}
+
+	results := []*step.ImageScanResult{}
+	failed := []string{}
+	for _, target := range s.spec.Targets {
+		result, err := s.scan(ctx, target, outputDir, configDir)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+
+		fails, warns := s.spec.CheckGates(result)
+		result.Passed = len(fails) == 0
+		if len(warns) > 0 {
+			log.Warnf("Image %s has %s vulnerabilities.", result.Image, strings.Join(warns, ", "))
+		}
+		if len(fails) > 0 {
+			failed = append(failed, fmt.Sprintf("%s has %s vulnerabilities", result.Image, strings.Join(fails, ", ")))
+		}
+	}
+
+	summaryFile := filepath.Join(outputDir, step.ImageScanSummaryFile)
+	content, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(summaryFile, content, 0644); err != nil {
+		return err
+	}
+	if _, err := s.upload(summaryFile); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("image scan failed: %s", strings.Join(failed, "; "))
+	}
+	log.Info("Finish scan images.")
+	return nil
+}
+
+func (s *ImageScanStep) scan(ctx context.Context, target *step.ImageScanTarget, outputDir, configDir string) (*step.ImageScanResult, error) {
+	name := strings.Trim(strings.Join([]string{target.ServiceName, target.ServiceModule}, "-"), "-")
+	if name == "" {
+		name = strings.NewReplacer("/", "-", ":", "-", "@", "-").Replace(target.Image)
+	}
+	reportFile := filepath.Join(outputDir, name+"-vulnerabilities.json")
+	sbomFile := filepath.Join(outputDir, name+"-sbom.json")
+
+	log.Infof("Scanning image %s.", target.Image)
+	startTime := time.Now()
+	if err := s.runTrivy(ctx, configDir, target.Image, "--scanners", "vuln", "--format", "json", "--output", reportFile); err != nil {
+		return nil, fmt.Errorf("failed to scan image %s: %s", target.Image, err)
+	}
+	if err := s.runTrivy(ctx, configDir, target.Image, "--format", string(s.spec.GetSBOMFormat()), "--output", sbomFile); err != nil {
+		return nil, fmt.Errorf("failed to generate sbom of image %s: %s", target.Image, err)
+	}
+	log.Infof("Image %s scanned. Duration: %.2f seconds.", target.Image, time.Since(startTime).Seconds())
+
+	content, err := os.ReadFile(reportFile)
+	if err != nil {
+		return nil, err
+	}
+	report := &trivyReport{}
+	if err := json.Unmarshal(content, report); err != nil {
+		return nil, fmt.Errorf("failed to parse scan report of image %s: %s", target.Image, err)
+	}
+	result := &step.ImageScanResult{
+		ServiceName:     target.ServiceName,
+		ServiceModule:   target.ServiceModule,
+		Image:           target.Image,
+		Vulnerabilities: map[string]int{},
+	}
+	for _, res := range report.Results {
+		for _, vuln := range res.Vulnerabilities {
+			if s.spec.IsIgnored(vuln.VulnerabilityID) {
+				result.Ignored++
+				continue
+			}
+			result.Vulnerabilities[strings.ToUpper(vuln.Severity)]++
+		}
+	}
+	log.Infof("Vulnerabilities of %s: CRITICAL: %d, HIGH: %d, MEDIUM: %d, LOW: %d, UNKNOWN: %d, ignored: %d.", target.Image,
+		result.Vulnerabilities["CRITICAL"], result.Vulnerabilities["HIGH"], result.Vulnerabilities["MEDIUM"],
+		result.Vulnerabilities["LOW"], result.Vulnerabilities["UNKNOWN"], result.Ignored)
+
+	if result.ReportKey, err = s.upload(reportFile); err != nil {
+		return nil, err
+	}
+	if result.SBOMKey, err = s.upload(sbomFile); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *ImageScanStep) runTrivy(ctx context.Context, configDir, image string, args ...string) error {
+	args = append([]string{"image", "--quiet", "--cache-dir", filepath.Join(step.ImageScanDir, "cache")}, args...)
+	if reg := s.findRegistry(image); reg != nil && (!reg.TLSEnabled || strings.HasPrefix(reg.RegAddr, "http://")) {
+		args = append(args, "--insecure")
+	}
+	args = append(args, image)
+	cmd := exec.CommandContext(ctx, trivyExe, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Dir = s.workspace
+	cmd.Env = append(s.envs, "DOCKER_CONFIG="+configDir)
+	return cmd.Run()
+}
+
+func (s *ImageScanStep) findRegistry(image string) *step.RegistryNamespace {
+	for _, reg := range s.spec.Registries {
+		host := strings.TrimPrefix(strings.TrimPrefix(reg.RegAddr, "http://"), "https://")
+		if host != "" && strings.HasPrefix(image, strings.TrimSuffix(host, "/")+"/") {
+			return reg
+		}
+	}
+	return nil
+}
+
+func (s *ImageScanStep) writeDockerConfig(dir string) error {
+	auths := map[string]interface{}{}
+	for _, reg := range s.spec.Registries {
+		if reg.AccessKey == "" {
+			continue
+		}
+		host := strings.TrimPrefix(strings.TrimPrefix(reg.RegAddr, "http://"), "https://")
+		auth := base64.StdEncoding.EncodeToString([]byte(reg.AccessKey + ":" + reg.SecretKey))
+		auths[strings.TrimSuffix(host, "/")] = map[string]string{"auth": auth}
+	}
+	content, err := json.Marshal(map[string]interface{}{"auths": auths})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "config.json"), content, 0600)
+}
+
+// upload archives the file to the dest dir, the object key is returned.
+func (s *ImageScanStep) upload(file string) (string, error) {
+	if s.spec.S3DestDir == "" || s.spec.S3Storage == nil {
+		return "", nil
+	}
+	forcedPathStyle := true
+	if s.spec.S3Storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3.NewClient(s.spec.S3Storage.Endpoint, s.spec.S3Storage.Ak, s.spec.S3Storage.Sk, s.spec.S3Storage.Region, s.spec.S3Storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return "", fmt.Errorf("failed to create s3 client to upload file, err: %s", err)
+	}
+	key := strings.TrimLeft(path.Join(s.spec.S3Storage.Subfolder, s.spec.S3DestDir, filepath.Base(file)), "/")
+	if err := client.Upload(s.spec.S3Storage.Bucket, file, key); err != nil {
+		return "", fmt.Errorf("failed to upload %s: %s", filepath.Base(file), err)
+	}
+	return key, nil
+}
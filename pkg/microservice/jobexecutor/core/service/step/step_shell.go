@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/koderover/zadig/pkg/tool/log"
@@ -77,6 +78,8 @@ func (s *ShellStep) Run(ctx context.Context) error {
 	cmd := exec.Command("/bin/bash", filepath.Join(os.TempDir(), userScriptFile))
 	cmd.Dir = s.workspace
 	cmd.Env = s.envs
+	// the script runs in its own process group, so that the processes it starts are killed with it on timeout.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	fileName := filepath.Join(os.TempDir(), "user_script.log")
 	//如果文件不存在就创建文件，避免后面使用变量出错
@@ -114,6 +117,16 @@ func (s *ShellStep) Run(ctx context.Context) error {
 		return err
 	}
 
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+
 	wg.Wait()
 
 	return cmd.Wait()
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// fakeStep returns err after delay, or when the context is done if it honours the context.
+type fakeStep struct {
+	delay          time.Duration
+	err            error
+	honoursContext bool
+}
+
+func (s *fakeStep) Run(ctx context.Context) error {
+	if !s.honoursContext {
+		time.Sleep(s.delay)
+		return s.err
+	}
+	select {
+	case <-time.After(s.delay):
+		return s.err
+	case <-ctx.Done():
+		return errors.New("signal: killed")
+	}
+}
+
+var _ = Describe("Testing steps", func() {
+
+	var gracePeriod time.Duration
+	BeforeEach(func() {
+		gracePeriod = stepKillGracePeriod
+		stepKillGracePeriod = 100 * time.Millisecond
+	})
+	AfterEach(func() {
+		stepKillGracePeriod = gracePeriod
+	})
+
+	scriptErr := errors.New("exit status 1")
+
+	table.DescribeTable("running steps with a deadline",
+		func(step *fakeStep, timeout time.Duration, expectedErr error, notStopped bool) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			err := runWithDeadline(ctx, step)
+			if expectedErr == nil {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			Expect(errors.Is(err, expectedErr)).To(BeTrue(), "unexpected error: %v", err)
+			var notStoppedErr *StepNotStoppedError
+			Expect(errors.As(err, &notStoppedErr)).To(Equal(notStopped))
+		},
+		table.Entry("step passed", &fakeStep{honoursContext: true}, time.Second, nil, false),
+		table.Entry("step failed", &fakeStep{err: scriptErr, honoursContext: true}, time.Second, scriptErr, false),
+		table.Entry("step stopped at the deadline", &fakeStep{delay: time.Minute, honoursContext: true}, 50*time.Millisecond,
+			context.DeadlineExceeded, false),
+		table.Entry("step returned in the grace period", &fakeStep{delay: 120 * time.Millisecond}, 50*time.Millisecond,
+			context.DeadlineExceeded, false),
+		table.Entry("step returned after its processes were killed", &fakeStep{delay: 200 * time.Millisecond}, 50*time.Millisecond,
+			context.DeadlineExceeded, false),
+		table.Entry("step not stopped", &fakeStep{delay: time.Second}, 50*time.Millisecond, context.DeadlineExceeded, true),
+	)
+
+	It("should return the cancellation of the step", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}()
+		err := runWithDeadline(ctx, &fakeStep{delay: time.Minute, honoursContext: true})
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+	})
+})
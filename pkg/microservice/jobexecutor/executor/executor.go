@@ -19,14 +19,18 @@ package executor
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"time"
 
 	commonconfig "github.com/koderover/zadig/pkg/config"
 	job "github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service"
+	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/step"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/util/redact"
 )
 
 // const (
@@ -44,6 +48,14 @@ func Execute(ctx context.Context) error {
 	// os.Stdout = f
 	// os.Stderr = f
 
+	// everything written to stdout and stderr goes through the redactor, including the outputs of commands,
+	// secrets are added once the job context is loaded.
+	redactor := redact.New()
+	restoreStdout, err := redactStdout(redactor)
+	if err != nil {
+		return err
+	}
+
 	log.Init(&log.Config{
 		Level:       commonconfig.LogLevel(),
 		NoCaller:    true,
@@ -56,7 +68,6 @@ func Execute(ctx context.Context) error {
 	start := time.Now()
 
 	excutor := "job-executor"
-	var err error
 	defer func() {
 		// os.Remove(ZadigLifeCycleFile)
 		resultMsg := types.JobSuccess
@@ -64,13 +75,19 @@ func Execute(ctx context.Context) error {
 			resultMsg = types.JobFail
 			fmt.Printf("Failed to run: %s.\n", err)
 		}
+		// the build engine containers wait for builds until they are stopped, or the pod never ends.
+		if stopErr := step.StopBuildEngines(); stopErr != nil {
+			fmt.Printf("Failed to stop build engines: %s.\n", stopErr)
+		}
 		fmt.Printf("Job Status: %s\n", resultMsg)
+		fmt.Printf("====================== %s End. Duration: %.2f seconds ======================\n", excutor, time.Since(start).Seconds())
+		// all logs must be flushed before the dog food is written, or they may be lost when the pod is deleted.
+		restoreStdout()
+
 		dogFoodErr := ioutil.WriteFile(setting.DogFood, []byte(resultMsg), 0644)
 		if dogFoodErr != nil {
-			log.Errorf("Failed to create dog food: %s.", dogFoodErr)
+			fmt.Printf("Failed to create dog food: %s.\n", dogFoodErr)
 		}
-
-		fmt.Printf("====================== %s End. Duration: %.2f seconds ======================\n", excutor, time.Since(start).Seconds())
 		time.Sleep(30 * time.Second)
 	}()
 
@@ -79,6 +96,7 @@ func Execute(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	redactor.Add(redact.EnvValues(j.Ctx.SecretEnvs)...)
 	fmt.Printf("====================== %s Start ======================\n", excutor)
 	if err = j.Run(ctx); err != nil {
 		return err
@@ -88,3 +106,32 @@ func Execute(ctx context.Context) error {
 	}
 	return nil
 }
+
+// redactStdout redirects stdout and stderr to a pipe, whose content is written to the original stdout after secrets
+// in it are masked. The returned function restores stdout and stderr and flushes the content left.
+func redactStdout(redactor *redact.Redactor) (func(), error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create pipe for stdout error: %v", err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = writer, writer
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		redactWriter := redactor.NewWriter(stdout)
+		_, _ = io.Copy(redactWriter, reader)
+		_ = redactWriter.Flush()
+	}()
+
+	return func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		_ = writer.Close()
+		// background processes started by steps may still hold the pipe.
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}, nil
+}
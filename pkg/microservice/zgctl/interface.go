@@ -34,6 +34,9 @@ type ZgCtler interface {
 
 	// ConfigKubeconfig stores relations between env and kubeconfig.
 	ConfigKubeconfig(projectName, envName, kubeconfigPath string) error
+
+	// RunJob runs a job of a workflow task locally in a container with the job executor.
+	RunJob(ctx context.Context, opts *RunJobOptions) error
 }
 
 type ZgCtlHandler interface {
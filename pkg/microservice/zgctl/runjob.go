@@ -0,0 +1,214 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zgctl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+
+	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/meta"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+const (
+	localJobConfigDir = "/zadig-config"
+	localJobExecutor  = "/usr/local/bin/jobexecutor"
+)
+
+type RunJobOptions struct {
+	WorkflowName string
+	TaskID       int64
+	JobName      string
+	// SecretEnvFile contains secret envs and credentials of the steps in KEY=VALUE lines, the missing ones are prompted for.
+	SecretEnvFile string
+	// JobExecutorBin is the linux job executor binary mounted in the container.
+	JobExecutorBin string
+	// WorkspaceDir is the local dir mounted as the workspace of the job.
+	WorkspaceDir string
+}
+
+func (z *zgctl) RunJob(ctx context.Context, opts *RunJobOptions) error {
+	debugCtx, err := z.aslanClient.GetJobDebugContext(opts.WorkflowName, opts.TaskID, opts.JobName)
+	if err != nil {
+		return err
+	}
+	secrets, err := readSecretEnvFile(opts.SecretEnvFile)
+	if err != nil {
+		return err
+	}
+	jobCtx, err := fillCredentials(debugCtx, secrets)
+	if err != nil {
+		return err
+	}
+	jobCtx.SecretEnvs = []string{}
+	for _, key := range debugCtx.SecretEnvs {
+		value, err := getSecret(secrets, "secret env", key)
+		if err != nil {
+			return err
+		}
+		jobCtx.SecretEnvs = append(jobCtx.SecretEnvs, fmt.Sprintf("%s=%s", key, value))
+	}
+	if len(jobCtx.ServiceContainers) > 0 {
+		log.Warnf("Service containers %s are not started locally.", strings.Join(jobCtx.ServiceContainers, ", "))
+		jobCtx.ServiceContainers = nil
+	}
+
+	runDir := filepath.Join(z.homeDir, "jobs", fmt.Sprintf("%s-%d-%s", opts.WorkflowName, opts.TaskID, opts.JobName))
+	configDir := filepath.Join(runDir, "config")
+	contextDir := filepath.Join(runDir, "zadig")
+	workspaceDir := opts.WorkspaceDir
+	if workspaceDir == "" {
+		workspaceDir = filepath.Join(runDir, "workspace")
+	}
+	for _, dir := range []string{configDir, contextDir, workspaceDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create dir %q: %s", dir, err)
+		}
+	}
+	content, err := yaml.Marshal(jobCtx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job context: %s", err)
+	}
+	// the config contains secrets.
+	if err := ioutil.WriteFile(filepath.Join(configDir, "job-config.xml"), content, 0600); err != nil {
+		return fmt.Errorf("failed to write job config: %s", err)
+	}
+
+	workspaceDir, err = filepath.Abs(workspaceDir)
+	if err != nil {
+		return err
+	}
+	jobExecutorBin, err := filepath.Abs(os.ExpandEnv(opts.JobExecutorBin))
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(jobExecutorBin); err != nil {
+		return fmt.Errorf("job executor binary %q is not found, it can be built by `GOOS=linux go build ./cmd/jobexecutor`: %s", jobExecutorBin, err)
+	}
+
+	args := []string{
+		"run", "--rm",
+		"-v", fmt.Sprintf("%s:%s:ro", jobExecutorBin, localJobExecutor),
+		"-v", fmt.Sprintf("%s:%s:ro", configDir, localJobConfigDir),
+		"-v", fmt.Sprintf("%s:/zadig", contextDir),
+		"-v", fmt.Sprintf("%s:%s", workspaceDir, debugCtx.Workspace),
+		"-v", fmt.Sprintf("%s:%s", setting.DefaultDockSock, setting.DefaultDockSock),
+		"-e", fmt.Sprintf("%s=%s", setting.JobConfigFile, filepath.Join(localJobConfigDir, "job-config.xml")),
+		"-e", fmt.Sprintf("%s=unix://%s", setting.DockerHost, setting.DefaultDockSock),
+		debugCtx.Image, localJobExecutor,
+	}
+	log.Infof("Run job %s of workflow %s task %d in image %s, workspace: %s.", opts.JobName, opts.WorkflowName, opts.TaskID, debugCtx.Image, workspaceDir)
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// fillCredentials puts the credentials of the steps back into the job context, the missing ones are prompted for.
+func fillCredentials(debugCtx *types.JobDebugContext, secrets map[string]string) (*meta.JobContext, error) {
+	placeholders := map[string]string{}
+	for _, key := range debugCtx.Credentials {
+		value, err := getSecret(secrets, "credential", key)
+		if err != nil {
+			return nil, err
+		}
+		placeholders[types.JobDebugCredentialPlaceholder(key)] = value
+	}
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(debugCtx.JobContext), doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job context: %s", err)
+	}
+	var fill func(node *yaml.Node)
+	fill = func(node *yaml.Node) {
+		if node.Kind == yaml.ScalarNode {
+			if value, ok := placeholders[node.Value]; ok {
+				node.Value = value
+			}
+			return
+		}
+		for _, n := range node.Content {
+			fill(n)
+		}
+	}
+	fill(doc)
+
+	jobCtx := &meta.JobContext{}
+	if err := doc.Decode(jobCtx); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job context: %s", err)
+	}
+	return jobCtx, nil
+}
+
+// readSecretEnvFile reads the values of secret envs and credentials in KEY=VALUE lines.
+func readSecretEnvFile(secretEnvFile string) (map[string]string, error) {
+	values := map[string]string{}
+	if secretEnvFile == "" {
+		return values, nil
+	}
+	content, err := ioutil.ReadFile(secretEnvFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret env file %q: %s", secretEnvFile, err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items := strings.SplitN(line, "=", 2)
+		if len(items) != 2 {
+			continue
+		}
+		values[items[0]] = items[1]
+	}
+	return values, nil
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// getSecret returns the value of the key in the secret env file, it is prompted for if it is missing.
+func getSecret(secrets map[string]string, kind, key string) (string, error) {
+	if value, ok := secrets[key]; ok {
+		return value, nil
+	}
+	fmt.Printf("Value of %s %s: ", kind, key)
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		input, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s %s: %s", kind, key, err)
+		}
+		return string(input), nil
+	}
+	input, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s %s: %s", kind, key, err)
+	}
+	return strings.TrimRight(input, "\r\n"), nil
+}
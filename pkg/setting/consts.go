@@ -374,6 +374,7 @@ const (
 	ProductStatusDeleting = "deleting"
 	ProductStatusUnknown  = "unknown"
 	ProductStatusUnstable = "Unstable"
+	ProductStatusSleeping = "sleeping"
 )
 
 // DeliveryVersion status
@@ -746,6 +747,9 @@ const (
 const (
 	IMLark     = "lark"
 	IMDingding = "dingding"
+	IMWeCom    = "wecom"
+	// IMWebhook is a generic http approval system which is called back when the approval is decided.
+	IMWebhook = "webhook"
 )
 
 // lark app
@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/koderover/zadig/pkg/tool/httpclient"
+	"github.com/koderover/zadig/pkg/types"
 )
 
 type workflowConcurrencySettingResp struct {
@@ -42,3 +43,14 @@ func (c *Client) GetWorkflowConcurrencySetting() (*workflowConcurrencySettingRes
 	}
 	return resp, nil
 }
+
+func (c *Client) GetJobDebugContext(workflowName string, taskID int64, jobName string) (*types.JobDebugContext, error) {
+	url := fmt.Sprintf("/workflow/v4/workflowtask/workflow/%s/task/%d/job/%s/debug", workflowName, taskID, jobName)
+
+	resp := &types.JobDebugContext{}
+	_, err := c.Get(url, httpclient.SetResult(resp))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get context of job %s in task %d of workflow %s: %s", jobName, taskID, workflowName, err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,27 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	policyservice "github.com/koderover/zadig/pkg/microservice/policy/core/service"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+// GetUserRulesByProject returns the verbs of the user in the project and on the resources of it.
+func (c *Client) GetUserRulesByProject(uid, projectName string) (*policyservice.GetUserRulesByProjectResp, error) {
+	return policyservice.GetUserRulesByProject(uid, projectName, log.SugaredLogger())
+}
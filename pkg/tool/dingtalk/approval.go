@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dingtalk
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ApprovalActionAnd = "AND"
+	ApprovalActionOr  = "OR"
+
+	InstanceStatusRunning    = "RUNNING"
+	InstanceStatusTerminated = "TERMINATED"
+	InstanceStatusCompleted  = "COMPLETED"
+
+	InstanceResultAgree  = "agree"
+	InstanceResultRefuse = "refuse"
+)
+
+type FormComponentValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Approver struct {
+	ActionType string   `json:"actionType"`
+	UserIDs    []string `json:"userIds"`
+}
+
+type CreateApprovalInstanceArgs struct {
+	ProcessCode      string                `json:"processCode"`
+	OriginatorUserID string                `json:"originatorUserId"`
+	Approvers        []*Approver           `json:"approvers,omitempty"`
+	FormValues       []*FormComponentValue `json:"formComponentValues"`
+}
+
+// CreateApprovalInstance https://open.dingtalk.com/document/orgapp/create-an-approval-instance
+func (c *Client) CreateApprovalInstance(args *CreateApprovalInstanceArgs) (string, error) {
+	result := &struct {
+		InstanceID string `json:"instanceId"`
+	}{}
+	if err := c.request(http.MethodPost, "/v1.0/workflow/processInstances", args, result); err != nil {
+		return "", errors.Wrap(err, "create approval instance")
+	}
+	if result.InstanceID == "" {
+		return "", errors.New("get empty instance id")
+	}
+	return result.InstanceID, nil
+}
+
+type OperationRecord struct {
+	UserID string `json:"userId"`
+	Date   string `json:"date"`
+	Type   string `json:"type"`
+	Result string `json:"result"`
+	Remark string `json:"remark"`
+}
+
+type ApprovalInstance struct {
+	Status           string             `json:"status"`
+	Result           string             `json:"result"`
+	OperationRecords []*OperationRecord `json:"operationRecords"`
+}
+
+// GetApprovalInstance https://open.dingtalk.com/document/orgapp/obtains-the-details-of-a-single-approval-instance-pop
+func (c *Client) GetApprovalInstance(instanceID string) (*ApprovalInstance, error) {
+	result := &struct {
+		Result *ApprovalInstance `json:"result"`
+	}{}
+	if err := c.request(http.MethodGet, "/v1.0/workflow/processInstances?processInstanceId="+instanceID, nil, result); err != nil {
+		return nil, errors.Wrap(err, "get approval instance")
+	}
+	if result.Result == nil {
+		return nil, errors.New("get empty approval instance")
+	}
+	return result.Result, nil
+}
+
+// TerminateApprovalInstance https://open.dingtalk.com/document/orgapp/terminate-a-workflow-by-using-an-instance-id
+func (c *Client) TerminateApprovalInstance(instanceID, remark string) error {
+	return c.request(http.MethodPost, "/v1.0/workflow/processInstances/terminate", map[string]interface{}{
+		"processInstanceId": instanceID,
+		"isSystem":          true,
+		"remark":            remark,
+	}, nil)
+}
+
+// ParseRecordTime parses the time of operation records like 2022-10-12T15:04Z.
+func ParseRecordTime(date string) int64 {
+	for _, layout := range []string{"2006-01-02T15:04Z", time.RFC3339} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t.Unix()
+		}
+	}
+	return time.Now().Unix()
+}
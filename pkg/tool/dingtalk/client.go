@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dingtalk
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultAPIAddress = "https://api.dingtalk.com"
+	legacyAPIAddress  = "https://oapi.dingtalk.com"
+)
+
+// Client is a DingTalk open api client of an internal app, the access token is cached until it expires.
+type Client struct {
+	*req.Client
+	AppKey    string
+	AppSecret string
+
+	mu          sync.Mutex
+	accessToken string
+	expireTime  time.Time
+}
+
+func NewClient(appKey, appSecret string) *Client {
+	return &Client{
+		Client:    req.C().SetTimeout(10 * time.Second),
+		AppKey:    appKey,
+		AppSecret: appSecret,
+	}
+}
+
+type accessTokenResponse struct {
+	AccessToken string `json:"accessToken"`
+	ExpireIn    int64  `json:"expireIn"`
+}
+
+func (c *Client) getAccessToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != "" && time.Now().Before(c.expireTime) {
+		return c.accessToken, nil
+	}
+
+	result := &accessTokenResponse{}
+	resp, err := c.R().SetBodyJsonMarshal(map[string]string{
+		"appKey":    c.AppKey,
+		"appSecret": c.AppSecret,
+	}).SetResult(result).Post(defaultAPIAddress + "/v1.0/oauth2/accessToken")
+	if err != nil {
+		return "", errors.Wrap(err, "send request")
+	}
+	if !resp.IsSuccess() || result.AccessToken == "" {
+		return "", fmt.Errorf("get access token failed, status: %d, body: %s", resp.GetStatusCode(), resp.String())
+	}
+	c.accessToken = result.AccessToken
+	// refresh the token a minute before it expires.
+	c.expireTime = time.Now().Add(time.Duration(result.ExpireIn-60) * time.Second)
+	return c.accessToken, nil
+}
+
+// request sends a request to the new open api whose errors are returned with non-2xx status codes.
+func (c *Client) request(method, path string, body, result interface{}) error {
+	token, err := c.getAccessToken()
+	if err != nil {
+		return err
+	}
+	r := c.R().SetHeader("x-acs-dingtalk-access-token", token)
+	if body != nil {
+		r.SetBodyJsonMarshal(body)
+	}
+	if result != nil {
+		r.SetResult(result)
+	}
+	resp, err := r.Send(method, defaultAPIAddress+path)
+	if err != nil {
+		return errors.Wrap(err, "send request")
+	}
+	if !resp.IsSuccess() {
+		return fmt.Errorf("unexpected status code %d, body: %s", resp.GetStatusCode(), resp.String())
+	}
+	return nil
+}
+
+type legacyResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// Validate checks whether the app key and secret are correct.
+func Validate(appKey, appSecret string) error {
+	_, err := NewClient(appKey, appSecret).getAccessToken()
+	return err
+}
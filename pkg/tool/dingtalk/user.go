@@ -0,0 +1,48 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dingtalk
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// GetUserIDByMobile https://open.dingtalk.com/document/orgapp/query-users-by-phone-number
+func (c *Client) GetUserIDByMobile(mobile string) (string, error) {
+	token, err := c.getAccessToken()
+	if err != nil {
+		return "", err
+	}
+	result := &struct {
+		legacyResponse
+		Result struct {
+			UserID string `json:"userid"`
+		} `json:"result"`
+	}{}
+	_, err = c.R().SetQueryParam("access_token", token).
+		SetBodyJsonMarshal(map[string]string{"mobile": mobile}).
+		SetResult(result).
+		Post(legacyAPIAddress + "/topapi/v2/user/getbymobile")
+	if err != nil {
+		return "", errors.Wrap(err, "send request")
+	}
+	if result.ErrCode != 0 {
+		return "", fmt.Errorf("get user by mobile error: %s", result.ErrMsg)
+	}
+	return result.Result.UserID, nil
+}
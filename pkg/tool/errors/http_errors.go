@@ -290,6 +290,12 @@ var (
 
 	// ErrApproveTask ...
 	ErrApproveTask = NewHTTPError(6169, "批准工作流任务失败")
+	// ErrListApproval ...
+	ErrListApproval = NewHTTPError(6170, "列出待审批任务失败")
+	// ErrGetApproval ...
+	ErrGetApproval = NewHTTPError(6171, "获取审批详情失败")
+	// ErrUpdateQueuedTask ...
+	ErrUpdateQueuedTask = NewHTTPError(6172, "调整排队中的工作流任务失败")
 
 	//-----------------------------------------------------------------------------------------------
 	// Keystore APIs Range: 6180 - 6189
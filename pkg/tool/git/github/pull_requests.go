@@ -91,3 +91,18 @@ func (c *Client) ListFiles(ctx context.Context, owner string, repo string, numbe
 
 	return res, err
 }
+
+// CreateComment comments on the pull request, pull requests share the comments with issues in github.
+func (c *Client) CreateComment(ctx context.Context, owner string, repo string, number int, body string) (*github.IssueComment, error) {
+	comment, err := wrap(c.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: &body}))
+	if cm, ok := comment.(*github.IssueComment); ok {
+		return cm, err
+	}
+
+	return nil, err
+}
+
+func (c *Client) EditComment(ctx context.Context, owner string, repo string, commentID int64, body string) error {
+	_, err := wrap(c.Issues.EditComment(ctx, owner, repo, commentID, &github.IssueComment{Body: &body}))
+	return err
+}
@@ -53,3 +53,8 @@ func (c *Client) GetSingleCommitOfProject(owner, repo, commitSha string) (*gitla
 
 	return nil, err
 }
+
+func (c *Client) SetCommitStatus(owner, repo, sha string, opts *gitlab.SetCommitStatusOptions) error {
+	_, err := wrap(c.Commits.SetCommitStatus(generateProjectName(owner, repo), sha, opts))
+	return err
+}
@@ -295,3 +295,49 @@ func (c *Client) ListFiles(bucketName, prefix string, recursive bool) ([]string,
 
 	return ret, nil
 }
+
+// ListObjectInfos lists all objects with the given prefix recursively, with their sizes and last modified times.
+func (c *Client) ListObjectInfos(bucketName, prefix string) ([]*s3.Object, error) {
+	ret := make([]*s3.Object, 0)
+	input := &s3.ListObjectsInput{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(prefix),
+	}
+	err := c.ListObjectsPages(input, func(output *s3.ListObjectsOutput, lastPage bool) bool {
+		ret = append(ret, output.Contents...)
+		return true
+	})
+	if err != nil {
+		log.Errorf("bucket [%s] listing objects with prefix [%v] failed, error: %v", bucketName, prefix, err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ObjectExists checks whether the object exists in the bucket.
+func (c *Client) ObjectExists(bucketName, objectKey string) (bool, error) {
+	_, err := c.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err == nil {
+		return true, nil
+	}
+	if e, ok := err.(awserr.RequestFailure); ok && e.StatusCode() == 404 {
+		return false, nil
+	}
+	return false, err
+}
+
+// TouchObject copies the object to itself with new metadata, so that its last modified time is updated.
+func (c *Client) TouchObject(bucketName, objectKey string, metadata map[string]string) error {
+	opt := &s3.CopyObjectInput{
+		Bucket:            aws.String(bucketName),
+		CopySource:        aws.String(bucketName + "/" + objectKey),
+		Key:               aws.String(objectKey),
+		Metadata:          aws.StringMap(metadata),
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+	}
+	_, err := c.S3.CopyObject(opt)
+	return err
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wecom
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// ApproverAttrOr means any one of the approvers can decide, ApproverAttrAnd means all approvers must agree.
+	ApproverAttrOr  = 1
+	ApproverAttrAnd = 2
+
+	SpStatusPending  = 1
+	SpStatusApproved = 2
+	SpStatusRejected = 3
+	SpStatusRevoked  = 4
+	SpStatusDeleted  = 7
+)
+
+type Approver struct {
+	Attr    int      `json:"attr"`
+	UserIDs []string `json:"userid"`
+}
+
+type CreateApprovalInstanceArgs struct {
+	CreatorUserID string
+	TemplateID    string
+	// ControlID is the id of the text control in the template which holds the content.
+	ControlID string
+	Approvers []*Approver
+	Content   string
+	Summary   string
+}
+
+type textValue struct {
+	Text string `json:"text"`
+}
+
+type content struct {
+	Control string     `json:"control"`
+	ID      string     `json:"id"`
+	Value   *textValue `json:"value"`
+}
+
+type summaryInfo struct {
+	Text string `json:"text"`
+	Lang string `json:"lang"`
+}
+
+// CreateApprovalInstance https://developer.work.weixin.qq.com/document/path/91853
+func (c *Client) CreateApprovalInstance(args *CreateApprovalInstanceArgs) (string, error) {
+	body := map[string]interface{}{
+		"creator_userid":        args.CreatorUserID,
+		"template_id":           args.TemplateID,
+		"use_template_approver": 0,
+		"approver":              args.Approvers,
+		"apply_data": map[string]interface{}{
+			"contents": []*content{{Control: "Textarea", ID: args.ControlID, Value: &textValue{Text: args.Content}}},
+		},
+		"summary_list": []map[string]interface{}{
+			{"summary_info": []*summaryInfo{{Text: args.Summary, Lang: "zh_CN"}}},
+		},
+	}
+	result := &struct {
+		response
+		SpNo string `json:"sp_no"`
+	}{}
+	if err := c.post("/oa/applyevent", body, result); err != nil {
+		return "", errors.Wrap(err, "create approval instance")
+	}
+	return result.SpNo, nil
+}
+
+type ApprovalDetail struct {
+	Approver struct {
+		UserID string `json:"userid"`
+	} `json:"approver"`
+	Speech   string `json:"speech"`
+	SpStatus int    `json:"sp_status"`
+	SpTime   int64  `json:"sptime"`
+}
+
+type ApprovalRecord struct {
+	SpStatus int               `json:"sp_status"`
+	Details  []*ApprovalDetail `json:"details"`
+}
+
+type ApprovalInstance struct {
+	SpNo     string            `json:"sp_no"`
+	SpStatus int               `json:"sp_status"`
+	SpRecord []*ApprovalRecord `json:"sp_record"`
+}
+
+// GetApprovalInstance https://developer.work.weixin.qq.com/document/path/91983
+func (c *Client) GetApprovalInstance(spNo string) (*ApprovalInstance, error) {
+	result := &struct {
+		response
+		Info *ApprovalInstance `json:"info"`
+	}{}
+	if err := c.post("/oa/getapprovaldetail", map[string]string{"sp_no": spNo}, result); err != nil {
+		return nil, errors.Wrap(err, "get approval instance")
+	}
+	if result.Info == nil {
+		return nil, errors.New("get empty approval instance")
+	}
+	return result.Info, nil
+}
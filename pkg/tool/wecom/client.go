@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wecom
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"github.com/pkg/errors"
+)
+
+const defaultAPIAddress = "https://qyapi.weixin.qq.com/cgi-bin"
+
+// Client is a WeCom api client of a self-built app, the access token is cached until it expires.
+type Client struct {
+	*req.Client
+	CorpID string
+	Secret string
+
+	mu          sync.Mutex
+	accessToken string
+	expireTime  time.Time
+}
+
+func NewClient(corpID, secret string) *Client {
+	return &Client{
+		Client: req.C().SetTimeout(10 * time.Second),
+		CorpID: corpID,
+		Secret: secret,
+	}
+}
+
+type response struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (r *response) err() error {
+	if r.ErrCode != 0 {
+		return fmt.Errorf("errcode: %d, errmsg: %s", r.ErrCode, r.ErrMsg)
+	}
+	return nil
+}
+
+type errResponse interface {
+	err() error
+}
+
+func (c *Client) getAccessToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != "" && time.Now().Before(c.expireTime) {
+		return c.accessToken, nil
+	}
+
+	result := &struct {
+		response
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	_, err := c.R().SetQueryParams(map[string]string{
+		"corpid":     c.CorpID,
+		"corpsecret": c.Secret,
+	}).SetResult(result).Get(defaultAPIAddress + "/gettoken")
+	if err != nil {
+		return "", errors.Wrap(err, "send request")
+	}
+	if err := result.err(); err != nil {
+		return "", errors.Wrap(err, "get access token")
+	}
+	c.accessToken = result.AccessToken
+	// refresh the token a minute before it expires.
+	c.expireTime = time.Now().Add(time.Duration(result.ExpiresIn-60) * time.Second)
+	return c.accessToken, nil
+}
+
+func (c *Client) post(path string, body interface{}, result errResponse) error {
+	token, err := c.getAccessToken()
+	if err != nil {
+		return err
+	}
+	_, err = c.R().SetQueryParam("access_token", token).
+		SetBodyJsonMarshal(body).
+		SetResult(result).
+		Post(defaultAPIAddress + path)
+	if err != nil {
+		return errors.Wrap(err, "send request")
+	}
+	return result.err()
+}
+
+// Validate checks whether the corp id and secret are correct.
+func Validate(corpID, secret string) error {
+	_, err := NewClient(corpID, secret).getAccessToken()
+	return err
+}
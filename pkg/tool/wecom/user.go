@@ -0,0 +1,33 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wecom
+
+import (
+	"github.com/pkg/errors"
+)
+
+// GetUserIDByMobile https://developer.work.weixin.qq.com/document/path/95402
+func (c *Client) GetUserIDByMobile(mobile string) (string, error) {
+	result := &struct {
+		response
+		UserID string `json:"userid"`
+	}{}
+	if err := c.post("/user/getuserid", map[string]string{"mobile": mobile}, result); err != nil {
+		return "", errors.Wrap(err, "get user by mobile")
+	}
+	return result.UserID, nil
+}
@@ -31,3 +31,23 @@ type StartDevmodeInfo struct {
 const IDESidecarImage = "koderover.tencentcloudcr.com/koderover-public/zgctl-sidecar:20220526172433-amd64"
 
 const DevmodeWorkDir = "/home/zadig/"
+
+// JobDebugContext is the rendered context of a job run by the job executor, it is used to run the job locally.
+type JobDebugContext struct {
+	JobName string `json:"job_name"`
+	// Image is the base image of the job.
+	Image     string `json:"image"`
+	Workspace string `json:"workspace"`
+	// JobContext is the job config consumed by the job executor in yaml, values of secret envs and credentials are removed.
+	JobContext string `json:"job_context"`
+	// SecretEnvs are the keys of the secret envs, which should be provided locally.
+	SecretEnvs []string `json:"secret_envs"`
+	// Credentials are the keys of the credentials in the steps, which should be provided locally.
+	// The value of each credential is replaced by JobDebugCredentialPlaceholder(key) in JobContext.
+	Credentials       []string `json:"credentials"`
+	ServiceContainers []string `json:"service_containers"`
+}
+
+func JobDebugCredentialPlaceholder(key string) string {
+	return "${ZADIG_CREDENTIAL:" + key + "}"
+}
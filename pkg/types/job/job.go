@@ -18,6 +18,7 @@ package job
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/koderover/zadig/pkg/setting"
@@ -26,13 +27,85 @@ import (
 const (
 	JobOutputDir       = "/zadig/results/"
 	JobTerminationFile = "/zadig/termination"
+	// ServicesReadyFile is created in the job container when all service containers are ready.
+	ServicesReadyFile = "/zadig/services-ready"
+	// JobStepResultFile saves the results of the steps, it is read by aslan when the job is completed.
+	JobStepResultFile = "/zadig/step-results"
 )
 
+// RunIf decides whether a step runs according to the results of the previous steps.
+type RunIf string
+
+const (
+	// RunIfOnSuccess runs the step only when all previous steps succeeded, it is the default.
+	RunIfOnSuccess RunIf = "on_success"
+	// RunIfOnFailure runs the step only when any previous step failed, like collecting logs for debugging.
+	RunIfOnFailure RunIf = "on_failure"
+	// RunIfAlways runs the step whatever the previous steps result, like tearing down test fixtures.
+	RunIfAlways RunIf = "always"
+)
+
+// ShouldRun returns whether the step runs when the previous steps failed or not, an empty value means on_success.
+func (r RunIf) ShouldRun(failed bool) bool {
+	switch r {
+	case RunIfAlways:
+		return true
+	case RunIfOnFailure:
+		return failed
+	default:
+		return !failed
+	}
+}
+
+type StepStatus string
+
+const (
+	StepStatusPassed  StepStatus = "passed"
+	StepStatusFailed  StepStatus = "failed"
+	StepStatusTimeout StepStatus = "timeout"
+	StepStatusSkipped StepStatus = "skipped"
+)
+
+type StepResult struct {
+	Name      string     `json:"name"`
+	Status    StepStatus `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	StartTime int64      `json:"start_time,omitempty"`
+	EndTime   int64      `json:"end_time,omitempty"`
+}
+
 type JobOutput struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
+	// ObjectKey is set when the value is too large for the termination message and saved in object storage.
+	ObjectKey string `json:"object_key,omitempty"`
 }
 
 func GetJobOutputKey(key, outputName string) string {
 	return fmt.Sprintf(setting.RenderValueTemplate, strings.Join([]string{"job", key, "output", outputName}, "."))
 }
+
+// GetArtifactObjectKey returns the object key of an artifact uploaded by a job of the workflow task.
+func GetArtifactObjectKey(workflowName string, taskID int64, jobName, artifactName string) string {
+	return path.Join(workflowName, fmt.Sprintf("%d", taskID), "artifacts", jobName, artifactName+".tar.gz")
+}
+
+// GetCacheObjectPrefix returns the object key prefix of the dependency caches of a project.
+func GetCacheObjectPrefix(projectName string) string {
+	return path.Join("cache", projectName) + "/"
+}
+
+// GetOutputObjectPrefix returns the object key prefix of the large outputs of a job.
+func GetOutputObjectPrefix(workflowName string, taskID int64, jobName string) string {
+	return path.Join(workflowName, fmt.Sprintf("%d", taskID), "outputs", jobName)
+}
+
+// GetServiceContainerLogName returns the name to save the container log of a service container of the job.
+func GetServiceContainerLogName(jobName, serviceName string) string {
+	return fmt.Sprintf("%s-service-%s", jobName, serviceName)
+}
+
+// GetJobAttemptLogName returns the name to save the container log of a failed attempt of the job.
+func GetJobAttemptLogName(jobName string, attempt int) string {
+	return fmt.Sprintf("%s-attempt-%d", jobName, attempt)
+}
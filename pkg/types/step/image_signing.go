@@ -0,0 +1,50 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+// ImageSigningDir is shared by the job container and the init container which provides cosign.
+const ImageSigningDir = "/zadig/image-signing"
+
+// ImageSigningKeyDir holds the signing keys mounted from a secret when the job runs, the files are named by the key ids.
+const ImageSigningKeyDir = "/etc/zadig/image-signing-keys"
+
+// ImageSigning signs the digest of the pushed image with the private key KeyID, and attaches Provenance to the image
+// as a SLSA provenance attestation if it is not nil.
+type ImageSigning struct {
+	KeyID      string      `bson:"key_id"                 json:"key_id"                 yaml:"key_id"`
+	Provenance *Provenance `bson:"provenance,omitempty"   json:"provenance,omitempty"   yaml:"provenance,omitempty"`
+}
+
+// Provenance describes which workflow task and source code build the image.
+type Provenance struct {
+	BuilderID     string            `bson:"builder_id"         json:"builder_id"         yaml:"builder_id"`
+	InvocationURL string            `bson:"invocation_url"     json:"invocation_url"     yaml:"invocation_url"`
+	ProjectName   string            `bson:"project_name"       json:"project_name"       yaml:"project_name"`
+	WorkflowName  string            `bson:"workflow_name"      json:"workflow_name"      yaml:"workflow_name"`
+	TaskID        int64             `bson:"task_id"            json:"task_id"            yaml:"task_id"`
+	JobName       string            `bson:"job_name"           json:"job_name"           yaml:"job_name"`
+	Repos         []*ProvenanceRepo `bson:"repos"              json:"repos"              yaml:"repos"`
+}
+
+// ProvenanceRepo is a source repository of the image, the commit is read from the checkout path in the
+// workspace if CommitID is empty.
+type ProvenanceRepo struct {
+	URL          string `bson:"url"                json:"url"                yaml:"url"`
+	Ref          string `bson:"ref"                json:"ref"                yaml:"ref"`
+	CommitID     string `bson:"commit_id"          json:"commit_id"          yaml:"commit_id"`
+	CheckoutPath string `bson:"checkout_path"      json:"checkout_path"      yaml:"checkout_path"`
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+// StepArtifactSpec is the spec of upload_artifact and download_artifact steps, artifacts are shared
+// between jobs of the same workflow task through object storage.
+type StepArtifactSpec struct {
+	Artifacts []*Artifact `bson:"artifacts"                          json:"artifacts"                                 yaml:"artifacts"`
+	S3        *S3         `bson:"s3_storage"                         json:"s3_storage"                                yaml:"s3_storage"`
+}
+
+// Artifact is a file or directory relative to the workspace, for download_artifact steps, Path is the
+// directory to extract into and JobName is the upstream job which uploads the artifact.
+type Artifact struct {
+	Name      string `bson:"name"                                   json:"name"                                      yaml:"name"`
+	Path      string `bson:"path"                                   json:"path"                                      yaml:"path"`
+	JobName   string `bson:"job_name,omitempty"                     json:"job_name,omitempty"                        yaml:"job_name,omitempty"`
+	ObjectKey string `bson:"object_key,omitempty"                   json:"object_key,omitempty"                      yaml:"object_key,omitempty"`
+}
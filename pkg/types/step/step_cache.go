@@ -0,0 +1,32 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+// StepCacheSpec is the spec of cache_restore and cache_save steps. Caches are shared by all workflows of a project
+// through object storage, Key is a template like go-{{ hashFiles "go.sum" }}, and the RestoreKeys are prefixes
+// tried in order when no cache of the key exists, the latest cache matching a prefix is restored.
+type StepCacheSpec struct {
+	Key         string   `bson:"key"                                json:"key"                                       yaml:"key"`
+	RestoreKeys []string `bson:"restore_keys,omitempty"             json:"restore_keys,omitempty"                    yaml:"restore_keys,omitempty"`
+	Paths       []string `bson:"paths"                              json:"paths"                                     yaml:"paths"`
+	// MaxSize is the max size of the compressed cache in MB, larger caches are not saved.
+	MaxSize      int64  `bson:"max_size,omitempty"                 json:"max_size,omitempty"                        yaml:"max_size,omitempty"`
+	ObjectPrefix string `bson:"object_prefix,omitempty"            json:"object_prefix,omitempty"                   yaml:"object_prefix,omitempty"`
+	S3           *S3    `bson:"s3_storage"                         json:"s3_storage"                                yaml:"s3_storage"`
+}
+
+const DefaultCacheMaxSize int64 = 2048
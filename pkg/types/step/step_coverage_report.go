@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import "fmt"
+
+type CoverageFormat string
+
+const (
+	CoverageFormatGo        CoverageFormat = "go"
+	CoverageFormatCobertura CoverageFormat = "cobertura"
+	CoverageFormatJacoco    CoverageFormat = "jacoco"
+)
+
+// StepCoverageReportSpec is the spec of coverage_report steps. The report is parsed to check the gates and uploaded
+// as it is, the coverage of every run is recorded by the test name.
+type StepCoverageReportSpec struct {
+	ReportPath string `bson:"report_path"                        json:"report_path"                               yaml:"report_path"`
+	// Format is detected from the content of the report if it is empty.
+	Format    CoverageFormat `bson:"format,omitempty"                   json:"format,omitempty"                          yaml:"format,omitempty"`
+	S3DestDir string         `bson:"s3_dest_dir"                        json:"s3_dest_dir"                               yaml:"s3_dest_dir"`
+	FileName  string         `bson:"file_name"                          json:"file_name"                                 yaml:"file_name"`
+	TestName  string         `bson:"test_name"                          json:"test_name"                                 yaml:"test_name"`
+	Branch    string         `bson:"branch,omitempty"                   json:"branch,omitempty"                          yaml:"branch,omitempty"`
+	PR        int            `bson:"pr,omitempty"                       json:"pr,omitempty"                              yaml:"pr,omitempty"`
+	// MinLineCoverage and MinBranchCoverage are percentages, 0 means no gate.
+	MinLineCoverage   float64 `bson:"min_line_coverage,omitempty"        json:"min_line_coverage,omitempty"               yaml:"min_line_coverage,omitempty"`
+	MinBranchCoverage float64 `bson:"min_branch_coverage,omitempty"      json:"min_branch_coverage,omitempty"             yaml:"min_branch_coverage,omitempty"`
+	// MaxRegression is the max drop of line coverage in percentage points compared with the latest run on BaseBranch.
+	MaxRegression float64          `bson:"max_regression,omitempty"           json:"max_regression,omitempty"                  yaml:"max_regression,omitempty"`
+	BaseBranch    string           `bson:"base_branch,omitempty"              json:"base_branch,omitempty"                     yaml:"base_branch,omitempty"`
+	BaseCoverage  *CoverageSummary `bson:"base_coverage,omitempty"            json:"base_coverage,omitempty"                   yaml:"base_coverage,omitempty"`
+	S3Storage     *S3              `bson:"s3_storage"                         json:"s3_storage"                                yaml:"s3_storage"`
+}
+
+// CoverageSummary is the coverage of a report, the coverages are percentages. Go reports count statements as lines
+// and have no branches.
+type CoverageSummary struct {
+	Format          CoverageFormat `bson:"format"                             json:"format"                                    yaml:"format"`
+	LinesCovered    int            `bson:"lines_covered"                      json:"lines_covered"                             yaml:"lines_covered"`
+	LinesValid      int            `bson:"lines_valid"                        json:"lines_valid"                               yaml:"lines_valid"`
+	BranchesCovered int            `bson:"branches_covered"                   json:"branches_covered"                          yaml:"branches_covered"`
+	BranchesValid   int            `bson:"branches_valid"                     json:"branches_valid"                            yaml:"branches_valid"`
+	LineCoverage    float64        `bson:"line_coverage"                      json:"line_coverage"                             yaml:"line_coverage"`
+	BranchCoverage  float64        `bson:"branch_coverage"                    json:"branch_coverage"                           yaml:"branch_coverage"`
+}
+
+// CheckGates returns an error if the coverage is below the thresholds or regresses too much.
+func (s *StepCoverageReportSpec) CheckGates(summary *CoverageSummary) error {
+	if s.MinLineCoverage > 0 && summary.LineCoverage < s.MinLineCoverage {
+		return fmt.Errorf("line coverage %.2f%% is below the threshold %.2f%%", summary.LineCoverage, s.MinLineCoverage)
+	}
+	if s.MinBranchCoverage > 0 && summary.BranchesValid > 0 && summary.BranchCoverage < s.MinBranchCoverage {
+		return fmt.Errorf("branch coverage %.2f%% is below the threshold %.2f%%", summary.BranchCoverage, s.MinBranchCoverage)
+	}
+	if s.MaxRegression > 0 && s.BaseCoverage != nil && s.BaseCoverage.LineCoverage-summary.LineCoverage > s.MaxRegression {
+		return fmt.Errorf("line coverage %.2f%% regresses by more than %.2f%% compared with %.2f%% on branch %s",
+			summary.LineCoverage, s.MaxRegression, s.BaseCoverage.LineCoverage, s.BaseBranch)
+	}
+	return nil
+}
+
+func (s *StepCoverageReportSpec) Validate() error {
+	if s.ReportPath == "" {
+		return fmt.Errorf("coverage report path is not set")
+	}
+	switch s.Format {
+	case "", CoverageFormatGo, CoverageFormatCobertura, CoverageFormatJacoco:
+	default:
+		return fmt.Errorf("unsupported coverage report format: %s", s.Format)
+	}
+	for _, threshold := range []float64{s.MinLineCoverage, s.MinBranchCoverage, s.MaxRegression} {
+		if threshold < 0 || threshold > 100 {
+			return fmt.Errorf("coverage thresholds must be between 0 and 100")
+		}
+	}
+	return nil
+}
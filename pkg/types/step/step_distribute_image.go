@@ -20,6 +20,7 @@ type StepImageDistributeSpec struct {
 	SourceRegistry   *RegistryNamespace      `bson:"source_registry"                json:"source_registry"               yaml:"source_registry"`
 	TargetRegistry   *RegistryNamespace      `bson:"target_registry"                json:"target_registry"               yaml:"target_registry"`
 	DistributeTarget []*DistributeTaskTarget `bson:"distribute_target"              json:"distribute_target"             yaml:"distribute_target"`
+	Signing          *ImageSigning           `bson:"signing,omitempty"              json:"signing,omitempty"             yaml:"signing,omitempty"`
 }
 
 type DistributeTaskTarget struct {
@@ -29,6 +30,8 @@ type DistributeTaskTarget struct {
 	ServiceName   string `bson:"service_name"       yaml:"service_name"     json:"service_name"`
 	ServiceModule string `bson:"service_module"     yaml:"service_module"   json:"service_module"`
 	UpdateTag     bool   `bson:"update_tag"         yaml:"update_tag"       json:"update_tag"`
+	// MatrixSuffix is set if the source image is built by an expanded job task of a matrix build job.
+	MatrixSuffix string `bson:"matrix_suffix,omitempty" yaml:"matrix_suffix,omitempty" json:"matrix_suffix,omitempty"`
 }
 
 type RegistryNamespace struct {
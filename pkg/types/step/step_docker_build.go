@@ -18,10 +18,32 @@ package step
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/koderover/zadig/pkg/setting"
 )
 
+// BuildEngine is the tool used by the docker build step to build and push images.
+type BuildEngine string
+
+const (
+	// BuildEngineDocker builds with the docker daemon of dind or the host.
+	BuildEngineDocker BuildEngine = "docker"
+	// BuildEngineBuildKit builds with a rootless buildkitd sidecar, multi-arch builds are supported.
+	BuildEngineBuildKit BuildEngine = "buildkit"
+	// BuildEngineKaniko builds with a kaniko sidecar, no docker daemon is needed.
+	BuildEngineKaniko BuildEngine = "kaniko"
+)
+
+const (
+	// BuildEngineDir is shared by the job container and the build engine containers.
+	BuildEngineDir = "/zadig/build-engine"
+	// BuildKitAddr is the address buildkitd listens on in the job pod.
+	BuildKitAddr = "tcp://127.0.0.1:1234"
+)
+
+// StepDockerBuildSpec builds and pushes ImageName with BuildEngine, a manifest list is pushed if more than one
+// of the Platforms is set, and CacheRef is the registry reference of the remote layer cache if it is not empty.
 type StepDockerBuildSpec struct {
 	Source                string          `bson:"source"                              json:"source"                                 yaml:"source"`
 	WorkDir               string          `bson:"work_dir"                            json:"work_dir"                               yaml:"work_dir"`
@@ -34,6 +56,10 @@ type StepDockerBuildSpec struct {
 	Proxy                 *Proxy          `bson:"proxy"                               json:"proxy"                                  yaml:"proxy"`
 	IgnoreCache           bool            `bson:"ignore_cache"                        json:"ignore_cache"                           yaml:"ignore_cache"`
 	DockerRegistry        *DockerRegistry `bson:"docker_registry"                     json:"docker_registry"                        yaml:"docker_registry"`
+	BuildEngine           BuildEngine     `bson:"build_engine,omitempty"              json:"build_engine,omitempty"                 yaml:"build_engine,omitempty"`
+	Platforms             []string        `bson:"platforms,omitempty"                 json:"platforms,omitempty"                    yaml:"platforms,omitempty"`
+	CacheRef              string          `bson:"cache_ref,omitempty"                 json:"cache_ref,omitempty"                    yaml:"cache_ref,omitempty"`
+	Signing               *ImageSigning   `bson:"signing,omitempty"                   json:"signing,omitempty"                      yaml:"signing,omitempty"`
 }
 
 type DockerRegistry struct {
@@ -54,3 +80,33 @@ func (s *StepDockerBuildSpec) GetDockerFile() string {
 	}
 	return s.DockerFile
 }
+
+func (s *StepDockerBuildSpec) GetBuildEngine() BuildEngine {
+	if s.BuildEngine == "" {
+		return BuildEngineDocker
+	}
+	return s.BuildEngine
+}
+
+// Validate checks whether the build options are supported by the build engine.
+func (s *StepDockerBuildSpec) Validate() error {
+	return ValidateBuildEngine(s.GetBuildEngine(), s.Platforms)
+}
+
+func ValidateBuildEngine(engine BuildEngine, platforms []string) error {
+	switch engine {
+	case "", BuildEngineDocker, BuildEngineKaniko:
+		if len(platforms) > 1 {
+			return fmt.Errorf("multi-platform build is only supported by %s", BuildEngineBuildKit)
+		}
+	case BuildEngineBuildKit:
+	default:
+		return fmt.Errorf("unknown build engine: %s", engine)
+	}
+	for _, platform := range platforms {
+		if parts := strings.Split(platform, "/"); len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid platform: %s, it should be like linux/amd64", platform)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SBOMFormat is the format of the software bill of materials generated by the image scan step.
+type SBOMFormat string
+
+const (
+	SBOMFormatSPDX      SBOMFormat = "spdx-json"
+	SBOMFormatCycloneDX SBOMFormat = "cyclonedx"
+)
+
+const (
+	// ImageScanDir is shared by the job container and the init container which provides the scanner.
+	ImageScanDir = "/zadig/image-scan"
+	// ImageScanSummaryFile is the name of the summary uploaded with the reports, aslan reads it after the step.
+	ImageScanSummaryFile = "summary.json"
+)
+
+var defaultFailSeverities = []string{"CRITICAL"}
+var defaultWarnSeverities = []string{"HIGH"}
+
+// StepImageScanSpec scans the images of Targets for vulnerabilities and generates their SBOMs, the step fails
+// if any vulnerability of FailSeverities is found, and IgnoredCVEs are left out of the gates.
+type StepImageScanSpec struct {
+	Targets        []*ImageScanTarget   `bson:"targets"                    json:"targets"                    yaml:"targets"`
+	Registries     []*RegistryNamespace `bson:"registries"                 json:"registries"                 yaml:"registries"`
+	SBOMFormat     SBOMFormat           `bson:"sbom_format"                json:"sbom_format"                yaml:"sbom_format"`
+	FailSeverities []string             `bson:"fail_severities"            json:"fail_severities"            yaml:"fail_severities"`
+	WarnSeverities []string             `bson:"warn_severities"            json:"warn_severities"            yaml:"warn_severities"`
+	IgnoredCVEs    []string             `bson:"ignored_cves"               json:"ignored_cves"               yaml:"ignored_cves"`
+	S3DestDir      string               `bson:"s3_dest_dir"                json:"s3_dest_dir"                yaml:"s3_dest_dir"`
+	S3Storage      *S3                  `bson:"s3_storage"                 json:"s3_storage"                 yaml:"s3_storage"`
+}
+
+type ImageScanTarget struct {
+	ServiceName   string `bson:"service_name"       json:"service_name"       yaml:"service_name"`
+	ServiceModule string `bson:"service_module"     json:"service_module"     yaml:"service_module"`
+	Image         string `bson:"image"              json:"image"              yaml:"image"`
+}
+
+// ImageScanResult is the result of one target, Vulnerabilities counts the vulnerabilities by severity
+// except the ignored ones.
+type ImageScanResult struct {
+	ServiceName     string         `bson:"service_name"       json:"service_name"       yaml:"service_name"`
+	ServiceModule   string         `bson:"service_module"     json:"service_module"     yaml:"service_module"`
+	Image           string         `bson:"image"              json:"image"              yaml:"image"`
+	Vulnerabilities map[string]int `bson:"vulnerabilities"    json:"vulnerabilities"    yaml:"vulnerabilities"`
+	Ignored         int            `bson:"ignored"            json:"ignored"            yaml:"ignored"`
+	ReportKey       string         `bson:"report_key"         json:"report_key"         yaml:"report_key"`
+	SBOMKey         string         `bson:"sbom_key"           json:"sbom_key"           yaml:"sbom_key"`
+	Passed          bool           `bson:"passed"             json:"passed"             yaml:"passed"`
+}
+
+func (s *StepImageScanSpec) GetSBOMFormat() SBOMFormat {
+	if s.SBOMFormat == "" {
+		return SBOMFormatSPDX
+	}
+	return s.SBOMFormat
+}
+
+func (s *StepImageScanSpec) GetFailSeverities() []string {
+	if len(s.FailSeverities) == 0 {
+		return defaultFailSeverities
+	}
+	return s.FailSeverities
+}
+
+func (s *StepImageScanSpec) GetWarnSeverities() []string {
+	if len(s.WarnSeverities) == 0 {
+		return defaultWarnSeverities
+	}
+	return s.WarnSeverities
+}
+
+// IsIgnored reports whether the vulnerability is in the allow-list.
+func (s *StepImageScanSpec) IsIgnored(id string) bool {
+	for _, cve := range s.IgnoredCVEs {
+		if strings.EqualFold(strings.TrimSpace(cve), id) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckGates returns the gates the result fails and the severities it warns on.
+func (s *StepImageScanSpec) CheckGates(result *ImageScanResult) (failed, warned []string) {
+	for _, severity := range s.GetFailSeverities() {
+		if count := result.Vulnerabilities[strings.ToUpper(severity)]; count > 0 {
+			failed = append(failed, fmt.Sprintf("%d %s", count, strings.ToUpper(severity)))
+		}
+	}
+	for _, severity := range s.GetWarnSeverities() {
+		if count := result.Vulnerabilities[strings.ToUpper(severity)]; count > 0 {
+			warned = append(warned, fmt.Sprintf("%d %s", count, strings.ToUpper(severity)))
+		}
+	}
+	return failed, warned
+}
+
+func (s *StepImageScanSpec) Validate() error {
+	switch s.GetSBOMFormat() {
+	case SBOMFormatSPDX, SBOMFormatCycloneDX:
+	default:
+		return fmt.Errorf("unsupported sbom format: %s", s.SBOMFormat)
+	}
+	for _, severity := range append(s.GetFailSeverities(), s.GetWarnSeverities()...) {
+		switch strings.ToUpper(severity) {
+		case "CRITICAL", "HIGH", "MEDIUM", "LOW", "UNKNOWN":
+		default:
+			return fmt.Errorf("unsupported severity: %s", severity)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package coverage parses code coverage reports of Go coverprofile, Cobertura and JaCoCo XML.
+package coverage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+// Detect detects the format of a report by its content.
+func Detect(data []byte) (step.CoverageFormat, error) {
+	content := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(content, []byte("mode:")):
+		return step.CoverageFormatGo, nil
+	case bytes.Contains(content, []byte("<coverage")):
+		return step.CoverageFormatCobertura, nil
+	case bytes.Contains(content, []byte("<report")):
+		return step.CoverageFormatJacoco, nil
+	default:
+		return "", fmt.Errorf("unknown coverage report format")
+	}
+}
+
+// Parse parses a report, the format is detected if it is empty.
+func Parse(data []byte, format step.CoverageFormat) (*step.CoverageSummary, error) {
+	var err error
+	if format == "" {
+		if format, err = Detect(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var summary *step.CoverageSummary
+	switch format {
+	case step.CoverageFormatGo:
+		summary, err = parseGo(data)
+	case step.CoverageFormatCobertura:
+		summary, err = parseCobertura(data)
+	case step.CoverageFormatJacoco:
+		summary, err = parseJacoco(data)
+	default:
+		return nil, fmt.Errorf("unsupported coverage report format: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s coverage report error: %v", format, err)
+	}
+	summary.Format = format
+	summary.LineCoverage = percent(summary.LinesCovered, summary.LinesValid)
+	summary.BranchCoverage = percent(summary.BranchesCovered, summary.BranchesValid)
+	return summary, nil
+}
+
+func percent(covered, valid int) float64 {
+	if valid == 0 {
+		return 0
+	}
+	return math.Round(float64(covered)*10000/float64(valid)) / 100
+}
+
+// parseGo parses a coverprofile like:
+//
+//	mode: set
+//	github.com/koderover/zadig/pkg/util/file.go:25.40,27.2 1 1
+//
+// blocks may be repeated when profiles of packages are merged, a block is covered if it is covered in any of them.
+func parseGo(data []byte) (*step.CoverageSummary, error) {
+	type block struct {
+		statements int
+		covered    bool
+	}
+	blocks := map[string]*block{}
+	order := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid line: %s", line)
+		}
+		statements, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid line: %s", line)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid line: %s", line)
+		}
+		b, ok := blocks[fields[0]]
+		if !ok {
+			b = &block{statements: statements}
+			blocks[fields[0]] = b
+			order = append(order, fields[0])
+		}
+		b.covered = b.covered || count > 0
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	summary := &step.CoverageSummary{}
+	for _, key := range order {
+		summary.LinesValid += blocks[key].statements
+		if blocks[key].covered {
+			summary.LinesCovered += blocks[key].statements
+		}
+	}
+	return summary, nil
+}
+
+type coberturaReport struct {
+	LinesCovered    *int `xml:"lines-covered,attr"`
+	LinesValid      *int `xml:"lines-valid,attr"`
+	BranchesCovered *int `xml:"branches-covered,attr"`
+	BranchesValid   *int `xml:"branches-valid,attr"`
+	Packages        []struct {
+		Classes []struct {
+			Lines []coberturaLine `xml:"lines>line"`
+		} `xml:"classes>class"`
+	} `xml:"packages>package"`
+}
+
+type coberturaLine struct {
+	Hits              int    `xml:"hits,attr"`
+	Branch            bool   `xml:"branch,attr"`
+	ConditionCoverage string `xml:"condition-coverage,attr"`
+}
+
+// parseCobertura uses the totals of the report, or counts the lines of classes if the report has no totals.
+func parseCobertura(data []byte) (*step.CoverageSummary, error) {
+	report := &coberturaReport{}
+	if err := xml.Unmarshal(data, report); err != nil {
+		return nil, err
+	}
+	if report.LinesCovered != nil && report.LinesValid != nil {
+		summary := &step.CoverageSummary{LinesCovered: *report.LinesCovered, LinesValid: *report.LinesValid}
+		if report.BranchesCovered != nil && report.BranchesValid != nil {
+			summary.BranchesCovered = *report.BranchesCovered
+			summary.BranchesValid = *report.BranchesValid
+		}
+		return summary, nil
+	}
+
+	summary := &step.CoverageSummary{}
+	for _, pkg := range report.Packages {
+		for _, class := range pkg.Classes {
+			for _, line := range class.Lines {
+				summary.LinesValid++
+				if line.Hits > 0 {
+					summary.LinesCovered++
+				}
+				// condition-coverage is like "50% (1/2)"
+				if !line.Branch || line.ConditionCoverage == "" {
+					continue
+				}
+				var rate, covered, valid int
+				if _, err := fmt.Sscanf(line.ConditionCoverage, "%d%% (%d/%d)", &rate, &covered, &valid); err == nil {
+					summary.BranchesCovered += covered
+					summary.BranchesValid += valid
+				}
+			}
+		}
+	}
+	return summary, nil
+}
+
+type jacocoReport struct {
+	Counters []struct {
+		Type    string `xml:"type,attr"`
+		Missed  int    `xml:"missed,attr"`
+		Covered int    `xml:"covered,attr"`
+	} `xml:"counter"`
+}
+
+// parseJacoco uses the counters of the report element, which are the totals of all packages.
+func parseJacoco(data []byte) (*step.CoverageSummary, error) {
+	report := &jacocoReport{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// reports refer to an external DTD which is not needed.
+	decoder.Strict = false
+	if err := decoder.Decode(report); err != nil {
+		return nil, err
+	}
+	summary := &step.CoverageSummary{}
+	found := false
+	for _, counter := range report.Counters {
+		switch counter.Type {
+		case "LINE":
+			found = true
+			summary.LinesCovered = counter.Covered
+			summary.LinesValid = counter.Covered + counter.Missed
+		case "BRANCH":
+			summary.BranchesCovered = counter.Covered
+			summary.BranchesValid = counter.Covered + counter.Missed
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no line counter found in the report")
+	}
+	return summary, nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package coverage_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util/coverage"
+)
+
+const goProfile = `mode: set
+github.com/koderover/zadig/pkg/util/a.go:10.20,12.2 2 1
+github.com/koderover/zadig/pkg/util/a.go:14.20,16.2 1 0
+github.com/koderover/zadig/pkg/util/a.go:14.20,16.2 1 1
+github.com/koderover/zadig/pkg/util/b.go:3.10,8.2 5 0
+`
+
+const coberturaReport = `<?xml version="1.0" ?>
+<coverage line-rate="0.5" branch-rate="0.25" lines-covered="5" lines-valid="10" branches-covered="1" branches-valid="4" version="5.5">
+	<packages/>
+</coverage>`
+
+const coberturaReportWithoutTotals = `<?xml version="1.0" ?>
+<coverage line-rate="0.5" branch-rate="0.5">
+	<packages><package name="app"><classes><class name="main.py"><lines>
+		<line number="1" hits="1"/>
+		<line number="2" hits="0"/>
+		<line number="3" hits="2" branch="true" condition-coverage="50% (1/2)"/>
+		<line number="4" hits="0"/>
+	</lines></class></classes></package></packages>
+</coverage>`
+
+const jacocoReport = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<!DOCTYPE report PUBLIC "-//JACOCO//DTD Report 1.1//EN" "report.dtd">
+<report name="demo">
+	<package name="com/example"><counter type="LINE" missed="100" covered="100"/></package>
+	<counter type="INSTRUCTION" missed="30" covered="70"/>
+	<counter type="BRANCH" missed="3" covered="1"/>
+	<counter type="LINE" missed="1" covered="3"/>
+</report>`
+
+var _ = Describe("Testing coverage", func() {
+
+	It("should parse go coverprofiles", func() {
+		summary, err := coverage.Parse([]byte(goProfile), "")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(summary.Format).To(Equal(step.CoverageFormatGo))
+		Expect(summary.LinesCovered).To(Equal(3))
+		Expect(summary.LinesValid).To(Equal(8))
+		Expect(summary.LineCoverage).To(Equal(37.5))
+	})
+
+	It("should parse cobertura reports", func() {
+		summary, err := coverage.Parse([]byte(coberturaReport), "")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(summary.Format).To(Equal(step.CoverageFormatCobertura))
+		Expect(summary.LineCoverage).To(Equal(50.0))
+		Expect(summary.BranchCoverage).To(Equal(25.0))
+
+		summary, err = coverage.Parse([]byte(coberturaReportWithoutTotals), step.CoverageFormatCobertura)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(summary.LinesCovered).To(Equal(2))
+		Expect(summary.LinesValid).To(Equal(4))
+		Expect(summary.BranchesCovered).To(Equal(1))
+		Expect(summary.BranchesValid).To(Equal(2))
+	})
+
+	It("should parse jacoco reports", func() {
+		summary, err := coverage.Parse([]byte(jacocoReport), "")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(summary.Format).To(Equal(step.CoverageFormatJacoco))
+		Expect(summary.LineCoverage).To(Equal(75.0))
+		Expect(summary.BranchCoverage).To(Equal(25.0))
+	})
+
+	It("should check gates", func() {
+		summary := &step.CoverageSummary{LineCoverage: 70}
+		Expect((&step.StepCoverageReportSpec{MinLineCoverage: 80}).CheckGates(summary)).Should(HaveOccurred())
+		Expect((&step.StepCoverageReportSpec{MinLineCoverage: 60}).CheckGates(summary)).ShouldNot(HaveOccurred())
+		spec := &step.StepCoverageReportSpec{MaxRegression: 5, BaseBranch: "main", BaseCoverage: &step.CoverageSummary{LineCoverage: 76}}
+		Expect(spec.CheckGates(summary)).Should(HaveOccurred())
+		spec.BaseCoverage.LineCoverage = 74
+		Expect(spec.CheckGates(summary)).ShouldNot(HaveOccurred())
+	})
+})
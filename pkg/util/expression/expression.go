@@ -0,0 +1,382 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package expression implements the small boolean language used by workflow conditions, e.g.
+//
+//	trigger.branch == "main" && !contains(workflow.params.skip, "deploy")
+//
+// All values are strings, variables are dotted names resolved from a flat map, comparisons
+// are numeric when both sides are numbers, and a value is true unless it is "", "false" or "0".
+package expression
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	trueValue  = "true"
+	falseValue = "false"
+)
+
+type Expression struct {
+	raw  string
+	root node
+}
+
+// Parse parses raw into an expression, the syntax and function arguments are checked here
+// so a parsed expression can only fail on evaluation because of an invalid regular expression.
+func Parse(raw string) (*Expression, error) {
+	tokens, err := tokenize(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", raw, err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected %s at position %d", p.peek(), p.peek().pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", raw, err)
+	}
+	return &Expression{raw: raw, root: root}, nil
+}
+
+func (e *Expression) String() string {
+	return e.raw
+}
+
+// Variables returns the sorted and deduplicated variable names referenced by the expression.
+func (e *Expression) Variables() []string {
+	set := map[string]struct{}{}
+	e.root.variables(set)
+	resp := make([]string, 0, len(set))
+	for name := range set {
+		resp = append(resp, name)
+	}
+	sort.Strings(resp)
+	return resp
+}
+
+// Evaluate evaluates the expression against vars, variables missing in vars are empty strings.
+func (e *Expression) Evaluate(vars map[string]string) (bool, error) {
+	value, err := e.root.eval(vars)
+	if err != nil {
+		return false, fmt.Errorf("evaluate expression %q error: %v", e.raw, err)
+	}
+	return truthy(value), nil
+}
+
+func truthy(value string) bool {
+	return value != "" && value != falseValue && value != "0"
+}
+
+func boolValue(b bool) string {
+	if b {
+		return trueValue
+	}
+	return falseValue
+}
+
+type node interface {
+	eval(vars map[string]string) (string, error)
+	variables(set map[string]struct{})
+}
+
+type literalNode struct {
+	value string
+}
+
+func (n *literalNode) eval(map[string]string) (string, error) { return n.value, nil }
+func (n *literalNode) variables(map[string]struct{})          {}
+
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) eval(vars map[string]string) (string, error) { return vars[n.name], nil }
+func (n *variableNode) variables(set map[string]struct{})           { set[n.name] = struct{}{} }
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(vars map[string]string) (string, error) {
+	value, err := n.operand.eval(vars)
+	if err != nil {
+		return "", err
+	}
+	return boolValue(!truthy(value)), nil
+}
+
+func (n *notNode) variables(set map[string]struct{}) { n.operand.variables(set) }
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) eval(vars map[string]string) (string, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return "", err
+	}
+	// && and || are short-circuited.
+	switch n.op {
+	case "&&":
+		if !truthy(left) {
+			return falseValue, nil
+		}
+	case "||":
+		if truthy(left) {
+			return trueValue, nil
+		}
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return "", err
+	}
+	switch n.op {
+	case "&&", "||":
+		return boolValue(truthy(right)), nil
+	default:
+		return boolValue(compare(n.op, left, right)), nil
+	}
+}
+
+func (n *binaryNode) variables(set map[string]struct{}) {
+	n.left.variables(set)
+	n.right.variables(set)
+}
+
+func compare(op, left, right string) bool {
+	result := strings.Compare(left, right)
+	leftNumber, leftErr := strconv.ParseFloat(left, 64)
+	rightNumber, rightErr := strconv.ParseFloat(right, 64)
+	if leftErr == nil && rightErr == nil {
+		switch {
+		case leftNumber < rightNumber:
+			result = -1
+		case leftNumber > rightNumber:
+			result = 1
+		default:
+			result = 0
+		}
+	}
+	switch op {
+	case "==":
+		return result == 0
+	case "!=":
+		return result != 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	default:
+		return result >= 0
+	}
+}
+
+type function struct {
+	args int
+	call func(args []string) (string, error)
+}
+
+var functions = map[string]function{
+	"contains": {args: 2, call: func(args []string) (string, error) {
+		return boolValue(strings.Contains(args[0], args[1])), nil
+	}},
+	"startsWith": {args: 2, call: func(args []string) (string, error) {
+		return boolValue(strings.HasPrefix(args[0], args[1])), nil
+	}},
+	"endsWith": {args: 2, call: func(args []string) (string, error) {
+		return boolValue(strings.HasSuffix(args[0], args[1])), nil
+	}},
+	"matches": {args: 2, call: func(args []string) (string, error) {
+		re, err := regexp.Compile(args[1])
+		if err != nil {
+			return "", fmt.Errorf("invalid regular expression %q: %v", args[1], err)
+		}
+		return boolValue(re.MatchString(args[0])), nil
+	}},
+	"empty": {args: 1, call: func(args []string) (string, error) {
+		return boolValue(args[0] == ""), nil
+	}},
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n *callNode) eval(vars map[string]string) (string, error) {
+	args := make([]string, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(vars)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, value)
+	}
+	return functions[n.name].call(args)
+}
+
+func (n *callNode) variables(set map[string]struct{}) {
+	for _, arg := range n.args {
+		arg.variables(set)
+	}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	t := p.next()
+	if t.kind != kind || (text != "" && t.text != text) {
+		return fmt.Errorf("expected %q but got %s at position %d", text, t, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is(tokenOperator, "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is(tokenOperator, "&&") {
+		p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokenOperator && t.text != "&&" && t.text != "||" && t.text != "!" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{op: t.text, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().is(tokenOperator, "!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString, tokenNumber:
+		return &literalNode{value: t.text}, nil
+	case tokenIdent:
+		if t.text == trueValue || t.text == falseValue {
+			return &literalNode{value: t.text}, nil
+		}
+		if p.peek().is(tokenPunct, "(") {
+			return p.parseCall(t)
+		}
+		return &variableNode{name: t.text}, nil
+	case tokenPunct:
+		if t.text == "(" {
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(tokenPunct, ")")
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at position %d", name.text, name.pos)
+	}
+	p.next()
+	call := &callNode{name: name.text}
+	for !p.peek().is(tokenPunct, ")") {
+		if len(call.args) > 0 {
+			if err := p.expect(tokenPunct, ","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	p.next()
+	if len(call.args) != fn.args {
+		return nil, fmt.Errorf("function %s expects %d arguments but got %d", name.text, fn.args, len(call.args))
+	}
+	return call, nil
+}
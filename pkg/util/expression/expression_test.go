@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package expression_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/util/expression"
+)
+
+type evaluateParams struct {
+	expression string
+	expected   bool
+}
+
+var vars = map[string]string{
+	"trigger.branch":                      "main",
+	"trigger.is_pr":                       "false",
+	"workflow.params.replicas":            "10",
+	"workflow.params.message":             `say "hi"`,
+	"job.build.svc-a.svc-a.output.IMAGE":  "koderover/svc-a:20221010",
+	"job.build.svc-a.svc-a.output.CHANGE": "",
+}
+
+var _ = Describe("Testing expression", func() {
+
+	DescribeTable("Testing Evaluate",
+		func(p evaluateParams) {
+			expr, err := expression.Parse(p.expression)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			res, err := expr.Evaluate(vars)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res).To(Equal(p.expected))
+		},
+		Entry("equal", evaluateParams{expression: `trigger.branch == "main"`, expected: true}),
+		Entry("not equal", evaluateParams{expression: `trigger.branch != 'main'`, expected: false}),
+		Entry("false string", evaluateParams{expression: `trigger.is_pr`, expected: false}),
+		Entry("negation", evaluateParams{expression: `!trigger.is_pr && trigger.branch == "main"`, expected: true}),
+		Entry("numeric comparison", evaluateParams{expression: `workflow.params.replicas > 9`, expected: true}),
+		Entry("escaped quotes", evaluateParams{expression: `workflow.params.message == "say \"hi\""`, expected: true}),
+		Entry("precedence", evaluateParams{expression: `false && false || true`, expected: true}),
+		Entry("parentheses", evaluateParams{expression: `false && (false || true)`, expected: false}),
+		Entry("function", evaluateParams{expression: `startsWith(job.build.svc-a.svc-a.output.IMAGE, "koderover/")`, expected: true}),
+		Entry("regular expression", evaluateParams{expression: `matches(trigger.branch, "^(main|release-.*)$")`, expected: true}),
+		Entry("empty output", evaluateParams{expression: `job.build.svc-a.svc-a.output.CHANGE`, expected: false}),
+		Entry("undefined variable", evaluateParams{expression: `empty(trigger.tag)`, expected: true}),
+	)
+
+	DescribeTable("Testing Parse errors",
+		func(raw string) {
+			_, err := expression.Parse(raw)
+			Expect(err).Should(HaveOccurred())
+		},
+		Entry("unterminated string", `trigger.branch == "main`),
+		Entry("dangling operator", `trigger.branch ==`),
+		Entry("unbalanced parentheses", `(trigger.branch == "main"`),
+		Entry("unknown function", `lower(trigger.branch) == "main"`),
+		Entry("wrong argument count", `contains(trigger.branch)`),
+		Entry("invalid character", `trigger.branch = "main"`),
+	)
+
+	It("Testing Variables", func() {
+		expr, err := expression.Parse(`trigger.branch == "main" && (contains(workflow.params.message, "hi") || trigger.branch == "dev")`)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(expr.Variables()).To(Equal([]string{"trigger.branch", "workflow.params.message"}))
+	})
+})
@@ -0,0 +1,209 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package redact masks secrets in logs and outputs.
+//
+// Besides the secrets themselves, their base64 and url encoded variants, their escaped forms, and every line of
+// multi-line secrets like ssh keys and kubeconfigs are masked. A Writer masks a stream, in which a secret may
+// be split across writes.
+package redact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const Mask = "********"
+
+// minLineLength is the min length of the lines of multi-line secrets to be masked alone, shorter lines like
+// the blank ones are too common in logs.
+const minLineLength = 8
+
+type Redactor struct {
+	mu sync.RWMutex
+	// patterns are indexed by their first bytes, the longer ones come first.
+	patterns map[byte][]string
+	known    map[string]bool
+	maxLen   int
+}
+
+func New(secrets ...string) *Redactor {
+	r := &Redactor{
+		patterns: make(map[byte][]string),
+		known:    make(map[string]bool),
+	}
+	r.Add(secrets...)
+	return r
+}
+
+// Add adds secrets to be masked.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, secret := range secrets {
+		for _, pattern := range variants(secret) {
+			if r.known[pattern] {
+				continue
+			}
+			r.known[pattern] = true
+			r.patterns[pattern[0]] = append(r.patterns[pattern[0]], pattern)
+			sort.SliceStable(r.patterns[pattern[0]], func(i, j int) bool {
+				return len(r.patterns[pattern[0]][i]) > len(r.patterns[pattern[0]][j])
+			})
+			if len(pattern) > r.maxLen {
+				r.maxLen = len(pattern)
+			}
+		}
+	}
+}
+
+func variants(secret string) []string {
+	resp := []string{}
+	for _, value := range []string{secret, strings.TrimSpace(secret)} {
+		if value == "" {
+			continue
+		}
+		resp = append(resp,
+			value,
+			base64.StdEncoding.EncodeToString([]byte(value)),
+			base64.RawStdEncoding.EncodeToString([]byte(value)),
+			base64.URLEncoding.EncodeToString([]byte(value)),
+			base64.RawURLEncoding.EncodeToString([]byte(value)),
+			url.QueryEscape(value),
+			url.PathEscape(value),
+		)
+		if !strings.Contains(value, "\n") {
+			continue
+		}
+		resp = append(resp,
+			strings.ReplaceAll(value, "\n", `\n`),
+			strings.ReplaceAll(strings.ReplaceAll(value, "\r", `\r`), "\n", `\n`),
+		)
+		for _, line := range strings.Split(value, "\n") {
+			if line = strings.TrimSpace(line); len(line) >= minLineLength {
+				resp = append(resp, line)
+			}
+		}
+	}
+	return resp
+}
+
+// Redact masks all secrets in s.
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.known) == 0 {
+		return s
+	}
+	out, _ := r.scan([]byte(s), len(s))
+	return string(out)
+}
+
+// scan masks the secrets starting before end in data, returns the masked data and the length of data consumed,
+// which may be larger than end if a secret starts before end.
+func (r *Redactor) scan(data []byte, end int) ([]byte, int) {
+	out := bytes.Buffer{}
+	i := 0
+	for i < end {
+		if n := r.match(data[i:]); n > 0 {
+			out.WriteString(Mask)
+			i += n
+			continue
+		}
+		out.WriteByte(data[i])
+		i++
+	}
+	return out.Bytes(), i
+}
+
+func (r *Redactor) match(data []byte) int {
+	for _, pattern := range r.patterns[data[0]] {
+		if len(pattern) <= len(data) && string(data[:len(pattern)]) == pattern {
+			return len(pattern)
+		}
+	}
+	return 0
+}
+
+// Writer masks the secrets written to it before writing to the underlying writer, the data which may be the
+// beginning of a secret is held until more data comes or it is flushed.
+type Writer struct {
+	mu       sync.Mutex
+	redactor *Redactor
+	w        io.Writer
+	pending  []byte
+}
+
+func (r *Redactor) NewWriter(w io.Writer) *Writer {
+	return &Writer{redactor: r, w: w}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, p...)
+
+	w.redactor.mu.RLock()
+	// a secret starting before end is always complete in pending, and only the whole of multi-line
+	// secrets may cross a line break, whose lines are masked alone, so a complete line is never held.
+	end := len(w.pending) - w.redactor.maxLen + 1
+	if i := bytes.LastIndexByte(w.pending, '\n'); i+1 > end {
+		end = i + 1
+	}
+	if end <= 0 {
+		w.redactor.mu.RUnlock()
+		return len(p), nil
+	}
+	out, n := w.redactor.scan(w.pending, end)
+	w.redactor.mu.RUnlock()
+
+	w.pending = append(w.pending[:0], w.pending[n:]...)
+	if _, err := w.w.Write(out); err != nil {
+		return len(p), err
+	}
+	return len(p), nil
+}
+
+// Flush masks and writes all the data held.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) == 0 {
+		return nil
+	}
+	w.redactor.mu.RLock()
+	out, _ := w.redactor.scan(w.pending, len(w.pending))
+	w.redactor.mu.RUnlock()
+	w.pending = w.pending[:0]
+	_, err := w.w.Write(out)
+	return err
+}
+
+// EnvValues returns the values of envs like KEY=VALUE, values may contain =.
+func EnvValues(envs []string) []string {
+	resp := []string{}
+	for _, env := range envs {
+		if _, value, ok := strings.Cut(env, "="); ok && value != "" {
+			resp = append(resp, value)
+		}
+	}
+	return resp
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package signature verifies the cosign signatures of images, the signatures are stored in the registry
+// as the layers of the `sha256-<hex>.sig` tag in the repository of the image.
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/regclient/regclient"
+	"github.com/regclient/regclient/types/manifest"
+	"github.com/regclient/regclient/types/ref"
+)
+
+const signatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// payload is the simple signing payload signed by cosign.
+type payload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// PublicKey returns the public key of a PEM encoded private key, PKCS#8, PKCS#1 and EC keys are supported.
+func PublicKey(privateKeyPEM []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("invalid PEM private key")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch k := key.(type) {
+		case *ecdsa.PrivateKey:
+			return k.Public(), nil
+		case *rsa.PrivateKey:
+			return k.Public(), nil
+		case ed25519.PrivateKey:
+			return k.Public(), nil
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key.Public(), nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key.Public(), nil
+	}
+	return nil, errors.New("unsupported private key, only PKCS#8, PKCS#1 and EC keys are supported")
+}
+
+// MarshalPublicKey encodes the public key to PEM.
+func MarshalPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePublicKey decodes the PEM encoded public key.
+func ParsePublicKey(publicKeyPEM []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return nil, errors.New("invalid PEM public key")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// SignatureTag returns the tag of the signatures of the manifest digest.
+func SignatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
+
+// VerifyPayload verifies the base64 encoded signature of the payload, and that the payload is signed for digest.
+func VerifyPayload(data []byte, signature, digest string, pub crypto.PublicKey) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %s", err)
+	}
+	hash := sha256.Sum256(data)
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, hash[:], sig) {
+			return errors.New("signature mismatch")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig); err != nil {
+			return errors.New("signature mismatch")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, sig) {
+			return errors.New("signature mismatch")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	p := &payload{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return fmt.Errorf("invalid signature payload: %s", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for %s, not %s", p.Critical.Image.DockerManifestDigest, digest)
+	}
+	return nil
+}
+
+// Verify verifies that the image is signed by the private key of pub, the image is resolved to its digest first.
+// It returns the image pinned to the verified digest, which must be deployed instead of the mutable tag.
+func Verify(ctx context.Context, client *regclient.RegClient, image string, pub crypto.PublicKey) (string, error) {
+	imageRef, err := ref.New(image)
+	if err != nil {
+		return "", fmt.Errorf("parse image %s error: %s", image, err)
+	}
+	digest := imageRef.Digest
+	if digest == "" {
+		m, err := client.ManifestHead(ctx, imageRef)
+		if err != nil {
+			return "", fmt.Errorf("get manifest of image %s error: %s", image, err)
+		}
+		digest = m.GetDescriptor().Digest.String()
+	}
+	if err := verifyDigest(ctx, client, imageRef, image, digest, pub); err != nil {
+		return "", err
+	}
+	return PinDigest(image, digest), nil
+}
+
+// PinDigest replaces the digest of the image with digest, the tag is kept for readability and for the charts which
+// set the repository and the tag separately, the runtimes pull the image by the digest when both are set.
+func PinDigest(image, digest string) string {
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	return image + "@" + digest
+}
+
+func verifyDigest(ctx context.Context, client *regclient.RegClient, imageRef ref.Ref, image, digest string, pub crypto.PublicKey) error {
+
+	sigRef := imageRef
+	sigRef.Digest = ""
+	sigRef.Tag = SignatureTag(digest)
+	m, err := client.ManifestGet(ctx, sigRef)
+	if err != nil {
+		return fmt.Errorf("image %s is not signed", image)
+	}
+	imager, ok := m.(manifest.Imager)
+	if !ok {
+		return fmt.Errorf("invalid signature manifest of image %s", image)
+	}
+	layers, err := imager.GetLayers()
+	if err != nil {
+		return fmt.Errorf("invalid signature manifest of image %s: %s", image, err)
+	}
+	for _, layer := range layers {
+		signature, ok := layer.Annotations[signatureAnnotation]
+		if !ok {
+			continue
+		}
+		reader, err := client.BlobGet(ctx, sigRef, layer)
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			continue
+		}
+		if err := VerifyPayload(data, signature, digest, pub); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("no valid signature of image %s is found", image)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signature_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/util/signature"
+)
+
+const digest = "sha256:6c3c624b58dbbcd3c0dd82b4c53f04194d1247c6eebdaab7c610cf7d66709b3b"
+
+const payload = `{"critical":{"identity":{"docker-reference":"koderover.tencentcloudcr.com/test/app"},` +
+	`"image":{"docker-manifest-digest":"` + digest + `"},"type":"cosign container image signature"},"optional":null}`
+
+var _ = Describe("Testing signature", func() {
+
+	It("should get the public key of PKCS#8 and EC keys", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		Expect(err).NotTo(HaveOccurred())
+		pub, err := signature.PublicKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pub).To(Equal(key.Public()))
+
+		der, err = x509.MarshalECPrivateKey(key)
+		Expect(err).NotTo(HaveOccurred())
+		pub, err = signature.PublicKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pub).To(Equal(key.Public()))
+
+		encoded, err := signature.MarshalPublicKey(pub)
+		Expect(err).NotTo(HaveOccurred())
+		parsed, err := signature.ParsePublicKey(encoded)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(Equal(key.Public()))
+
+		_, err = signature.PublicKey([]byte("not a key"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should verify ecdsa and rsa signatures", func() {
+		hash := sha256.Sum256([]byte(payload))
+
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		sig, err := ecdsa.SignASN1(rand.Reader, ecKey, hash[:])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signature.VerifyPayload([]byte(payload), base64.StdEncoding.EncodeToString(sig), digest, ecKey.Public())).To(Succeed())
+
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		sig, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hash[:])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signature.VerifyPayload([]byte(payload), base64.StdEncoding.EncodeToString(sig), digest, rsaKey.Public())).To(Succeed())
+	})
+
+	It("should reject signatures of other keys or digests", func() {
+		hash := sha256.Sum256([]byte(payload))
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+		Expect(err).NotTo(HaveOccurred())
+		encoded := base64.StdEncoding.EncodeToString(sig)
+
+		Expect(signature.VerifyPayload([]byte(payload), encoded, digest, other.Public())).NotTo(Succeed())
+		Expect(signature.VerifyPayload([]byte(payload), encoded, "sha256:0000", key.Public())).NotTo(Succeed())
+	})
+
+	It("should get the signature tag", func() {
+		Expect(signature.SignatureTag(digest)).To(Equal("sha256-6c3c624b58dbbcd3c0dd82b4c53f04194d1247c6eebdaab7c610cf7d66709b3b.sig"))
+	})
+
+	It("should pin images to the digest", func() {
+		Expect(signature.PinDigest("koderover.tencentcloudcr.com/test/app:20221010-1", digest)).To(Equal("koderover.tencentcloudcr.com/test/app:20221010-1@" + digest))
+		Expect(signature.PinDigest("localhost:5000/app", digest)).To(Equal("localhost:5000/app@" + digest))
+		Expect(signature.PinDigest("app:v1@sha256:0000", digest)).To(Equal("app:v1@" + digest))
+	})
+})
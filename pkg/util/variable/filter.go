@@ -0,0 +1,162 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package variable
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	errUndefined = errors.New("undefined variable")
+
+	nameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.\-]*$`)
+)
+
+type filter struct {
+	args  int
+	apply func(value string, args []string) string
+}
+
+var filters = map[string]filter{
+	"default": {args: 1, apply: func(value string, args []string) string {
+		if value == "" {
+			return args[0]
+		}
+		return value
+	}},
+	"upper": {apply: func(value string, _ []string) string { return strings.ToUpper(value) }},
+	"lower": {apply: func(value string, _ []string) string { return strings.ToLower(value) }},
+	"trim":  {apply: func(value string, _ []string) string { return strings.TrimSpace(value) }},
+	"replace": {args: 2, apply: func(value string, args []string) string {
+		return strings.ReplaceAll(value, args[0], args[1])
+	}},
+	// quote quotes the value with double quotes and escapes special characters in it.
+	"quote": {apply: func(value string, _ []string) string { return strconv.Quote(value) }},
+	// squote quotes the value with single quotes for shell scripts.
+	"squote": {apply: func(value string, _ []string) string {
+		return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+	}},
+	"base64": {apply: func(value string, _ []string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	}},
+}
+
+type filterCall struct {
+	name string
+	args []string
+}
+
+type reference struct {
+	name    string
+	filters []*filterCall
+}
+
+// parseReference parses the content between {{ and }}, returns false if it is not a variable reference.
+func parseReference(content string) (*reference, bool) {
+	parts, err := splitPipeline(content)
+	if err != nil || len(parts) == 0 || len(parts[0]) != 1 {
+		return nil, false
+	}
+	name := parts[0][0]
+	if !strings.HasPrefix(name, ".") || !nameRegex.MatchString(name[1:]) {
+		return nil, false
+	}
+	ref := &reference{name: name[1:]}
+	for _, part := range parts[1:] {
+		if len(part) == 0 {
+			return nil, false
+		}
+		ref.filters = append(ref.filters, &filterCall{name: part[0], args: part[1:]})
+	}
+	return ref, true
+}
+
+func (ref *reference) resolve(values map[string]string) (string, error) {
+	value, ok := values[ref.name]
+	hasDefault := false
+	for _, call := range ref.filters {
+		f, found := filters[call.name]
+		if !found {
+			return "", fmt.Errorf("unknown filter %s", call.name)
+		}
+		if len(call.args) != f.args {
+			return "", fmt.Errorf("filter %s expects %d arguments but got %d", call.name, f.args, len(call.args))
+		}
+		if call.name == "default" {
+			hasDefault = true
+		}
+	}
+	if !ok && !hasDefault {
+		return "", errUndefined
+	}
+	for _, call := range ref.filters {
+		value = filters[call.name].apply(value, call.args)
+	}
+	return value, nil
+}
+
+// splitPipeline splits `.a | f "x y" z` into [[.a] [f x y z]], quoted words may contain spaces and pipes.
+func splitPipeline(content string) ([][]string, error) {
+	resp := [][]string{}
+	words := []string{}
+	word := strings.Builder{}
+	inWord := false
+	runes := []rune(content)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '"':
+			end := i + 1
+			for ; end < len(runes) && runes[end] != '"'; end++ {
+				if runes[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(runes) {
+				return nil, errors.New("unterminated string")
+			}
+			unquoted, err := strconv.Unquote(string(runes[i : end+1]))
+			if err != nil {
+				return nil, err
+			}
+			word.WriteString(unquoted)
+			inWord = true
+			i = end
+		case c == '|' || c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			if c == '|' {
+				resp = append(resp, words)
+				words = []string{}
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return append(resp, words), nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package variable renders the {{.name}} references of workflow variables.
+//
+// A reference may be followed by filters, e.g. {{.workflow.params.tag | default "latest" | lower}}.
+// The first segment of a name is its scope, references in unknown scopes are kept as they are,
+// so that other kinds of templates like helm values are not touched, references in a deferred
+// scope are kept to be rendered later, and references to undefined variables in other registered
+// scopes are reported as errors.
+package variable
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var referenceRegex = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+type scopeMode int
+
+const (
+	scopeStrict scopeMode = iota + 1
+	scopeDeferred
+)
+
+type Renderer struct {
+	values map[string]string
+	scopes map[string]scopeMode
+}
+
+func NewRenderer() *Renderer {
+	return &Renderer{
+		values: make(map[string]string),
+		scopes: make(map[string]scopeMode),
+	}
+}
+
+// Set sets the value of a variable, the scope of the variable is registered if it is not.
+func (r *Renderer) Set(name, value string) *Renderer {
+	r.values[name] = value
+	if _, ok := r.scopes[scopeOf(name)]; !ok {
+		r.scopes[scopeOf(name)] = scopeStrict
+	}
+	return r
+}
+
+// Strict registers scopes in which every reference must be set, even if no variable is set in them.
+func (r *Renderer) Strict(scopes ...string) *Renderer {
+	for _, scope := range scopes {
+		r.scopes[scope] = scopeStrict
+	}
+	return r
+}
+
+// Defer registers scopes whose references are kept as they are, to be rendered later.
+func (r *Renderer) Defer(scopes ...string) *Renderer {
+	for _, scope := range scopes {
+		r.scopes[scope] = scopeDeferred
+	}
+	return r
+}
+
+// RenderString renders all references in s.
+func (r *Renderer) RenderString(s string) (string, error) {
+	undefined := map[string]struct{}{}
+	resp, err := r.renderString(s, undefined)
+	if err != nil {
+		return "", err
+	}
+	return resp, undefinedError(undefined)
+}
+
+// Render renders all string values in obj, which should be a pointer to a struct, map or slice.
+// obj is walked after being converted to json, so the rendered values never break its structure.
+func (r *Renderer) Render(obj interface{}) error {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("marshal object error: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	// keep numbers as they are, large integers lose precision as float64.
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return fmt.Errorf("decode object error: %v", err)
+	}
+
+	undefined := map[string]struct{}{}
+	tree, err = r.walk(tree, undefined)
+	if err != nil {
+		return err
+	}
+	if err := undefinedError(undefined); err != nil {
+		return err
+	}
+
+	b, err = json.Marshal(tree)
+	if err != nil {
+		return fmt.Errorf("marshal rendered object error: %v", err)
+	}
+	return json.Unmarshal(b, obj)
+}
+
+func (r *Renderer) walk(node interface{}, undefined map[string]struct{}) (interface{}, error) {
+	switch v := node.(type) {
+	case string:
+		return r.renderString(v, undefined)
+	case map[string]interface{}:
+		for key, value := range v {
+			rendered, err := r.walk(value, undefined)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = rendered
+		}
+	case []interface{}:
+		for i, value := range v {
+			rendered, err := r.walk(value, undefined)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rendered
+		}
+	}
+	return node, nil
+}
+
+func (r *Renderer) renderString(s string, undefined map[string]struct{}) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var renderErr error
+	resp := referenceRegex.ReplaceAllStringFunc(s, func(raw string) string {
+		if renderErr != nil {
+			return raw
+		}
+		ref, ok := parseReference(referenceRegex.FindStringSubmatch(raw)[1])
+		if !ok || r.scopes[scopeOf(ref.name)] != scopeStrict {
+			return raw
+		}
+		value, err := ref.resolve(r.values)
+		if err == errUndefined {
+			undefined[ref.name] = struct{}{}
+			return raw
+		}
+		if err != nil {
+			renderErr = fmt.Errorf("render %s error: %v", raw, err)
+			return raw
+		}
+		return value
+	})
+	return resp, renderErr
+}
+
+func scopeOf(name string) string {
+	return strings.SplitN(name, ".", 2)[0]
+}
+
+func undefinedError(undefined map[string]struct{}) error {
+	if len(undefined) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(undefined))
+	for name := range undefined {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("undefined variables: %s", strings.Join(names, ", "))
+}
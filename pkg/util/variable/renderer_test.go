@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package variable_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/util/variable"
+)
+
+type renderParams struct {
+	input    string
+	expected string
+}
+
+func newRenderer() *variable.Renderer {
+	return variable.NewRenderer().
+		Set("project", "demo").
+		Set("workflow.params.message", "say \"hi\"\nbye").
+		Set("workflow.params.tag", "").
+		Set("workflow.params.tag-long", "v1.0.0").
+		Strict("env").
+		Defer("jobs")
+}
+
+var _ = Describe("Testing renderer", func() {
+
+	DescribeTable("Testing RenderString",
+		func(p renderParams) {
+			res, err := newRenderer().RenderString(p.input)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(res).To(Equal(p.expected))
+		},
+		Entry("plain variable", renderParams{input: "{{.project}}-app", expected: "demo-app"}),
+		Entry("spaces", renderParams{input: "{{ .project }}", expected: "demo"}),
+		Entry("key is a prefix of another key", renderParams{input: "{{.workflow.params.tag-long}}", expected: "v1.0.0"}),
+		Entry("default", renderParams{input: `{{.workflow.params.tag | default "latest"}}`, expected: "latest"}),
+		Entry("default of undefined variable", renderParams{input: `{{.env.REGION | default "cn" | upper}}`, expected: "CN"}),
+		Entry("quote", renderParams{input: `{{.workflow.params.message | quote}}`, expected: `"say \"hi\"\nbye"`}),
+		Entry("deferred scope", renderParams{input: "{{.jobs.build.outputs.IMAGE}}", expected: "{{.jobs.build.outputs.IMAGE}}"}),
+		Entry("unknown scope", renderParams{input: "{{ .Values.image }}", expected: "{{ .Values.image }}"}),
+	)
+
+	It("should report undefined variables", func() {
+		_, err := newRenderer().RenderString("{{.workflow.params.missing}} {{.env.REGION}}")
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).To(Equal("undefined variables: env.REGION, workflow.params.missing"))
+	})
+
+	It("should report unknown filters", func() {
+		_, err := newRenderer().RenderString("{{.project | title}}")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should keep the structure of objects", func() {
+		obj := &struct {
+			Script string            `json:"script"`
+			Envs   map[string]string `json:"envs"`
+			Count  int64             `json:"count"`
+		}{
+			Script: `echo "{{.workflow.params.message}}"`,
+			Envs:   map[string]string{"PROJECT": "{{.project}}"},
+			Count:  1665000000000000001,
+		}
+		Expect(newRenderer().Render(obj)).ShouldNot(HaveOccurred())
+		Expect(obj.Script).To(Equal("echo \"say \"hi\"\nbye\""))
+		Expect(obj.Envs["PROJECT"]).To(Equal("demo"))
+		Expect(obj.Count).To(Equal(int64(1665000000000000001)))
+	})
+})